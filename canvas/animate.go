@@ -0,0 +1,263 @@
+package canvas
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+	"time"
+)
+
+// An Easing maps the fraction of the time of a tween that has
+// passed, from 0 to 1, to the fraction of the change that should
+// have been made by then, so that changes can start slowly or
+// overshoot rather than moving at a constant rate.
+//
+type Easing func(t float64) float64
+
+var (
+	// Linear makes the change at a constant rate.
+	Linear Easing = func(t float64) float64 { return t }
+
+	// EaseIn starts slowly and speeds up.
+	EaseIn Easing = func(t float64) float64 { return t * t * t }
+
+	// EaseOut starts quickly and slows down.
+	EaseOut Easing = func(t float64) float64 {
+		t = 1 - t
+		return 1 - t*t*t
+	}
+
+	// EaseInOut starts and ends slowly.
+	EaseInOut Easing = func(t float64) float64 {
+		return (1 - math.Cos(t*math.Pi)) / 2
+	}
+
+	// Bounce falls to the end and bounces on it,
+	// like a dropped ball.
+	Bounce Easing = bounce
+)
+
+func bounce(t float64) float64 {
+	const n, d = 7.5625, 2.75
+	switch {
+	case t < 1/d:
+		return n * t * t
+	case t < 2/d:
+		t -= 1.5 / d
+		return n*t*t + 0.75
+	case t < 2.5/d:
+		t -= 2.25 / d
+		return n*t*t + 0.9375
+	}
+	t -= 2.625 / d
+	return n*t*t + 0.984375
+}
+
+// A Tween describes a change made gradually over a time.
+// Each frame of the change, Step is called with the eased
+// fraction of the change that should have been made by
+// then; the last call is made with 1. Step may be called
+// with values outside 0 to 1 by an easing that overshoots.
+//
+type Tween struct {
+	Duration time.Duration
+	Ease     Easing // if nil, Linear is used.
+	Step     func(v float64)
+}
+
+// An Animator runs tweens, stepping all of them at each frame from
+// a single goroutine and then flushing the canvas once, so that
+// any number of moving items cause only one redraw a frame.
+// Each step makes its changes with the usual methods of the items,
+// so it must not be run from within Atomically.
+//
+type Animator struct {
+	c        *Canvas
+	interval time.Duration
+	mu       sync.Mutex
+	running  []*Animation
+	ticking  bool
+}
+
+// An Animation is a tween being run by an Animator.
+//
+type Animation struct {
+	a       *Animator
+	tween   Tween
+	start   time.Time
+	done    func()
+	stopped bool
+}
+
+// NewAnimator returns an Animator for items in c that steps
+// its tweens every interval, or 60 times a second if interval
+// is zero. If c is shown by a Background with a limited frame
+// rate (see SetFrameRate), the two are best made the same.
+//
+func NewAnimator(c *Canvas, interval time.Duration) *Animator {
+	if interval <= 0 {
+		interval = time.Second / 60
+	}
+	return &Animator{c: c, interval: interval}
+}
+
+// Start starts running t, and returns the running animation.
+// If done is not nil, it is called, from the animator's goroutine,
+// when the animation finishes, but not if it is stopped.
+//
+func (a *Animator) Start(t Tween, done func()) *Animation {
+	if t.Ease == nil {
+		t.Ease = Linear
+	}
+	anim := &Animation{a: a, tween: t, start: time.Now(), done: done}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.running = append(a.running, anim)
+	if !a.ticking {
+		a.ticking = true
+		go a.tick()
+	}
+	return anim
+}
+
+// Stop stops the animation where it is, leaving
+// the change it was making partly made.
+//
+func (anim *Animation) Stop() {
+	anim.a.mu.Lock()
+	anim.stopped = true
+	anim.a.mu.Unlock()
+}
+
+// StopAll stops all the animations that a is running.
+//
+func (a *Animator) StopAll() {
+	a.mu.Lock()
+	for _, anim := range a.running {
+		anim.stopped = true
+	}
+	a.mu.Unlock()
+}
+
+// tick steps the running animations every interval
+// until there are none left.
+func (a *Animator) tick() {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		a.mu.Lock()
+		running := make([]*Animation, 0, len(a.running))
+		for _, anim := range a.running {
+			if !anim.stopped {
+				running = append(running, anim)
+			}
+		}
+		a.running = running
+		if len(running) == 0 {
+			a.ticking = false
+			a.mu.Unlock()
+			return
+		}
+		a.mu.Unlock()
+
+		var finished []*Animation
+		for _, anim := range running {
+			if anim.step(now) {
+				finished = append(finished, anim)
+			}
+		}
+		a.c.Flush()
+		for _, anim := range finished {
+			a.mu.Lock()
+			anim.stopped = true
+			a.mu.Unlock()
+			if anim.done != nil {
+				anim.done()
+			}
+		}
+	}
+}
+
+// step makes the change due at the time now,
+// and reports whether the animation has finished.
+func (anim *Animation) step(now time.Time) bool {
+	t := anim.tween
+	f := 1.0
+	if t.Duration > 0 {
+		f = float64(now.Sub(anim.start)) / float64(t.Duration)
+	}
+	if f >= 1 {
+		t.Step(1)
+		return true
+	}
+	if f < 0 {
+		f = 0
+	}
+	t.Step(t.Ease(f))
+	return false
+}
+
+// TweenMove returns a tween that moves it by delta,
+// if it can be moved (see MoveItem).
+//
+func TweenMove(it Item, delta image.Point, d time.Duration, ease Easing) Tween {
+	var moved image.Point
+	return Tween{d, ease, func(v float64) {
+		p := lerpPoint(image.ZP, delta, v)
+		MoveItem(it, p.Sub(moved))
+		moved = p
+	}}
+}
+
+// TweenBounds returns a tween that changes the bounding
+// rectangle of it from from to to, moving and resizing it.
+//
+func TweenBounds(it ResizableItem, from, to image.Rectangle, d time.Duration, ease Easing) Tween {
+	return Tween{d, ease, func(v float64) {
+		it.SetBounds(image.Rectangle{
+			lerpPoint(from.Min, to.Min, v),
+			lerpPoint(from.Max, to.Max, v),
+		}.Canon())
+	}}
+}
+
+// TweenFill returns a tween that changes the fill of it
+// from the colour from to the colour to, including their
+// opacity.
+//
+func TweenFill(it Colorer, from, to color.Color, d time.Duration, ease Easing) Tween {
+	c0 := color.NRGBAModel.Convert(from).(color.NRGBA)
+	c1 := color.NRGBAModel.Convert(to).(color.NRGBA)
+	return Tween{d, ease, func(v float64) {
+		it.SetFill(&image.Uniform{color.NRGBA{
+			lerpByte(c0.R, c1.R, v),
+			lerpByte(c0.G, c1.G, v),
+			lerpByte(c0.B, c1.B, v),
+			lerpByte(c0.A, c1.A, v),
+		}})
+	}}
+}
+
+// TweenOpacity returns a tween that fades it, filled with
+// the colour col, from the opacity from to the opacity to,
+// where 0 is transparent and 1 is the opacity of col.
+//
+func TweenOpacity(it Colorer, col color.Color, from, to float64, d time.Duration, ease Easing) Tween {
+	c := color.NRGBAModel.Convert(col).(color.NRGBA)
+	c0, c1 := c, c
+	c0.A = uint8(float64(c.A)*clamp01(from) + 0.5)
+	c1.A = uint8(float64(c.A)*clamp01(to) + 0.5)
+	return TweenFill(it, c0, c1, d, ease)
+}
+
+func lerpPoint(p, q image.Point, v float64) image.Point {
+	return image.Pt(
+		p.X+int(math.Floor(float64(q.X-p.X)*v+0.5)),
+		p.Y+int(math.Floor(float64(q.Y-p.Y)*v+0.5)),
+	)
+}
+
+func lerpByte(a, b uint8, v float64) uint8 {
+	return uint8(clamp01(float64(a)/255+(float64(b)-float64(a))/255*v)*255 + 0.5)
+}
@@ -0,0 +1,95 @@
+package canvas
+
+import (
+	"image"
+	"math"
+)
+
+// An Antialias value gives the quality of anti-aliasing
+// used to draw the edges of shapes such as polygons and lines.
+//
+type Antialias int
+
+const (
+	// AntialiasDefault uses the setting of the
+	// enclosing canvas, or AntialiasFast if there is none.
+	AntialiasDefault Antialias = iota
+
+	// AntialiasNone draws each pixel either fully
+	// or not at all, giving crisp, jagged edges.
+	AntialiasNone
+
+	// AntialiasFast blends the pixels on the edges
+	// of a shape in proportion to how much of each
+	// the shape covers.
+	AntialiasFast
+
+	// AntialiasHigh is like AntialiasFast, but corrects
+	// the blending for the gamma of the display, so that
+	// thin lines and shallow edges look evenly smooth.
+	AntialiasHigh
+)
+
+// SetAntialias sets the anti-aliasing used by the shapes
+// inside c, including those in nested canvases, that have no
+// setting of their own. If a is AntialiasDefault, the setting
+// of the enclosing canvas is used.
+//
+func (c *Canvas) SetAntialias(a Antialias) {
+	c.Atomically(func(flush FlushFunc) {
+		c.antialias = a
+		// the shapes look for their setting in SetContainer.
+		c.setContainers()
+		flush(c.r, nil)
+	})
+	c.Flush()
+}
+
+// AntialiasOf returns the anti-aliasing to be used by
+// a shape inside b: that of the innermost canvas containing
+// b that has a setting, or AntialiasFast if there is none.
+//
+func AntialiasOf(b Backing) Antialias {
+	for {
+		switch c := b.(type) {
+		case *Canvas:
+			if c.antialias != AntialiasDefault {
+				return c.antialias
+			}
+			b = c.backing
+		case nestedBacking:
+			b = c.outer()
+		default:
+			return AntialiasFast
+		}
+	}
+}
+
+// gammaCoverage maps the coverage of a pixel to the
+// alpha used to draw it with AntialiasHigh.
+var gammaCoverage [256]uint8
+
+func init() {
+	for i := range gammaCoverage {
+		gammaCoverage[i] = uint8(math.Pow(float64(i)/255, 1/2.2)*255 + 0.5)
+	}
+}
+
+// apply adjusts the coverage held in mask
+// to give the anti-aliasing a.
+func (a Antialias) apply(mask *image.Alpha) {
+	switch a {
+	case AntialiasNone:
+		for i, v := range mask.Pix {
+			if v >= 0x80 {
+				mask.Pix[i] = 0xff
+			} else {
+				mask.Pix[i] = 0
+			}
+		}
+	case AntialiasHigh:
+		for i, v := range mask.Pix {
+			mask.Pix[i] = gammaCoverage[v]
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package canvas
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// A BackgroundMode gives the way in which the
+// background image of a canvas is drawn.
+//
+type BackgroundMode int
+
+const (
+	// BackgroundPlain draws the image in the coordinates
+	// of the canvas, as is usual for a uniform colour.
+	BackgroundPlain BackgroundMode = iota
+
+	// BackgroundTiled repeats the image across the
+	// canvas, starting at the top left of its bounds.
+	BackgroundTiled
+
+	// BackgroundCentred draws one copy of the image,
+	// centred in the canvas; the area around it is
+	// left transparent.
+	BackgroundCentred
+)
+
+// SetBackground sets the image drawn behind all the items of c,
+// such as a uniform colour made with image.NewUniform, or a
+// texture to be tiled. If bg is nil, c has no background, and
+// whatever is behind c shows through where there are no items.
+//
+func (c *Canvas) SetBackground(bg image.Image, mode BackgroundMode) {
+	c.Atomically(func(flush FlushFunc) {
+		if bg != nil && mode == BackgroundTiled && bg.Bounds().Empty() {
+			bg = nil
+		}
+		c.background = bg
+		c.bgmode = mode
+		c.opaque = bg != nil && mode != BackgroundCentred && opaqueImage(bg)
+		if c.static != nil {
+			c.static.stale = c.r
+		}
+		flush(c.xform().worldRect(c.r), nil)
+	})
+	c.Flush()
+}
+
+// SetBackgroundColor is like SetBackground with
+// a uniform image of the colour col.
+//
+func (c *Canvas) SetBackgroundColor(col color.Color) {
+	c.SetBackground(image.NewUniform(col), BackgroundPlain)
+}
+
+// drawBackground draws the background of c
+// onto the area r of dst, over what is there.
+func (c *Canvas) drawBackground(dst draw.Image, r image.Rectangle) {
+	bg := c.background
+	switch c.bgmode {
+	case BackgroundTiled:
+		b := bg.Bounds()
+		// the tile whose top left corner is at or before r.Min.
+		x0 := b.Min.X + floorDiv(r.Min.X-b.Min.X, b.Dx())*b.Dx()
+		y0 := b.Min.Y + floorDiv(r.Min.Y-b.Min.Y, b.Dy())*b.Dy()
+		for y := y0; y < r.Max.Y; y += b.Dy() {
+			for x := x0; x < r.Max.X; x += b.Dx() {
+				t := b.Add(image.Pt(x, y).Sub(b.Min)).Intersect(r)
+				sp := t.Min.Sub(image.Pt(x, y)).Add(b.Min)
+				drawOver(dst, t, bg, sp, nil, image.ZP)
+			}
+		}
+	case BackgroundCentred:
+		b := bg.Bounds()
+		d := centre(c.r).Sub(centre(b))
+		t := b.Add(d).Intersect(r)
+		if !t.Empty() {
+			drawOver(dst, t, bg, t.Min.Sub(d), nil, image.ZP)
+		}
+	default:
+		drawOver(dst, r, bg, r.Min, nil, image.ZP)
+	}
+}
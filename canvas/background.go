@@ -3,22 +3,36 @@ package canvas
 import (
 	"image"
 	"image/draw"
-	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // A Background is the base layer on which other
 // objects can be layered. It implements the Backing
 // interface and displays a single object only.
 type Background struct {
-	lock     sync.Mutex
+	lock     ownedLock
 	r        image.Rectangle // overall rectangle (always origin 0, 0)
 	img      draw.Image
 	bg       image.Image
 	item     Drawer
 	imgflush func(r image.Rectangle)
+	cb       Clipboard
+	sel      Clipboard  // the primary selection.
+	front    draw.Image // when double buffered, the image shown; img is the back buffer.
+	unshown  damage     // areas of the back buffer not yet copied to front.
 
 	flushrect image.Rectangle
 	waste     int
+
+	interval  time.Duration // the shortest time between frames; zero for no limit.
+	lastFrame time.Time
+	frameDue  *time.Timer // non-nil while a frame is waiting to be shown.
+	waiters   []chan bool // closed when the next frame is shown.
+
+	reentrant int32     // non-zero if Atomically may be called from within itself; accessed atomically.
+	flushing  FlushFunc // the flush function of the current call to Atomically.
+	flushDue  bool      // Flush was called from within the current call to Atomically.
 }
 
 // NewBackground creates a new Background object that
@@ -55,12 +69,146 @@ func (b *Background) SetItem(item Drawer) {
 	b.lock.Unlock()
 }
 
+// SetDoubleBuffered sets whether b is double buffered.
+// When it is, items are drawn into a separate image of
+// the same size, and the areas that have changed are
+// copied from it to the image given to NewBackground only
+// when Flush is called, so that changes made within
+// several calls to Atomically appear together, and a
+// partially drawn item is never seen. The separate image
+// has the same pixel format as the first where possible,
+// so that copying from one to the other is cheap.
+//
+func (b *Background) SetDoubleBuffered(on bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if on == (b.front != nil) {
+		return
+	}
+	if on {
+		b.front = b.img
+		b.img = newImageLike(b.front, b.r)
+	} else {
+		b.img = b.front
+		b.front = nil
+		b.unshown = nil
+	}
+	// items may hold on to the image they were drawn into.
+	b.flushrect = b.r
+	b.waste = 0
+	if b.item != nil {
+		b.item.SetContainer(b)
+	}
+}
+
+// SetReentrant sets whether Atomically may be called
+// from within a call to Atomically on the same goroutine,
+// in which case the inner call simply calls its function,
+// sharing the flush function of the outer call. This is
+// off by default, as it usually hides a mistake, such as
+// an item calling a method of another from within Draw.
+// It should be called before b is used.
+//
+func (b *Background) SetReentrant(on bool) {
+	var r int32
+	if on {
+		r = 1
+	}
+	atomic.StoreInt32(&b.reentrant, r)
+}
+
+// lockID returns the id to take b's lock with: that of the
+// calling goroutine if reentry is to be seen, or 0, as
+// finding it is slow.
+func (b *Background) lockID() int64 {
+	if CheckLocks || atomic.LoadInt32(&b.reentrant) != 0 {
+		return goid()
+	}
+	return 0
+}
+
+// SetImage replaces the image that b draws to with img,
+// usually because the window that it is shown in has
+// changed size. If the item in b can be resized (see
+// Resizer), as a Canvas can, it is given the bounds of
+// the new image; then the whole of b is drawn again.
+//
+func (b *Background) SetImage(img draw.Image) {
+	b.lock.Lock()
+	b.r = img.Bounds()
+	if b.front != nil {
+		b.front = img
+		b.img = newImageLike(img, b.r)
+		b.unshown = nil
+	} else {
+		b.img = img
+	}
+	b.flushrect = b.r
+	b.waste = 0
+	item := b.item
+	if item != nil {
+		// items may hold on to the image they were drawn into.
+		item.SetContainer(b)
+	}
+	b.lock.Unlock()
+	if it, ok := item.(Resizer); ok {
+		it.SetBounds(b.r)
+	}
+	b.Flush()
+}
+
+// SetClipboard sets the clipboard used by items
+// inside b, usually that of the window system.
+// If cb is nil, a clipboard local to the program is used.
+//
+func (b *Background) SetClipboard(cb Clipboard) {
+	b.lock.Lock()
+	b.cb = cb
+	b.lock.Unlock()
+}
+
+func (b *Background) Clipboard() Clipboard {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.cb
+}
+
+// SetSelection sets the primary selection used by items
+// inside b, usually that of the window system. If sel is
+// nil, a selection local to the program is used.
+//
+func (b *Background) SetSelection(sel Clipboard) {
+	b.lock.Lock()
+	b.sel = sel
+	b.lock.Unlock()
+}
+
+func (b *Background) Selection() Clipboard {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.sel
+}
+
 func (b *Background) Rect() image.Rectangle {
 	return b.img.Bounds()
 }
 
+// Atomically calls f with b locked. Calling Atomically again
+// from within f, directly or through the methods of an item,
+// deadlocks, unless b has been made reentrant with
+// SetReentrant; if CheckLocks is set, it panics instead.
+//
 func (b *Background) Atomically(f func(FlushFunc)) {
+	id := b.lockID()
+	if id != 0 && b.lock.heldBy(id) {
+		if atomic.LoadInt32(&b.reentrant) == 0 {
+			panic(errReentrant)
+		}
+		f(b.flushing)
+		return
+	}
 	// could pre-allocate inside b if we cared.
+	var d damage
 	flush := func(r image.Rectangle, drawn Drawer) {
 		if drawn != nil && drawn != b.item {
 			panic("flushed object not directly inside Background")
@@ -69,11 +217,28 @@ func (b *Background) Atomically(f func(FlushFunc)) {
 			debugp("non canonical flushrect %v", r)
 			panic("oops background")
 		}
-		b.addFlush(r, drawn != nil)
+		if drawn != nil {
+			b.addFlush(r, true)
+			return
+		}
+		// merge all the undrawn rectangles before
+		// deciding which to draw together.
+		d.add(r)
 	}
-	b.lock.Lock()
+	b.lock.lockAs(id)
 	defer b.lock.Unlock()
+	b.flushing = flush
+	defer func() {
+		b.flushing = nil
+	}()
 	f(flush)
+	for _, r := range d {
+		b.addFlush(r, false)
+	}
+	if b.flushDue {
+		b.flushDue = false
+		b.scheduleFrame()
+	}
 }
 
 // stolen from inferno's devdraw
@@ -81,9 +246,7 @@ func (b *Background) addFlush(r image.Rectangle, drawn bool) {
 	r = r.Intersect(b.r)
 	if b.flushrect.Empty() {
 		if drawn {
-			if b.imgflush != nil {
-				b.imgflush(r)
-			}
+			b.show(r)
 		} else {
 			b.flushrect = r
 			b.waste = 0
@@ -96,9 +259,7 @@ func (b *Background) addFlush(r image.Rectangle, drawn bool) {
 	// do nothing except possible call the external flush.
 	overlaps := b.flushrect.Overlaps(r)
 	if !overlaps && drawn {
-		if b.imgflush != nil {
-			b.imgflush(r)
-		}
+		b.show(r)
 		return
 	}
 	nbb := b.flushrect.Union(r)
@@ -131,40 +292,175 @@ func (b *Background) addFlush(r image.Rectangle, drawn bool) {
 
 func (b *Background) flush() {
 	if !b.flushrect.Empty() {
+		t0 := profileStart()
 		draw.DrawMask(b.img, b.flushrect, b.bg, b.flushrect.Min, nil, image.ZP, draw.Src)
 		b.item.Draw(b.img, b.flushrect)
+		profileAdd(t0, profileComposite)
+		b.show(b.flushrect)
+		b.flushrect = image.ZR
+	}
+}
+
+// show makes r, which has been drawn, visible externally,
+// or, if b is double buffered, records that it must be
+// copied to the front buffer when b is next flushed.
+func (b *Background) show(r image.Rectangle) {
+	if b.front != nil {
+		b.unshown.add(r)
+		return
+	}
+	if b.imgflush != nil {
+		t0 := profileStart()
+		b.imgflush(r)
+		profileAdd(t0, profileFlush)
+	}
+}
+
+// swap copies the changed areas of the back
+// buffer to the front.
+func (b *Background) swap() {
+	t0 := profileStart()
+	defer profileAdd(t0, profileFlush)
+	for _, r := range b.unshown {
+		draw.Draw(b.front, r, b.img, r.Min, draw.Src)
 		if b.imgflush != nil {
-			b.imgflush(b.flushrect)
+			b.imgflush(r)
 		}
-		b.flushrect = image.ZR
 	}
+	b.unshown = nil
 }
 
 // Flush flushes all pending changes, and makes them visible.
+// If the frame rate is limited (see SetFrameRate), and
+// the last frame was shown too recently, the changes are
+// shown later instead, together with any others flushed
+// in the meantime. If Flush is called from within
+// Atomically, as by a callback of a widget, and b is
+// reentrant or CheckLocks is set, the changes are flushed
+// when the outermost call to Atomically returns; otherwise
+// it deadlocks.
 //
 func (b *Background) Flush() {
+	id := b.lockID()
+	if id != 0 && b.lock.heldBy(id) {
+		b.flushDue = true
+		return
+	}
+	b.lock.lockAs(id)
+	defer b.lock.Unlock()
+	b.scheduleFrame()
+}
+
+// SetFrameRate limits the rate at which changes are shown
+// to at most fps frames a second, so that a stream of
+// small changes, each flushed as it is made, does not
+// overwhelm the display. If fps is zero or less,
+// every Flush shows the changes at once.
+//
+func (b *Background) SetFrameRate(fps int) {
 	b.lock.Lock()
-	b.flush()
+	defer b.lock.Unlock()
+	b.interval = 0
+	if fps > 0 {
+		b.interval = time.Second / time.Duration(fps)
+	}
+}
+
+// WaitFrame waits until the next frame has been shown,
+// so that an animation can make one change each frame
+// without getting ahead of the display. If the frame
+// rate is not limited, it returns at once.
+//
+func (b *Background) WaitFrame() {
+	b.lock.Lock()
+	if b.interval <= 0 {
+		b.lock.Unlock()
+		return
+	}
+	c := make(chan bool)
+	b.waiters = append(b.waiters, c)
+	b.scheduleFrame()
 	b.lock.Unlock()
+	<-c
+}
+
+// NextFrame waits until the next frame has been shown by
+// the backing at the root of b, if that is a Background
+// (or other backing with a WaitFrame method), looking
+// through canvases and containers such as Split and
+// BoxLayout. It must not be called from within Atomically.
+//
+func NextFrame(b Backing) {
+	for {
+		switch c := b.(type) {
+		case interface {
+			WaitFrame()
+		}:
+			c.WaitFrame()
+			return
+		case *Canvas:
+			b = c.backing
+		case nestedBacking:
+			b = c.outer()
+		default:
+			return
+		}
+	}
 }
 
+// scheduleFrame shows the next frame now if the frame
+// rate allows it, otherwise arranges for it to be
+// shown when it does. It is called with b.lock held.
+func (b *Background) scheduleFrame() {
+	if b.frameDue != nil {
+		return
+	}
+	wait := b.interval - time.Since(b.lastFrame)
+	if b.interval <= 0 || wait <= 0 {
+		b.showFrame()
+		return
+	}
+	b.frameDue = time.AfterFunc(wait, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		b.frameDue = nil
+		b.showFrame()
+	})
+}
+
+// showFrame makes all pending changes visible.
+// It is called with b.lock held.
+func (b *Background) showFrame() {
+	b.flush()
+	if b.front != nil {
+		b.swap()
+	}
+	b.lastFrame = time.Now()
+	for _, c := range b.waiters {
+		close(c)
+	}
+	b.waiters = nil
+	profileFrame()
+}
+
+var _ ClipboardBacking = (*Background)(nil)
+var _ SelectionBacking = (*Background)(nil)
+
 type nullBacking bool
 
 // NullBacking returns an object that satisfies the
 // Backing interface but has no actual image associated
-// with it.
+// with it. Its Atomically does no locking, so it may be
+// called from within itself, as when an item not yet
+// added to a canvas sets up the items inside it.
 //
 func NullBacking() Backing {
 	return nullBacking(false)
 }
 
-var globalLock sync.Mutex
-
 func (_ nullBacking) Flush() {}
 
 func (_ nullBacking) Atomically(f func(f FlushFunc)) {
-	globalLock.Lock()
-	defer globalLock.Unlock()
 	f(func(_ image.Rectangle, _ Drawer) {})
 }
 
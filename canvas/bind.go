@@ -0,0 +1,99 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+)
+
+// A MouseHandler holds functions that are called when
+// the mouse is used over an item bound with Bind.
+// Each function is passed the item and the mouse event,
+// with its location relative to the top left corner of
+// the item's bounding box at the time of the press,
+// or of entering or leaving the item.
+// Any of the functions may be nil.
+//
+// OnPress is called when a button is pressed over the item,
+// and OnRelease when it is released again, wherever the
+// pointer is. OnClick is called after OnRelease if the
+// pointer is still over the item. OnEnter and OnLeave
+// are called as for the methods of HoverHandler.
+//
+// The functions are called from the goroutine delivering
+// mouse events, outside Atomically, so they may change
+// the item or the canvas. ClickCount may be called
+// on the canvas to find out whether a press or
+// click is part of a double click.
+//
+type MouseHandler struct {
+	OnPress   func(it Item, m ui.MouseEvent)
+	OnRelease func(it Item, m ui.MouseEvent)
+	OnClick   func(it Item, m ui.MouseEvent)
+	OnEnter   func(it Item, m ui.MouseEvent)
+	OnLeave   func(it Item, m ui.MouseEvent)
+}
+
+// Bind arranges for the functions in h to be called
+// when the mouse is used over it, which must be inside c.
+// This allows mouse interaction with any item, not just
+// those that implement HandleMouser; if it does implement
+// HandleMouser and h has any of the functions called
+// for button presses, h is used in place of its HandleMouse method.
+// If h is nil, any binding for it is removed.
+//
+func (c *Canvas) Bind(it Item, h *MouseHandler) {
+	c.Atomically(func(_ FlushFunc) {
+		if h == nil {
+			delete(c.bindings, it)
+			return
+		}
+		if c.bindings == nil {
+			c.bindings = make(map[Item]*MouseHandler)
+		}
+		c.bindings[it] = h
+	})
+}
+
+// presses reports whether h has any of the
+// functions called for button presses.
+func (h *MouseHandler) presses() bool {
+	return h.OnPress != nil || h.OnRelease != nil || h.OnClick != nil
+}
+
+// A binding delivers the mouse events for a bound item
+// to its MouseHandler.
+type binding struct {
+	c      *Canvas
+	it     Item
+	h      *MouseHandler
+	origin image.Point // the top left of the item when it was hit.
+}
+
+// local returns m with its location relative to the item.
+func (b *binding) local(m ui.MouseEvent) ui.MouseEvent {
+	m.Loc = m.Loc.Sub(b.origin)
+	return m
+}
+
+func (b *binding) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&^wheelButtons == 0 {
+		return false
+	}
+	if b.h.OnPress != nil {
+		b.h.OnPress(b.it, b.local(m))
+	}
+	m = Drag(m, ec, nil)
+	if b.h.OnRelease != nil {
+		b.h.OnRelease(b.it, b.local(m))
+	}
+	if b.h.OnClick != nil {
+		var in bool
+		b.c.Atomically(func(_ FlushFunc) {
+			in = b.it.HitTest(m.Loc)
+		})
+		if in {
+			b.h.OnClick(b.it, b.local(m))
+		}
+	}
+	return true
+}
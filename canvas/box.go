@@ -0,0 +1,247 @@
+package canvas
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Box creates a rectangular image of the given size, filled with the given colour,
+// with a border-size border of colour borderCol.
+//
+func Box(width, height int, col image.Image, border int, borderCol image.Image) image.Image {
+	side := BoxSide{border, borderCol}
+	return BoxSpec{
+		Fill:   col,
+		Top:    side,
+		Right:  side,
+		Bottom: side,
+		Left:   side,
+	}.Image(width, height)
+}
+
+// A BoxSide describes the border along one side of a box.
+//
+type BoxSide struct {
+	Width int
+	Fill  image.Image
+}
+
+// A BoxSpec describes the appearance of a rectangular box
+// with optionally rounded corners and a border that
+// may be different on each side.
+// Where two sides of different colours meet,
+// the corner is divided between them diagonally.
+//
+type BoxSpec struct {
+	Fill                     image.Image // may be nil, for an empty box.
+	Radius                   int         // radius of the outer corners.
+	Top, Right, Bottom, Left BoxSide
+}
+
+// boxSamples gives the number of samples in each direction
+// taken to calculate the coverage of a pixel.
+const boxSamples = 4
+
+// Image returns an image of the box with the given size.
+// The edges of rounded corners are anti-aliased.
+//
+func (spec BoxSpec) Image(width, height int) *image.RGBA {
+	r := image.Rect(0, 0, width, height)
+	img := image.NewRGBA(r)
+	if r.Empty() {
+		return img
+	}
+	rad := float64(spec.Radius)
+	if max := math.Min(float64(width), float64(height)) / 2; rad > max {
+		rad = max
+	}
+	shape := boxShape{sides: [4]BoxSide{spec.Top, spec.Right, spec.Bottom, spec.Left}}
+	w := &shape.w
+	for i, side := range shape.sides {
+		if side.Width > 0 {
+			w[i] = float64(side.Width)
+		}
+	}
+	shape.outer = roundRect{
+		x0: 0, y0: 0, x1: float64(width), y1: float64(height),
+		rx: [4]float64{rad, rad, rad, rad},
+		ry: [4]float64{rad, rad, rad, rad},
+	}
+	shape.inner = roundRect{
+		x0: w[3], y0: w[0], x1: float64(width) - w[1], y1: float64(height) - w[2],
+		rx: [4]float64{pos(rad - w[3]), pos(rad - w[1]), pos(rad - w[1]), pos(rad - w[3])},
+		ry: [4]float64{pos(rad - w[0]), pos(rad - w[0]), pos(rad - w[2]), pos(rad - w[2])},
+	}
+	if w[0]+w[2] > float64(height) || w[1]+w[3] > float64(width) {
+		// The sides overlap, so sample every pixel
+		// to divide them as nearestSide says.
+		spec.sample(img, r, &shape)
+		return img
+	}
+
+	// Away from the corners, each pixel lies wholly inside
+	// the fill or one side, so they can be drawn directly.
+	sides := &shape.sides
+	t, rt, b, l := int(w[0]), width-int(w[1]), height-int(w[2]), int(w[3])
+	fillRect(img, image.Rect(l, t, rt, b), spec.Fill)
+	fillRect(img, image.Rect(0, 0, width, t), sides[0].Fill)
+	fillRect(img, image.Rect(rt, t, width, b), sides[1].Fill)
+	fillRect(img, image.Rect(0, b, width, height), sides[2].Fill)
+	fillRect(img, image.Rect(0, t, l, b), sides[3].Fill)
+
+	// The corners need sampling only if they are rounded
+	// or divided between sides of different colours.
+	// Each corner is given by the indexes of the sides
+	// that meet there, the upright one first.
+	for _, c := range [4][2]int{{3, 0}, {1, 0}, {1, 2}, {3, 2}} {
+		v, h := c[0], c[1]
+		if rad == 0 && (w[v] == 0 || w[h] == 0 || sameImage(sides[v].Fill, sides[h].Fill)) {
+			continue
+		}
+		cw, ch := int(math.Ceil(math.Max(rad, w[v]))), int(math.Ceil(math.Max(rad, w[h])))
+		cr := image.Rect(0, 0, cw, ch)
+		if v == 1 {
+			cr = cr.Add(image.Pt(width-cw, 0))
+		}
+		if h == 2 {
+			cr = cr.Add(image.Pt(0, height-ch))
+		}
+		draw.Draw(img, cr, image.Transparent, image.ZP, draw.Src)
+		spec.sample(img, cr, &shape)
+	}
+	return img
+}
+
+// A boxShape holds the geometry of a box being drawn.
+type boxShape struct {
+	sides        [4]BoxSide
+	w            [4]float64 // widths of the sides, none negative.
+	outer, inner roundRect
+}
+
+// fillRect fills r in img with src, if src is non-nil.
+func fillRect(img *image.RGBA, r image.Rectangle, src image.Image) {
+	if src != nil {
+		draw.Draw(img, r, src, r.Min, draw.Src)
+	}
+}
+
+// sample draws the part r of the box with the given shape into img,
+// taking boxSamples×boxSamples samples of each pixel. The pixels
+// of img in r must be transparent.
+func (spec BoxSpec) sample(img *image.RGBA, r image.Rectangle, shape *boxShape) {
+	width, height := shape.outer.x1, shape.outer.y1
+	fillMask := newAlpha(r)
+	defer freeAlpha(fillMask)
+	var sideMasks [4]*image.Alpha
+	for i := range sideMasks {
+		if shape.w[i] > 0 && shape.sides[i].Fill != nil {
+			sideMasks[i] = newAlpha(r)
+			defer freeAlpha(sideMasks[i])
+		}
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			var fillN int
+			var sideN [4]int
+			for sy := 0; sy < boxSamples; sy++ {
+				fy := float64(y) + (float64(sy)+0.5)/boxSamples
+				for sx := 0; sx < boxSamples; sx++ {
+					fx := float64(x) + (float64(sx)+0.5)/boxSamples
+					switch {
+					case shape.inner.contains(fx, fy):
+						fillN++
+					case shape.outer.contains(fx, fy):
+						sideN[nearestSide(fx, fy, width, height, shape.w)]++
+					}
+				}
+			}
+			i := fillMask.PixOffset(x, y)
+			fillMask.Pix[i] = samples2alpha(fillN)
+			for j, m := range sideMasks {
+				if m != nil {
+					m.Pix[i] = samples2alpha(sideN[j])
+				}
+			}
+		}
+	}
+	if spec.Fill != nil {
+		draw.DrawMask(img, r, spec.Fill, r.Min, fillMask, r.Min, draw.Over)
+	}
+	for i, m := range sideMasks {
+		if m != nil {
+			draw.DrawMask(img, r, shape.sides[i].Fill, r.Min, m, r.Min, draw.Over)
+		}
+	}
+}
+
+// sameImage reports whether a and b are known
+// to be the same image.
+func sameImage(a, b image.Image) (same bool) {
+	// Comparing images of a type that cannot
+	// be compared panics; they may differ.
+	defer func() {
+		recover()
+	}()
+	return a == b
+}
+
+func samples2alpha(n int) uint8 {
+	return uint8(n * 0xff / (boxSamples * boxSamples))
+}
+
+func pos(x float64) float64 {
+	if x < 0 {
+		return 0
+	}
+	return x
+}
+
+// nearestSide returns the index (top, right, bottom, left)
+// of the side of a width by height box whose border the
+// point (x, y) is proportionally furthest into.
+// w holds the widths of the sides.
+func nearestSide(x, y, width, height float64, w [4]float64) int {
+	d := [4]float64{y, width - x, height - y, x}
+	best, bestf := 0, math.Inf(1)
+	for i, wi := range w {
+		if wi > 0 {
+			if f := d[i] / wi; f < bestf {
+				best, bestf = i, f
+			}
+		}
+	}
+	return best
+}
+
+// A roundRect represents a rectangle with elliptical corners.
+// The corner radii are held in the order top-left, top-right,
+// bottom-right, bottom-left.
+type roundRect struct {
+	x0, y0, x1, y1 float64
+	rx, ry         [4]float64
+}
+
+func (r *roundRect) contains(x, y float64) bool {
+	if x < r.x0 || x >= r.x1 || y < r.y0 || y >= r.y1 {
+		return false
+	}
+	var cx, cy float64
+	var i int
+	switch {
+	case x < r.x0+r.rx[0] && y < r.y0+r.ry[0]:
+		i, cx, cy = 0, r.x0+r.rx[0], r.y0+r.ry[0]
+	case x >= r.x1-r.rx[1] && y < r.y0+r.ry[1]:
+		i, cx, cy = 1, r.x1-r.rx[1], r.y0+r.ry[1]
+	case x >= r.x1-r.rx[2] && y >= r.y1-r.ry[2]:
+		i, cx, cy = 2, r.x1-r.rx[2], r.y1-r.ry[2]
+	case x < r.x0+r.rx[3] && y >= r.y1-r.ry[3]:
+		i, cx, cy = 3, r.x0+r.rx[3], r.y1-r.ry[3]
+	default:
+		return true
+	}
+	dx := (x - cx) / r.rx[i]
+	dy := (y - cy) / r.ry[i]
+	return dx*dx+dy*dy <= 1
+}
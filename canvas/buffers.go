@@ -0,0 +1,97 @@
+package canvas
+
+import (
+	"image"
+	"sync"
+)
+
+// The pixel buffers of temporary images and coverage masks
+// are kept for reuse once they are finished with, so that
+// animation does not make a new one for every frame.
+// Buffers are held in size classes of powers of two bytes,
+// so that a buffer can be reused for any image of up to
+// the size of its class.
+var bufPools [32]sync.Pool
+
+// minBufClass is the smallest size class kept;
+// smaller buffers are cheap enough to allocate.
+const minBufClass = 8
+
+// bufClass returns the size class of buffers
+// that can hold n bytes.
+func bufClass(n int) int {
+	c := minBufClass
+	for 1<<uint(c) < n {
+		c++
+	}
+	return c
+}
+
+// getBuf returns a zeroed buffer of n bytes.
+func getBuf(n int) []uint8 {
+	c := bufClass(n)
+	if c >= len(bufPools) {
+		return make([]uint8, n)
+	}
+	if b, ok := bufPools[c].Get().([]uint8); ok {
+		b = b[:n]
+		for i := range b {
+			b[i] = 0
+		}
+		return b
+	}
+	return make([]uint8, n, 1<<uint(c))
+}
+
+// putBuf returns b to its pool. It must not
+// be used after the call.
+func putBuf(b []uint8) {
+	c := bufClass(cap(b))
+	if c >= len(bufPools) || cap(b) != 1<<uint(c) {
+		// not one of ours.
+		return
+	}
+	bufPools[c].Put(b[:0])
+}
+
+// newRGBA is like image.NewRGBA, but the pixels may
+// come from a buffer that has been used before.
+// The image may be given back with freeRGBA.
+func newRGBA(r image.Rectangle) *image.RGBA {
+	w, h := r.Dx(), r.Dy()
+	if w <= 0 || h <= 0 {
+		return image.NewRGBA(r)
+	}
+	return &image.RGBA{Pix: getBuf(4 * w * h), Stride: 4 * w, Rect: r}
+}
+
+// freeRGBA gives the buffer of img back for reuse.
+// Neither img nor any image sharing its pixels
+// may be used afterwards.
+func freeRGBA(img *image.RGBA) {
+	if img != nil {
+		putBuf(img.Pix)
+		img.Pix = nil
+	}
+}
+
+// newAlpha is like image.NewAlpha, but the pixels may
+// come from a buffer that has been used before.
+// The mask may be given back with freeAlpha.
+func newAlpha(r image.Rectangle) *image.Alpha {
+	w, h := r.Dx(), r.Dy()
+	if w <= 0 || h <= 0 {
+		return image.NewAlpha(r)
+	}
+	return &image.Alpha{Pix: getBuf(w * h), Stride: w, Rect: r}
+}
+
+// freeAlpha gives the buffer of m back for reuse.
+// Neither m nor any image sharing its pixels
+// may be used afterwards.
+func freeAlpha(m *image.Alpha) {
+	if m != nil {
+		putBuf(m.Pix)
+		m.Pix = nil
+	}
+}
@@ -0,0 +1,189 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+)
+
+// The visual states of a button-like widget.
+const (
+	stateNormal = iota
+	stateHover
+	statePressed
+)
+
+// A Button shows a labelled button that can be clicked with the mouse.
+//
+type Button struct {
+	backing Backing
+	value   values.Value
+	Item
+	c     *Canvas
+	box   Rect
+	label *Text
+	faces [3]image.Image // indexed by state.
+	state int
+	font  *truetype.Font
+	bg    color.Color
+	theme *Theme
+	pref  image.Point // the size the button was created with.
+}
+
+// NewButton returns a new Button occupying r, showing the given label
+// drawn in fg on a background of bg.
+// Each time the button is clicked (pressed and released
+// with the pointer inside it), value is set to true.
+//
+func NewButton(r image.Rectangle, label string, font *truetype.Font, fg, bg color.Color, value values.Value) *Button {
+	obj := new(Button)
+	obj.value = value
+	obj.backing = NullBacking()
+	obj.c = NewCanvas(nil, r)
+	obj.pref = r.Size()
+	obj.font = font
+	obj.bg = bg
+	obj.box = *NewRect(r, nil, 0, nil)
+	obj.label = NewText(centre(r), 0, label, font, 12, nil)
+	obj.label.SetFill(&image.Uniform{fg})
+	obj.restyle()
+	obj.c.AddItem(&obj.box)
+	obj.c.AddItem(obj.label)
+	obj.Item = obj.c
+	return obj
+}
+
+var _ ResizableItem = (*Button)(nil)
+var _ Sizer = (*Button)(nil)
+
+func (obj *Button) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the button,
+// overriding that of its canvas. If t is nil, the
+// canvas's theme is used.
+//
+func (obj *Button) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.box.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+// SetBounds moves and resizes the button to occupy r.
+//
+func (obj *Button) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.box.r
+		obj.box.r = r
+		obj.c.r = r
+		obj.label.setPoint(centre(r))
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size of the label with
+// the border and padding around it.
+//
+func (obj *Button) MinSize() image.Point {
+	t := themeFor(obj.theme, obj.backing)
+	d := 2 * (t.Padding + t.BorderWidth)
+	return obj.label.Bbox().Size().Add(image.Pt(d, d))
+}
+
+func (obj *Button) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+func (obj *Button) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	obj.faces = [3]image.Image{
+		stateNormal:  &image.Uniform{obj.bg},
+		stateHover:   &image.Uniform{shade(obj.bg, t.Hover)},
+		statePressed: &image.Uniform{shade(obj.bg, t.Pressed)},
+	}
+	obj.box.fill = obj.faces[obj.state]
+	obj.box.setBorder(t.BorderWidth, t.border())
+	t.styleLabel(obj.label, obj.font)
+}
+
+func (obj *Button) setState(state int) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		if state != obj.state {
+			obj.state = state
+			obj.box.fill = obj.faces[state]
+			flush(obj.box.r, nil)
+		}
+	})
+	obj.backing.Flush()
+}
+
+// HandleMouse shows the button as pressed while the
+// first mouse button is held down over it.
+// If it is called with no buttons pressed, the button
+// is highlighted until the pointer leaves it; other
+// events received meanwhile are discarded.
+//
+func (obj *Button) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	r := obj.box.Bbox()
+	if m.Buttons == 0 {
+		obj.setState(stateHover)
+		for m.Buttons == 0 {
+			e, ok := (<-ec).(ui.MouseEvent)
+			if !ok {
+				continue
+			}
+			m = e
+			if !m.Loc.In(r) {
+				obj.setState(stateNormal)
+				return true
+			}
+		}
+	}
+	if m.Buttons&1 == 0 {
+		return false
+	}
+	obj.setState(statePressed)
+	but := m.Buttons
+	for {
+		if m, ok := (<-ec).(ui.MouseEvent); ok {
+			in := m.Loc.In(r)
+			if (m.Buttons & but) != but {
+				obj.setState(stateNormal)
+				if in {
+					obj.value.Set(true)
+				}
+				break
+			}
+			if in {
+				obj.setState(statePressed)
+			} else {
+				obj.setState(stateNormal)
+			}
+		}
+	}
+	return true
+}
+
+// shade returns col with its red, green and blue
+// components multiplied by f.
+func shade(col color.Color, f float64) color.Color {
+	r, g, b, a := col.RGBA()
+	s := func(x uint32) uint16 {
+		y := float64(x) * f
+		if y > float64(a) {
+			// keep the colour a valid premultiplied value.
+			y = float64(a)
+		}
+		return uint16(y)
+	}
+	return color.RGBA64{s(r), s(g), s(b), uint16(a)}
+}
@@ -21,6 +21,7 @@ import (
 	"image/color"
 	"image/draw"
 	"log"
+	"sync/atomic"
 )
 
 // The Flush method is used to flush any pending changes
@@ -54,9 +55,22 @@ type Backing interface {
 // rectangle have been non-transparently overwritten);
 // its value gives the item that has changed,
 // which must be a direct child of the Backing object.
+// The backing may hold on to the rectangles until
+// the end of the call to Atomically, merging them
+// as it chooses before it redraws any of them.
 //
 type FlushFunc func(r image.Rectangle, drawn Drawer)
 
+// Rects informs the backing of several changed rectangles
+// at once, such as the old and new bounding boxes of an item
+// that has moved, leaving it to decide how best to merge them.
+//
+func (flush FlushFunc) Rects(drawn Drawer, rs ...image.Rectangle) {
+	for _, r := range rs {
+		flush(r, drawn)
+	}
+}
+
 // The Drawer interface represents the basic level
 // of functionality for a drawn object.
 // SetContainer is called when the object is placed
@@ -70,7 +84,9 @@ type FlushFunc func(r image.Rectangle, drawn Drawer)
 // be changed. It is legitimate for the Drawer object
 // to retain the dst image until SetContainer is
 // called (for instance, to perform its own direct
-// manipulation of the image).
+// manipulation of the image). dst may have any pixel
+// format, so the object should not assume that it
+// is an *image.RGBA, although it usually is.
 //
 // Neither method in Drawer should interact with any object
 // outside its direct control (for example by modifying
@@ -111,9 +127,30 @@ type HandlerItem interface {
 	HandleMouser
 }
 
+// The mouse button bits that represent movement
+// of the scroll wheel. A button press is delivered
+// each time the wheel moves one step, with exactly
+// one of these bits set along with any buttons
+// that are held down. An item handling a wheel event
+// should act on it at once, without waiting for the
+// bit to be released or reading further events.
+// Wheels that tilt sideways give WheelLeft and WheelRight.
+//
+const (
+	WheelUp    = 1 << 3
+	WheelDown  = 1 << 4
+	WheelLeft  = 1 << 5
+	WheelRight = 1 << 6
+)
+
+// wheelButtons holds all the wheel bits.
+const wheelButtons = WheelUp | WheelDown | WheelLeft | WheelRight
+
 // static interface checks:
 var _ Backing = (*Canvas)(nil)
 var _ HandlerItem = (*Canvas)(nil)
+var _ HandleKeyer = (*Canvas)(nil)
+var _ Resizer = (*Canvas)(nil)
 
 // A Canvas represents a z-ordered set of drawable Items.
 // As a Canvas itself implements Item and Backing, Canvas's can
@@ -125,7 +162,38 @@ type Canvas struct {
 	backing    Backing
 	opaque     bool
 	background image.Image
-	items      list.List // foreground objects are at the end of the list
+	bgmode     BackgroundMode // how background is drawn.
+	items      list.List      // foreground objects are at the end of the list
+	focus      HandleKeyer
+	ring       image.Rectangle // the focus ring as of the last Atomically; empty if none.
+	overlays   []overlay // transient items, always at the top.
+	tips       map[Item]Item
+	tip        tipState
+	bindings   map[Item]*MouseHandler
+	tags       map[Item][]string    // the tags of items, in the order added.
+	data       map[Item]interface{} // attached with SetData.
+	hover      Item                 // the item last under the pointer that wants to know.
+	clicks     clickState
+	mods       Modifiers       // the modifier keys held down.
+	grab       grabState       // the item capturing the mouse, if any.
+	theme      *Theme          // if nil, the theme of the enclosing canvas is used.
+	sched      scheduler       // runs the callbacks set by After and Every.
+	index      *itemIndex      // nil until there are enough items to need it.
+	static     *staticLayer    // nil until AddStatic is called.
+	antialias  Antialias       // if AntialiasDefault, that of the enclosing canvas is used.
+	rendering  bool            // set in the outermost canvas by RenderTo while it draws.
+	recorder   *FrameRecorder  // captures a frame on each Flush, if non-nil.
+	zoom       float64         // the scale of the view of the items; zero for none.
+	org        image.Point     // the world point shown at r.Min when zoom is set.
+	world      image.Rectangle // if non-empty, the rectangle returned by Rect.
+	ratio      float64         // device pixels to each logical pixel; zero for one.
+	recording  int32           // non-zero when recorder is set; read without the lock.
+}
+
+// An overlay records an item added with Popup.
+type overlay struct {
+	e       *list.Element
+	dismiss func()
 }
 
 // NewCanvas returns a new Canvas object that is inside
@@ -157,15 +225,59 @@ func (c *Canvas) Opaque() bool {
 // for items to draw into.
 //
 func (c *Canvas) Rect() image.Rectangle {
+	if !c.world.Empty() {
+		return c.world
+	}
+	if c.ratio != 0 {
+		return c.logical()
+	}
 	return c.r
 }
 
+// SetBounds changes the bounding rectangle of c, for instance
+// when the window it fills has been resized. The items inside
+// c stay where they are, except that any item that can be
+// resized (see Resizer) and whose bounding box was the whole
+// of Rect, such as a layout filling the canvas, is given the
+// new Rect, so that its contents are laid out again.
+//
+func (c *Canvas) SetBounds(r image.Rectangle) {
+	var fill []Resizer
+	c.backing.Atomically(func(flush FlushFunc) {
+		old := c.Rect()
+		for e := c.items.Front(); e != nil; e = e.Next() {
+			it, ok := e.Value.(Resizer)
+			if ok && e.Value.(Item).Bbox().Eq(old) {
+				fill = append(fill, it)
+			}
+		}
+		flush.Rects(nil, c.r, r)
+		c.r = r
+		c.img = nil
+		c.setContainers()
+	})
+	r = c.Rect()
+	for _, it := range fill {
+		it.SetBounds(r)
+	}
+	c.Flush()
+}
+
 func (c *Canvas) SetContainer(b Backing) {
 	c.img = nil
 	c.backing = b
+	c.setContainers()
+}
+
+// setContainers tells the items in c that
+// it has changed in a way that might affect them.
+func (c *Canvas) setContainers() {
 	for e := c.items.Front(); e != nil; e = e.Next() {
 		e.Value.(Item).SetContainer(c)
 	}
+	if c.static != nil {
+		c.static.setContainer()
+	}
 }
 
 func (c *Canvas) Bbox() image.Rectangle {
@@ -175,61 +287,320 @@ func (c *Canvas) Bbox() image.Rectangle {
 func (c *Canvas) Flush() {
 	if c != nil && c.backing != nil {
 		c.backing.Flush()
+		if atomic.LoadInt32(&c.recording) != 0 {
+			var fr *FrameRecorder
+			c.Atomically(func(_ FlushFunc) {
+				fr = c.recorder
+			})
+			if fr != nil {
+				fr.Capture()
+			}
+		}
 	}
 }
 
 // HandleMouse delivers the mouse events to the top-most
-// item that that is hit by the mouse point.
+// item that that is hit by the mouse point, or to
+// its MouseHandler if it has been bound with Bind.
+//
+// If there are any overlays (see Popup), a button
+// press outside all of them dismisses them and is
+// absorbed.
+//
+// A wheel event that the top-most item does not absorb
+// is offered in turn to the items underneath it.
+// While an item has grabbed the mouse (see GrabMouse),
+// all events are delivered to it instead.
+//
+// If c is zoomed or panned (see SetZoom), the events are
+// passed on in world coordinates, and one event more than
+// the items consumed may have been read from ec and discarded.
 //
 func (c *Canvas) HandleMouse(_ Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
-	var chosen HandlerItem
+	var grab grabState
+	var t xform
 	c.Atomically(func(_ FlushFunc) {
-		for e := c.items.Back(); e != nil; e = e.Prev() {
-			if h, ok := e.Value.(HandlerItem); ok {
-				if h.HitTest(m.Loc) {
-					chosen = h
-					break
-				}
+		grab = c.grab
+		t = c.xform()
+	})
+	if grab.h != nil {
+		return grab.h.HandleMouse(grab.c, m, ec)
+	}
+	if !t.identity() {
+		m.Loc = t.world(m.Loc)
+		tc, done := mapMouse(ec, t.world)
+		defer done()
+		ec = tc
+	}
+	var chosen []HandleMouser
+	var target Item
+	var dismissed []overlay
+	wheel := m.Buttons&wheelButtons != 0
+	c.trackTip(m)
+	c.trackHover(m)
+	c.trackClick(m)
+	c.Atomically(func(flush FlushFunc) {
+		if m.Buttons != 0 && len(c.overlays) > 0 && !c.overlayHit(m.Loc) {
+			dismissed = c.overlays
+			c.overlays = nil
+			for _, o := range dismissed {
+				c.remove(o.e, flush)
 			}
+			return
 		}
+		c.overlapping(hitRect(m.Loc), true, func(e *list.Element) bool {
+			it := e.Value.(Item)
+			b := c.bindings[it]
+			if b != nil && !b.presses() {
+				b = nil
+			}
+			h, ok := it.(HandleMouser)
+			if (b != nil || ok) && it.HitTest(m.Loc) {
+				if b != nil {
+					h = &binding{c, it, b, it.Bbox().Min}
+				}
+				chosen = append(chosen, h)
+				if target == nil {
+					target = it
+				}
+				return wheel
+			}
+			return true
+		})
 	})
+	if dismissed != nil {
+		for _, o := range dismissed {
+			if o.dismiss != nil {
+				o.dismiss()
+			}
+		}
+		return true
+	}
+	if wheel {
+		for _, h := range chosen {
+			if h.HandleMouse(c, m, ec) {
+				return true
+			}
+		}
+		return false
+	}
 	if chosen != nil {
-		return chosen.HandleMouse(c, m, ec)
+		var focus HandleKeyer
+		c.Atomically(func(_ FlushFunc) {
+			focus = c.focus
+		})
+		absorbed := chosen[0].HandleMouse(c, m, ec)
+		if absorbed && m.Buttons != 0 {
+			// the item may have given the focus elsewhere
+			// itself, for instance to a popup menu.
+			var old, k HandleKeyer
+			c.Atomically(func(flush FlushFunc) {
+				if c.focus == focus {
+					k, _ = target.(HandleKeyer)
+					old = c.changeFocus(k, flush)
+				}
+			})
+			c.Flush()
+			unfocus(old, k)
+		}
+		return absorbed
 	}
 	return false
 }
 
-func (c *Canvas) HitTest(p image.Point) (hit bool) {
-	for e := c.items.Back(); e != nil; e = e.Prev() {
-		if e.Value.(Item).HitTest(p) {
+// raiseOverlays moves all overlays back to the
+// top of the z-ordering.
+func (c *Canvas) raiseOverlays() {
+	for _, o := range c.overlays {
+		c.items.MoveToBack(o.e)
+	}
+	c.index.restacked()
+}
+
+// overlayHit reports whether any overlay is hit by p.
+func (c *Canvas) overlayHit(p image.Point) bool {
+	for _, o := range c.overlays {
+		if o.e.Value.(Item).HitTest(p) {
 			return true
 		}
 	}
 	return false
 }
 
+func (c *Canvas) HitTest(p image.Point) (hit bool) {
+	return c.hit(c.xform().world(p)) != nil
+}
+
+// ItemAt returns the top-most item in c whose HitTest
+// method reports that it is hit by p, or nil if there is none.
+// Items inside nested canvases are not searched; use
+// ItemAt on the nested canvas for those.
+//
+func (c *Canvas) ItemAt(p image.Point) (it Item) {
+	c.Atomically(func(_ FlushFunc) {
+		it = c.hit(p)
+	})
+	return
+}
+
+// ItemsAt returns all the items in c that are hit by p,
+// in z-order from the top-most down.
+//
+func (c *Canvas) ItemsAt(p image.Point) (items []Item) {
+	c.Atomically(func(_ FlushFunc) {
+		c.overlapping(hitRect(p), true, func(e *list.Element) bool {
+			if it := e.Value.(Item); it.HitTest(p) {
+				items = append(items, it)
+			}
+			return true
+		})
+	})
+	return
+}
+
+// hit returns the top-most item in c that is hit by p, or nil.
+func (c *Canvas) hit(p image.Point) (it Item) {
+	c.overlapping(hitRect(p), true, func(e *list.Element) bool {
+		if x := e.Value.(Item); x.HitTest(p) {
+			it = x
+			return false
+		}
+		return true
+	})
+	return
+}
+
 func (c *Canvas) Draw(dst draw.Image, clipr image.Rectangle) {
 	clipr = clipr.Intersect(c.r)
-	c.img = dst
-	if c.background != nil {
-		draw.Draw(dst, clipr, c.background, clipr.Min, draw.Over)
+	switch {
+	case outermost(c).rendering:
+		// dst belongs to the caller; keep the image c is shown on.
+	case shownDirectly(c):
+		c.img = dst
+	default:
+		// dst is a temporary image, which
+		// must not be drawn onto later.
+		c.img = nil
 	}
-	clipr = clipr.Intersect(c.r)
-	for e := c.items.Front(); e != nil; e = e.Next() {
+	if !c.xform().identity() {
+		c.drawViewed(dst, clipr)
+		return
+	}
+	c.drawWorld(dst, clipr)
+}
+
+// drawWorld draws the area clipr of the items' coordinate
+// space onto dst, which uses the same coordinates.
+func (c *Canvas) drawWorld(dst draw.Image, clipr image.Rectangle) {
+	items, covered := c.visible(clipr)
+	switch {
+	case covered:
+		// the background cannot be seen.
+	case c.static != nil:
+		c.static.draw(dst, clipr)
+	case c.background != nil:
+		c.drawBackground(dst, clipr)
+	}
+	var rasters []*RasterItem
+	for _, it := range items {
+		if r, ok := it.(rasterer); ok && r.rasterItem().stale() {
+			rasters = append(rasters, r.rasterItem())
+		}
+	}
+	if len(rasters) > 1 {
+		prepareRasters(rasters)
+	}
+	for _, it := range items {
+		t0 := profileStart()
+		it.Draw(dst, clipr)
+		profileItem(it, t0)
+	}
+	if r, ok := c.focusRing(); ok && r.Overlaps(clipr) {
+		ring := Rect{r: r, border: focusRingWidth, borderFill: &image.Uniform{ThemeOf(c).Focus}}
+		ring.Draw(dst, clipr)
+	}
+}
+
+// maxOccluders is the largest number of opaque items
+// that visible will check each item against.
+const maxOccluders = 8
+
+// visible returns the items that must be drawn to draw
+// the area clipr, from the bottom upwards, leaving out
+// any hidden within clipr by an opaque item above them.
+// It also reports whether the whole of clipr is covered
+// by an opaque item, in which case the background
+// need not be drawn.
+func (c *Canvas) visible(clipr image.Rectangle) (items []Item, covered bool) {
+	var opaque []image.Rectangle
+	c.overlapping(clipr, true, func(e *list.Element) bool {
 		it := e.Value.(Item)
-		if it.Bbox().Overlaps(clipr) {
-			it.Draw(dst, clipr)
+		r := it.Bbox().Intersect(clipr)
+		if r.Empty() {
+			return true
+		}
+		for _, o := range opaque {
+			if r.In(o) {
+				return true
+			}
+		}
+		items = append(items, it)
+		if it.Opaque() {
+			if r.Eq(clipr) {
+				covered = true
+				return false
+			}
+			if len(opaque) < maxOccluders {
+				opaque = append(opaque, r)
+			}
 		}
+		return true
+	})
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
 	}
+	return
+}
+
+// Raise moves it to the top of the canvas z-ordering.
+//
+func (c *Canvas) Raise(it Item) {
+	c.restack(it, nil, true)
 }
 
-// Raise moves the Item it adjacent to nextto in the canvas z-ordering.
+// Lower moves it to the bottom of the canvas z-ordering.
+//
+func (c *Canvas) Lower(it Item) {
+	c.restack(it, nil, false)
+}
+
+// RaiseAbove moves it so that it is just above other
+// in the canvas z-ordering.
+//
+func (c *Canvas) RaiseAbove(it, other Item) {
+	if other != nil {
+		c.restack(it, other, true)
+	}
+}
+
+// LowerBelow moves it so that it is just below other
+// in the canvas z-ordering.
+//
+func (c *Canvas) LowerBelow(it, other Item) {
+	if other != nil {
+		c.restack(it, other, false)
+	}
+}
+
+// restack moves the Item it adjacent to nextto in the canvas z-ordering.
 // If above is true, the item will be placed just above nextto,
 // otherwise it will be placed just below.
 // If nextto is nil, then the item will be placed at
 // the very top (above==true) or the very bottom (above==false).
+// Only the area covered by it can change appearance,
+// so that is all that is flushed.
 //
-func (c *Canvas) Raise(it, nextto Item, above bool) {
+func (c *Canvas) restack(it, nextto Item, above bool) {
 	if it == nextto {
 		return
 	}
@@ -243,23 +614,25 @@ func (c *Canvas) Raise(it, nextto Item, above bool) {
 				ae = e
 			}
 		}
-		if ie != nil && (nextto == nil || ae != nil) {
-			c.items.Remove(ie)
-			if ae != nil {
-				if above {
-					c.items.InsertAfter(it, ae)
-				} else {
-					c.items.InsertBefore(it, ae)
-				}
+		if ie == nil || (nextto != nil && ae == nil) {
+			return
+		}
+		if ae != nil {
+			if above {
+				c.items.MoveAfter(ie, ae)
 			} else {
-				if above {
-					c.items.PushBack(it)
-				} else {
-					c.items.PushFront(it)
-				}
+				c.items.MoveBefore(ie, ae)
+			}
+		} else {
+			if above {
+				c.items.MoveToBack(ie)
+				c.raiseOverlays()
+			} else {
+				c.items.MoveToFront(ie)
 			}
-			flush(it.Bbox(), nil)
 		}
+		c.index.restacked()
+		flush(it.Bbox(), nil)
 	})
 }
 
@@ -268,10 +641,9 @@ func (c *Canvas) Raise(it, nextto Item, above bool) {
 func (c *Canvas) drawAbove(it Item, clipr image.Rectangle) {
 	clipr = clipr.Intersect(c.r)
 	drawing := false
-	for e := c.items.Front(); e != nil; e = e.Next() {
+	visit := func(e *list.Element) bool {
 		if e.Value == nil {
 			panic("nil value - can't happen?")
-			continue
 		}
 		item := e.Value.(Item)
 		if drawing && item.Bbox().Overlaps(clipr) {
@@ -279,32 +651,65 @@ func (c *Canvas) drawAbove(it Item, clipr image.Rectangle) {
 		} else if item == it {
 			drawing = true
 		}
+		return true
+	}
+	c.overlapping(clipr, false, visit)
+	if !drawing && c.index != nil {
+		// it has moved since it was last indexed.
+		for e := c.items.Front(); e != nil; e = e.Next() {
+			visit(e)
+		}
 	}
 }
 
 // DeleteItem deletes a single item from the canvas.
 //
 func (c *Canvas) Delete(it Item) {
-	removed := false
 	c.Atomically(func(flush FlushFunc) {
-		var next *list.Element
-		for e := c.items.Front(); e != nil; e = next {
-			next = e.Next()
-			if e.Value.(Item) == it {
-				c.items.Remove(e)
-				flush(it.Bbox(), nil)
-				removed = true
-				break
-			}
-		}
-		if removed {
-			it.SetContainer(NullBacking())
-		} else {
+		if !c.deleteItem(it, flush) {
 			log.Printf("item %T not removed", it)
 		}
 	})
 }
 
+func (c *Canvas) deleteItem(it Item, flush FlushFunc) bool {
+	for e := c.items.Front(); e != nil; e = e.Next() {
+		if e.Value.(Item) == it {
+			c.remove(e, flush)
+			return true
+		}
+	}
+	return c.deleteStatic(it, flush)
+}
+
+// remove removes the item held in e from the canvas.
+func (c *Canvas) remove(e *list.Element, flush FlushFunc) {
+	it := e.Value.(Item)
+	c.index.delete(e)
+	c.items.Remove(e)
+	flush(it.Bbox(), nil)
+	for i, o := range c.overlays {
+		if o.e == e {
+			c.overlays = append(c.overlays[:i], c.overlays[i+1:]...)
+			break
+		}
+	}
+	if it == c.hover {
+		c.hover = nil
+	}
+	// the lock held here is shared with the outermost canvas.
+	if top := outermost(c); top.grab.c == c {
+		if h, ok := it.(HandleMouser); ok && h == top.grab.h {
+			top.grab = grabState{}
+		}
+	}
+	if k, ok := it.(HandleKeyer); ok && k == c.focus {
+		c.focus = nil
+		flush(it.Bbox().Inset(-focusRingWidth), nil)
+	}
+	it.SetContainer(NullBacking())
+}
+
 func (c *Canvas) Replace(it, it1 Item) (replaced bool) {
 	c.Atomically(func(flush FlushFunc) {
 		var next *list.Element
@@ -312,9 +717,10 @@ func (c *Canvas) Replace(it, it1 Item) (replaced bool) {
 			next = e.Next()
 			if e.Value.(Item) == it {
 				r := it.Bbox()
+				c.index.delete(e)
 				e.Value = it1
-				flush(r, nil)
-				flush(it1.Bbox(), nil)
+				c.index.insert(e)
+				flush.Rects(nil, r, it1.Bbox())
 				replaced = true
 				break
 			}
@@ -327,40 +733,165 @@ func (c *Canvas) Replace(it, it1 Item) (replaced bool) {
 // the appearance of items in the canvas.
 // See the Backing interface for details
 //
+// Areas flushed as changed but not drawn are not passed
+// on until f returns, when any that overlap are merged,
+// so that an area flushed several times is redrawn once.
+// The areas are clipped to the rectangle of the canvas before
+// they are passed on, so that a change to an item that lies
+// partly outside a nested canvas never causes the items of
+// the enclosing canvas around it to be redrawn.
+//
 func (c *Canvas) Atomically(f func(FlushFunc)) {
 	if c == nil || c.backing == nil {
 		panic("nil c or backing")
 	}
 	c.backing.Atomically(func(bflush FlushFunc) {
+		var d, drawnRects damage
 		f(func(r image.Rectangle, drawn Drawer) {
-			if drawn != nil {
+			if drawn == nil || !c.xform().identity() {
+				// an item in a zoomed canvas has not
+				// drawn itself where it is shown.
+				d.add(r)
+				return
+			}
+			drawnRects = append(drawnRects, r)
+			if r = r.Intersect(c.r); !r.Empty() {
 				c.drawAbove(drawn.(Item), r)
-				drawn = c
-			} else if c.img != nil && c.opaque {
+				bflush(r, c)
+			}
+		})
+		// the focused item may have moved without
+		// flushing where its ring is drawn.
+		if ring, _ := c.focusRing(); ring != c.ring {
+			d.add(c.ring)
+			d.add(ring)
+			c.ring = ring
+		}
+		// any item whose bounding box has
+		// changed has flushed where it was.
+		c.index.update(append(drawnRects, d...))
+		t := c.xform()
+		for _, r := range d {
+			r = t.screenRect(r).Intersect(c.r)
+			if r.Empty() {
+				continue
+			}
+			var drawn Drawer
+			if c.img != nil && c.opaque {
 				// if we're opaque, then we can just redraw ourselves
 				// without worrying about what might be underneath.
 				c.Draw(c.img, r)
 				drawn = c
 			}
 			bflush(r, drawn)
-		})
+		}
 	})
 }
 
 func (c *Canvas) AddItem(item Item) {
 	c.Atomically(func(flush FlushFunc) {
-		item.SetContainer(c)
-		c.items.PushBack(item)
-		r := item.Bbox()
-		if item.Opaque() && c.img != nil {
-			item.Draw(c.img, r.Intersect(c.r))
-			flush(r, item)
-		} else {
-			flush(r, nil)
-		}
+		c.addItem(item, flush)
+	})
+}
+
+func (c *Canvas) addItem(item Item, flush FlushFunc) {
+	item.SetContainer(c)
+	var e *list.Element
+	if len(c.overlays) > 0 {
+		// keep overlays above everything else.
+		e = c.items.InsertBefore(item, c.overlays[0].e)
+	} else {
+		e = c.items.PushBack(item)
+	}
+	c.index.insert(e)
+	r := item.Bbox()
+	if item.Opaque() && c.img != nil && c.xform().identity() {
+		item.Draw(c.img, r.Intersect(c.r))
+		flush(r, item)
+	} else {
+		flush(r, nil)
+	}
+}
+
+// Popup adds it to the top of the canvas z-ordering as
+// a transient overlay, such as a menu or a drop-down list.
+// Overlays stay above all other items, including those
+// added later. When a mouse button is pressed outside
+// every overlay, all overlays are removed from the
+// canvas and their dismiss functions, if non-nil, are called.
+// An overlay may also be removed with Delete,
+// in which case dismiss is not called.
+//
+func (c *Canvas) Popup(it Item, dismiss func()) {
+	c.Atomically(func(flush FlushFunc) {
+		it.SetContainer(c)
+		e := c.items.PushBack(it)
+		c.index.insert(e)
+		c.overlays = append(c.overlays, overlay{e, dismiss})
+		flush(it.Bbox(), nil)
 	})
 }
 
+// TopCanvas returns the outermost Canvas reachable
+// from b by following the containers of nested canvases,
+// or nil if b is not a Canvas. As Canvases do not change
+// coordinates, items in the returned canvas share the
+// coordinate space of items in b.
+//
+func TopCanvas(b Backing) *Canvas {
+	c, _ := b.(*Canvas)
+	for c != nil {
+		parent, ok := c.backing.(*Canvas)
+		if !ok {
+			break
+		}
+		c = parent
+	}
+	return c
+}
+
+// outermost returns the outermost Canvas containing b,
+// looking through containers such as Split and BoxLayout,
+// or nil if there is none.
+func outermost(b Backing) *Canvas {
+	var top *Canvas
+	for b != nil {
+		switch c := b.(type) {
+		case *Canvas:
+			top, b = c, c.backing
+		case nestedBacking:
+			b = c.outer()
+		default:
+			b = nil
+		}
+	}
+	return top
+}
+
+// shownDirectly reports whether the image that c is drawn
+// onto is the one that its outermost canvas is shown on,
+// so that c can keep it to draw onto later. It is not if a
+// canvas holding c is zoomed or panned, or c is inside a
+// static layer, as then c is drawn onto another image first.
+func shownDirectly(c *Canvas) bool {
+	for b := c.backing; b != nil; {
+		switch o := b.(type) {
+		case *Canvas:
+			if !o.xform().identity() {
+				return false
+			}
+			b = o.backing
+		case *staticLayer:
+			return false
+		case nestedBacking:
+			b = o.outer()
+		default:
+			b = nil
+		}
+	}
+	return true
+}
+
 func debugp(f string, a ...interface{}) {
 	log.Printf(f, a...)
 }
@@ -0,0 +1,69 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/raster"
+	"image"
+	"math"
+)
+
+// The interfaces below are optional capabilities of items.
+// An editor or a command working on items it knows nothing
+// else about, such as one that drags or aligns the items
+// selected, may find out which changes an item supports
+// with a type assertion and make them through the interface.
+
+// A Mover is an item that can be moved.
+//
+type Mover interface {
+	Move(delta image.Point)
+}
+
+// A Resizer is an item that can be given a new bounding
+// rectangle, changing its size as well as its position.
+//
+type Resizer interface {
+	SetBounds(r image.Rectangle)
+}
+
+// A Transformer is an item whose geometry can be
+// changed by an arbitrary affine transformation.
+//
+type Transformer interface {
+	Transform(m Affine)
+}
+
+// A Colorer is an item whose colour can be changed.
+//
+type Colorer interface {
+	SetFill(fill image.Image)
+}
+
+// MoveItem moves it by delta, if it is a Mover,
+// a MoveableItem or a Resizer, and reports
+// whether it was able to.
+//
+func MoveItem(it Item, delta image.Point) bool {
+	switch it := it.(type) {
+	case Mover:
+		it.Move(delta)
+	case MoveableItem:
+		it.SetCentre(centre(it.Bbox()).Add(delta))
+	case Resizer:
+		it.SetBounds(it.(Item).Bbox().Add(delta))
+	default:
+		return false
+	}
+	return true
+}
+
+// fixed applies m to a fixed point point.
+func (m Affine) fixed(p raster.Point) raster.Point {
+	x, y := m.Transform(float64(p.X)/fixScale, float64(p.Y)/fixScale)
+	return raster.Point{roundFix(x), roundFix(y)}
+}
+
+// pixel applies m to a pixel, rounding the result.
+func (m Affine) pixel(p image.Point) image.Point {
+	x, y := m.Transform(float64(p.X), float64(p.Y))
+	return image.Pt(int(math.Floor(x+0.5)), int(math.Floor(y+0.5)))
+}
@@ -0,0 +1,171 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+)
+
+// A Checkbox shows a labelled box that can be
+// checked and unchecked by clicking on it.
+//
+type Checkbox struct {
+	backing Backing
+	value   values.Value
+	Item
+	c       *Canvas
+	r       image.Rectangle
+	box     Rect
+	mark    *Marker
+	markCol image.Image
+	label   *Text
+	checked bool
+	font    *truetype.Font
+	theme   *Theme
+	pref    image.Point // the size the checkbox was created with.
+}
+
+// minCheckSize is the smallest size of the box of a
+// Checkbox, and of the ring of a RadioGroup option.
+const minCheckSize = 8
+
+// NewCheckbox returns a new Checkbox occupying r, with
+// the box at the left and the label to its right.
+// The value, which should be of type bool, is used to set and get
+// the state of the checkbox; clicking on the checkbox
+// toggles it.
+//
+func NewCheckbox(r image.Rectangle, label string, font *truetype.Font, fg, bg color.Color, value values.Value) *Checkbox {
+	obj := new(Checkbox)
+	obj.value = value
+	obj.backing = NullBacking()
+	obj.r = r
+	obj.pref = r.Size()
+	obj.c = NewCanvas(nil, r)
+	obj.box = *NewRect(image.ZR, &image.Uniform{bg}, 0, nil)
+	obj.markCol = &image.Uniform{fg}
+	obj.mark = NewMarker(MarkerCross, image.Transparent, 1, image.ZP)
+	obj.font = font
+	obj.label = NewText(image.ZP, W, label, font, 12, nil)
+	obj.label.SetFill(&image.Uniform{fg})
+	obj.layout()
+	obj.restyle()
+	obj.c.AddItem(&obj.box)
+	obj.c.AddItem(obj.mark)
+	obj.c.AddItem(obj.label)
+	obj.Item = obj.c
+	go obj.listener()
+	return obj
+}
+
+var _ ResizableItem = (*Checkbox)(nil)
+var _ Sizer = (*Checkbox)(nil)
+
+func (obj *Checkbox) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the checkbox,
+// overriding that of its canvas. If t is nil, the
+// canvas's theme is used.
+//
+func (obj *Checkbox) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+// layout places the box, mark and label within the checkbox.
+func (obj *Checkbox) layout() {
+	r := obj.r
+	size := r.Dy()
+	br := image.Rect(r.Min.X, r.Min.Y, r.Min.X+size, r.Max.Y)
+	obj.box.r = br
+	obj.mark.p = centre(br)
+	obj.mark.setSize(size - 4)
+	obj.label.setPoint(image.Pt(br.Max.X+size/2, centre(r).Y))
+}
+
+// SetBounds moves and resizes the checkbox to occupy r.
+// The box is as high as r, and square.
+//
+func (obj *Checkbox) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		obj.c.r = r
+		obj.layout()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed for the smallest
+// box with the label beside it.
+//
+func (obj *Checkbox) MinSize() image.Point {
+	l := obj.label.Bbox().Size()
+	h := l.Y
+	if h < minCheckSize {
+		h = minCheckSize
+	}
+	return image.Pt(h+h/2+l.X, h)
+}
+
+func (obj *Checkbox) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+func (obj *Checkbox) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	obj.box.setBorder(t.BorderWidth, t.border())
+	t.styleLabel(obj.label, obj.font)
+}
+
+func (obj *Checkbox) listener() {
+	g := obj.value.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		checked := x.(bool)
+		obj.backing.Atomically(func(flush FlushFunc) {
+			if checked == obj.checked {
+				return
+			}
+			obj.checked = checked
+			if checked {
+				obj.mark.fill = obj.markCol
+			} else {
+				obj.mark.fill = image.Transparent
+			}
+			flush(obj.box.r, nil)
+		})
+		obj.backing.Flush()
+	}
+}
+
+// HandleMouse toggles the checkbox when
+// the first mouse button is released over it.
+//
+func (obj *Checkbox) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&1 == 0 {
+		return false
+	}
+	if m := Drag(m, ec, nil); m.Loc.In(obj.r) {
+		var checked bool
+		obj.backing.Atomically(func(_ FlushFunc) {
+			checked = obj.checked
+		})
+		obj.value.Set(!checked)
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"time"
+)
+
+// ClickTime is the longest time between two presses of
+// a mouse button for them to count as a double click.
+//
+var ClickTime = 400 * time.Millisecond
+
+// ClickDist is the furthest the pointer may move, in
+// pixels along either axis, between two presses of a mouse
+// button for them to count as a double click.
+//
+var ClickDist = 4
+
+// clickState records the most recent button press
+// seen by a canvas.
+type clickState struct {
+	n       int // number of presses in quick succession.
+	buttons int
+	p       image.Point
+	t       time.Time
+}
+
+// trackClick counts m if it is a button press following
+// closely on the last one, with the same buttons.
+func (c *Canvas) trackClick(m ui.MouseEvent) {
+	buttons := m.Buttons &^ wheelButtons
+	if buttons == 0 {
+		return
+	}
+	c.Atomically(func(_ FlushFunc) {
+		last := &c.clicks
+		if buttons == last.buttons &&
+			m.Time.Sub(last.t) <= ClickTime &&
+			!moved(last.p, m.Loc, ClickDist) {
+			last.n++
+		} else {
+			last.n = 1
+		}
+		last.buttons = buttons
+		last.p = m.Loc
+		last.t = m.Time
+	})
+}
+
+// ClickCount returns the number of times in quick
+// succession that the buttons of the most recent press
+// delivered to the innermost canvas containing b have been
+// pressed: 1 for a single click, 2 for a double click,
+// 3 for a triple click and so on. It is intended to be
+// called from HandleMouse or a bound MouseHandler,
+// and returns 0 if no press has been seen.
+//
+func ClickCount(b Backing) (n int) {
+	for {
+		switch c := b.(type) {
+		case *Canvas:
+			c.Atomically(func(_ FlushFunc) {
+				n = c.clicks.n
+			})
+			return
+		case nestedBacking:
+			b = c.outer()
+		default:
+			return 0
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package canvas
+
+import (
+	"sync"
+)
+
+// TextFormat is the format of plain text held in a Clipboard.
+//
+const TextFormat = "text/plain;charset=utf-8"
+
+// A Clipboard holds data that has been cut or copied,
+// so that it can be pasted elsewhere. The data may be
+// held in several formats at once, each named by a MIME
+// type such as TextFormat.
+//
+// Get returns the data held in the given format, or
+// false if there is none. Set replaces the contents of
+// the clipboard with data, which maps each format that
+// is offered to the data in that format.
+//
+type Clipboard interface {
+	Get(format string) ([]byte, bool)
+	Set(data map[string][]byte)
+}
+
+// A ClipboardBacking is a Backing that provides access
+// to a clipboard, usually that of the window system.
+//
+type ClipboardBacking interface {
+	Backing
+	Clipboard() Clipboard
+}
+
+// A SelectionBacking is a Backing that provides access to
+// the primary selection of the window system, such as that
+// of X, which holds whatever was last selected, so that it
+// can be pasted without being copied explicitly.
+//
+type SelectionBacking interface {
+	Backing
+	Selection() Clipboard
+}
+
+// localClipboard and localSelection are used by items that
+// are not inside a ClipboardBacking or SelectionBacking,
+// so that data can at least be pasted within the same program.
+var (
+	localClipboard = NewClipboard()
+	localSelection = NewClipboard()
+)
+
+// NewClipboard returns a Clipboard that holds its data in
+// memory, suitable for a backing that has no clipboard
+// of its own, or for use in tests.
+//
+func NewClipboard() Clipboard {
+	return &memClipboard{}
+}
+
+type memClipboard struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (cb *memClipboard) Get(format string) ([]byte, bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	d, ok := cb.data[format]
+	return d, ok
+}
+
+func (cb *memClipboard) Set(data map[string][]byte) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.data = make(map[string][]byte)
+	for f, d := range data {
+		cb.data[f] = append([]byte(nil), d...)
+	}
+}
+
+// ClipboardOf returns the clipboard to be used by an item
+// inside b: that of the innermost ClipboardBacking containing
+// b, looking through canvases and containers such as Split
+// and BoxLayout. If there is none, a clipboard shared by
+// the whole program is returned. It must not be called
+// from within Atomically.
+//
+func ClipboardOf(b Backing) Clipboard {
+	return innermostClipboard(b, localClipboard, func(b Backing) (Clipboard, bool) {
+		if c, ok := b.(ClipboardBacking); ok {
+			return c.Clipboard(), true
+		}
+		return nil, false
+	})
+}
+
+// SelectionOf is like ClipboardOf, but returns the
+// primary selection, from the innermost SelectionBacking.
+//
+func SelectionOf(b Backing) Clipboard {
+	return innermostClipboard(b, localSelection, func(b Backing) (Clipboard, bool) {
+		if c, ok := b.(SelectionBacking); ok {
+			return c.Selection(), true
+		}
+		return nil, false
+	})
+}
+
+// innermostClipboard returns the clipboard found by get
+// in the innermost backing containing b that has one,
+// or local if there is none.
+func innermostClipboard(b Backing, local Clipboard, get func(Backing) (Clipboard, bool)) Clipboard {
+	for {
+		if cb, ok := get(b); ok {
+			if cb != nil {
+				return cb
+			}
+			return local
+		}
+		switch c := b.(type) {
+		case *Canvas:
+			b = c.backing
+		case nestedBacking:
+			b = c.outer()
+		default:
+			return local
+		}
+	}
+}
+
+// ClipboardText returns the plain text held in
+// the clipboard for items inside b.
+//
+func ClipboardText(b Backing) (string, bool) {
+	d, ok := ClipboardOf(b).Get(TextFormat)
+	return string(d), ok
+}
+
+// SetClipboardText replaces the contents of the
+// clipboard for items inside b with the text s.
+//
+func SetClipboardText(b Backing, s string) {
+	ClipboardOf(b).Set(map[string][]byte{TextFormat: []byte(s)})
+}
+
+// SelectionText returns the plain text held in the
+// primary selection for items inside b.
+//
+func SelectionText(b Backing) (string, bool) {
+	d, ok := SelectionOf(b).Get(TextFormat)
+	return string(d), ok
+}
+
+// SetSelectionText makes s the primary selection
+// for items inside b, as when the user selects text.
+//
+func SetSelectionText(b Backing, s string) {
+	SelectionOf(b).Set(map[string][]byte{TextFormat: []byte(s)})
+}
@@ -0,0 +1,296 @@
+package canvas
+
+import (
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// pickerStrip is the width of the hue strip and
+// the height of the swatch in a ColorPicker.
+const pickerStrip = 16
+
+// A ColorPicker allows a colour to be chosen by
+// picking a hue from a vertical strip, and a saturation
+// and value from a square showing all the colours
+// of that hue. The chosen colour is shown in
+// a swatch below.
+//
+type ColorPicker struct {
+	backing Backing
+	value   values.Value
+	r       image.Rectangle
+	h, s, v float64 // the current colour, each in the range [0, 1].
+	square  *image.RGBA
+	strip   *image.RGBA
+	pref    image.Point // the size the picker was created with.
+}
+
+var _ HandlerItem = (*ColorPicker)(nil)
+var _ ResizableItem = (*ColorPicker)(nil)
+var _ Sizer = (*ColorPicker)(nil)
+
+// NewColorPicker returns a new ColorPicker occupying r.
+// The value, which should be of type color.Color,
+// holds the chosen colour; colours chosen with
+// the mouse are of type color.RGBA.
+//
+func NewColorPicker(r image.Rectangle, value values.Value) *ColorPicker {
+	obj := new(ColorPicker)
+	obj.backing = NullBacking()
+	obj.value = value
+	obj.r = r
+	obj.pref = r.Size()
+	obj.makeImages()
+	go obj.listener()
+	return obj
+}
+
+// makeImages makes the images of the hue strip and
+// the square to fit the picker's rectangle.
+func (obj *ColorPicker) makeImages() {
+	obj.strip = image.NewRGBA(obj.stripRect())
+	sr := obj.strip.Bounds()
+	for y := sr.Min.Y; y < sr.Max.Y; y++ {
+		c := hsv2rgb(float64(y-sr.Min.Y)/float64(sr.Dy()), 1, 1)
+		for x := sr.Min.X; x < sr.Max.X; x++ {
+			obj.strip.Set(x, y, c)
+		}
+	}
+	obj.square = image.NewRGBA(obj.squareRect())
+	obj.fillSquare()
+}
+
+// SetBounds moves and resizes the picker to occupy r.
+//
+func (obj *ColorPicker) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		obj.makeImages()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed for a square
+// as large as the width of the hue strip.
+//
+func (obj *ColorPicker) MinSize() image.Point {
+	return image.Pt(2*pickerStrip+4, 2*pickerStrip+4)
+}
+
+func (obj *ColorPicker) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+func (obj *ColorPicker) squareRect() image.Rectangle {
+	return image.Rect(obj.r.Min.X, obj.r.Min.Y, obj.r.Max.X-pickerStrip-4, obj.r.Max.Y-pickerStrip-4)
+}
+
+func (obj *ColorPicker) stripRect() image.Rectangle {
+	return image.Rect(obj.r.Max.X-pickerStrip, obj.r.Min.Y, obj.r.Max.X, obj.r.Max.Y-pickerStrip-4)
+}
+
+func (obj *ColorPicker) swatchRect() image.Rectangle {
+	return image.Rect(obj.r.Min.X, obj.r.Max.Y-pickerStrip, obj.r.Max.X, obj.r.Max.Y)
+}
+
+// fillSquare draws all the colours of the current hue
+// into the square, with saturation increasing to the right
+// and value increasing upwards.
+func (obj *ColorPicker) fillSquare() {
+	r := obj.square.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		v := 1 - float64(y-r.Min.Y)/float64(r.Dy())
+		for x := r.Min.X; x < r.Max.X; x++ {
+			obj.square.Set(x, y, hsv2rgb(obj.h, float64(x-r.Min.X)/float64(r.Dx()), v))
+		}
+	}
+}
+
+func (obj *ColorPicker) listener() {
+	g := obj.value.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		col, _ := x.(color.Color)
+		if col == nil {
+			continue
+		}
+		h, s, v := rgb2hsv(col)
+		obj.backing.Atomically(func(flush FlushFunc) {
+			if s == 0 || v == 0 || math.Abs(h-obj.h) < 1.0/360 {
+				// the hue is undefined, or differs only
+				// by rounding, so keep the old one.
+				h = obj.h
+			}
+			if h != obj.h {
+				obj.h = h
+				obj.fillSquare()
+			}
+			obj.s, obj.v = s, v
+			flush(obj.r, nil)
+		})
+		obj.backing.Flush()
+	}
+}
+
+func (obj *ColorPicker) SetContainer(c Backing) {
+	obj.backing = c
+}
+
+func (obj *ColorPicker) Draw(dst draw.Image, clipr image.Rectangle) {
+	sq, st, sw := obj.squareRect(), obj.stripRect(), obj.swatchRect()
+	draw.Draw(dst, sq.Intersect(clipr), obj.square, sq.Intersect(clipr).Min, draw.Src)
+	draw.Draw(dst, st.Intersect(clipr), obj.strip, st.Intersect(clipr).Min, draw.Src)
+	draw.Draw(dst, sw.Intersect(clipr), &image.Uniform{hsv2rgb(obj.h, obj.s, obj.v)}, image.ZP, draw.Src)
+
+	// mark the current hue with a line across the strip,
+	// and the current saturation and value with a small
+	// square, drawn in black or white to contrast.
+	y := st.Min.Y + int(obj.h*float64(st.Dy()))
+	draw.Draw(dst, image.Rect(st.Min.X, y-1, st.Max.X, y+1).Intersect(clipr), image.Black, image.ZP, draw.Src)
+	p := image.Pt(sq.Min.X+int(obj.s*float64(sq.Dx())), sq.Min.Y+int((1-obj.v)*float64(sq.Dy())))
+	mark := image.Black
+	if obj.v < 0.5 {
+		mark = image.White
+	}
+	mr := image.Rect(p.X-3, p.Y-3, p.X+4, p.Y+4).Intersect(sq)
+	for _, r := range []image.Rectangle{
+		{mr.Min, image.Pt(mr.Max.X, mr.Min.Y+1)},
+		{image.Pt(mr.Min.X, mr.Max.Y-1), mr.Max},
+		{mr.Min, image.Pt(mr.Min.X+1, mr.Max.Y)},
+		{image.Pt(mr.Max.X-1, mr.Min.Y), mr.Max},
+	} {
+		draw.Draw(dst, r.Intersect(clipr), mark, image.ZP, draw.Src)
+	}
+}
+
+func (obj *ColorPicker) Bbox() image.Rectangle {
+	return obj.r
+}
+
+func (obj *ColorPicker) HitTest(p image.Point) bool {
+	return p.In(obj.r)
+}
+
+func (obj *ColorPicker) Opaque() bool {
+	// there is a gap between the parts.
+	return false
+}
+
+// HandleMouse picks the saturation and value, or the hue,
+// under the pointer while the first mouse button is held down.
+//
+func (obj *ColorPicker) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&1 == 0 {
+		return false
+	}
+	sq, st := obj.squareRect(), obj.stripRect()
+	var pick func(p image.Point)
+	switch {
+	case m.Loc.In(sq):
+		pick = func(p image.Point) {
+			s := clamp01(float64(p.X-sq.Min.X) / float64(sq.Dx()))
+			v := clamp01(1 - float64(p.Y-sq.Min.Y)/float64(sq.Dy()))
+			var h float64
+			obj.backing.Atomically(func(_ FlushFunc) {
+				h = obj.h
+			})
+			obj.value.Set(hsv2rgb(h, s, v))
+		}
+	case m.Loc.In(st):
+		pick = func(p image.Point) {
+			h := clamp01(float64(p.Y-st.Min.Y) / float64(st.Dy()))
+			var s, v float64
+			obj.backing.Atomically(func(flush FlushFunc) {
+				// the hue must be set here, as it cannot
+				// be recovered from a grey.
+				if h != obj.h {
+					obj.h = h
+					obj.fillSquare()
+					flush(obj.r, nil)
+				}
+				s, v = obj.s, obj.v
+			})
+			obj.backing.Flush()
+			obj.value.Set(hsv2rgb(h, s, v))
+		}
+	default:
+		waitRelease(m.Buttons, ec)
+		return true
+	}
+	pick(m.Loc)
+	Drag(m, ec, func(m ui.MouseEvent) {
+		pick(m.Loc)
+	})
+	return true
+}
+
+// hsv2rgb returns the colour with the given hue,
+// saturation and value, each in the range [0, 1].
+func hsv2rgb(h, s, v float64) color.RGBA {
+	h = 6 * (h - math.Floor(h))
+	i := int(h)
+	f := h - float64(i)
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	var r, g, b float64
+	switch i {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+	c8 := func(x float64) uint8 {
+		return uint8(x*255 + 0.5)
+	}
+	return color.RGBA{c8(r), c8(g), c8(b), 0xff}
+}
+
+// rgb2hsv returns the hue, saturation and value of col,
+// ignoring any transparency.
+func rgb2hsv(col color.Color) (h, s, v float64) {
+	r32, g32, b32, a32 := col.RGBA()
+	if a32 == 0 {
+		return 0, 0, 0
+	}
+	r := float64(r32) / float64(a32)
+	g := float64(g32) / float64(a32)
+	b := float64(b32) / float64(a32)
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	v = max
+	d := max - min
+	if max == 0 || d == 0 {
+		return 0, 0, v
+	}
+	s = d / max
+	switch max {
+	case r:
+		h = (g - b) / d
+	case g:
+		h = 2 + (b-r)/d
+	default:
+		h = 4 + (r-g)/d
+	}
+	h /= 6
+	if h < 0 {
+		h++
+	}
+	return
+}
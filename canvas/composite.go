@@ -0,0 +1,148 @@
+package canvas
+
+import (
+	"image"
+	"image/draw"
+)
+
+// drawOver is like draw.DrawMask with the Over operator,
+// but has inner loops of its own for the common case of
+// drawing a uniform colour or an *image.RGBA onto an
+// *image.RGBA through an optional *image.Alpha mask,
+// working directly on the pixels rather than converting
+// each one to and from a color.Color.
+func drawOver(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, mask image.Image, mp image.Point) {
+	d, ok := dst.(*image.RGBA)
+	if !ok {
+		draw.DrawMask(dst, r, src, sp, mask, mp, draw.Over)
+		return
+	}
+	var m *image.Alpha
+	if mask != nil {
+		if m, ok = mask.(*image.Alpha); !ok {
+			draw.DrawMask(dst, r, src, sp, mask, mp, draw.Over)
+			return
+		}
+	}
+	switch s := src.(type) {
+	case *image.Uniform:
+		r, sp, mp = clipOver(d, r, nil, sp, m, mp)
+		if !r.Empty() {
+			uniformOver(d, r, s, m, mp)
+		}
+	case *image.RGBA:
+		if s == d {
+			// the areas might overlap.
+			break
+		}
+		r, sp, mp = clipOver(d, r, s, sp, m, mp)
+		if !r.Empty() {
+			rgbaOver(d, r, s, sp, m, mp)
+		}
+	default:
+		draw.DrawMask(dst, r, src, sp, mask, mp, draw.Over)
+	}
+}
+
+// newImageLike returns a new image with bounds r and, where
+// it is one of the standard formats, the same pixel format as
+// img, so that drawing one onto the other need not convert
+// each pixel. Other formats get an *image.RGBA.
+func newImageLike(img image.Image, r image.Rectangle) draw.Image {
+	switch img := img.(type) {
+	case *image.RGBA64:
+		return image.NewRGBA64(r)
+	case *image.NRGBA:
+		return image.NewNRGBA(r)
+	case *image.NRGBA64:
+		return image.NewNRGBA64(r)
+	case *image.Gray:
+		return image.NewGray(r)
+	case *image.Gray16:
+		return image.NewGray16(r)
+	case *image.Paletted:
+		return image.NewPaletted(r, img.Palette)
+	}
+	return image.NewRGBA(r)
+}
+
+// clipOver clips r to the bounds of dst, and of src and
+// mask if they are not nil, adjusting sp and mp to match.
+func clipOver(dst *image.RGBA, r image.Rectangle, src *image.RGBA, sp image.Point, mask *image.Alpha, mp image.Point) (image.Rectangle, image.Point, image.Point) {
+	orig := r.Min
+	r = r.Intersect(dst.Rect)
+	if src != nil {
+		r = r.Intersect(src.Rect.Add(orig.Sub(sp)))
+	}
+	if mask != nil {
+		r = r.Intersect(mask.Rect.Add(orig.Sub(mp)))
+	}
+	d := r.Min.Sub(orig)
+	return r, sp.Add(d), mp.Add(d)
+}
+
+const m16 = 1<<16 - 1
+
+// uniformOver draws the colour of src over r in dst,
+// through mask if it is not nil.
+func uniformOver(dst *image.RGBA, r image.Rectangle, src *image.Uniform, mask *image.Alpha, mp image.Point) {
+	sr, sg, sb, sa := src.C.RGBA()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := dst.PixOffset(r.Min.X, y)
+		mi := 0
+		if mask != nil {
+			mi = mask.PixOffset(mp.X, mp.Y+y-r.Min.Y)
+		}
+		for x := r.Min.X; x < r.Max.X; x, i, mi = x+1, i+4, mi+1 {
+			ma := uint32(m16)
+			if mask != nil {
+				ma = uint32(mask.Pix[mi])
+				if ma == 0 {
+					continue
+				}
+				ma |= ma << 8
+			}
+			over(dst.Pix[i:i+4], sr, sg, sb, sa, ma)
+		}
+	}
+}
+
+// rgbaOver draws src over r in dst, through
+// mask if it is not nil.
+func rgbaOver(dst *image.RGBA, r image.Rectangle, src *image.RGBA, sp image.Point, mask *image.Alpha, mp image.Point) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := dst.PixOffset(r.Min.X, y)
+		si := src.PixOffset(sp.X, sp.Y+y-r.Min.Y)
+		mi := 0
+		if mask != nil {
+			mi = mask.PixOffset(mp.X, mp.Y+y-r.Min.Y)
+		}
+		for x := r.Min.X; x < r.Max.X; x, i, si, mi = x+1, i+4, si+4, mi+1 {
+			ma := uint32(m16)
+			if mask != nil {
+				ma = uint32(mask.Pix[mi])
+				if ma == 0 {
+					continue
+				}
+				ma |= ma << 8
+			}
+			s := src.Pix[si : si+4]
+			sa := uint32(s[3]) * 0x101
+			if sa == 0 {
+				continue
+			}
+			over(dst.Pix[i:i+4], uint32(s[0])*0x101, uint32(s[1])*0x101, uint32(s[2])*0x101, sa, ma)
+		}
+	}
+}
+
+// over composites the premultiplied 16-bit colour
+// (sr, sg, sb, sa), scaled by the 16-bit mask value
+// ma, over the 8-bit pixel d.
+func over(d []uint8, sr, sg, sb, sa, ma uint32) {
+	a := (m16 - (sa * ma / m16)) * 0x101
+	d[0] = uint8((uint32(d[0])*a + sr*ma) / m16 >> 8)
+	d[1] = uint8((uint32(d[1])*a + sg*ma) / m16 >> 8)
+	d[2] = uint8((uint32(d[2])*a + sb*ma) / m16 >> 8)
+	d[3] = uint8((uint32(d[3])*a + sa*ma) / m16 >> 8)
+}
@@ -0,0 +1,78 @@
+package canvas
+
+import (
+	"image"
+)
+
+// damage accumulates the rectangles flushed during
+// one call to Atomically, merging those that overlap
+// so that little area need be redrawn more than once.
+type damage []image.Rectangle
+
+// add adds r to the damage, merging it with
+// any rectangles that it overlaps, as long as
+// the merged rectangle would not be mostly
+// area that has not changed.
+func (d *damage) add(r image.Rectangle) {
+	if r.Empty() {
+		return
+	}
+	rs := *d
+	for i := 0; i < len(rs); {
+		q := rs[i]
+		if q.Overlaps(r) && !wasteful(q, r) {
+			r = r.Union(q)
+			rs[i] = rs[len(rs)-1]
+			rs = rs[:len(rs)-1]
+			// r has grown, so it may now overlap
+			// rectangles that it did not before.
+			i = 0
+			continue
+		}
+		i++
+	}
+	*d = append(rs, r)
+}
+
+// wasteful reports whether the union of r and q
+// has much more area than r and q together.
+func wasteful(r, q image.Rectangle) bool {
+	u := area(r.Union(q))
+	return u > 1024 && u > area(r)+area(q)
+}
+
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
+
+// A Damage accumulates the changed areas of an image, merging
+// those that overlap, as a Background does while drawing. It is
+// intended for backings that send the changes elsewhere, such as
+// over a network, as they are flushed. The zero value is empty.
+// A Damage must not be used by several goroutines at once.
+//
+type Damage struct {
+	rs damage
+}
+
+// Add records that r has changed.
+//
+func (d *Damage) Add(r image.Rectangle) {
+	d.rs.add(r)
+}
+
+// Empty reports whether no area has changed.
+//
+func (d *Damage) Empty() bool {
+	return len(d.rs) == 0
+}
+
+// Take returns the changed areas and empties d. Areas
+// that overlap have been merged, unless the merged area
+// would have been mostly unchanged.
+//
+func (d *Damage) Take() []image.Rectangle {
+	rs := []image.Rectangle(d.rs)
+	d.rs = nil
+	return rs
+}
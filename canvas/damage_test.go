@@ -0,0 +1,88 @@
+package canvas
+
+import (
+	"image"
+	"sort"
+	"testing"
+)
+
+var damageTests = []struct {
+	about string
+	add   []image.Rectangle
+	want  []image.Rectangle
+}{{
+	"empty rectangles are ignored",
+	[]image.Rectangle{image.ZR, image.Rect(5, 5, 5, 10)},
+	nil,
+}, {
+	"separate rectangles are kept apart",
+	[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(20, 0, 30, 10)},
+	[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(20, 0, 30, 10)},
+}, {
+	"touching rectangles do not overlap",
+	[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(10, 0, 20, 10)},
+	[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(10, 0, 20, 10)},
+}, {
+	"overlapping rectangles are merged",
+	[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(5, 5, 15, 15)},
+	[]image.Rectangle{image.Rect(0, 0, 15, 15)},
+}, {
+	"a rectangle inside another is absorbed",
+	[]image.Rectangle{image.Rect(0, 0, 100, 100), image.Rect(10, 10, 20, 20), image.Rect(0, 0, 100, 100)},
+	[]image.Rectangle{image.Rect(0, 0, 100, 100)},
+}, {
+	"a merged rectangle merges with those it grows to overlap",
+	[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(20, 0, 30, 10), image.Rect(5, 0, 25, 10)},
+	[]image.Rectangle{image.Rect(0, 0, 30, 10)},
+}, {
+	"a small union is merged even if mostly unchanged",
+	[]image.Rectangle{image.Rect(0, 10, 30, 11), image.Rect(10, 0, 11, 30)},
+	[]image.Rectangle{image.Rect(0, 0, 30, 30)},
+}, {
+	"a large union that is mostly unchanged is not merged",
+	[]image.Rectangle{image.Rect(0, 100, 1000, 101), image.Rect(500, 0, 501, 1000)},
+	[]image.Rectangle{image.Rect(0, 100, 1000, 101), image.Rect(500, 0, 501, 1000)},
+}}
+
+func TestDamage(t *testing.T) {
+	for _, test := range damageTests {
+		var d Damage
+		for _, r := range test.add {
+			d.Add(r)
+		}
+		if d.Empty() != (len(test.want) == 0) {
+			t.Errorf("%s: Empty returned %v", test.about, d.Empty())
+		}
+		got := d.Take()
+		sort.Sort(byMin(got))
+		sort.Sort(byMin(test.want))
+		if !equalRects(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.about, got, test.want)
+		}
+		if !d.Empty() {
+			t.Errorf("%s: not empty after Take", test.about)
+		}
+	}
+}
+
+// byMin sorts rectangles by their top left corners.
+type byMin []image.Rectangle
+
+func (rs byMin) Len() int      { return len(rs) }
+func (rs byMin) Swap(i, j int) { rs[i], rs[j] = rs[j], rs[i] }
+func (rs byMin) Less(i, j int) bool {
+	a, b := rs[i].Min, rs[j].Min
+	return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+}
+
+func equalRects(a, b []image.Rectangle) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package canvas
+
+import (
+	"code.google.com/p/rog-go/values"
+	"container/list"
+	"image"
+	"image/color"
+)
+
+// SetData attaches x to it, for the application's own use,
+// such as a record of what the item represents, so that it
+// can be found again from the item chosen with the mouse.
+// If x is nil, any data attached to it is removed.
+//
+func (c *Canvas) SetData(it Item, x interface{}) {
+	c.Atomically(func(_ FlushFunc) {
+		if x == nil {
+			delete(c.data, it)
+			return
+		}
+		if c.data == nil {
+			c.data = make(map[Item]interface{})
+		}
+		c.data[it] = x
+	})
+}
+
+// Data returns the data attached to it with SetData,
+// or nil if there is none.
+//
+func (c *Canvas) Data(it Item) (x interface{}) {
+	c.Atomically(func(_ FlushFunc) {
+		x = c.data[it]
+	})
+	return
+}
+
+// DataAt returns the top-most item under p, in the
+// coordinates of the items, that has data attached,
+// and the data, or nil if there is no such item.
+//
+func (c *Canvas) DataAt(p image.Point) (it Item, x interface{}) {
+	c.Atomically(func(_ FlushFunc) {
+		if len(c.data) == 0 {
+			return
+		}
+		c.overlapping(hitRect(p), true, func(e *list.Element) bool {
+			i := e.Value.(Item)
+			d, ok := c.data[i]
+			if ok && i.HitTest(p) {
+				it, x = i, d
+				return false
+			}
+			return true
+		})
+	})
+	return
+}
+
+// Follow calls set with each new value of v, until v is
+// closed, flushing c after each call, so that some property
+// of the items in c follows v as Slider follows its value.
+// The calls are made from a goroutine of their own, outside
+// Atomically, so set may use the methods of the items.
+//
+func (c *Canvas) Follow(v values.Value, set func(x interface{})) {
+	g := v.Getter()
+	go func() {
+		for {
+			x, ok := g.Get()
+			if !ok {
+				return
+			}
+			set(x)
+			c.Flush()
+		}
+	}()
+}
+
+// BindCentre keeps the centre of it at the value of v,
+// which must hold an image.Point.
+//
+func (c *Canvas) BindCentre(it MoveableItem, v values.Value) {
+	c.Follow(v, func(x interface{}) {
+		it.SetCentre(x.(image.Point))
+	})
+}
+
+// BindBounds keeps the bounding rectangle of it at the
+// value of v, which must hold an image.Rectangle.
+//
+func (c *Canvas) BindBounds(it Resizer, v values.Value) {
+	c.Follow(v, func(x interface{}) {
+		it.SetBounds(x.(image.Rectangle))
+	})
+}
+
+// BindFill keeps the fill of it at the value of v, which
+// must hold either an image.Image or a color.Color.
+//
+func (c *Canvas) BindFill(it Colorer, v values.Value) {
+	c.Follow(v, func(x interface{}) {
+		switch x := x.(type) {
+		case image.Image:
+			it.SetFill(x)
+		case color.Color:
+			it.SetFill(&image.Uniform{x})
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package canvas
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LoadText reads a description of items from r, one item to
+// a line, and adds them to c, above any items already there.
+// It is a quicker way to write a scene by hand than the
+// JSON read by Load, and makes the same built-in items.
+// Each line gives the type of an item followed by its
+// attributes, as in
+//
+//	rect rect=0,0,400,300 fill=#ffffff border=2 borderfill=#000000
+//	polygon fill=#ff0000 points=10,10,60,10,35,50 rule=nonzero
+//	line points=0,0,100,100 width=1.5 fill=#0000ff
+//	ellipse at=200,150 radii=40,20 width=2 fill=#00ff00
+//	spline kind=bspline points=0,0,50,80,100,0 width=1 fill=#000000
+//	marker kind=diamond at=30,40 size=7 fill=#000000
+//	image src=foo.png at=10,20 opaque=true
+//	canvas rect=10,10,110,110 fill=#c0c0c0 {
+//		rect rect=20,20,40,40 fill=#ff000080
+//	}
+//
+// Colours are written as #rrggbb or #rrggbbaa, without the
+// alpha premultiplied; a canvas is opaque if its fill is. A line
+// ending in { starts a nested canvas, whose items follow until
+// a line holding only }. Any item may be marked static=true to
+// add it with AddStatic. Blank lines, and those starting with //,
+// are ignored. The image of an image item is obtained by calling
+// open with the name given by src.
+//
+func (c *Canvas) LoadText(r io.Reader, open func(ref string) (image.Image, error)) error {
+	items, err := parseText(r)
+	if err != nil {
+		return err
+	}
+	return loadItems(c, items, open)
+}
+
+// parseText parses a description for LoadText into
+// the form of the items read by Load.
+func parseText(r io.Reader) ([]sceneItem, error) {
+	// stack holds the items of each canvas being read,
+	// innermost last; the outermost is the top level.
+	stack := [][]sceneItem{nil}
+	var canvases []sceneItem
+	lines := bufio.NewScanner(r)
+	for n := 1; lines.Scan(); n++ {
+		line := strings.TrimSpace(lines.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "//"):
+			continue
+		case line == "}":
+			if len(canvases) == 0 {
+				return nil, fmt.Errorf("canvas: line %d: unmatched }", n)
+			}
+			s := canvases[len(canvases)-1]
+			s.Items = stack[len(stack)-1]
+			canvases = canvases[:len(canvases)-1]
+			stack = stack[:len(stack)-1]
+			stack[len(stack)-1] = append(stack[len(stack)-1], s)
+			continue
+		}
+		open := strings.HasSuffix(line, "{")
+		if open {
+			line = strings.TrimSuffix(line, "{")
+		}
+		s, err := parseItem(strings.Fields(line))
+		if err != nil {
+			return nil, fmt.Errorf("canvas: line %d: %v", n, err)
+		}
+		if open {
+			if s.Type != "canvas" {
+				return nil, fmt.Errorf("canvas: line %d: only a canvas can hold items", n)
+			}
+			canvases = append(canvases, s)
+			stack = append(stack, nil)
+			continue
+		}
+		stack[len(stack)-1] = append(stack[len(stack)-1], s)
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	if len(canvases) > 0 {
+		return nil, fmt.Errorf("canvas: missing } at end of description")
+	}
+	return stack[0], nil
+}
+
+// kindNames gives the names of the kinds of
+// splines and markers, and of fill rules.
+var kindNames = map[string]map[string]int{
+	"spline": {
+		"catmullrom": int(CatmullRom),
+		"bspline":    int(BSpline),
+	},
+	"marker": {
+		"plus":     int(MarkerPlus),
+		"cross":    int(MarkerCross),
+		"diamond":  int(MarkerDiamond),
+		"triangle": int(MarkerTriangle),
+		"circle":   int(MarkerCircle),
+		"errorbar": int(MarkerErrorBar),
+	},
+	"polygon": {
+		"evenodd": int(EvenOdd),
+		"nonzero": int(NonZero),
+	},
+}
+
+// parseItem parses the fields of one line of a description.
+func parseItem(fields []string) (s sceneItem, err error) {
+	if len(fields) == 0 {
+		return s, fmt.Errorf("no item type")
+	}
+	s.Type = fields[0]
+	switch s.Type {
+	case "rect", "image", "polygon", "line", "ellipse", "spline", "marker", "canvas":
+	default:
+		return s, fmt.Errorf("unknown item type %q", s.Type)
+	}
+	var at []float64
+	for _, f := range fields[1:] {
+		i := strings.Index(f, "=")
+		if i < 0 {
+			return s, fmt.Errorf("attribute %q has no value", f)
+		}
+		key, val := f[:i], f[i+1:]
+		switch key {
+		case "fill":
+			s.Fill, err = parseColour(val)
+		case "borderfill":
+			s.BorderFill, err = parseColour(val)
+		case "border":
+			s.Border, err = strconv.Atoi(val)
+		case "width":
+			s.Width, err = strconv.ParseFloat(val, 64)
+		case "size":
+			s.Size, err = strconv.Atoi(val)
+		case "src":
+			s.Image = val
+		case "rect":
+			var r []float64
+			if r, err = parseNumbers(val, 4); err == nil {
+				s.Rect = []int{int(r[0]), int(r[1]), int(r[2]), int(r[3])}
+			}
+		case "at", "centre", "center":
+			at, err = parseNumbers(val, 2)
+		case "points":
+			var p []float64
+			if p, err = parseNumbers(val, -2); err == nil {
+				for j := 0; j < len(p); j += 2 {
+					s.Points = append(s.Points, [2]float64{p[j], p[j+1]})
+				}
+			}
+		case "radius":
+			var r float64
+			if r, err = strconv.ParseFloat(val, 64); err == nil {
+				s.Radii = []float64{r, r}
+			}
+		case "radii", "errorbar":
+			s.Radii, err = parseNumbers(val, 2)
+		case "kind", "rule":
+			k, ok := kindNames[s.Type][val]
+			if !ok {
+				return s, fmt.Errorf("unknown %s %s %q", s.Type, key, val)
+			}
+			s.Kind = k
+		case "opaque":
+			s.Opaque, err = strconv.ParseBool(val)
+		case "static":
+			s.Static, err = strconv.ParseBool(val)
+		default:
+			return s, fmt.Errorf("unknown attribute %q", key)
+		}
+		if err != nil {
+			return s, fmt.Errorf("bad %s %q", key, val)
+		}
+	}
+	switch s.Type {
+	case "image":
+		if at != nil {
+			x, y := int(at[0]), int(at[1])
+			s.Rect = []int{x, y, x, y}
+		}
+		if s.Rect == nil {
+			s.Rect = make([]int, 4)
+		}
+	case "ellipse", "marker":
+		if at != nil {
+			s.Points = [][2]float64{{at[0], at[1]}}
+		}
+		if s.Type == "marker" && s.Radii == nil {
+			s.Radii = []float64{float64(s.Size / 2), float64(s.Size / 2)}
+		}
+	}
+	return s, nil
+}
+
+// parseNumbers parses a list of numbers separated by commas.
+// If n is positive, there must be exactly n of them;
+// if it is negative, the count must be a multiple of -n.
+func parseNumbers(s string, n int) ([]float64, error) {
+	fields := strings.Split(s, ",")
+	if n > 0 && len(fields) != n || n < 0 && len(fields)%-n != 0 {
+		return nil, fmt.Errorf("wrong number of values")
+	}
+	nums := make([]float64, len(fields))
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = x
+	}
+	return nums, nil
+}
+
+// parseColour parses a colour written as #rrggbb or
+// #rrggbbaa into the form used by Save, or "none"
+// for no fill.
+func parseColour(s string) (string, error) {
+	switch {
+	case s == "none":
+		return "", nil
+	case len(s) == 7:
+		s += "ff"
+	case len(s) != 9:
+		return "", fmt.Errorf("bad colour")
+	}
+	if _, err := loadFill(s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
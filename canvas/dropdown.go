@@ -0,0 +1,269 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+	"reflect"
+)
+
+// dropdownRows is the maximum number of rows
+// shown at once in a Dropdown's list.
+const dropdownRows = 8
+
+// A Dropdown shows the currently selected one of a set of
+// options; clicking on it pops up a list of all the options
+// from which another may be chosen.
+//
+type Dropdown struct {
+	backing Backing
+	value   values.Value
+	Item
+	c        *Canvas
+	r        image.Rectangle
+	options  []string
+	label    *Text
+	font     *truetype.Font
+	fg, bg   color.Color
+	selected int
+	byName   bool
+	popup    *ListBox // the list, while it is shown.
+	frame    *Rect
+	arrow    *Polygon
+	theme    *Theme
+	pref     image.Point // the size the dropdown was created with.
+}
+
+// NewDropdown returns a new Dropdown occupying r, allowing
+// a choice between the given options.
+// The value holds the currently selected option; if its type
+// is string, it holds the option's text, otherwise it should
+// be of type int, and holds the option's index.
+//
+// The list is shown as an overlay (see Canvas.Popup) on the
+// outermost canvas containing the Dropdown, below it if
+// there is room, otherwise above.
+//
+func NewDropdown(r image.Rectangle, options []string, font *truetype.Font, fg, bg color.Color, value values.Value) *Dropdown {
+	obj := new(Dropdown)
+	obj.value = value
+	obj.backing = NullBacking()
+	obj.r = r
+	obj.pref = r.Size()
+	obj.options = append([]string(nil), options...)
+	obj.byName = value.Type().Kind() == reflect.String
+	obj.selected = -1
+	obj.font = font
+	obj.fg, obj.bg = fg, bg
+	obj.c = NewCanvas(nil, r)
+	obj.frame = NewRect(r, &image.Uniform{bg}, 0, nil)
+	obj.c.AddItem(obj.frame)
+	obj.arrow = NewPolygon(&image.Uniform{fg}, obj.arrowPoints())
+	obj.c.AddItem(obj.arrow)
+	obj.label = NewText(image.ZP, W, "", font, 12, nil)
+	obj.label.SetFill(&image.Uniform{fg})
+	obj.c.AddItem(obj.label)
+	obj.restyle()
+	obj.Item = obj.c
+	go obj.listener()
+	return obj
+}
+
+var _ HandlerItem = (*Dropdown)(nil)
+var _ ResizableItem = (*Dropdown)(nil)
+var _ Sizer = (*Dropdown)(nil)
+
+// arrowPoints returns the vertices of the triangle
+// drawn at the right of the dropdown.
+func (obj *Dropdown) arrowPoints() []image.Point {
+	r := obj.r
+	h := r.Dy()
+	ar := image.Rect(r.Max.X-h, r.Min.Y, r.Max.X, r.Max.Y).Inset(h / 3)
+	return []image.Point{ar.Min, {ar.Max.X, ar.Min.Y}, {centre(ar).X, ar.Max.Y}}
+}
+
+func (obj *Dropdown) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the dropdown and
+// its list, overriding that of its canvas. If t is nil,
+// the canvas's theme is used.
+//
+func (obj *Dropdown) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Dropdown) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	obj.frame.setBorder(t.BorderWidth, t.border())
+	t.styleLabel(obj.label, obj.font)
+	obj.label.setPoint(image.Pt(obj.r.Min.X+t.Padding, centre(obj.r).Y))
+}
+
+// SetBounds moves and resizes the dropdown to occupy r.
+// A list that is already shown is not moved.
+//
+func (obj *Dropdown) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		obj.c.r = r
+		obj.frame.r = r
+		obj.arrow.points = pixel2fixPoints(obj.arrowPoints())
+		obj.arrow.makeOutline()
+		obj.restyle()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed to show the
+// currently selected option and the arrow.
+//
+func (obj *Dropdown) MinSize() image.Point {
+	t := themeFor(obj.theme, obj.backing)
+	l := obj.label.Bbox().Size()
+	h := l.Y + 2*t.BorderWidth + t.Padding
+	return image.Pt(l.X+t.Padding+h, h)
+}
+
+func (obj *Dropdown) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+// index returns the index of the option held in x.
+func (obj *Dropdown) index(x interface{}) int {
+	if obj.byName {
+		for i, opt := range obj.options {
+			if opt == x.(string) {
+				return i
+			}
+		}
+		return -1
+	}
+	i := int(reflect.ValueOf(x).Int())
+	if i < 0 || i >= len(obj.options) {
+		return -1
+	}
+	return i
+}
+
+func (obj *Dropdown) listener() {
+	g := obj.value.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		sel := obj.index(x)
+		obj.backing.Atomically(func(flush FlushFunc) {
+			if sel == obj.selected {
+				return
+			}
+			obj.selected = sel
+			r := obj.label.Bbox()
+			if sel >= 0 {
+				obj.label.setText(obj.options[sel])
+			} else {
+				obj.label.setText("")
+			}
+			flush.Rects(nil, r, obj.label.Bbox())
+		})
+		obj.backing.Flush()
+	}
+}
+
+// HandleMouse pops up the list of options when the
+// first mouse button is pressed, or hides it if it
+// is already shown.
+//
+func (obj *Dropdown) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&1 == 0 {
+		return false
+	}
+	top := TopCanvas(obj.backing)
+	if top == nil {
+		return false
+	}
+	var popup *ListBox
+	var sel int
+	var theme *Theme
+	obj.backing.Atomically(func(_ FlushFunc) {
+		popup, sel = obj.popup, obj.selected
+		theme = themeFor(obj.theme, obj.backing)
+	})
+	if popup != nil {
+		top.Delete(popup)
+		obj.closed(popup)
+		waitRelease(m.Buttons, ec)
+		return true
+	}
+	// show the list below the dropdown, or above
+	// it if there is not enough room below.
+	n := len(obj.options)
+	if n > dropdownRows {
+		n = dropdownRows
+	}
+	h := n*listRowHeight + 2*theme.BorderWidth
+	lr := image.Rect(obj.r.Min.X, obj.r.Max.Y, obj.r.Max.X, obj.r.Max.Y+h)
+	if lr.Max.Y > top.Rect().Max.Y && obj.r.Min.Y-h >= top.Rect().Min.Y {
+		lr = lr.Sub(image.Pt(0, h+obj.r.Dy()))
+	}
+	lv := values.NewValue(sel, nil)
+	popup = NewListBox(lr, obj.options, obj.font, obj.fg, obj.bg, lv)
+	// the list is shown on the top canvas, which
+	// may not have the same theme as the dropdown.
+	popup.theme = theme
+	if sel >= 0 {
+		popup.ScrollTo(sel)
+	}
+	obj.backing.Atomically(func(_ FlushFunc) {
+		obj.popup = popup
+	})
+	top.Popup(popup, func() {
+		obj.closed(popup)
+	})
+	go obj.choose(top, popup, lv)
+	waitRelease(m.Buttons, ec)
+	return true
+}
+
+// choose waits for an option to be chosen from
+// the popup list, then sets the value and removes the list.
+func (obj *Dropdown) choose(top *Canvas, popup *ListBox, lv values.Value) {
+	g := lv.Getter()
+	g.Get() // the initial selection.
+	x, ok := g.Get()
+	if !ok {
+		return
+	}
+	top.Delete(popup)
+	obj.closed(popup)
+	if i := x.(int); i >= 0 {
+		if obj.byName {
+			obj.value.Set(obj.options[i])
+		} else {
+			obj.value.Set(reflect.ValueOf(i).Convert(obj.value.Type()).Interface())
+		}
+	}
+}
+
+// closed records that popup is no longer shown.
+func (obj *Dropdown) closed(popup *ListBox) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		if obj.popup == popup {
+			obj.popup = nil
+		}
+	})
+	popup.value.Close()
+}
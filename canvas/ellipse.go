@@ -48,6 +48,10 @@ func NewEllipse(col image.Image, cr image.Point, ra, rb int, width float64) *Ell
 	return obj
 }
 
+func (obj *Ellipse) rasterItem() *RasterItem {
+	return &obj.raster
+}
+
 func (obj *Ellipse) SetContainer(b Backing) {
 	obj.backing = b
 	obj.raster.SetContainer(b)
@@ -149,9 +153,25 @@ func (obj *Ellipse) makeOutline() {
 	obj.raster.CalcBbox()
 }
 
+// Move moves the ellipse by delta, without rasterizing
+// it again if it lies wholly inside its canvas.
+//
 func (obj *Ellipse) Move(delta image.Point) {
-	cr := fix2pixelPoint(obj.cr)
-	obj.SetCentre(cr.Add(delta))
+	obj.MoveFixed(pixel2fixPoint(delta))
+}
+
+// MoveFixed is like Move, but delta is in fixed point,
+// so that the ellipse can be moved by a fraction of a pixel.
+//
+func (obj *Ellipse) MoveFixed(delta raster.Point) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		obj.cr = raster.Point{obj.cr.X + delta.X, obj.cr.Y + delta.Y}
+		if !wholePixels(delta) || !obj.raster.translate(fix2pixelPoint(delta)) {
+			obj.makeOutline()
+		}
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
 }
 
 // SetEndPoints changes the center of the ellipse
@@ -161,11 +181,29 @@ func (obj *Ellipse) SetCentre(p image.Point) {
 		r := obj.raster.Bbox()
 		obj.cr = pixel2fixPoint(p)
 		obj.makeOutline()
-		flush(r, nil)
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+// SetAntialias sets the anti-aliasing used to draw the ellipse.
+// If a is AntialiasDefault, the setting of its canvas is used.
+//
+func (obj *Ellipse) SetAntialias(a Antialias) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetAntialias(a)
 		flush(obj.raster.Bbox(), nil)
 	})
 }
 
+// SetHitThreshold sets the coverage that a pixel of the ellipse
+// must exceed for HitTest to succeed, as for RasterItem.
+//
+func (obj *Ellipse) SetHitThreshold(a uint8) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		obj.raster.SetHitThreshold(a)
+	})
+}
+
 // SetColor changes the colour of the ellipse
 //
 func (obj *Ellipse) SetFill(fill image.Image) {
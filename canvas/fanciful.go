@@ -39,16 +39,10 @@ func (d *dragger) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{})
 		return false
 	}
 	delta := centre(d.Bbox()).Sub(m.Loc)
-	but := m.Buttons
-	for {
-		if m, ok := (<-ec).(ui.MouseEvent); ok {
-			d.SetCentre(m.Loc.Add(delta))
-			f.Flush()
-			if (m.Buttons & but) != but {
-				break
-			}
-		}
-	}
+	Drag(m, ec, func(m ui.MouseEvent) {
+		d.SetCentre(m.Loc.Add(delta))
+		f.Flush()
+	})
 	return true
 }
 
@@ -75,8 +69,7 @@ func (m *mover) SetCentre(p image.Point) {
 		bbox := m.item.Bbox()
 		oldr := bbox.Sub(m.delta)
 		m.delta = centre(bbox).Sub(p)
-		flush(oldr, nil)
-		flush(bbox.Sub(m.delta), nil)
+		flush.Rects(nil, oldr, bbox.Sub(m.delta))
 	})
 	m.backing.Flush()
 }
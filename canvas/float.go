@@ -0,0 +1,230 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/raster"
+	"image"
+)
+
+// A PointF is a point with floating point coordinates, in the
+// same units as an image.Point, for callers such as simulations
+// and plots that work in floating point and should not have
+// to round to whole pixels each time they change an item.
+// The coordinates are held by the items in fixed point, with
+// 8 bits of fraction, so they are exact to 1/256 of a pixel.
+//
+type PointF struct {
+	X, Y float64
+}
+
+// PtF is shorthand for PointF{x, y}.
+//
+func PtF(x, y float64) PointF {
+	return PointF{x, y}
+}
+
+// PointFOf returns p as a PointF.
+//
+func PointFOf(p image.Point) PointF {
+	return PointF{float64(p.X), float64(p.Y)}
+}
+
+// Add returns the vector p+q.
+//
+func (p PointF) Add(q PointF) PointF {
+	return PointF{p.X + q.X, p.Y + q.Y}
+}
+
+// Sub returns the vector p-q.
+//
+func (p PointF) Sub(q PointF) PointF {
+	return PointF{p.X - q.X, p.Y - q.Y}
+}
+
+// Round returns p rounded to the nearest pixel.
+//
+func (p PointF) Round() image.Point {
+	return fix2pixelPoint(p.fixed())
+}
+
+func (p PointF) fixed() raster.Point {
+	return raster.Point{roundFix(p.X), roundFix(p.Y)}
+}
+
+func fix2PointF(p raster.Point) PointF {
+	return PointF{fixed2float(p.X), fixed2float(p.Y)}
+}
+
+func pointF2fixPoints(points []PointF) []raster.Point {
+	rpoints := make([]raster.Point, len(points))
+	for i, p := range points {
+		rpoints[i] = p.fixed()
+	}
+	return rpoints
+}
+
+// minFixPoint returns the smallest X and Y
+// coordinates of points, which must not be empty.
+func minFixPoint(points ...raster.Point) raster.Point {
+	min := points[0]
+	for _, p := range points[1:] {
+		if p.X < min.X {
+			min.X = p.X
+		}
+		if p.Y < min.Y {
+			min.Y = p.Y
+		}
+	}
+	return min
+}
+
+// A MoverF is an item that can be moved
+// by a fraction of a pixel.
+//
+type MoverF interface {
+	MoveF(delta PointF)
+}
+
+// MoveItemF is like MoveItem, but if it is not a MoverF,
+// delta is rounded to the nearest pixel.
+//
+func MoveItemF(it Item, delta PointF) bool {
+	if it, ok := it.(MoverF); ok {
+		it.MoveF(delta)
+		return true
+	}
+	return MoveItem(it, delta.Round())
+}
+
+// NewLineF is like NewLine, but the end points
+// are given in floating point.
+//
+func NewLineF(fill image.Image, p0, p1 PointF, width float64) *Line {
+	obj := NewLine(fill, image.ZP, image.ZP, width)
+	obj.p0 = p0.fixed()
+	obj.p1 = p1.fixed()
+	obj.makeOutline()
+	return obj
+}
+
+// EndPointsF returns the end points of the line.
+//
+func (obj *Line) EndPointsF() (p0, p1 PointF) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		p0, p1 = fix2PointF(obj.p0), fix2PointF(obj.p1)
+	})
+	return
+}
+
+// SetEndPointsF is like SetEndPoints, but the
+// end points are given in floating point.
+//
+func (obj *Line) SetEndPointsF(p0, p1 PointF) {
+	obj.SetEndPointsFixed(p0.fixed(), p1.fixed())
+}
+
+// MoveF is like Move, but delta is in floating point.
+// The line is rasterized again unless delta comes
+// to a whole number of pixels.
+//
+func (obj *Line) MoveF(delta PointF) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.moveFixed(delta.fixed(), flush)
+	})
+}
+
+// SetMinPointF moves the line so that the smallest
+// X and Y coordinates of its end points are p.
+//
+func (obj *Line) SetMinPointF(p PointF) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		min := minFixPoint(obj.p0, obj.p1)
+		q := p.fixed()
+		obj.moveFixed(raster.Point{q.X - min.X, q.Y - min.Y}, flush)
+	})
+}
+
+func (obj *Line) moveFixed(d raster.Point, flush FlushFunc) {
+	r := obj.raster.Bbox()
+	obj.p0 = raster.Point{obj.p0.X + d.X, obj.p0.Y + d.Y}
+	obj.p1 = raster.Point{obj.p1.X + d.X, obj.p1.Y + d.Y}
+	if !wholePixels(d) || !obj.raster.translate(fix2pixelPoint(d)) {
+		obj.makeOutline()
+	}
+	flush.Rects(nil, r, obj.raster.Bbox())
+}
+
+// NewPolygonF is like NewPolygon, but the
+// vertices are given in floating point.
+//
+func NewPolygonF(fill image.Image, points []PointF) *Polygon {
+	obj := NewPolygon(fill, nil)
+	obj.points = pointF2fixPoints(points)
+	return obj
+}
+
+// PointsF returns a copy of the polygon's vertices.
+//
+func (obj *Polygon) PointsF() (points []PointF) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		points = make([]PointF, len(obj.points))
+		for i, p := range obj.points {
+			points[i] = fix2PointF(p)
+		}
+	})
+	return
+}
+
+// SetPointsF is like SetPoints, but the
+// vertices are given in floating point.
+//
+func (obj *Polygon) SetPointsF(points []PointF) {
+	obj.SetPointsFixed(pointF2fixPoints(points))
+}
+
+// MoveF is like Move, but delta is in floating point.
+//
+func (obj *Polygon) MoveF(delta PointF) {
+	obj.MoveFixed(delta.fixed())
+}
+
+// SetMinPointF moves the polygon so that the smallest
+// X and Y coordinates of its vertices are p.
+// It does nothing if the polygon has no vertices.
+//
+func (obj *Polygon) SetMinPointF(p PointF) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		if len(obj.points) == 0 {
+			return
+		}
+		min := minFixPoint(obj.points...)
+		q := p.fixed()
+		obj.moveFixed(raster.Point{q.X - min.X, q.Y - min.Y}, flush)
+	})
+}
+
+// CentreF returns the centre of the ellipse.
+//
+func (obj *Ellipse) CentreF() (p PointF) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		p = fix2PointF(obj.cr)
+	})
+	return
+}
+
+// SetCentreF is like SetCentre, but the
+// centre is given in floating point.
+//
+func (obj *Ellipse) SetCentreF(p PointF) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		obj.cr = p.fixed()
+		obj.makeOutline()
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+// MoveF is like Move, but delta is in floating point.
+//
+func (obj *Ellipse) MoveF(delta PointF) {
+	obj.MoveFixed(delta.fixed())
+}
@@ -0,0 +1,178 @@
+package canvas
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A FrameRecorder captures snapshots of a canvas as it
+// changes, so that they can be written out as an animated
+// GIF or a sequence of PNG files, for making demonstrations
+// or looking for glitches in an animation frame by frame.
+// Each frame is held in memory until the recorder is reset.
+//
+type FrameRecorder struct {
+	c      *Canvas
+	mu     sync.Mutex
+	frames []frame
+	stop   chan bool // non-nil while capturing at a fixed rate.
+}
+
+type frame struct {
+	img *image.RGBA
+	t   time.Time
+}
+
+// NewFrameRecorder returns a new FrameRecorder for c,
+// with no frames captured.
+//
+func NewFrameRecorder(c *Canvas) *FrameRecorder {
+	return &FrameRecorder{c: c}
+}
+
+// Capture adds a snapshot of the canvas as it is now.
+// It must not be called from within Atomically.
+//
+func (fr *FrameRecorder) Capture() {
+	img := fr.c.Snapshot()
+	fr.mu.Lock()
+	fr.frames = append(fr.frames, frame{img, time.Now()})
+	fr.mu.Unlock()
+}
+
+// CaptureFlushes sets whether a frame is captured
+// each time the canvas is flushed.
+//
+func (fr *FrameRecorder) CaptureFlushes(on bool) {
+	c := fr.c
+	c.Atomically(func(_ FlushFunc) {
+		if on {
+			c.recorder = fr
+			atomic.StoreInt32(&c.recording, 1)
+		} else if c.recorder == fr {
+			c.recorder = nil
+			atomic.StoreInt32(&c.recording, 0)
+		}
+	})
+}
+
+// Start starts capturing a frame every interval,
+// until Stop is called.
+//
+func (fr *FrameRecorder) Start(interval time.Duration) {
+	fr.Stop()
+	stop := make(chan bool)
+	fr.mu.Lock()
+	fr.stop = stop
+	fr.mu.Unlock()
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				fr.Capture()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the capturing started by Start.
+//
+func (fr *FrameRecorder) Stop() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if fr.stop != nil {
+		close(fr.stop)
+		fr.stop = nil
+	}
+}
+
+// Len returns the number of frames captured.
+//
+func (fr *FrameRecorder) Len() int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return len(fr.frames)
+}
+
+// Reset discards all the frames captured so far.
+//
+func (fr *FrameRecorder) Reset() {
+	fr.mu.Lock()
+	fr.frames = nil
+	fr.mu.Unlock()
+}
+
+// WriteGIF writes the frames captured so far to w as
+// an animated GIF, each shown for as long as it was
+// before the next was captured. The colours are reduced
+// to a fixed palette, with dithering.
+//
+func (fr *FrameRecorder) WriteGIF(w io.Writer) error {
+	frames := fr.captured()
+	if len(frames) == 0 {
+		return fmt.Errorf("canvas: no frames captured")
+	}
+	var g gif.GIF
+	for i, f := range frames {
+		r := f.img.Bounds()
+		p := image.NewPaletted(r, palette.Plan9)
+		draw.FloydSteinberg.Draw(p, r, f.img, r.Min)
+		// the delay is in hundredths of a second.
+		delay := 10
+		if i+1 < len(frames) {
+			delay = int(frames[i+1].t.Sub(f.t) / (10 * time.Millisecond))
+		}
+		if delay < 1 {
+			delay = 1
+		}
+		g.Image = append(g.Image, p)
+		g.Delay = append(g.Delay, delay)
+	}
+	return gif.EncodeAll(w, &g)
+}
+
+// WritePNGs writes each frame captured so far as a PNG
+// file in dir, named by prefix followed by the frame
+// number, counting from zero, padded to four digits.
+//
+func (fr *FrameRecorder) WritePNGs(dir, prefix string) error {
+	for i, f := range fr.captured() {
+		name := filepath.Join(dir, fmt.Sprintf("%s%04d.png", prefix, i))
+		if err := writePNGFile(name, f.img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fr *FrameRecorder) captured() []frame {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return append([]frame(nil), fr.frames...)
+}
+
+func writePNGFile(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,142 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"time"
+)
+
+// DragThreshold is how far, in pixels along either axis,
+// the pointer must move with a button held down before
+// Recognize counts the movement as a drag.
+//
+var DragThreshold = 3
+
+// Drag reads mouse events from ec until the buttons pressed
+// in m are no longer all held down, calling f, if it is
+// non-nil, with each one, including the one that releases
+// them. It returns that last event. Events other than
+// mouse events are discarded. If several movements are
+// waiting in ec, only the latest is passed to f, so that
+// a drag keeps up with the pointer however slowly f runs.
+// If ec is closed first, Drag returns the last mouse event
+// read, or m if there was none.
+//
+// Drag is intended to be called from HandleMouse
+// with its initial event, to follow the pointer
+// until the buttons are released.
+//
+func Drag(m ui.MouseEvent, ec <-chan interface{}, f func(m ui.MouseEvent)) ui.MouseEvent {
+	but := m.Buttons
+	last := m
+	var pending interface{}
+	for {
+		x := pending
+		if x != nil {
+			pending = nil
+		} else {
+			var ok bool
+			if x, ok = <-ec; !ok {
+				return last
+			}
+		}
+		e, ok := x.(ui.MouseEvent)
+		if !ok {
+			continue
+		}
+		if (e.Buttons & but) == but {
+			e, pending = coalesce(e, ec)
+		}
+		last = e
+		if f != nil {
+			f(e)
+		}
+		if (e.Buttons & but) != but {
+			return e
+		}
+	}
+}
+
+// coalesce returns the latest of the mouse events with the
+// same buttons as m that are already waiting in ec, or m if
+// there are none, and the first waiting event that is not
+// one of them, if any. It does not wait for events.
+func coalesce(m ui.MouseEvent, ec <-chan interface{}) (ui.MouseEvent, interface{}) {
+	for {
+		select {
+		case x, ok := <-ec:
+			if !ok {
+				return m, nil
+			}
+			if e, ok := x.(ui.MouseEvent); ok && e.Buttons == m.Buttons {
+				m = e
+				continue
+			}
+			return m, x
+		default:
+			return m, nil
+		}
+	}
+}
+
+// A Gesture is the kind of gesture found by Recognize.
+type Gesture int
+
+const (
+	GestureClick Gesture = iota // the buttons were released without moving.
+	GestureDrag                 // the pointer moved with the buttons held down.
+	GestureHold                 // the buttons were held down without moving.
+)
+
+// Recognize reads mouse events from ec following the
+// press m, until it can tell which gesture they make.
+// If the buttons pressed in m are released before the
+// pointer moves more than DragThreshold from m.Loc,
+// the gesture is a click; if the pointer moves further
+// first, it is a drag; and if hold is greater than zero,
+// and the buttons are held down without moving for that
+// long, it is a hold. Recognize returns the gesture and
+// the last event read, which is m if no event was read.
+//
+// After a drag or a hold, the buttons are still down,
+// so the caller will usually go on to call Drag.
+// If ec is closed before the gesture is known, it is
+// taken as a drag, which Drag then ends at once.
+//
+func Recognize(m ui.MouseEvent, ec <-chan interface{}, hold time.Duration) (Gesture, ui.MouseEvent) {
+	var timeout <-chan time.Time
+	if hold > 0 {
+		t := time.NewTimer(hold)
+		defer t.Stop()
+		timeout = t.C
+	}
+	last := m
+	for {
+		select {
+		case x, ok := <-ec:
+			if !ok {
+				return GestureDrag, last
+			}
+			e, ok := x.(ui.MouseEvent)
+			if !ok {
+				continue
+			}
+			last = e
+			if (e.Buttons & m.Buttons) != m.Buttons {
+				return GestureClick, e
+			}
+			if moved(m.Loc, e.Loc, DragThreshold) {
+				return GestureDrag, e
+			}
+		case <-timeout:
+			return GestureHold, last
+		}
+	}
+}
+
+// moved reports whether q is further than d from
+// p along either axis.
+func moved(p, q image.Point, d int) bool {
+	v := q.Sub(p)
+	return v.X > d || v.X < -d || v.Y > d || v.Y < -d
+}
@@ -0,0 +1,30 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"testing"
+)
+
+// TestGestureClosed checks that Drag and Recognize
+// return when the event channel is closed.
+func TestGestureClosed(t *testing.T) {
+	press := ui.MouseEvent{Buttons: 1, Loc: image.Pt(10, 10)}
+	move := ui.MouseEvent{Buttons: 1, Loc: image.Pt(12, 10)}
+	ec := make(chan interface{}, 2)
+	ec <- move
+	close(ec)
+	var seen []ui.MouseEvent
+	if e := Drag(press, ec, func(m ui.MouseEvent) { seen = append(seen, m) }); e != move {
+		t.Errorf("Drag returned %v, want %v", e, move)
+	}
+	if len(seen) != 1 || seen[0] != move {
+		t.Errorf("Drag called f with %v, want [%v]", seen, move)
+	}
+
+	ec = make(chan interface{})
+	close(ec)
+	if g, e := Recognize(press, ec, 0); g != GestureDrag || e != press {
+		t.Errorf("Recognize returned %v, %v; want %v, %v", g, e, GestureDrag, press)
+	}
+}
@@ -0,0 +1,223 @@
+// The golden package helps tests of canvas items check what the
+// items draw, by comparing it with a stored "golden" image, so that
+// changes to the rendering code can be made without changing what
+// is drawn by accident. Small differences, such as those that
+// different rounding makes at anti-aliased edges, can be allowed.
+//
+// For example, in a test:
+//
+//	func TestButton(t *testing.T) {
+//		img := golden.Render(image.Rect(0, 0, 100, 40), image.White, func(c *canvas.Canvas) {
+//			c.AddItem(canvas.NewButton(...))
+//		})
+//		golden.Check(t, "testdata/button.png", img, golden.Options{Tolerance: 2, Fuzz: 1})
+//	}
+//
+// Running the tests with the flag -golden.update writes the
+// images drawn as the golden images, rather than checking them.
+//
+package golden
+
+import (
+	"code.google.com/p/rog-go/canvas"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"strings"
+)
+
+var update = flag.Bool("golden.update", false, "write the images drawn as golden images, rather than checking them")
+
+// Options says how closely an image must
+// match its golden image.
+//
+type Options struct {
+	// Tolerance is the largest difference, from 0 to 255, in
+	// each of red, green, blue and alpha, that two pixels may
+	// have and still be counted as the same.
+	Tolerance int
+
+	// Fuzz is the distance, in pixels, that a pixel may be
+	// from one that it matches in the other image, so that edges
+	// that have moved slightly, as anti-aliasing may make them,
+	// still match. A pixel matches only if it matches one near
+	// it in the other image, and the pixel in its place in the
+	// other image matches one near it in this one.
+	Fuzz int
+
+	// MaxBad is the number of pixels that may
+	// fail to match before the images differ.
+	MaxBad int
+}
+
+// A Tester is the part of testing.T used by Check.
+//
+type Tester interface {
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// Render returns what is drawn in an image of bounds r, with
+// bg drawn behind it, by a canvas that fills the image, once
+// f has added items to it and the canvas has been flushed.
+// It needs no window system.
+//
+func Render(r image.Rectangle, bg image.Image, f func(c *canvas.Canvas)) *image.RGBA {
+	c, b := canvas.NewImageCanvas(r, bg)
+	f(c)
+	return b.Snapshot()
+}
+
+// Check checks that img matches the golden image stored in the
+// PNG file, reporting an error to t if not, and writing, next
+// to the file, img, with ".got" before the file's extension,
+// and an image showing where they differ, with ".diff". If the
+// -golden.update flag is set, img is written to file instead.
+//
+func Check(t Tester, file string, img image.Image, opt Options) {
+	if *update {
+		if err := writePNG(file, img); err != nil {
+			t.Fatalf("cannot update golden image: %v", err)
+		}
+		return
+	}
+	want, err := readPNG(file)
+	if err != nil {
+		writePNG(siblingFile(file, "got"), img)
+		t.Fatalf("cannot read golden image: %v (run with -golden.update to create it)", err)
+		return
+	}
+	diff, bad := Compare(img, want, opt)
+	if diff == nil {
+		writePNG(siblingFile(file, "got"), img)
+		t.Errorf("%s: image has bounds %v; golden image has %v", file, img.Bounds(), want.Bounds())
+		return
+	}
+	if bad <= opt.MaxBad {
+		return
+	}
+	got, diffFile := siblingFile(file, "got"), siblingFile(file, "diff")
+	if err := writePNG(got, img); err != nil {
+		t.Errorf("cannot write image: %v", err)
+	}
+	if err := writePNG(diffFile, diff); err != nil {
+		t.Errorf("cannot write difference: %v", err)
+	}
+	t.Errorf("%s: %d pixels differ; image written to %s, differences to %s", file, bad, got, diffFile)
+}
+
+// Compare compares got with want, returning the number of pixels
+// that do not match, as described by opt (whose MaxBad is ignored),
+// and an image showing them: want, faded to grey, with the pixels
+// that do not match in red. If the images have different bounds,
+// Compare returns a nil image.
+//
+func Compare(got, want image.Image, opt Options) (diff *image.RGBA, bad int) {
+	r := want.Bounds()
+	if !got.Bounds().Eq(r) {
+		return nil, 0
+	}
+	g, w := toRGBA(got), toRGBA(want)
+	diff = image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if matchNear(g, w, x, y, opt) && matchNear(w, g, x, y, opt) {
+				diff.SetRGBA(x, y, faded(w.RGBAAt(x, y)))
+				continue
+			}
+			bad++
+			diff.SetRGBA(x, y, color.RGBA{0xff, 0, 0, 0xff})
+		}
+	}
+	return diff, bad
+}
+
+// matchNear reports whether the pixel of a at x, y is
+// within opt.Tolerance of any pixel of b within opt.Fuzz
+// of the same place.
+func matchNear(a, b *image.RGBA, x, y int, opt Options) bool {
+	c := a.RGBAAt(x, y)
+	near := image.Rect(x-opt.Fuzz, y-opt.Fuzz, x+opt.Fuzz+1, y+opt.Fuzz+1).Intersect(b.Rect)
+	for ny := near.Min.Y; ny < near.Max.Y; ny++ {
+		for nx := near.Min.X; nx < near.Max.X; nx++ {
+			if within(c, b.RGBAAt(nx, ny), opt.Tolerance) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func within(c0, c1 color.RGBA, tolerance int) bool {
+	return absdiff(c0.R, c1.R) <= tolerance &&
+		absdiff(c0.G, c1.G) <= tolerance &&
+		absdiff(c0.B, c1.B) <= tolerance &&
+		absdiff(c0.A, c1.A) <= tolerance
+}
+
+func absdiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// faded returns c as a light grey, so that pixels that
+// differ stand out against it, while what the image
+// shows can still be made out.
+func faded(c color.RGBA) color.RGBA {
+	// Composite onto white, then take the
+	// luminance and move it towards white.
+	r := int(c.R) + 0xff - int(c.A)
+	g := int(c.G) + 0xff - int(c.A)
+	b := int(c.B) + 0xff - int(c.A)
+	v := uint8(0xc0 + (299*r+587*g+114*b)/1000/4)
+	return color.RGBA{v, v, v, 0xff}
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Rect, img, rgba.Rect.Min, draw.Src)
+	return rgba
+}
+
+// siblingFile returns file with kind inserted before
+// its extension: siblingFile("x.png", "got") is "x.got.png".
+func siblingFile(file, kind string) string {
+	if i := strings.LastIndex(file, "."); i > strings.LastIndex(file, "/") {
+		return file[:i] + "." + kind + file[i:]
+	}
+	return file + "." + kind
+}
+
+func readPNG(file string) (image.Image, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return img, nil
+}
+
+func writePNG(file string, img image.Image) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
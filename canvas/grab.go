@@ -0,0 +1,39 @@
+package canvas
+
+// A grabState records the item that has grabbed
+// the mouse, and the canvas holding it.
+type grabState struct {
+	h HandleMouser
+	c *Canvas
+}
+
+// GrabMouse makes it, which must be inside c, capture the
+// mouse: every mouse event passed to the outermost canvas
+// containing c is delivered to the HandleMouse method of it,
+// wherever the pointer is and whether or not it hits it,
+// until UngrabMouse is called or it is removed from c.
+// Each event is delivered with its own call, as if it were
+// the first of an interaction, so that the item need not
+// keep hold of the event channel between events.
+//
+// Overlays are not dismissed while the mouse is grabbed,
+// and tooltips and hover events are suspended.
+//
+func (c *Canvas) GrabMouse(it HandleMouser) {
+	top := outermost(c)
+	top.Atomically(func(_ FlushFunc) {
+		top.grab = grabState{it, c}
+	})
+}
+
+// UngrabMouse ends any capture of the mouse
+// by an item inside c.
+//
+func (c *Canvas) UngrabMouse() {
+	top := outermost(c)
+	top.Atomically(func(_ FlushFunc) {
+		if top.grab.c == c {
+			top.grab = grabState{}
+		}
+	})
+}
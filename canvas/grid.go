@@ -0,0 +1,139 @@
+package canvas
+
+import (
+	"image"
+	"image/draw"
+)
+
+// A Grid draws graph-paper style horizontal and vertical lines
+// across a rectangle. Only the lines that fall within
+// the clip rectangle are drawn, so a large grid costs
+// no more to redraw than a small one.
+//
+type Grid struct {
+	backing    Backing
+	r          image.Rectangle
+	spacing    int
+	majorEvery int
+	minor      image.Image
+	major      image.Image
+}
+
+// NewGrid returns a new Grid covering r, with lines spacing pixels
+// apart starting from r.Min. Every majorEvery'th line is drawn
+// with major; the others are drawn with minor. If majorEvery is
+// zero or less, all lines are drawn with minor.
+//
+func NewGrid(r image.Rectangle, spacing, majorEvery int, minor, major image.Image) *Grid {
+	obj := new(Grid)
+	obj.backing = NullBacking()
+	obj.r = r.Canon()
+	obj.setSpacing(spacing, majorEvery)
+	obj.minor = minor
+	obj.major = major
+	return obj
+}
+
+func (obj *Grid) setSpacing(spacing, majorEvery int) {
+	if spacing < 1 {
+		spacing = 1
+	}
+	obj.spacing = spacing
+	obj.majorEvery = majorEvery
+}
+
+func (obj *Grid) SetContainer(b Backing) {
+	obj.backing = b
+}
+
+func (obj *Grid) Draw(dst draw.Image, clipr image.Rectangle) {
+	clipr = clipr.Intersect(obj.r)
+	if clipr.Empty() {
+		return
+	}
+	// Draw minor lines first, so that major lines
+	// are drawn on top where they cross.
+	for pass := 0; pass < 2; pass++ {
+		x0 := obj.r.Min.X + (clipr.Min.X-obj.r.Min.X+obj.spacing-1)/obj.spacing*obj.spacing
+		for x := x0; x < clipr.Max.X; x += obj.spacing {
+			if col := obj.lineFill(x-obj.r.Min.X, pass); col != nil {
+				r := image.Rect(x, clipr.Min.Y, x+1, clipr.Max.Y)
+				draw.DrawMask(dst, r, col, r.Min, nil, image.ZP, draw.Over)
+			}
+		}
+		y0 := obj.r.Min.Y + (clipr.Min.Y-obj.r.Min.Y+obj.spacing-1)/obj.spacing*obj.spacing
+		for y := y0; y < clipr.Max.Y; y += obj.spacing {
+			if col := obj.lineFill(y-obj.r.Min.Y, pass); col != nil {
+				r := image.Rect(clipr.Min.X, y, clipr.Max.X, y+1)
+				draw.DrawMask(dst, r, col, r.Min, nil, image.ZP, draw.Over)
+			}
+		}
+	}
+}
+
+// lineFill returns the image to draw the line at offset d
+// from the grid's origin. It returns nil if the line should
+// not be drawn in the given pass (0 for minor lines, 1 for major).
+func (obj *Grid) lineFill(d, pass int) image.Image {
+	major := obj.isMajor(d)
+	switch {
+	case pass == 0 && !major:
+		return obj.minor
+	case pass == 1 && major:
+		return obj.major
+	}
+	return nil
+}
+
+func (obj *Grid) isMajor(d int) bool {
+	return obj.majorEvery > 0 && (d/obj.spacing)%obj.majorEvery == 0
+}
+
+func (obj *Grid) Bbox() image.Rectangle {
+	return obj.r
+}
+
+// HitTest returns true only if p lies on one of the grid's lines.
+//
+func (obj *Grid) HitTest(p image.Point) bool {
+	if !p.In(obj.r) {
+		return false
+	}
+	d := p.Sub(obj.r.Min)
+	return d.X%obj.spacing == 0 || d.Y%obj.spacing == 0
+}
+
+func (obj *Grid) Opaque() bool {
+	return false
+}
+
+// SetBounds changes the rectangle covered by the grid.
+//
+func (obj *Grid) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		flush.Rects(nil, old, r)
+	})
+}
+
+// SetSpacing changes the spacing of the grid lines
+// and the frequency of major lines.
+//
+func (obj *Grid) SetSpacing(spacing, majorEvery int) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.setSpacing(spacing, majorEvery)
+		flush(obj.r, nil)
+	})
+}
+
+// SetFill changes the images used to draw the minor and major lines.
+//
+func (obj *Grid) SetFill(minor, major image.Image) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.minor = minor
+		obj.major = major
+		flush(obj.r, nil)
+	})
+}
@@ -0,0 +1,183 @@
+package canvas
+
+import (
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/draw"
+)
+
+// A Handle identifies one of the eight resize
+// handles drawn by a Handles item.
+type Handle int
+
+const (
+	HandleNW Handle = iota
+	HandleN
+	HandleNE
+	HandleE
+	HandleSE
+	HandleS
+	HandleSW
+	HandleW
+	numHandles
+)
+
+// A HandleDrag describes the movement of a handle.
+// Delta holds the total distance the handle has been
+// dragged, and Rect holds the target's original bounding
+// box with the edges belonging to the handle moved by Delta.
+// Done is true for the final event of a drag.
+//
+type HandleDrag struct {
+	Handle Handle
+	Delta  image.Point
+	Rect   image.Rectangle
+	Done   bool
+}
+
+// Handles is an item that draws the eight resize handles
+// around another item's bounding box and reports
+// how they are dragged.
+//
+type Handles struct {
+	backing Backing
+	target  Item
+	r       image.Rectangle // rectangle that the handles surround.
+	size    int
+	handles [numHandles]Rect
+	value   values.Value
+}
+
+// NewHandles returns a new Handles item surrounding target.
+// Each handle is a square of the given size, filled with fill.
+// Drags of the handles are reported by setting value,
+// which should be of type HandleDrag.
+// The Handles item does not itself change the target;
+// its owner should do that in response to the value changing,
+// and then call Update.
+//
+func NewHandles(target Item, size int, fill image.Image, value values.Value) *Handles {
+	obj := new(Handles)
+	obj.backing = NullBacking()
+	obj.target = target
+	obj.size = size
+	obj.value = value
+	for i := range obj.handles {
+		obj.handles[i] = *NewRect(image.ZR, fill, 1, image.Black)
+	}
+	obj.r = target.Bbox()
+	obj.layout()
+	return obj
+}
+
+var _ HandlerItem = (*Handles)(nil)
+
+func (obj *Handles) layout() {
+	r := obj.r
+	mid := centre(r)
+	pts := [numHandles]image.Point{
+		HandleNW: r.Min,
+		HandleN:  {mid.X, r.Min.Y},
+		HandleNE: {r.Max.X, r.Min.Y},
+		HandleE:  {r.Max.X, mid.Y},
+		HandleSE: r.Max,
+		HandleS:  {mid.X, r.Max.Y},
+		HandleSW: {r.Min.X, r.Max.Y},
+		HandleW:  {r.Min.X, mid.Y},
+	}
+	for i, p := range pts {
+		min := p.Sub(image.Pt(obj.size/2, obj.size/2))
+		obj.handles[i].r = image.Rectangle{min, min.Add(image.Pt(obj.size, obj.size))}
+	}
+}
+
+// Update moves the handles to surround the target's
+// current bounding box.
+//
+func (obj *Handles) Update() {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.Bbox()
+		obj.r = obj.target.Bbox()
+		obj.layout()
+		flush.Rects(nil, old, obj.Bbox())
+	})
+}
+
+func (obj *Handles) SetContainer(b Backing) {
+	obj.backing = b
+}
+
+func (obj *Handles) Draw(dst draw.Image, clipr image.Rectangle) {
+	for i := range obj.handles {
+		h := &obj.handles[i]
+		if h.r.Overlaps(clipr) {
+			h.Draw(dst, clipr)
+		}
+	}
+}
+
+func (obj *Handles) Bbox() (r image.Rectangle) {
+	for i := range obj.handles {
+		r = r.Union(obj.handles[i].r)
+	}
+	return
+}
+
+func (obj *Handles) HitTest(p image.Point) bool {
+	return obj.handleAt(p) >= 0
+}
+
+func (obj *Handles) Opaque() bool {
+	return false
+}
+
+// handleAt returns the handle at p, or -1 if there is none.
+func (obj *Handles) handleAt(p image.Point) Handle {
+	for i := range obj.handles {
+		if p.In(obj.handles[i].r) {
+			return Handle(i)
+		}
+	}
+	return -1
+}
+
+// resize returns r with the edges that belong to h moved by delta.
+func (h Handle) resize(r image.Rectangle, delta image.Point) image.Rectangle {
+	switch h {
+	case HandleNW, HandleW, HandleSW:
+		r.Min.X += delta.X
+	case HandleNE, HandleE, HandleSE:
+		r.Max.X += delta.X
+	}
+	switch h {
+	case HandleNW, HandleN, HandleNE:
+		r.Min.Y += delta.Y
+	case HandleSW, HandleS, HandleSE:
+		r.Max.Y += delta.Y
+	}
+	return r.Canon()
+}
+
+func (obj *Handles) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&1 == 0 {
+		return false
+	}
+	var h Handle
+	var r0 image.Rectangle
+	obj.backing.Atomically(func(_ FlushFunc) {
+		h = obj.handleAt(m.Loc)
+		r0 = obj.r
+	})
+	if h < 0 {
+		return false
+	}
+	p0 := m.Loc
+	but := m.Buttons
+	Drag(m, ec, func(m ui.MouseEvent) {
+		delta := m.Loc.Sub(p0)
+		done := (m.Buttons & but) != but
+		obj.value.Set(HandleDrag{h, delta, h.resize(r0, delta), done})
+	})
+	return true
+}
@@ -0,0 +1,121 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"container/list"
+	"image"
+)
+
+// A HoverHandler is an item that is told when the pointer
+// enters and leaves it. HandleEnter is called with the
+// first mouse event over the item, and HandleLeave with
+// the first event after it has left, or that is over
+// another HoverHandler above it.
+//
+// The pointer is tracked with the mouse events passed to
+// the canvas's HandleMouse, so an item does not see
+// the pointer leave while some item is handling mouse
+// events itself. The methods are called outside Atomically.
+//
+type HoverHandler interface {
+	HandleEnter(m ui.MouseEvent)
+	HandleLeave(m ui.MouseEvent)
+}
+
+var _ HoverHandler = (*Canvas)(nil)
+
+// hovers reports whether it wants to know when the
+// pointer enters and leaves it.
+func (c *Canvas) hovers(it Item) bool {
+	if _, ok := it.(HoverHandler); ok {
+		return true
+	}
+	b := c.bindings[it]
+	return b != nil && (b.OnEnter != nil || b.OnLeave != nil)
+}
+
+// trackHover tells the items the pointer has left or
+// entered when m is over a different item from the last event.
+func (c *Canvas) trackHover(m ui.MouseEvent) {
+	var old, it Item
+	var ob, ib *MouseHandler
+	var oldMin, itMin image.Point
+	c.Atomically(func(_ FlushFunc) {
+		c.overlapping(hitRect(m.Loc), true, func(e *list.Element) bool {
+			if x := e.Value.(Item); c.hovers(x) && x.HitTest(m.Loc) {
+				it = x
+				return false
+			}
+			return true
+		})
+		if it == c.hover {
+			old, it = nil, nil
+			return
+		}
+		old, c.hover = c.hover, it
+		ob, ib = c.bindings[old], c.bindings[it]
+		if old != nil {
+			oldMin = old.Bbox().Min
+		}
+		if it != nil {
+			itMin = it.Bbox().Min
+		}
+	})
+	leave(old, ob, m, oldMin)
+	enter(it, ib, m, itMin)
+}
+
+// leave tells it, with binding b and top left corner
+// min, that the pointer has left it.
+func leave(it Item, b *MouseHandler, m ui.MouseEvent, min image.Point) {
+	if it == nil {
+		return
+	}
+	if h, ok := it.(HoverHandler); ok {
+		h.HandleLeave(m)
+	}
+	if b != nil && b.OnLeave != nil {
+		m.Loc = m.Loc.Sub(min)
+		b.OnLeave(it, m)
+	}
+}
+
+// enter tells it, with binding b and top left corner
+// min, that the pointer has entered it.
+func enter(it Item, b *MouseHandler, m ui.MouseEvent, min image.Point) {
+	if it == nil {
+		return
+	}
+	if h, ok := it.(HoverHandler); ok {
+		h.HandleEnter(m)
+	}
+	if b != nil && b.OnEnter != nil {
+		m.Loc = m.Loc.Sub(min)
+		b.OnEnter(it, m)
+	}
+}
+
+// HandleEnter does nothing; the items inside c are
+// told that the pointer has entered them when c
+// is passed the event by HandleMouse.
+//
+func (c *Canvas) HandleEnter(m ui.MouseEvent) {
+}
+
+// HandleLeave tells the item in c that the pointer
+// was last over, if any, that it has left.
+//
+func (c *Canvas) HandleLeave(m ui.MouseEvent) {
+	var old Item
+	var b *MouseHandler
+	var min image.Point
+	c.Atomically(func(_ FlushFunc) {
+		m.Loc = c.xform().world(m.Loc)
+		old, c.hover = c.hover, nil
+		if old != nil {
+			b = c.bindings[old]
+			min = old.Bbox().Min
+		}
+	})
+	leave(old, b, m, min)
+}
@@ -0,0 +1,287 @@
+package canvas
+
+import (
+	"container/list"
+	"image"
+	"sort"
+)
+
+// indexThreshold is the number of items a canvas must
+// hold before it starts to keep an index of them.
+// Below that, looking at every item is quick enough.
+const indexThreshold = 64
+
+// indexCell is the width and height of the cells
+// of an itemIndex.
+const indexCell = 64
+
+// maxIndexCells is the largest number of cells an item
+// is entered into. Items covering more than this are
+// kept separately and offered by every query.
+const maxIndexCells = 256
+
+// An itemIndex records which items of a canvas might
+// be found in each cell of a grid laid over it, so
+// that redrawing a small area or finding the item
+// under the pointer need not look at every item.
+//
+// Items with empty bounding boxes are kept with the
+// large ones, so that they are looked at again when
+// anything is flushed.
+//
+// Items do not tell the canvas when their bounding
+// boxes change, but they must flush the area they used
+// to cover, so the index is brought up to date by
+// looking again at the items indexed under every area
+// flushed (see update).
+//
+// A nil *itemIndex ignores all changes.
+type itemIndex struct {
+	l       *list.List
+	entries map[*list.Element]*indexEntry
+	cells   map[image.Point][]*indexEntry
+	large   map[*indexEntry]bool // entries too big or small for the cells.
+	nextz   int
+	zdirty  bool // the z values no longer follow the list.
+}
+
+type indexEntry struct {
+	e *list.Element
+	r image.Rectangle // the bounding box the entry is indexed under.
+	z int             // increases from the bottom of the canvas upwards.
+}
+
+// newItemIndex returns an index of all the items in l.
+func newItemIndex(l *list.List) *itemIndex {
+	x := &itemIndex{
+		l:       l,
+		entries: make(map[*list.Element]*indexEntry),
+		cells:   make(map[image.Point][]*indexEntry),
+		large:   make(map[*indexEntry]bool),
+	}
+	for e := l.Front(); e != nil; e = e.Next() {
+		x.insert(e)
+	}
+	return x
+}
+
+// insert adds the item held in e.
+func (x *itemIndex) insert(e *list.Element) {
+	if x == nil {
+		return
+	}
+	ent := &indexEntry{e: e}
+	if e.Next() == nil {
+		ent.z = x.nextz
+		x.nextz++
+	} else {
+		x.zdirty = true
+	}
+	x.entries[e] = ent
+	x.enter(ent, e.Value.(Item).Bbox())
+}
+
+// delete removes the item held in e.
+func (x *itemIndex) delete(e *list.Element) {
+	if x == nil {
+		return
+	}
+	if ent := x.entries[e]; ent != nil {
+		x.leave(ent)
+		delete(x.entries, e)
+	}
+}
+
+// restacked records that the items in the
+// list have changed order.
+func (x *itemIndex) restacked() {
+	if x == nil {
+		return
+	}
+	x.zdirty = true
+}
+
+// update looks again at the bounding boxes of all
+// the items indexed under any of the rectangles in rs.
+func (x *itemIndex) update(rs []image.Rectangle) {
+	if x == nil {
+		return
+	}
+	seen := make(map[*indexEntry]bool)
+	for _, r := range rs {
+		x.visit(r, func(ent *indexEntry) {
+			seen[ent] = true
+		})
+	}
+	for ent := range seen {
+		if r := ent.e.Value.(Item).Bbox(); r != ent.r {
+			x.leave(ent)
+			x.enter(ent, r)
+		}
+	}
+}
+
+// query returns the elements holding the items that
+// might overlap r, from the bottom of the canvas upwards.
+func (x *itemIndex) query(r image.Rectangle) []*list.Element {
+	if x.zdirty {
+		x.renumber()
+	}
+	var found zorder
+	seen := make(map[*indexEntry]bool)
+	x.visit(r, func(ent *indexEntry) {
+		if !seen[ent] && ent.r.Overlaps(r) {
+			seen[ent] = true
+			found = append(found, ent)
+		}
+	})
+	sort.Sort(found)
+	es := make([]*list.Element, len(found))
+	for i, ent := range found {
+		es[i] = ent.e
+	}
+	return es
+}
+
+// renumber makes the z values follow
+// the order of the list again.
+func (x *itemIndex) renumber() {
+	x.nextz = 0
+	for e := x.l.Front(); e != nil; e = e.Next() {
+		x.entries[e].z = x.nextz
+		x.nextz++
+	}
+	x.zdirty = false
+}
+
+// visit calls f with every entry in the cells
+// overlapping r, and every large entry.
+// An entry may be visited more than once.
+func (x *itemIndex) visit(r image.Rectangle, f func(ent *indexEntry)) {
+	for ent := range x.large {
+		f(ent)
+	}
+	min, max := cellRange(r)
+	if (max.X-min.X)*(max.Y-min.Y) > len(x.cells) {
+		for _, ents := range x.cells {
+			for _, ent := range ents {
+				f(ent)
+			}
+		}
+		return
+	}
+	for p := min; p.Y < max.Y; p.Y++ {
+		for p.X = min.X; p.X < max.X; p.X++ {
+			for _, ent := range x.cells[p] {
+				f(ent)
+			}
+		}
+	}
+}
+
+func (x *itemIndex) enter(ent *indexEntry, r image.Rectangle) {
+	ent.r = r
+	min, max := cellRange(r)
+	if r.Empty() || (max.X-min.X)*(max.Y-min.Y) > maxIndexCells {
+		x.large[ent] = true
+		return
+	}
+	for p := min; p.Y < max.Y; p.Y++ {
+		for p.X = min.X; p.X < max.X; p.X++ {
+			x.cells[p] = append(x.cells[p], ent)
+		}
+	}
+}
+
+func (x *itemIndex) leave(ent *indexEntry) {
+	if x.large[ent] {
+		delete(x.large, ent)
+		return
+	}
+	min, max := cellRange(ent.r)
+	for p := min; p.Y < max.Y; p.Y++ {
+		for p.X = min.X; p.X < max.X; p.X++ {
+			ents := x.cells[p]
+			for i, e := range ents {
+				if e == ent {
+					ents[i] = ents[len(ents)-1]
+					ents = ents[:len(ents)-1]
+					break
+				}
+			}
+			if len(ents) == 0 {
+				delete(x.cells, p)
+			} else {
+				x.cells[p] = ents
+			}
+		}
+	}
+}
+
+// cellRange returns the cells covered by r,
+// from min up to but not including max.
+func cellRange(r image.Rectangle) (min, max image.Point) {
+	min = image.Pt(floorDiv(r.Min.X, indexCell), floorDiv(r.Min.Y, indexCell))
+	max = image.Pt(floorDiv(r.Max.X-1, indexCell)+1, floorDiv(r.Max.Y-1, indexCell)+1)
+	return
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((b - 1 - a) / b)
+	}
+	return a / b
+}
+
+// zorder sorts entries from the bottom upwards.
+type zorder []*indexEntry
+
+func (z zorder) Len() int           { return len(z) }
+func (z zorder) Less(i, j int) bool { return z[i].z < z[j].z }
+func (z zorder) Swap(i, j int)      { z[i], z[j] = z[j], z[i] }
+
+// overlapping calls f with each element of c holding an
+// item that might overlap r, from the top of the canvas
+// downwards if down is true, or from the bottom upwards
+// otherwise, until f returns false. It must be called
+// from within Atomically.
+func (c *Canvas) overlapping(r image.Rectangle, down bool, f func(e *list.Element) bool) {
+	if c.index == nil && c.items.Len() >= indexThreshold {
+		c.index = newItemIndex(&c.items)
+	}
+	if c.index == nil {
+		if down {
+			for e := c.items.Back(); e != nil; e = e.Prev() {
+				if !f(e) {
+					return
+				}
+			}
+		} else {
+			for e := c.items.Front(); e != nil; e = e.Next() {
+				if !f(e) {
+					return
+				}
+			}
+		}
+		return
+	}
+	es := c.index.query(r)
+	if down {
+		for i := len(es) - 1; i >= 0; i-- {
+			if !f(es[i]) {
+				return
+			}
+		}
+	} else {
+		for _, e := range es {
+			if !f(e) {
+				return
+			}
+		}
+	}
+}
+
+// hitRect returns the rectangle holding just p.
+func hitRect(p image.Point) image.Rectangle {
+	return image.Rectangle{p, p.Add(image.Pt(1, 1))}
+}
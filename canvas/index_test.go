@@ -0,0 +1,151 @@
+package canvas
+
+import (
+	"container/list"
+	"image"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// boxItem is an item that draws nothing
+// but has the bounding box r.
+type boxItem struct {
+	name string
+	r    image.Rectangle
+}
+
+func (it *boxItem) Draw(dst draw.Image, clipr image.Rectangle) {}
+func (it *boxItem) SetContainer(b Backing)                     {}
+func (it *boxItem) Bbox() image.Rectangle                      { return it.r }
+func (it *boxItem) HitTest(p image.Point) bool                 { return p.In(it.r) }
+func (it *boxItem) Opaque() bool                               { return false }
+
+// queryNames returns the names of the items
+// that x.query(r) returns, in order.
+func queryNames(x *itemIndex, r image.Rectangle) []string {
+	var names []string
+	for _, e := range x.query(r) {
+		names = append(names, e.Value.(*boxItem).name)
+	}
+	return names
+}
+
+func checkQuery(t *testing.T, x *itemIndex, r image.Rectangle, want ...string) {
+	got := queryNames(x, r)
+	if len(got) != len(want) {
+		t.Errorf("query %v: got %q, want %q", r, got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("query %v: got %q, want %q", r, got, want)
+			return
+		}
+	}
+}
+
+func TestIndexQuery(t *testing.T) {
+	var l list.List
+	l.PushBack(&boxItem{"a", image.Rect(0, 0, 10, 10)})
+	l.PushBack(&boxItem{"b", image.Rect(200, 200, 300, 300)})
+	l.PushBack(&boxItem{"c", image.Rect(5, 5, 70, 70)})
+	l.PushBack(&boxItem{"neg", image.Rect(-100, -100, -90, -90)})
+	l.PushBack(&boxItem{"large", image.Rect(-5000, -5000, 5000, 5000)})
+	x := newItemIndex(&l)
+
+	checkQuery(t, x, image.Rect(0, 0, 1, 1), "a", "large")
+	checkQuery(t, x, image.Rect(8, 8, 9, 9), "a", "c", "large")
+	checkQuery(t, x, image.Rect(65, 65, 250, 250), "b", "c", "large")
+	checkQuery(t, x, image.Rect(-95, -95, -94, -94), "neg", "large")
+	checkQuery(t, x, image.Rect(-89, -89, -1, -1), "large")
+	checkQuery(t, x, image.Rect(6000, 6000, 6001, 6001))
+	checkQuery(t, x, image.Rect(-10000, -10000, 10000, 10000), "a", "b", "c", "neg", "large")
+}
+
+func TestIndexChanges(t *testing.T) {
+	var l list.List
+	a := &boxItem{"a", image.Rect(0, 0, 10, 10)}
+	b := &boxItem{"b", image.Rect(0, 0, 10, 10)}
+	empty := &boxItem{"empty", image.ZR}
+	ea := l.PushBack(a)
+	l.PushBack(b)
+	l.PushBack(empty)
+	x := newItemIndex(&l)
+	checkQuery(t, x, image.Rect(0, 0, 1, 1), "a", "b")
+
+	// An item that has moved is found once the
+	// area it used to cover has been flushed.
+	old := a.r
+	a.r = image.Rect(500, 500, 510, 510)
+	x.update([]image.Rectangle{old})
+	checkQuery(t, x, image.Rect(0, 0, 1, 1), "b")
+	checkQuery(t, x, image.Rect(505, 505, 506, 506), "a")
+
+	// An item with an empty bounding box is looked at
+	// again whatever is flushed.
+	empty.r = image.Rect(1000, 0, 1010, 10)
+	x.update([]image.Rectangle{image.Rect(0, 0, 1, 1)})
+	checkQuery(t, x, image.Rect(1000, 0, 1001, 1), "empty")
+
+	// The order of the results follows the list.
+	a.r = image.Rect(0, 0, 10, 10)
+	x.update([]image.Rectangle{image.Rect(500, 500, 510, 510)})
+	l.MoveToBack(ea)
+	x.restacked()
+	checkQuery(t, x, image.Rect(0, 0, 1, 1), "b", "a")
+	c := &boxItem{"c", image.Rect(0, 0, 1, 1)}
+	x.insert(l.PushBack(c))
+	checkQuery(t, x, image.Rect(0, 0, 1, 1), "b", "a", "c")
+	x.insert(l.PushFront(&boxItem{"d", image.Rect(0, 0, 1, 1)}))
+	checkQuery(t, x, image.Rect(0, 0, 1, 1), "d", "b", "a", "c")
+
+	x.delete(ea)
+	l.Remove(ea)
+	checkQuery(t, x, image.Rect(0, 0, 1, 1), "d", "b", "c")
+}
+
+// TestIndexRandom checks that queries find the same items as
+// looking at every item would, as items move around.
+func TestIndexRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randRect := func() image.Rectangle {
+		p := image.Pt(rnd.Intn(2000)-1000, rnd.Intn(2000)-1000)
+		size := 100
+		if rnd.Intn(20) == 0 {
+			size = 3000
+		}
+		return image.Rectangle{p, p.Add(image.Pt(rnd.Intn(size), rnd.Intn(size)))}
+	}
+	var l list.List
+	var items []*boxItem
+	for i := 0; i < 200; i++ {
+		it := &boxItem{string(rune('a' + i%26)), randRect()}
+		items = append(items, it)
+		l.PushBack(it)
+	}
+	x := newItemIndex(&l)
+	for n := 0; n < 500; n++ {
+		it := items[rnd.Intn(len(items))]
+		old := it.r
+		it.r = randRect()
+		x.update([]image.Rectangle{old})
+
+		r := randRect()
+		var want []*list.Element
+		for e := l.Front(); e != nil; e = e.Next() {
+			if e.Value.(*boxItem).r.Overlaps(r) {
+				want = append(want, e)
+			}
+		}
+		got := x.query(r)
+		if len(got) != len(want) {
+			t.Fatalf("query %v: got %d items, want %d", r, len(got), len(want))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("query %v: item %d is %v, want %v", r, i, got[i].Value, want[i].Value)
+			}
+		}
+	}
+}
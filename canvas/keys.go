@@ -0,0 +1,277 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+)
+
+// Key values for special keys, as delivered in ui.KeyEvent.
+// Ordinary characters represent themselves; these
+// are the X keysyms for keys that have no character.
+// As with the rest of ui.KeyEvent, a negative value
+// indicates that the key has been released.
+//
+const (
+	KeyBackTab  = 0xfe20 // sent by Shift-Tab.
+	KeyTab      = 0xff09
+	KeyReturn   = 0xff0d
+	KeyEscape   = 0xff1b
+	KeyHome     = 0xff50
+	KeyLeft     = 0xff51
+	KeyUp       = 0xff52
+	KeyRight    = 0xff53
+	KeyDown     = 0xff54
+	KeyPageUp   = 0xff55
+	KeyPageDown = 0xff56
+	KeyEnd      = 0xff57
+	KeyShiftL   = 0xffe1
+	KeyShiftR   = 0xffe2
+	KeyCtrlL    = 0xffe3
+	KeyCtrlR    = 0xffe4
+	KeyAltL     = 0xffe9
+	KeyAltR     = 0xffea
+)
+
+// Modifiers holds the state of the modifier keys
+// as a set of bits.
+//
+type Modifiers int
+
+const (
+	ModShift Modifiers = 1 << iota
+	ModCtrl
+	ModAlt
+)
+
+// modifierKeys maps each modifier key to its bit.
+var modifierKeys = map[int]Modifiers{
+	KeyShiftL: ModShift,
+	KeyShiftR: ModShift,
+	KeyCtrlL:  ModCtrl,
+	KeyCtrlR:  ModCtrl,
+	KeyAltL:   ModAlt,
+	KeyAltR:   ModAlt,
+}
+
+// trackModifiers records the press or release of a
+// modifier key. The left and right keys are not
+// distinguished, so releasing either clears the bit.
+func (c *Canvas) trackModifiers(k ui.KeyEvent) {
+	key := k.Key
+	if key < 0 {
+		key = -key
+	}
+	mod, ok := modifierKeys[key]
+	if !ok {
+		return
+	}
+	c.Atomically(func(_ FlushFunc) {
+		if k.Key > 0 {
+			c.mods |= mod
+		} else {
+			c.mods &^= mod
+		}
+	})
+}
+
+// ModifiersOf returns the modifier keys held down, as seen
+// by the key events passed to the outermost canvas
+// containing b. It is intended to be called from
+// HandleMouse or a bound MouseHandler, so that, for
+// instance, a shift-click can extend a selection;
+// it must not be called from within Atomically.
+//
+func ModifiersOf(b Backing) (mods Modifiers) {
+	top := outermost(b)
+	if top == nil {
+		return 0
+	}
+	top.Atomically(func(_ FlushFunc) {
+		mods = top.mods
+	})
+	return
+}
+
+// HandleKeyer can be implemented by any object
+// that wishes to receive keyboard events.
+// HandleKey is called with each key event while the
+// object has the keyboard focus, and should
+// return true if the event was absorbed.
+//
+type HandleKeyer interface {
+	HandleKey(f Flusher, k ui.KeyEvent) bool
+}
+
+// focusRingWidth is the width of the ring drawn
+// around the item with the keyboard focus.
+const focusRingWidth = 2
+
+// HandleKey delivers a keyboard event to the item
+// that has the keyboard focus, if any.
+// Any item that implements HandleKeyer may take the focus.
+// An item gains the focus when it absorbs a mouse
+// button press, or when it is reached by pressing Tab,
+// which moves the focus to the next such item in the
+// order they were added to the canvas, or Shift-Tab,
+// which moves it to the previous one. Tab is only used
+// in this way if the item with the focus does not absorb it.
+//
+// A nested canvas passes the focus between its own items
+// before the focus moves on to the next item after it.
+// The item with the focus is drawn with a ring around it.
+//
+func (c *Canvas) HandleKey(_ Flusher, k ui.KeyEvent) bool {
+	c.trackModifiers(k)
+	var focus HandleKeyer
+	c.Atomically(func(_ FlushFunc) {
+		focus = c.focus
+	})
+	if focus != nil && focus.HandleKey(c, k) {
+		return true
+	}
+	switch k.Key {
+	case KeyTab:
+		return c.advanceFocus(false, false)
+	case KeyBackTab:
+		return c.advanceFocus(true, false)
+	}
+	return false
+}
+
+// focusContainer is implemented by items that pass
+// the keyboard focus between the items inside them.
+type focusContainer interface {
+	// enterFocus gives the focus to the first item inside,
+	// or the last if backward is true, and reports
+	// whether there was one.
+	enterFocus(backward bool) bool
+
+	// leaveFocus removes the focus from the items inside.
+	leaveFocus()
+}
+
+func (c *Canvas) enterFocus(backward bool) bool {
+	return c.advanceFocus(backward, true)
+}
+
+func (c *Canvas) leaveFocus() {
+	c.setFocus(nil)
+}
+
+// advanceFocus moves the focus to the next item that can
+// take it after the current focus, or before it if backward
+// is true. If restart is true, the search starts at the
+// first (or last) item instead. It reports whether the focus
+// was moved. Only the outermost canvas wraps around
+// when it reaches the end of its items.
+func (c *Canvas) advanceFocus(backward, restart bool) bool {
+	var items []HandleKeyer
+	cur := -1
+	nested := false
+	c.Atomically(func(_ FlushFunc) {
+		if len(c.overlays) > 0 {
+			// leave the focus with a popup.
+			items = nil
+			return
+		}
+		for e := c.items.Front(); e != nil; e = e.Next() {
+			if k, ok := e.Value.(HandleKeyer); ok {
+				if k == c.focus {
+					cur = len(items)
+				}
+				items = append(items, k)
+			}
+		}
+		switch c.backing.(type) {
+		case *Canvas, nestedBacking:
+			nested = true
+		}
+	})
+	if restart {
+		cur = -1
+	}
+	n := len(items)
+	if n == 0 {
+		return false
+	}
+	if cur < 0 && backward {
+		cur = n
+	}
+	for tries := 0; tries < n; tries++ {
+		if backward {
+			cur--
+		} else {
+			cur++
+		}
+		if cur < 0 || cur >= n {
+			if nested {
+				return false
+			}
+			cur = (cur + n) % n
+		}
+		it := items[cur]
+		if fc, ok := it.(focusContainer); ok && !fc.enterFocus(backward) {
+			continue
+		}
+		c.setFocus(it)
+		return true
+	}
+	return false
+}
+
+// setFocus gives the keyboard focus to it, which
+// may be nil.
+func (c *Canvas) setFocus(it HandleKeyer) {
+	var old HandleKeyer
+	c.Atomically(func(flush FlushFunc) {
+		old = c.changeFocus(it, flush)
+	})
+	c.Flush()
+	unfocus(old, it)
+}
+
+// changeFocus gives the focus to it from within Atomically,
+// flushing the focus rings of the old and new items.
+// It returns the old focus, which should be passed
+// to unfocus after Atomically has returned.
+func (c *Canvas) changeFocus(it HandleKeyer, flush FlushFunc) (old HandleKeyer) {
+	old = c.focus
+	if it == old {
+		return
+	}
+	c.focus = it
+	for _, k := range []HandleKeyer{old, it} {
+		if item, ok := k.(Item); ok {
+			flush(item.Bbox().Inset(-focusRingWidth), nil)
+		}
+	}
+	return
+}
+
+// unfocus removes the focus from the items inside old,
+// if it has lost the focus to it.
+func unfocus(old, it HandleKeyer) {
+	if fc, ok := old.(focusContainer); ok && old != it {
+		fc.leaveFocus()
+	}
+}
+
+// focusRing returns the rectangle around which the focus
+// ring should be drawn, or false if there is none.
+// The ring is drawn by the innermost canvas, and not
+// at all for popups.
+func (c *Canvas) focusRing() (image.Rectangle, bool) {
+	it, ok := c.focus.(Item)
+	if !ok {
+		return image.ZR, false
+	}
+	if _, ok := it.(focusContainer); ok {
+		return image.ZR, false
+	}
+	for _, o := range c.overlays {
+		if o.e.Value.(Item) == it {
+			return image.ZR, false
+		}
+	}
+	return it.Bbox().Inset(-focusRingWidth), true
+}
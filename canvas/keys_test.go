@@ -0,0 +1,36 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// keyRect is a Rect that can take the keyboard focus.
+type keyRect struct {
+	*Rect
+}
+
+func (r keyRect) HandleKey(f Flusher, k ui.KeyEvent) bool { return false }
+
+// TestFocusRingMoves checks that the focus ring follows the
+// focused item when it moves, although the item flushes only
+// its own bounding box.
+func TestFocusRingMoves(t *testing.T) {
+	c, b := NewImageCanvas(image.Rect(0, 0, 100, 100), image.White)
+	it := keyRect{NewRect(image.Rect(10, 10, 20, 20), image.Black, 0, nil)}
+	c.AddItem(it)
+	c.setFocus(it)
+	it.SetBounds(image.Rect(50, 50, 60, 60))
+	c.Flush()
+	img := b.Snapshot()
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	if got := img.RGBAAt(9, 9); got != white {
+		t.Errorf("old ring still drawn: got %v at (9, 9)", got)
+	}
+	want := color.RGBAModel.Convert(DefaultTheme.Focus).(color.RGBA)
+	if got := img.RGBAAt(49, 49); got != want {
+		t.Errorf("new ring not drawn: got %v at (49, 49), want %v", got, want)
+	}
+}
@@ -0,0 +1,298 @@
+package canvas
+
+import (
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+	"math"
+)
+
+// A Knob is a rotary control, turned by dragging
+// the pointer around its centre.
+//
+type Knob struct {
+	backing Backing
+	value   values.Value
+	Item
+	c            *Canvas
+	r            image.Rectangle
+	fg           image.Image
+	min, max     float64
+	start, sweep float64 // angular range, in degrees.
+	val          float64 // current value.
+	ticks        []*Line
+	pointer      *Line
+	ring, face   *Marker
+	theme        *Theme
+	pref         image.Point // the size the knob was created with.
+}
+
+// minKnobSize is the smallest size of a Knob
+// that leaves room for the tick marks.
+const minKnobSize = 24
+
+var _ HandlerItem = (*Knob)(nil)
+var _ ResizableItem = (*Knob)(nil)
+var _ Sizer = (*Knob)(nil)
+
+// NewKnob returns a new Knob centred in r, drawn with
+// fg on bg. The value, of type float64, holds the
+// knob's setting; by default it ranges from 0 to 1 over
+// 270 degrees of rotation, from bottom left through the
+// top to bottom right, and eleven tick marks are shown.
+//
+func NewKnob(r image.Rectangle, fg, bg color.Color, value values.Value) *Knob {
+	obj := new(Knob)
+	obj.backing = NullBacking()
+	obj.value = value
+	obj.r = r
+	obj.pref = r.Size()
+	obj.fg = &image.Uniform{fg}
+	obj.min, obj.max = 0, 1
+	obj.start, obj.sweep = 225, 270
+	obj.c = NewCanvas(nil, r)
+	obj.ring = NewMarker(MarkerCircle, image.Black, 1, image.ZP)
+	obj.face = NewMarker(MarkerCircle, &image.Uniform{bg}, 1, image.ZP)
+	obj.c.AddItem(obj.ring)
+	obj.c.AddItem(obj.face)
+	obj.pointer = NewLine(obj.fg, image.ZP, image.ZP, 2)
+	obj.c.AddItem(obj.pointer)
+	obj.placeRing()
+	obj.setTicks(11)
+	obj.movePointer()
+	obj.restyle()
+	obj.Item = obj.c
+	go obj.listener()
+	return obj
+}
+
+func (obj *Knob) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the knob,
+// overriding that of its canvas. If t is nil, the
+// canvas's theme is used.
+//
+func (obj *Knob) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Knob) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	obj.ring.fill = t.border()
+	obj.face.setSize(obj.ring.size - 2*t.BorderWidth)
+}
+
+// placeRing sizes the ring to fit the knob's rectangle.
+// The face inside it is sized by restyle.
+func (obj *Knob) placeRing() {
+	p := centre(obj.r)
+	size := obj.radius() * 2
+	if size%2 == 0 {
+		size--
+	}
+	obj.ring.p = p
+	obj.ring.setSize(size)
+	obj.face.p = p
+}
+
+// SetBounds moves and resizes the knob to be centred in r.
+//
+func (obj *Knob) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		obj.c.r = r
+		obj.placeRing()
+		obj.setTicks(len(obj.ticks))
+		obj.movePointer()
+		obj.restyle()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Knob) MinSize() image.Point {
+	return image.Pt(minKnobSize, minKnobSize)
+}
+
+func (obj *Knob) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+// radius returns the radius of the knob itself;
+// the tick marks lie outside it.
+func (obj *Knob) radius() int {
+	d := obj.r.Dx()
+	if obj.r.Dy() < d {
+		d = obj.r.Dy()
+	}
+	return d/2 - 6
+}
+
+// angle returns the angle of the knob, in radians
+// anticlockwise from three o'clock, for value v.
+func (obj *Knob) angle(v float64) float64 {
+	f := 0.0
+	if obj.max != obj.min {
+		f = clamp01((v - obj.min) / (obj.max - obj.min))
+	}
+	return (obj.start - f*obj.sweep) * math.Pi / 180
+}
+
+// radial returns the point at distance d from the
+// centre of the knob, in the direction a.
+func (obj *Knob) radial(a float64, d float64) image.Point {
+	p := centre(obj.r)
+	return image.Pt(p.X+int(math.Floor(d*math.Cos(a)+0.5)), p.Y-int(math.Floor(d*math.Sin(a)+0.5)))
+}
+
+func (obj *Knob) movePointer() {
+	a := obj.angle(obj.val)
+	obj.pointer.setEndPoints(obj.radial(a, float64(obj.radius())*0.3), obj.radial(a, float64(obj.radius()-2)))
+}
+
+func (obj *Knob) setTicks(n int) {
+	// the caller flushes the whole knob, and may
+	// hold the lock that the inner canvas uses.
+	nop := func(image.Rectangle, Drawer) {}
+	for _, t := range obj.ticks {
+		obj.c.deleteItem(t, nop)
+	}
+	obj.ticks = obj.ticks[:0]
+	rad := float64(obj.radius())
+	for i := 0; i < n; i++ {
+		f := 0.0
+		if n > 1 {
+			f = float64(i) / float64(n-1)
+		}
+		a := (obj.start - f*obj.sweep) * math.Pi / 180
+		t := NewLine(obj.fg, obj.radial(a, rad+2), obj.radial(a, rad+5), 1)
+		obj.ticks = append(obj.ticks, t)
+		obj.c.addItem(t, nop)
+	}
+}
+
+// SetTicks sets the number of tick marks shown
+// around the knob, evenly spaced across its range.
+//
+func (obj *Knob) SetTicks(n int) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.setTicks(n)
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+// SetRange sets the values at the two ends of the
+// knob's rotation.
+//
+func (obj *Knob) SetRange(min, max float64) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.min, obj.max = min, max
+		r := obj.pointer.Bbox()
+		obj.movePointer()
+		flush(r.Union(obj.pointer.Bbox()), nil)
+	})
+	obj.backing.Flush()
+}
+
+// SetAngles sets the angular range of the knob.
+// The minimum value is shown at angle start, in degrees
+// anticlockwise from three o'clock, and the
+// maximum value sweep degrees clockwise from there.
+//
+func (obj *Knob) SetAngles(start, sweep float64) {
+	if sweep <= 0 || sweep > 360 {
+		sweep = 360
+	}
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.start, obj.sweep = start, sweep
+		obj.setTicks(len(obj.ticks))
+		obj.movePointer()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Knob) listener() {
+	g := obj.value.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		v := x.(float64)
+		obj.backing.Atomically(func(flush FlushFunc) {
+			r := obj.pointer.Bbox()
+			obj.val = v
+			obj.movePointer()
+			flush(r.Union(obj.pointer.Bbox()), nil)
+		})
+		obj.backing.Flush()
+	}
+}
+
+// pos2val returns the value corresponding to the
+// direction of p from the centre of the knob.
+// Directions outside the knob's range give the
+// value at the nearest end.
+func (obj *Knob) pos2val(p image.Point) (v float64) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		c := centre(obj.r)
+		a := math.Atan2(float64(c.Y-p.Y), float64(p.X-c.X)) * 180 / math.Pi
+		d := math.Mod(obj.start-a, 360)
+		if d < 0 {
+			d += 360
+		}
+		if d > obj.sweep {
+			if d-obj.sweep < (360-obj.sweep)/2 {
+				d = obj.sweep
+			} else {
+				d = 0
+			}
+		}
+		v = obj.min + d/obj.sweep*(obj.max-obj.min)
+	})
+	return
+}
+
+// HandleMouse sets the knob to point towards the pointer
+// while the first mouse button is held down.
+// The wheel turns the knob by a fiftieth of its range.
+//
+func (obj *Knob) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&(WheelUp|WheelDown) != 0 {
+		var v float64
+		obj.backing.Atomically(func(_ FlushFunc) {
+			d := (obj.max - obj.min) / 50
+			if m.Buttons&WheelDown != 0 {
+				d = -d
+			}
+			lo, hi := obj.min, obj.max
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			v = math.Max(lo, math.Min(hi, obj.val+d))
+		})
+		obj.value.Set(v)
+		return true
+	}
+	if m.Buttons&1 == 0 {
+		return false
+	}
+	obj.value.Set(obj.pos2val(m.Loc))
+	Drag(m, ec, func(m ui.MouseEvent) {
+		obj.value.Set(obj.pos2val(m.Loc))
+	})
+	return true
+}
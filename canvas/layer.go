@@ -0,0 +1,129 @@
+package canvas
+
+import (
+	"image"
+	"image/draw"
+)
+
+// A staticLayer holds the items of a canvas that
+// rarely change. They are drawn, with the canvas
+// background, into an image that is kept from one
+// redraw to the next, so that changes to the items
+// above them need not draw them all again.
+//
+// The layer is the backing of its items, so that
+// it knows when they change.
+type staticLayer struct {
+	c     *Canvas
+	items []Item
+	img   *image.RGBA     // nil until first drawn.
+	stale image.Rectangle // the area of img that must be drawn again.
+}
+
+// AddStatic adds it to the static layer of c, below all
+// the items added with AddItem. The items in the static layer
+// are drawn once with the background of the canvas into an
+// image that is kept, and drawn again only when one of them
+// changes, so a canvas with many items that do not change,
+// such as the grid lines of a chart, can be redrawn quickly
+// as the items above them move.
+//
+// Items in the static layer do not receive mouse events,
+// and are not found by ItemAt. They are removed with Delete.
+//
+func (c *Canvas) AddStatic(it Item) {
+	c.Atomically(func(flush FlushFunc) {
+		if c.static == nil {
+			c.static = &staticLayer{c: c}
+		}
+		l := c.static
+		it.SetContainer(l)
+		l.items = append(l.items, it)
+		r := it.Bbox()
+		l.invalidate(r)
+		flush(r, nil)
+	})
+}
+
+// deleteStatic removes it from the static layer of c.
+// It reports whether it was found.
+func (c *Canvas) deleteStatic(it Item, flush FlushFunc) bool {
+	l := c.static
+	if l == nil {
+		return false
+	}
+	for i, x := range l.items {
+		if x == it {
+			copy(l.items[i:], l.items[i+1:])
+			l.items = l.items[:len(l.items)-1]
+			r := it.Bbox()
+			l.invalidate(r)
+			flush(r, nil)
+			return true
+		}
+	}
+	return false
+}
+
+// invalidate marks r as needing to be drawn again.
+func (l *staticLayer) invalidate(r image.Rectangle) {
+	l.stale = l.stale.Union(r.Intersect(l.c.r))
+}
+
+// draw draws the background of the canvas and the items of
+// the layer onto dst, within clipr, drawing them into
+// the kept image first if they have changed.
+func (l *staticLayer) draw(dst draw.Image, clipr image.Rectangle) {
+	r := l.c.r
+	if l.img == nil || !l.img.Bounds().Eq(r) {
+		freeRGBA(l.img)
+		l.img = newRGBA(r)
+		l.stale = r
+	}
+	if !l.stale.Empty() {
+		s := l.stale
+		draw.Draw(l.img, s, image.Transparent, image.ZP, draw.Src)
+		if l.c.background != nil {
+			l.c.drawBackground(l.img, s)
+		}
+		for _, it := range l.items {
+			if it.Bbox().Overlaps(s) {
+				it.Draw(l.img, s)
+			}
+		}
+		l.stale = image.ZR
+	}
+	drawOver(dst, clipr, l.img, clipr.Min, nil, image.ZP)
+}
+
+// setContainer tells the items of the layer
+// that the canvas holding it has changed.
+func (l *staticLayer) setContainer() {
+	l.img = nil
+	for _, it := range l.items {
+		it.SetContainer(l)
+	}
+}
+
+func (l *staticLayer) Atomically(f func(FlushFunc)) {
+	l.c.Atomically(func(flush FlushFunc) {
+		f(func(r image.Rectangle, _ Drawer) {
+			// if the item has drawn itself, that was
+			// not necessarily into the kept image.
+			l.invalidate(r)
+			flush(r, nil)
+		})
+	})
+}
+
+func (l *staticLayer) Flush() {
+	l.c.Flush()
+}
+
+func (l *staticLayer) Rect() image.Rectangle {
+	return l.c.r
+}
+
+func (l *staticLayer) outer() Backing {
+	return l.c
+}
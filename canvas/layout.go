@@ -0,0 +1,481 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"math"
+)
+
+// A ResizableItem is an item whose bounds can be
+// changed by calling SetBounds.
+//
+type ResizableItem interface {
+	Item
+	Resizer
+}
+
+// A Sizer is an item that can report how big it needs to be.
+// MinSize returns the smallest size at which the item can
+// be drawn sensibly, and PreferredSize the size it would
+// choose for itself, which is never smaller than MinSize.
+// Layout containers call these methods from within
+// Atomically, so they must not call Atomically themselves.
+//
+// The widgets in this package prefer the size of the
+// rectangle they were created with, and also implement
+// ResizableItem, so they can be laid out in a container.
+//
+type Sizer interface {
+	MinSize() image.Point
+	PreferredSize() image.Point
+}
+
+// minSize returns the minimum size of it,
+// or zero if it is not a Sizer.
+func minSize(it Item) image.Point {
+	if s, ok := it.(Sizer); ok {
+		return s.MinSize()
+	}
+	return image.ZP
+}
+
+// preferredSize returns the preferred size of it,
+// or zero if it is not a Sizer.
+func preferredSize(it Item) image.Point {
+	if s, ok := it.(Sizer); ok {
+		return s.PreferredSize()
+	}
+	return image.ZP
+}
+
+// maxPoint returns the larger of each of the
+// coordinates of p and q.
+func maxPoint(p, q image.Point) image.Point {
+	if q.X > p.X {
+		p.X = q.X
+	}
+	if q.Y > p.Y {
+		p.Y = q.Y
+	}
+	return p
+}
+
+// flushBounds flushes the old and new bounds of a
+// widget that has been moved by SetBounds, including
+// the focus ring that may be drawn around it.
+func flushBounds(flush FlushFunc, old, r image.Rectangle) {
+	flush.Rects(nil, old.Inset(-focusRingWidth), r.Inset(-focusRingWidth))
+}
+
+// place fits it into r. If it is a ResizableItem,
+// it is given r as its bounds; otherwise if it is
+// a MoveableItem, it is centred in r.
+// It must not be called from within Atomically,
+// as the item will make its own changes.
+func place(it Item, r image.Rectangle) {
+	switch it := it.(type) {
+	case ResizableItem:
+		it.SetBounds(r)
+	case MoveableItem:
+		it.SetCentre(centre(r))
+	}
+}
+
+// container holds the parts common to the layout
+// containers. The child items are held in an inner
+// canvas whose backing is the container itself.
+type container struct {
+	backing Backing
+	Item
+	self    Item // the item that embeds the container.
+	c       *Canvas
+	r       image.Rectangle
+	padding int
+	items   []Item
+}
+
+func (obj *container) init(self Item, r image.Rectangle) {
+	obj.backing = NullBacking()
+	obj.self = self
+	obj.r = r
+	obj.c = NewCanvas(nil, r)
+	obj.c.SetContainer(obj)
+	obj.Item = obj.c
+}
+
+func (obj *container) SetContainer(b Backing) {
+	obj.backing = b
+	// let the children find their theme again.
+	obj.c.SetContainer(obj)
+}
+
+func (obj *container) Atomically(f func(FlushFunc)) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		f(func(r image.Rectangle, it Drawer) {
+			// anything outside the container is clipped.
+			if r = r.Intersect(obj.r); r.Empty() {
+				return
+			}
+			if it != nil {
+				it = obj.self
+			}
+			flush(r, it)
+		})
+	})
+}
+
+func (obj *container) Rect() image.Rectangle {
+	return obj.r
+}
+
+func (obj *container) Flush() {
+	obj.backing.Flush()
+}
+
+func (obj *container) outer() Backing {
+	return obj.backing
+}
+
+func (obj *container) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	return obj.c.HandleMouse(f, m, ec)
+}
+
+func (obj *container) HandleEnter(m ui.MouseEvent) {
+}
+
+func (obj *container) HandleLeave(m ui.MouseEvent) {
+	obj.c.HandleLeave(m)
+}
+
+func (obj *container) HandleKey(f Flusher, k ui.KeyEvent) bool {
+	return obj.c.HandleKey(f, k)
+}
+
+func (obj *container) enterFocus(backward bool) bool {
+	return obj.c.enterFocus(backward)
+}
+
+func (obj *container) leaveFocus() {
+	obj.c.leaveFocus()
+}
+
+// relayout calculates new bounds for the children with
+// cells, while holding the backing, and then places them.
+func (obj *container) relayout(cells func() []image.Rectangle) {
+	var rs []image.Rectangle
+	var items []Item
+	obj.backing.Atomically(func(_ FlushFunc) {
+		rs = cells()
+		items = append(items, obj.items...)
+	})
+	for i, it := range items {
+		place(it, rs[i])
+	}
+	obj.backing.Flush()
+}
+
+// setBounds changes the bounds of the container,
+// then lays it out again.
+func (obj *container) setBounds(r image.Rectangle, cells func() []image.Rectangle) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r.Canon()
+		obj.c.r = obj.r
+		flush.Rects(nil, old, obj.r)
+	})
+	obj.relayout(cells)
+}
+
+func (obj *container) setPadding(p int, cells func() []image.Rectangle) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		obj.padding = p
+	})
+	obj.relayout(cells)
+}
+
+func (obj *container) add(it Item, cells func() []image.Rectangle) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		obj.items = append(obj.items, it)
+	})
+	obj.c.AddItem(it)
+	obj.relayout(cells)
+}
+
+// A BoxLayout arranges items in a row or a column,
+// resizing and moving them whenever its bounds change.
+// Each item has a natural size along the direction of
+// the box, and a stretch factor that determines what
+// share of any extra space it receives.
+// Items fill the box across its direction.
+// If the box is too small for the natural sizes of its
+// items, items that are Sizers are shrunk no further
+// than their minimum size, as long as there is room.
+//
+type BoxLayout struct {
+	container
+	vertical bool
+	sizes    []int
+	stretch  []float64
+}
+
+var _ ResizableItem = (*BoxLayout)(nil)
+var _ Sizer = (*BoxLayout)(nil)
+var _ HandlerItem = (*BoxLayout)(nil)
+var _ Backing = (*BoxLayout)(nil)
+
+// NewHBox returns a new BoxLayout occupying r,
+// that arranges its items from left to right.
+//
+func NewHBox(r image.Rectangle) *BoxLayout {
+	return NewBoxLayout(r, Horizontal)
+}
+
+// NewVBox returns a new BoxLayout occupying r,
+// that arranges its items from top to bottom.
+//
+func NewVBox(r image.Rectangle) *BoxLayout {
+	return NewBoxLayout(r, Vertical)
+}
+
+// NewBoxLayout returns a new BoxLayout occupying r,
+// that arranges its items in the given orientation.
+//
+func NewBoxLayout(r image.Rectangle, o Orientation) *BoxLayout {
+	obj := new(BoxLayout)
+	obj.init(obj, r)
+	obj.vertical = o == Vertical
+	return obj
+}
+
+// Add adds it to the end of the box, with the given
+// natural size and stretch factor. An item with a stretch
+// factor of zero keeps its natural size unless
+// the box is too small for all its items.
+// If size is zero and it is a Sizer, its preferred
+// size is used as its natural size.
+//
+func (obj *BoxLayout) Add(it Item, size int, stretch float64) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		obj.sizes = append(obj.sizes, size)
+		obj.stretch = append(obj.stretch, stretch)
+	})
+	obj.add(it, obj.cells)
+}
+
+// SetPadding sets the space left between adjacent
+// items, and between the items and the edge of the box.
+//
+func (obj *BoxLayout) SetPadding(p int) {
+	obj.setPadding(p, obj.cells)
+}
+
+// SetBounds changes the rectangle occupied by
+// the box, and lays out its items again.
+//
+func (obj *BoxLayout) SetBounds(r image.Rectangle) {
+	obj.setBounds(r, obj.cells)
+}
+
+// along returns the component of p along the box.
+func (obj *BoxLayout) along(p image.Point) int {
+	if obj.vertical {
+		return p.Y
+	}
+	return p.X
+}
+
+// across returns the component of p across the box.
+func (obj *BoxLayout) across(p image.Point) int {
+	if obj.vertical {
+		return p.X
+	}
+	return p.Y
+}
+
+// natural returns the natural size of the item with index i.
+func (obj *BoxLayout) natural(i int) int {
+	if size := obj.sizes[i]; size > 0 {
+		return size
+	}
+	return obj.along(preferredSize(obj.items[i]))
+}
+
+// minimum returns the size below which the item
+// with index i should not be shrunk.
+func (obj *BoxLayout) minimum(i int) int {
+	min := obj.along(minSize(obj.items[i]))
+	if n := obj.natural(i); min > n {
+		min = n
+	}
+	return min
+}
+
+func (obj *BoxLayout) MinSize() image.Point {
+	return obj.size(obj.minimum, minSize)
+}
+
+func (obj *BoxLayout) PreferredSize() image.Point {
+	return maxPoint(obj.size(obj.natural, preferredSize), obj.MinSize())
+}
+
+// size returns the size of the box when each item has
+// the size given by along in the direction of the box,
+// and by across in the other direction.
+func (obj *BoxLayout) size(along func(int) int, across func(Item) image.Point) image.Point {
+	pad := obj.padding
+	l := pad
+	w := 0
+	for i, it := range obj.items {
+		l += along(i) + pad
+		if a := obj.across(across(it)); a > w {
+			w = a
+		}
+	}
+	w += 2 * pad
+	if obj.vertical {
+		return image.Pt(w, l)
+	}
+	return image.Pt(l, w)
+}
+
+func (obj *BoxLayout) cells() []image.Rectangle {
+	n := len(obj.items)
+	pad := obj.padding
+	r := obj.r.Inset(pad)
+	min, max := r.Min.X, r.Max.X
+	if obj.vertical {
+		min, max = r.Min.Y, r.Max.Y
+	}
+	sizes := make([]float64, n)
+	mins := make([]float64, n)
+	total := 0.0
+	totalMin := 0.0
+	totalStretch := 0.0
+	for i := range obj.items {
+		sizes[i] = float64(obj.natural(i))
+		mins[i] = float64(obj.minimum(i))
+		total += sizes[i]
+		totalMin += mins[i]
+		totalStretch += obj.stretch[i]
+	}
+	avail := float64(max - min - pad*(n-1))
+	extra := avail - total
+	for i := range sizes {
+		switch {
+		case extra > 0 && totalStretch > 0:
+			sizes[i] += extra * obj.stretch[i] / totalStretch
+		case extra < 0 && -extra <= total-totalMin:
+			// take the space from the items in proportion
+			// to how far they are above their minimum size.
+			sizes[i] += extra * (sizes[i] - mins[i]) / (total - totalMin)
+		case extra < 0 && totalMin > 0:
+			// not enough room even for the minimum sizes:
+			// shrink those in proportion.
+			sizes[i] = math.Max(0, avail*mins[i]/totalMin)
+		case extra < 0 && total > 0:
+			sizes[i] = math.Max(0, avail*sizes[i]/total)
+		}
+	}
+	cells := make([]image.Rectangle, n)
+	pos := float64(min)
+	for i, size := range sizes {
+		p0, p1 := int(pos+0.5), int(pos+size+0.5)
+		if obj.vertical {
+			cells[i] = image.Rect(r.Min.X, p0, r.Max.X, p1)
+		} else {
+			cells[i] = image.Rect(p0, r.Min.Y, p1, r.Max.Y)
+		}
+		pos += size + float64(pad)
+	}
+	return cells
+}
+
+// A GridLayout arranges items in a grid of equally
+// sized cells, filling each row from left to right
+// before starting the next.
+//
+type GridLayout struct {
+	container
+	cols int
+}
+
+var _ ResizableItem = (*GridLayout)(nil)
+var _ Sizer = (*GridLayout)(nil)
+var _ Backing = (*GridLayout)(nil)
+
+// NewGridLayout returns a new GridLayout occupying r,
+// with the given number of columns. There are
+// as many rows as are needed to hold the items.
+//
+func NewGridLayout(r image.Rectangle, cols int) *GridLayout {
+	obj := new(GridLayout)
+	obj.init(obj, r)
+	if cols < 1 {
+		cols = 1
+	}
+	obj.cols = cols
+	return obj
+}
+
+// Add adds it to the next cell in the grid.
+//
+func (obj *GridLayout) Add(it Item) {
+	obj.add(it, obj.cells)
+}
+
+// SetPadding sets the space left between adjacent
+// cells, and between the cells and the edge of the grid.
+//
+func (obj *GridLayout) SetPadding(p int) {
+	obj.setPadding(p, obj.cells)
+}
+
+// SetBounds changes the rectangle occupied by
+// the grid, and lays out its items again.
+//
+func (obj *GridLayout) SetBounds(r image.Rectangle) {
+	obj.setBounds(r, obj.cells)
+}
+
+func (obj *GridLayout) MinSize() image.Point {
+	return obj.size(minSize)
+}
+
+func (obj *GridLayout) PreferredSize() image.Point {
+	return maxPoint(obj.size(preferredSize), obj.MinSize())
+}
+
+// size returns the size of the grid when each cell is
+// big enough for the largest item, as measured by size.
+func (obj *GridLayout) size(size func(Item) image.Point) image.Point {
+	var cell image.Point
+	for _, it := range obj.items {
+		cell = maxPoint(cell, size(it))
+	}
+	cols := obj.cols
+	if len(obj.items) < cols {
+		cols = len(obj.items)
+	}
+	rows := (len(obj.items) + obj.cols - 1) / obj.cols
+	pad := obj.padding
+	return image.Pt(cols*(cell.X+pad)+pad, rows*(cell.Y+pad)+pad)
+}
+
+func (obj *GridLayout) cells() []image.Rectangle {
+	n := len(obj.items)
+	rows := (n + obj.cols - 1) / obj.cols
+	if rows == 0 {
+		return nil
+	}
+	pad := obj.padding
+	r := obj.r.Inset(pad)
+	w := float64(r.Dx()-pad*(obj.cols-1)) / float64(obj.cols)
+	h := float64(r.Dy()-pad*(rows-1)) / float64(rows)
+	cells := make([]image.Rectangle, n)
+	for i := range cells {
+		x := float64(r.Min.X) + float64(i%obj.cols)*(w+float64(pad))
+		y := float64(r.Min.Y) + float64(i/obj.cols)*(h+float64(pad))
+		cells[i] = image.Rect(int(x+0.5), int(y+0.5), int(x+w+0.5), int(y+h+0.5))
+	}
+	return cells
+}
@@ -0,0 +1,503 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+	"reflect"
+)
+
+// listRowHeight is the height of each row in a ListBox.
+const listRowHeight = 18
+
+var intSliceType = reflect.TypeOf([]int(nil))
+
+// A ListBox shows a scrollable list of strings,
+// one per row, any of which may be selected.
+//
+type ListBox struct {
+	backing Backing
+	value   values.Value
+	Item
+	c        *Canvas
+	r        image.Rectangle
+	rows     []listRow // the visible rows.
+	entries  []string
+	selected []bool // parallel to entries.
+	top      int    // index of the entry shown in the first row.
+	hover    int    // index of the entry under the pointer, or -1.
+	anchor   int    // index of the entry last clicked, or -1.
+	multi    bool
+	faces    [3]image.Image // normal, hover and selected.
+	frame    *Rect
+	font     *truetype.Font
+	fg       image.Image
+	bg       color.Color
+	theme    *Theme
+	pref     image.Point // the size the list was created with.
+}
+
+type listRow struct {
+	box   Rect
+	label *Text
+}
+
+// The indexes of ListBox.faces.
+const (
+	faceNormal = iota
+	faceHover
+	faceSelected
+)
+
+// NewListBox returns a new ListBox occupying r, showing
+// the given entries drawn in fg on a background of bg.
+// If the value is of type []int, any number of
+// entries may be selected, and it holds the indexes
+// of the selected entries in ascending order;
+// otherwise it should be of type int, and holds the index
+// of the single selected entry, or -1 if there is none.
+//
+func NewListBox(r image.Rectangle, entries []string, font *truetype.Font, fg, bg color.Color, value values.Value) *ListBox {
+	obj := new(ListBox)
+	obj.value = value
+	obj.backing = NullBacking()
+	obj.r = r
+	obj.pref = r.Size()
+	obj.multi = value.Type() == intSliceType
+	obj.hover = -1
+	obj.anchor = -1
+	obj.entries = append([]string(nil), entries...)
+	obj.selected = make([]bool, len(entries))
+	obj.font = font
+	obj.fg = &image.Uniform{fg}
+	obj.bg = bg
+	obj.c = NewCanvas(nil, r)
+	obj.frame = NewRect(r, nil, 0, nil)
+	obj.c.AddItem(obj.frame)
+	obj.Item = obj.c
+	obj.backing.Atomically(func(_ FlushFunc) {
+		obj.makeRows()
+		obj.restyle()
+	})
+	go obj.listener()
+	return obj
+}
+
+var _ HandlerItem = (*ListBox)(nil)
+var _ ResizableItem = (*ListBox)(nil)
+var _ Sizer = (*ListBox)(nil)
+
+// makeRows replaces the visible rows with as
+// many as fit in the list's rectangle.
+// The rows are laid out by restyle.
+func (obj *ListBox) makeRows() {
+	// the caller flushes the whole list, and may
+	// hold the lock that the inner canvas uses.
+	nop := func(image.Rectangle, Drawer) {}
+	for i := range obj.rows {
+		obj.c.deleteItem(&obj.rows[i].box, nop)
+		obj.c.deleteItem(obj.rows[i].label, nop)
+	}
+	obj.rows = make([]listRow, obj.r.Dy()/listRowHeight)
+	for i := range obj.rows {
+		row := &obj.rows[i]
+		row.box = *NewRect(image.ZR, nil, 0, nil)
+		row.label = NewText(image.ZP, W, "", obj.font, 12, nil)
+		row.label.item.SetFill(obj.fg)
+		obj.c.addItem(&row.box, nop)
+		obj.c.addItem(row.label, nop)
+	}
+}
+
+// SetBounds moves and resizes the list to occupy r,
+// showing as many rows as fit.
+//
+func (obj *ListBox) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		obj.c.r = r
+		obj.frame.r = r
+		obj.makeRows()
+		if max := len(obj.entries) - len(obj.rows); obj.top > max {
+			obj.top = max
+		}
+		if obj.top < 0 {
+			obj.top = 0
+		}
+		obj.restyle()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed to show a single row.
+//
+func (obj *ListBox) MinSize() image.Point {
+	return image.Pt(4*listRowHeight, listRowHeight)
+}
+
+func (obj *ListBox) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+func (obj *ListBox) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the list,
+// overriding that of its canvas. If t is nil, the
+// canvas's theme is used.
+//
+func (obj *ListBox) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+// restyle lays out the rows and fills in their
+// text and faces using the list's current theme.
+func (obj *ListBox) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	obj.faces = [3]image.Image{
+		faceNormal:   &image.Uniform{obj.bg},
+		faceHover:    &image.Uniform{shade(obj.bg, t.Hover)},
+		faceSelected: &image.Uniform{shade(obj.bg, t.Pressed)},
+	}
+	obj.frame.fill = obj.faces[faceNormal]
+	obj.frame.setBorder(t.BorderWidth, t.border())
+	b := t.BorderWidth
+	r := obj.r
+	for i := range obj.rows {
+		row := &obj.rows[i]
+		y := r.Min.Y + i*listRowHeight
+		row.box.r = image.Rect(r.Min.X+b, y+b, r.Max.X-b, y+listRowHeight)
+		t.styleLabel(row.label, obj.font)
+		row.label.setPoint(image.Pt(r.Min.X+t.Padding, y+listRowHeight/2))
+	}
+	// the caller flushes the whole list.
+	obj.redraw(obj.top, func(image.Rectangle, Drawer) {})
+}
+
+// redraw updates all visible rows showing entries
+// from index i onwards.
+func (obj *ListBox) redraw(i int, flush FlushFunc) {
+	if i < obj.top {
+		i = obj.top
+	}
+	for ; i-obj.top < len(obj.rows); i++ {
+		obj.redrawRow(i, flush)
+	}
+}
+
+// redrawRow updates the row showing the entry
+// with index i, if it is visible.
+func (obj *ListBox) redrawRow(i int, flush FlushFunc) {
+	n := i - obj.top
+	if n < 0 || n >= len(obj.rows) {
+		return
+	}
+	row := &obj.rows[n]
+	face := faceNormal
+	text := ""
+	if i < len(obj.entries) {
+		text = obj.entries[i]
+		switch {
+		case obj.selected[i]:
+			face = faceSelected
+		case i == obj.hover:
+			face = faceHover
+		}
+	}
+	row.box.fill = obj.faces[face]
+	if row.label.item.Text != text {
+		row.label.setText(text)
+	}
+	flush(row.box.r, nil)
+}
+
+// selection returns the current selection
+// in the form held by the value.
+func (obj *ListBox) selection() interface{} {
+	if obj.multi {
+		sel := []int{}
+		for i, s := range obj.selected {
+			if s {
+				sel = append(sel, i)
+			}
+		}
+		return sel
+	}
+	for i, s := range obj.selected {
+		if s {
+			return reflect.ValueOf(i).Convert(obj.value.Type()).Interface()
+		}
+	}
+	return reflect.ValueOf(-1).Convert(obj.value.Type()).Interface()
+}
+
+func (obj *ListBox) listener() {
+	g := obj.value.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		obj.backing.Atomically(func(flush FlushFunc) {
+			sel := make([]bool, len(obj.entries))
+			if obj.multi {
+				for _, i := range x.([]int) {
+					if i >= 0 && i < len(sel) {
+						sel[i] = true
+					}
+				}
+			} else {
+				if i := int(reflect.ValueOf(x).Int()); i >= 0 && i < len(sel) {
+					sel[i] = true
+				}
+			}
+			// only rows whose selection has changed need redrawing.
+			for i := range sel {
+				if sel[i] != obj.selected[i] {
+					obj.selected[i] = sel[i]
+					obj.redrawRow(i, flush)
+				}
+			}
+		})
+		obj.backing.Flush()
+	}
+}
+
+// SetEntries replaces all the entries in the list.
+// The selection is cleared.
+//
+func (obj *ListBox) SetEntries(entries []string) {
+	var sel interface{}
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.entries = append([]string(nil), entries...)
+		obj.selected = make([]bool, len(entries))
+		obj.top = 0
+		obj.hover = -1
+		obj.anchor = -1
+		obj.redraw(0, flush)
+		sel = obj.selection()
+	})
+	obj.backing.Flush()
+	obj.value.Set(sel)
+}
+
+// SetEntry changes the text of the entry with index i.
+// Only that entry's row is redrawn.
+//
+func (obj *ListBox) SetEntry(i int, s string) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		if i >= 0 && i < len(obj.entries) {
+			obj.entries[i] = s
+			obj.redrawRow(i, flush)
+		}
+	})
+	obj.backing.Flush()
+}
+
+// InsertEntry inserts s into the list before the entry with
+// index i; if i is len(entries), it is appended.
+// Only the rows at or below the new entry are redrawn.
+//
+func (obj *ListBox) InsertEntry(i int, s string) {
+	var sel interface{}
+	obj.backing.Atomically(func(flush FlushFunc) {
+		if i < 0 || i > len(obj.entries) {
+			return
+		}
+		obj.entries = append(obj.entries, "")
+		copy(obj.entries[i+1:], obj.entries[i:])
+		obj.entries[i] = s
+		obj.selected = append(obj.selected, false)
+		copy(obj.selected[i+1:], obj.selected[i:])
+		obj.selected[i] = false
+		if obj.hover >= i {
+			obj.hover++
+		}
+		if obj.anchor >= i {
+			obj.anchor++
+		}
+		obj.redraw(i, flush)
+		sel = obj.selection()
+	})
+	obj.backing.Flush()
+	if sel != nil {
+		obj.value.Set(sel)
+	}
+}
+
+// DeleteEntry removes the entry with index i from the list.
+// Only the rows at or below the deleted entry are redrawn.
+//
+func (obj *ListBox) DeleteEntry(i int) {
+	var sel interface{}
+	obj.backing.Atomically(func(flush FlushFunc) {
+		if i < 0 || i >= len(obj.entries) {
+			return
+		}
+		obj.entries = append(obj.entries[:i], obj.entries[i+1:]...)
+		obj.selected = append(obj.selected[:i], obj.selected[i+1:]...)
+		switch {
+		case obj.hover == i:
+			obj.hover = -1
+		case obj.hover > i:
+			obj.hover--
+		}
+		switch {
+		case obj.anchor == i:
+			obj.anchor = -1
+		case obj.anchor > i:
+			obj.anchor--
+		}
+		if obj.top > 0 && obj.top+len(obj.rows) > len(obj.entries) {
+			obj.top--
+			i = obj.top
+		}
+		obj.redraw(i, flush)
+		sel = obj.selection()
+	})
+	obj.backing.Flush()
+	if sel != nil {
+		obj.value.Set(sel)
+	}
+}
+
+// ScrollTo scrolls the list so that the entry
+// with index i is shown in the first row,
+// or as close to it as possible.
+//
+func (obj *ListBox) ScrollTo(i int) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.scrollTo(i, flush)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *ListBox) scrollTo(i int, flush FlushFunc) {
+	if max := len(obj.entries) - len(obj.rows); i > max {
+		i = max
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i != obj.top {
+		obj.top = i
+		obj.redraw(i, flush)
+	}
+}
+
+// entryAt returns the index of the entry shown at p, or -1.
+func (obj *ListBox) entryAt(p image.Point) int {
+	for n := range obj.rows {
+		if p.In(obj.rows[n].box.r) {
+			if i := obj.top + n; i < len(obj.entries) {
+				return i
+			}
+			break
+		}
+	}
+	return -1
+}
+
+func (obj *ListBox) setHover(i int) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		if i == obj.hover {
+			return
+		}
+		old := obj.hover
+		obj.hover = i
+		obj.redrawRow(old, flush)
+		obj.redrawRow(i, flush)
+	})
+	obj.backing.Flush()
+}
+
+// HandleMouse highlights the entry under the pointer
+// when it is called with no buttons pressed, until the
+// pointer leaves the list. Clicking on an entry selects
+// it; if multiple selection is allowed, clicking on
+// an entry toggles its selection instead, and
+// shift-clicking selects all the entries from the
+// one last clicked.
+// The scroll wheel scrolls the list by one entry.
+//
+func (obj *ListBox) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	switch {
+	case m.Buttons&(WheelUp|WheelDown) != 0:
+		d := 1
+		if m.Buttons&WheelUp != 0 {
+			d = -1
+		}
+		obj.backing.Atomically(func(flush FlushFunc) {
+			obj.scrollTo(obj.top+d, flush)
+		})
+		obj.backing.Flush()
+		return true
+	case m.Buttons == 0:
+		for m.Buttons == 0 {
+			if !m.Loc.In(obj.r) {
+				obj.setHover(-1)
+				return true
+			}
+			obj.setHover(obj.entryAt(m.Loc))
+			e, ok := (<-ec).(ui.MouseEvent)
+			for !ok {
+				e, ok = (<-ec).(ui.MouseEvent)
+			}
+			m = e
+		}
+		obj.setHover(-1)
+	}
+	if m.Buttons&1 == 0 {
+		return false
+	}
+	i := obj.entryAt(m.Loc)
+	if i < 0 {
+		waitRelease(m.Buttons, ec)
+		return true
+	}
+	shift := ModifiersOf(obj.backing)&ModShift != 0
+	var sel interface{}
+	obj.backing.Atomically(func(flush FlushFunc) {
+		switch {
+		case obj.multi && shift && obj.anchor >= 0:
+			lo, hi := obj.anchor, i
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			for j := lo; j <= hi; j++ {
+				if !obj.selected[j] {
+					obj.selected[j] = true
+					obj.redrawRow(j, flush)
+				}
+			}
+		case obj.multi:
+			obj.selected[i] = !obj.selected[i]
+			obj.redrawRow(i, flush)
+			obj.anchor = i
+		default:
+			for j, s := range obj.selected {
+				if s != (j == i) {
+					obj.selected[j] = j == i
+					obj.redrawRow(j, flush)
+				}
+			}
+		}
+		sel = obj.selection()
+	})
+	obj.backing.Flush()
+	obj.value.Set(sel)
+	waitRelease(m.Buttons, ec)
+	return true
+}
@@ -0,0 +1,221 @@
+package canvas
+
+import (
+	"image"
+	"image/draw"
+)
+
+// A MarkerKind gives the shape of a Marker.
+type MarkerKind int
+
+const (
+	MarkerPlus MarkerKind = iota
+	MarkerCross
+	MarkerDiamond
+	MarkerTriangle
+	MarkerCircle
+	MarkerErrorBar
+)
+
+// A Marker is a small symbol centred on a point,
+// as used for the points of a scatter plot.
+// Markers are drawn from a mask calculated
+// at pixel resolution, so they remain crisp at small sizes.
+//
+type Marker struct {
+	backing Backing
+	kind    MarkerKind
+	fill    image.Image
+	size    int
+	p       image.Point
+	lo, hi  int // extent of an error bar below and above p.
+	r       image.Rectangle
+	mask    *image.Alpha
+}
+
+// NewMarker returns a new marker of the given kind and size,
+// filled with col and centred on p. Odd sizes give
+// the most symmetrical results.
+//
+func NewMarker(kind MarkerKind, col image.Image, size int, p image.Point) *Marker {
+	obj := new(Marker)
+	obj.backing = NullBacking()
+	obj.kind = kind
+	obj.fill = col
+	if size < 1 {
+		size = 1
+	}
+	obj.size = size
+	obj.p = p
+	obj.lo = size / 2
+	obj.hi = size / 2
+	obj.makeMask()
+	return obj
+}
+
+var _ MoveableItem = (*Marker)(nil)
+
+func (obj *Marker) SetContainer(b Backing) {
+	obj.backing = b
+}
+
+func (obj *Marker) Draw(dst draw.Image, clipr image.Rectangle) {
+	dr := obj.r.Intersect(clipr)
+	draw.DrawMask(dst, dr, obj.fill, dr.Min, obj.mask, dr.Min.Sub(obj.r.Min), draw.Over)
+}
+
+func (obj *Marker) Bbox() image.Rectangle {
+	return obj.r
+}
+
+// HitTest returns true if p is anywhere within
+// the marker's bounding box; markers are
+// usually too small for anything more precise to be useful.
+//
+func (obj *Marker) HitTest(p image.Point) bool {
+	return p.In(obj.r)
+}
+
+func (obj *Marker) Opaque() bool {
+	return false
+}
+
+// SetCentre moves the marker so that it is centred on p.
+//
+func (obj *Marker) SetCentre(p image.Point) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = obj.r.Add(p.Sub(obj.p))
+		obj.p = p
+		flush.Rects(nil, old, obj.r)
+	})
+}
+
+// Move moves the marker by delta.
+//
+func (obj *Marker) Move(delta image.Point) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = obj.r.Add(delta)
+		obj.p = obj.p.Add(delta)
+		flush.Rects(nil, old, obj.r)
+	})
+}
+
+// SetFill changes the colour of the marker.
+//
+func (obj *Marker) SetFill(fill image.Image) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.fill = fill
+		flush(obj.r, nil)
+	})
+}
+
+// SetErrorRange sets how far an error bar extends
+// below and above its centre. Negative extents are
+// taken as zero. It has no effect on other kinds
+// of marker.
+//
+func (obj *Marker) SetErrorRange(below, above int) {
+	if obj.kind != MarkerErrorBar {
+		return
+	}
+	if below < 0 {
+		below = 0
+	}
+	if above < 0 {
+		above = 0
+	}
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.lo, obj.hi = below, above
+		obj.makeMask()
+		flush.Rects(nil, old, obj.r)
+	})
+}
+
+// setSize changes the size of the marker. It does not
+// flush, so it should be called from within Atomically.
+func (obj *Marker) setSize(size int) {
+	if size < 1 {
+		size = 1
+	}
+	obj.size = size
+	obj.makeMask()
+}
+
+// makeMask calculates the marker's bounding box and mask.
+func (obj *Marker) makeMask() {
+	s := obj.size
+	min := obj.p.Sub(image.Pt(s/2, s/2))
+	obj.r = image.Rectangle{min, min.Add(image.Pt(s, s))}
+	if obj.kind == MarkerErrorBar {
+		obj.r.Min.Y = obj.p.Y - obj.hi
+		obj.r.Max.Y = obj.p.Y + obj.lo + 1
+	}
+	// the mask is in marker-relative coordinates, so that
+	// it does not need recalculating when the marker moves.
+	r := obj.r.Sub(obj.r.Min)
+	freeAlpha(obj.mask)
+	obj.mask = newAlpha(r)
+	c := obj.p.Sub(obj.r.Min)
+	half := float64(s) / 2
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx, dy := x-c.X, y-c.Y
+			var a uint8
+			switch obj.kind {
+			case MarkerPlus:
+				if dx == 0 || dy == 0 {
+					a = 0xff
+				}
+			case MarkerCross:
+				if dx == dy || dx == -dy {
+					a = 0xff
+				}
+			case MarkerErrorBar:
+				if dx == 0 || y == r.Min.Y || y == r.Max.Y-1 {
+					a = 0xff
+				}
+			case MarkerDiamond:
+				a = markerCoverage(x, y, c, func(fx, fy float64) bool {
+					return abs(fx)+abs(fy) <= half
+				})
+			case MarkerCircle:
+				a = markerCoverage(x, y, c, func(fx, fy float64) bool {
+					return fx*fx+fy*fy <= half*half
+				})
+			case MarkerTriangle:
+				a = markerCoverage(x, y, c, func(fx, fy float64) bool {
+					// upward pointing, apex at the top of the bbox.
+					return fy <= half && abs(fx)*2 <= fy+half
+				})
+			}
+			obj.mask.Pix[obj.mask.PixOffset(x, y)] = a
+		}
+	}
+}
+
+// markerCoverage returns the proportion of the pixel at (x, y)
+// for which inside returns true. The coordinates given to inside are
+// relative to the centre of the pixel at c.
+func markerCoverage(x, y int, c image.Point, inside func(fx, fy float64) bool) uint8 {
+	n := 0
+	for sy := 0; sy < boxSamples; sy++ {
+		fy := float64(y-c.Y) + (float64(sy)+0.5)/boxSamples - 0.5
+		for sx := 0; sx < boxSamples; sx++ {
+			fx := float64(x-c.X) + (float64(sx)+0.5)/boxSamples - 0.5
+			if inside(fx, fy) {
+				n++
+			}
+		}
+	}
+	return samples2alpha(n)
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
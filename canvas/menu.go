@@ -0,0 +1,651 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+)
+
+// A MenuEntry describes one entry in a menu.
+//
+type MenuEntry struct {
+	Label  string
+	Action func()      // called when the entry is chosen, if non-nil.
+	Sub    []MenuEntry // the entries of a submenu, if non-nil.
+}
+
+// menuStyle holds the attributes shared by a menu
+// and all its submenus.
+type menuStyle struct {
+	font   *truetype.Font
+	fg, bg color.Color
+	hilite image.Image
+	value  values.Value
+	theme  *Theme
+}
+
+func newMenuStyle(font *truetype.Font, fg, bg color.Color, value values.Value) *menuStyle {
+	st := &menuStyle{font: font, fg: fg, bg: bg, value: value}
+	return st.themed(DefaultTheme)
+}
+
+// themed returns a copy of st that uses the theme t.
+// The copy is made so that menus already shown
+// keep the style they were created with.
+func (st *menuStyle) themed(t *Theme) *menuStyle {
+	st1 := *st
+	st1.theme = t
+	st1.hilite = &image.Uniform{shade(st.bg, t.Pressed)}
+	return &st1
+}
+
+// label returns a new text item showing s.
+func (st *menuStyle) label(s string) *Text {
+	t := NewText(image.ZP, W, s, st.theme.font(st.font), st.theme.FontSize, nil)
+	t.SetFill(&image.Uniform{st.fg})
+	return t
+}
+
+// A Menu is a list of entries shown as an overlay on
+// a canvas (see Canvas.Popup), any of which may be chosen.
+// It is created by PopupMenu, and by MenuBar and ContextMenu.
+//
+// All the state of a menu is guarded by its canvas,
+// so that it can be closed from any goroutine.
+//
+type Menu struct {
+	backing Backing
+	Item
+	c        *Canvas
+	r        image.Rectangle
+	top      *Canvas
+	entries  []MenuEntry
+	rows     []Rect
+	hover    int // index of the highlighted entry, or -1.
+	sub      *Menu
+	subIndex int
+	parent   *Menu
+	path     string // labels of the containing menus.
+	style    *menuStyle
+	shown    bool
+	onClose  func()
+}
+
+var _ HandlerItem = (*Menu)(nil)
+var _ HandleKeyer = (*Menu)(nil)
+
+// PopupMenu shows a menu of the given entries as an overlay on top,
+// with its top left corner at p, or as near as
+// possible while remaining inside top.
+// The menu takes the keyboard focus; entries may be
+// chosen with the mouse or with the arrow keys and Return.
+//
+// When an entry is chosen, the menu is removed, the entry's Action
+// is called, and value, if non-nil, is set to a string holding
+// the labels of the menus containing the entry and the entry's
+// own label, separated by "/". The menu is also removed
+// when Escape is typed, or when a mouse button is pressed
+// outside it.
+//
+func PopupMenu(top *Canvas, p image.Point, entries []MenuEntry, font *truetype.Font, fg, bg color.Color, value values.Value) *Menu {
+	st := newMenuStyle(font, fg, bg, value).themed(ThemeOf(top))
+	return popupMenu(top, p, entries, st, nil)
+}
+
+func popupMenu(top *Canvas, p image.Point, entries []MenuEntry, st *menuStyle, onClose func()) *Menu {
+	m := newMenu(top, p, entries, st, nil, "")
+	m.onClose = onClose
+	m.show()
+	top.setFocus(m)
+	return m
+}
+
+func newMenu(top *Canvas, p image.Point, entries []MenuEntry, st *menuStyle, parent *Menu, path string) *Menu {
+	m := &Menu{
+		backing: NullBacking(),
+		top:     top,
+		entries: entries,
+		hover:   -1,
+		parent:  parent,
+		path:    path,
+		style:   st,
+	}
+	labels := make([]*Text, len(entries))
+	w := 0
+	for i, e := range entries {
+		labels[i] = st.label(e.Label)
+		if dx := labels[i].Bbox().Dx(); dx > w {
+			w = dx
+		}
+	}
+	// leave room for padding and the submenu arrow.
+	t := st.theme
+	b := t.BorderWidth
+	w += 2*t.Padding + listRowHeight
+	r := image.Rect(p.X, p.Y, p.X+w, p.Y+len(entries)*listRowHeight+2*b)
+	tr := top.Rect()
+	if d := r.Max.X - tr.Max.X; d > 0 {
+		r = r.Sub(image.Pt(d, 0))
+	}
+	if d := r.Max.Y - tr.Max.Y; d > 0 {
+		r = r.Sub(image.Pt(0, d))
+	}
+	if d := tr.Min.X - r.Min.X; d > 0 {
+		r = r.Add(image.Pt(d, 0))
+	}
+	if d := tr.Min.Y - r.Min.Y; d > 0 {
+		r = r.Add(image.Pt(0, d))
+	}
+	m.r = r
+	m.c = NewCanvas(nil, r)
+	m.c.AddItem(NewRect(r, &image.Uniform{st.bg}, b, t.border()))
+	m.rows = make([]Rect, len(entries))
+	for i, e := range entries {
+		y := r.Min.Y + b + i*listRowHeight
+		row := image.Rect(r.Min.X+b, y, r.Max.X-b, y+listRowHeight)
+		m.rows[i] = *NewRect(row, image.Transparent, 0, nil)
+		m.c.AddItem(&m.rows[i])
+		labels[i].SetPoint(image.Pt(row.Min.X+t.Padding, centre(row).Y))
+		m.c.AddItem(labels[i])
+		if e.Sub != nil {
+			ar := image.Rect(row.Max.X-listRowHeight, row.Min.Y, row.Max.X, row.Max.Y).Inset(listRowHeight / 3)
+			m.c.AddItem(NewPolygon(&image.Uniform{st.fg}, []image.Point{ar.Min, {ar.Max.X, centre(ar).Y}, {ar.Min.X, ar.Max.Y}}))
+		}
+	}
+	m.Item = m.c
+	return m
+}
+
+func (m *Menu) SetContainer(b Backing) {
+	m.backing = b
+}
+
+func (m *Menu) show() {
+	m.top.Atomically(func(_ FlushFunc) {
+		m.shown = true
+	})
+	m.top.Popup(m, m.dismissed)
+}
+
+// dismissed is called when the canvas removes the menu
+// because of a button press outside it.
+func (m *Menu) dismissed() {
+	m.top.Atomically(func(_ FlushFunc) {
+		m.shown = false
+		m.sub = nil
+	})
+	if m.parent == nil && m.onClose != nil {
+		m.onClose()
+	}
+}
+
+// Close removes the menu and any of its submenus from the canvas.
+//
+func (m *Menu) Close() {
+	var sub *Menu
+	var shown bool
+	m.top.Atomically(func(_ FlushFunc) {
+		sub, shown = m.sub, m.shown
+		m.sub, m.shown = nil, false
+		if m.parent != nil && m.parent.sub == m {
+			m.parent.sub = nil
+		}
+	})
+	if sub != nil {
+		sub.Close()
+	}
+	if !shown {
+		return
+	}
+	m.top.Delete(m)
+	if m.parent == nil && m.onClose != nil {
+		m.onClose()
+	}
+}
+
+func (m *Menu) root() *Menu {
+	for m.parent != nil {
+		m = m.parent
+	}
+	return m
+}
+
+// rowAt returns the index of the entry at p, or -1.
+func (m *Menu) rowAt(p image.Point) int {
+	for i := range m.rows {
+		if p.In(m.rows[i].r) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Menu) setHover(i int) {
+	m.top.Atomically(func(flush FlushFunc) {
+		if i == m.hover {
+			return
+		}
+		if old := m.hover; old >= 0 {
+			m.rows[old].fill = image.Transparent
+			flush(m.rows[old].r, nil)
+		}
+		if i >= 0 {
+			m.rows[i].fill = m.style.hilite
+			flush(m.rows[i].r, nil)
+		}
+		m.hover = i
+	})
+	m.top.Flush()
+}
+
+// openSub shows the submenu of entry i, if it has one,
+// closing any other submenu. It returns the submenu.
+func (m *Menu) openSub(i int) *Menu {
+	var sub *Menu
+	var subIndex int
+	m.top.Atomically(func(_ FlushFunc) {
+		sub, subIndex = m.sub, m.subIndex
+	})
+	if sub != nil {
+		if subIndex == i {
+			return sub
+		}
+		sub.Close()
+	}
+	e := m.entries[i]
+	if e.Sub == nil {
+		return nil
+	}
+	p := image.Pt(m.r.Max.X, m.rows[i].r.Min.Y-1)
+	sub = newMenu(m.top, p, e.Sub, m.style, m, m.path+e.Label+"/")
+	m.top.Atomically(func(_ FlushFunc) {
+		m.sub, m.subIndex = sub, i
+	})
+	sub.show()
+	return sub
+}
+
+// choose chooses entry i, opening its submenu
+// if it has one.
+func (m *Menu) choose(i int) {
+	e := m.entries[i]
+	if e.Sub != nil {
+		m.top.setFocus(m.openSub(i))
+		return
+	}
+	m.root().Close()
+	if e.Action != nil {
+		e.Action()
+	}
+	if m.style.value != nil {
+		m.style.value.Set(m.path + e.Label)
+	}
+}
+
+// HandleMouse highlights the entry under the pointer,
+// showing its submenu if it has one, and chooses an entry
+// when a mouse button is released over it.
+//
+func (m *Menu) HandleMouse(f Flusher, e ui.MouseEvent, ec <-chan interface{}) bool {
+	for e.Buttons == 0 {
+		if !e.Loc.In(m.r) {
+			// leave the highlight on an entry whose submenu is open,
+			// as the pointer is probably on its way there.
+			var sub *Menu
+			m.top.Atomically(func(_ FlushFunc) {
+				sub = m.sub
+			})
+			if sub == nil {
+				m.setHover(-1)
+			}
+			return true
+		}
+		if i := m.rowAt(e.Loc); i >= 0 {
+			m.setHover(i)
+			m.openSub(i)
+		}
+		e = nextMouse(ec)
+	}
+	if e.Buttons&wheelButtons != 0 {
+		return true
+	}
+	but := e.Buttons
+	for {
+		e = nextMouse(ec)
+		i := m.rowAt(e.Loc)
+		m.setHover(i)
+		if (e.Buttons & but) != but {
+			if i >= 0 {
+				m.choose(i)
+			}
+			return true
+		}
+	}
+}
+
+// HandleKey moves the highlight with the up and down
+// arrow keys; Return chooses the highlighted entry, and the
+// right arrow opens its submenu. The left arrow closes
+// a submenu, and Escape closes all menus.
+//
+func (m *Menu) HandleKey(f Flusher, k ui.KeyEvent) bool {
+	n := len(m.entries)
+	var hover int
+	m.top.Atomically(func(_ FlushFunc) {
+		hover = m.hover
+	})
+	switch k.Key {
+	case KeyDown:
+		if n > 0 {
+			m.setHover((hover + 1) % n)
+		}
+	case KeyUp:
+		if n > 0 {
+			if hover <= 0 {
+				hover = n
+			}
+			m.setHover(hover - 1)
+		}
+	case KeyReturn:
+		if hover >= 0 {
+			m.choose(hover)
+		}
+	case KeyRight:
+		if hover >= 0 && m.entries[hover].Sub != nil {
+			m.choose(hover)
+		}
+	case KeyLeft:
+		if m.parent != nil {
+			m.Close()
+			m.top.setFocus(m.parent)
+		}
+	case KeyEscape:
+		m.root().Close()
+	default:
+		return false
+	}
+	return true
+}
+
+// nextMouse returns the next mouse event from ec,
+// discarding any other events.
+func nextMouse(ec <-chan interface{}) ui.MouseEvent {
+	for {
+		if m, ok := (<-ec).(ui.MouseEvent); ok {
+			return m
+		}
+	}
+}
+
+// A MenuBar shows a row of menu titles; clicking
+// on a title shows the menu below it.
+//
+type MenuBar struct {
+	backing Backing
+	Item
+	c       *Canvas
+	r       image.Rectangle
+	entries []MenuEntry
+	titles  []Rect
+	labels  []*Text
+	frame   *Rect
+	style   *menuStyle
+	menu    *Menu // the open menu, if any.
+	theme   *Theme
+	pref    image.Point // the size the bar was created with.
+}
+
+var _ HandlerItem = (*MenuBar)(nil)
+var _ ResizableItem = (*MenuBar)(nil)
+var _ Sizer = (*MenuBar)(nil)
+
+// NewMenuBar returns a new MenuBar occupying r, with a title
+// for each of the given entries, whose submenus are
+// shown when the titles are clicked. An entry without
+// a submenu is chosen directly when its title is clicked.
+// Chosen entries are reported as for PopupMenu.
+// The menus are shown as overlays on the outermost canvas
+// containing the MenuBar.
+//
+func NewMenuBar(r image.Rectangle, entries []MenuEntry, font *truetype.Font, fg, bg color.Color, value values.Value) *MenuBar {
+	obj := new(MenuBar)
+	obj.backing = NullBacking()
+	obj.r = r
+	obj.pref = r.Size()
+	obj.entries = entries
+	obj.style = newMenuStyle(font, fg, bg, value)
+	obj.c = NewCanvas(nil, r)
+	obj.frame = NewRect(r, &image.Uniform{bg}, 0, nil)
+	obj.c.AddItem(obj.frame)
+	obj.titles = make([]Rect, len(entries))
+	for i, e := range entries {
+		obj.titles[i] = *NewRect(image.ZR, image.Transparent, 0, nil)
+		obj.c.AddItem(&obj.titles[i])
+		label := obj.style.label(e.Label)
+		obj.labels = append(obj.labels, label)
+		obj.c.AddItem(label)
+	}
+	obj.restyle()
+	obj.Item = obj.c
+	return obj
+}
+
+func (obj *MenuBar) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the menu bar and
+// its menus, overriding that of its canvas. If t is nil,
+// the canvas's theme is used.
+//
+func (obj *MenuBar) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+// SetBounds moves and resizes the menu bar to occupy r.
+// A menu that is already open is not moved.
+//
+func (obj *MenuBar) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		obj.c.r = r
+		obj.frame.r = r
+		obj.restyle()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed to show all the titles.
+//
+func (obj *MenuBar) MinSize() image.Point {
+	t := themeFor(obj.theme, obj.backing)
+	b := t.BorderWidth
+	size := image.Pt(2*b, 0)
+	for _, label := range obj.labels {
+		l := label.Bbox().Size()
+		size.X += l.X + 4*t.Padding
+		if h := l.Y + 2*b + t.Padding; h > size.Y {
+			size.Y = h
+		}
+	}
+	return size
+}
+
+func (obj *MenuBar) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+// restyle lays out the titles using the bar's current theme.
+func (obj *MenuBar) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	obj.style = obj.style.themed(t)
+	obj.frame.setBorder(t.BorderWidth, t.border())
+	b := t.BorderWidth
+	x := obj.r.Min.X + b
+	for i, label := range obj.labels {
+		t.styleLabel(label, obj.style.font)
+		w := label.Bbox().Dx() + 4*t.Padding
+		tr := image.Rect(x, obj.r.Min.Y+b, x+w, obj.r.Max.Y-b)
+		obj.titles[i].r = tr
+		label.setPoint(image.Pt(tr.Min.X+2*t.Padding, centre(tr).Y))
+		x += w
+	}
+}
+
+// setOpen highlights the title with index i, and
+// records the open menu.
+func (obj *MenuBar) setOpen(i int, menu *Menu) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		for j := range obj.titles {
+			t := &obj.titles[j]
+			var fill image.Image = image.Transparent
+			if j == i {
+				fill = obj.style.hilite
+			}
+			if t.fill != fill {
+				t.fill = fill
+				flush(t.r, nil)
+			}
+		}
+		obj.menu = menu
+	})
+	obj.backing.Flush()
+}
+
+// HandleMouse shows the menu for a title when
+// the first mouse button is pressed over it.
+//
+func (obj *MenuBar) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&1 == 0 {
+		return false
+	}
+	top := TopCanvas(obj.backing)
+	i := -1
+	var p image.Point
+	var menu *Menu
+	var st *menuStyle
+	obj.backing.Atomically(func(_ FlushFunc) {
+		// the titles move when the bar is restyled.
+		for j := range obj.titles {
+			if tr := obj.titles[j].r; m.Loc.In(tr) {
+				i = j
+				p = image.Pt(tr.Min.X, obj.r.Max.Y)
+			}
+		}
+		menu, st = obj.menu, obj.style
+	})
+	if top == nil || i < 0 {
+		waitRelease(m.Buttons, ec)
+		return true
+	}
+	if menu != nil {
+		menu.Close()
+	}
+	e := obj.entries[i]
+	if e.Sub == nil {
+		waitRelease(m.Buttons, ec)
+		if e.Action != nil {
+			e.Action()
+		}
+		if st.value != nil {
+			st.value.Set(e.Label)
+		}
+		return true
+	}
+	menu = newMenu(top, p, e.Sub, st, nil, e.Label+"/")
+	menu.onClose = func() {
+		obj.setOpen(-1, nil)
+	}
+	obj.setOpen(i, menu)
+	menu.show()
+	top.setFocus(menu)
+	waitRelease(m.Buttons, ec)
+	return true
+}
+
+// A ContextMenu wraps an item so that pressing the
+// right mouse button over it pops up a menu.
+// Other mouse events are passed to the item, if it
+// implements HandleMouser.
+//
+type ContextMenu struct {
+	Item
+	backing Backing
+	entries []MenuEntry
+	style   *menuStyle
+}
+
+var _ HandlerItem = (*ContextMenu)(nil)
+var _ Backing = (*ContextMenu)(nil)
+
+// NewContextMenu returns it wrapped in a ContextMenu
+// that shows the given entries. Chosen entries
+// are reported as for PopupMenu.
+//
+func NewContextMenu(it Item, entries []MenuEntry, font *truetype.Font, fg, bg color.Color, value values.Value) *ContextMenu {
+	cm := &ContextMenu{
+		Item:    it,
+		backing: NullBacking(),
+		entries: entries,
+		style:   newMenuStyle(font, fg, bg, value),
+	}
+	it.SetContainer(cm)
+	return cm
+}
+
+func (cm *ContextMenu) SetContainer(b Backing) {
+	cm.backing = b
+	cm.Item.SetContainer(cm)
+}
+
+func (cm *ContextMenu) Atomically(f func(FlushFunc)) {
+	cm.backing.Atomically(func(flush FlushFunc) {
+		f(func(r image.Rectangle, it Drawer) {
+			if it != nil {
+				it = cm
+			}
+			flush(r, it)
+		})
+	})
+}
+
+func (cm *ContextMenu) Rect() image.Rectangle {
+	return cm.backing.Rect()
+}
+
+func (cm *ContextMenu) Flush() {
+	cm.backing.Flush()
+}
+
+func (cm *ContextMenu) outer() Backing {
+	return cm.backing
+}
+
+func (cm *ContextMenu) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&4 != 0 {
+		if top := TopCanvas(cm.backing); top != nil {
+			var st *menuStyle
+			cm.Atomically(func(_ FlushFunc) {
+				st = cm.style.themed(ThemeOf(cm.backing))
+			})
+			popupMenu(top, m.Loc, cm.entries, st, nil)
+			waitRelease(m.Buttons, ec)
+			return true
+		}
+	}
+	if h, ok := cm.Item.(HandleMouser); ok {
+		return h.HandleMouse(f, m, ec)
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package canvas
+
+import (
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// backdropColor is the colour drawn over the
+// canvas behind a modal dialog.
+var backdropColor = color.RGBA{0, 0, 0, 0x60}
+
+// A Modal shows a dialog above a dim backdrop covering
+// a whole canvas. While it is shown, all mouse and
+// keyboard events are captured by the dialog; those
+// that miss it are discarded.
+//
+type Modal struct {
+	*Canvas
+	top       *Canvas
+	prevFocus HandleKeyer
+}
+
+// ShowModal shows dialog as a modal dialog on top.
+// The dialog is dismissed when result is set, typically
+// by one of the dialog's own items; the value is
+// left for the caller to read. As the first value received
+// from result dismisses the dialog, it should not have been set
+// before ShowModal is called (for instance, it may be made
+// with values.NewValue(nil, t)).
+//
+func ShowModal(top *Canvas, dialog Item, result values.Value) *Modal {
+	m := &Modal{
+		Canvas: NewCanvas(nil, top.Rect()),
+		top:    top,
+	}
+	m.AddItem(&backdrop{top.Rect(), &image.Uniform{backdropColor}})
+	m.AddItem(dialog)
+	top.Atomically(func(_ FlushFunc) {
+		m.prevFocus = top.focus
+	})
+	top.AddItem(m)
+	top.setFocus(m)
+	top.Flush()
+	go func() {
+		if _, ok := result.Getter().Get(); ok {
+			m.Dismiss()
+		}
+	}()
+	return m
+}
+
+// Dismiss removes the dialog and its backdrop, and
+// restores the keyboard focus to the item that had it
+// when the dialog was shown.
+//
+func (m *Modal) Dismiss() {
+	m.top.Atomically(func(flush FlushFunc) {
+		if m.top.deleteItem(m, flush) && m.top.focus == nil {
+			m.top.changeFocus(m.prevFocus, flush)
+		}
+	})
+	m.top.Flush()
+}
+
+// HandleKey delivers k to the item in the dialog with
+// the focus. Tab and Shift-Tab move the focus around
+// the items in the dialog, but never out of it, and
+// all other keys are absorbed.
+//
+func (m *Modal) HandleKey(f Flusher, k ui.KeyEvent) bool {
+	if !m.Canvas.HandleKey(f, k) {
+		switch k.Key {
+		case KeyTab:
+			m.advanceFocus(false, true)
+		case KeyBackTab:
+			m.advanceFocus(true, true)
+		}
+	}
+	return true
+}
+
+// backdrop dims everything underneath it, and
+// absorbs all mouse events.
+type backdrop struct {
+	r    image.Rectangle
+	fill image.Image
+}
+
+var _ HandlerItem = (*backdrop)(nil)
+
+func (b *backdrop) Draw(dst draw.Image, clipr image.Rectangle) {
+	draw.Draw(dst, b.r.Intersect(clipr), b.fill, image.ZP, draw.Over)
+}
+
+func (b *backdrop) SetContainer(_ Backing) {
+}
+
+func (b *backdrop) Bbox() image.Rectangle {
+	return b.r
+}
+
+func (b *backdrop) HitTest(p image.Point) bool {
+	return p.In(b.r)
+}
+
+func (b *backdrop) Opaque() bool {
+	return false
+}
+
+func (b *backdrop) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&^wheelButtons != 0 {
+		waitRelease(m.Buttons, ec)
+	}
+	return true
+}
@@ -10,20 +10,6 @@ import (
 	"math"
 )
 
-// Box creates a rectangular image of the given size, filled with the given colour,
-// with a border-size border of colour borderCol.
-//
-func Box(width, height int, col image.Image, border int, borderCol image.Image) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	if border < 0 {
-		border = 0
-	}
-	r := image.Rect(0, 0, width, height)
-	draw.DrawMask(img, r.Inset(border), col, image.ZP, nil, image.ZP, draw.Src)
-	BorderOp(img, r, border, borderCol, image.ZP, draw.Src)
-	return img
-}
-
 // An ImageItem is an Item that uses an image
 // to draw itself. It is intended to be used as a building
 // block for other Items.
@@ -92,8 +78,17 @@ func (obj *Image) SetCentre(p image.Point) {
 	obj.backing.Atomically(func(flush FlushFunc) {
 		r := obj.item.R
 		obj.item.R = r.Add(p.Sub(r.Min))
-		flush(r, nil)
-		flush(obj.item.R, nil)
+		flush.Rects(nil, r, obj.item.R)
+	})
+}
+
+// Move moves the image by delta.
+//
+func (obj *Image) Move(delta image.Point) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.item.R
+		obj.item.R = r.Add(delta)
+		flush.Rects(nil, r, obj.item.R)
 	})
 }
 
@@ -111,14 +106,23 @@ type Polygon struct {
 //
 func NewPolygon(fill image.Image, points []image.Point) *Polygon {
 	obj := new(Polygon)
+	obj.raster.SetFill(fill)
+	obj.points = pixel2fixPoints(points)
+	obj.Item = &obj.raster
+	obj.backing = NullBacking()
+	return obj
+}
+
+func pixel2fixPoints(points []image.Point) []raster.Point {
 	rpoints := make([]raster.Point, len(points))
 	for i, p := range points {
 		rpoints[i] = pixel2fixPoint(p)
 	}
-	obj.raster.SetFill(fill)
-	obj.points = rpoints
-	obj.Item = &obj.raster
-	return obj
+	return rpoints
+}
+
+func (obj *Polygon) rasterItem() *RasterItem {
+	return &obj.raster
 }
 
 func (obj *Polygon) SetContainer(c Backing) {
@@ -139,6 +143,193 @@ func (obj *Polygon) makeOutline() {
 	obj.raster.CalcBbox()
 }
 
+// GetPoints returns a copy of the polygon's vertices.
+//
+func (obj *Polygon) GetPoints() (points []image.Point) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		points = make([]image.Point, len(obj.points))
+		for i, p := range obj.points {
+			points[i] = fix2pixelPoint(p)
+		}
+	})
+	return
+}
+
+// SetPoints replaces all the vertices of the polygon.
+//
+func (obj *Polygon) SetPoints(points []image.Point) {
+	obj.reshape(func() {
+		obj.points = pixel2fixPoints(points)
+	})
+}
+
+// SetPointsFixed is like SetPoints, but the vertices are
+// in fixed point, so that they can lie between pixels.
+//
+func (obj *Polygon) SetPointsFixed(points []raster.Point) {
+	obj.reshape(func() {
+		obj.points = append([]raster.Point(nil), points...)
+	})
+}
+
+// InsertPoint inserts a new vertex before the vertex
+// with index i. If i is len(obj.GetPoints()), the vertex
+// is added at the end. It panics if i is out of range.
+//
+func (obj *Polygon) InsertPoint(i int, p image.Point) {
+	obj.reshape(func() {
+		if i < 0 || i > len(obj.points) {
+			panic("Polygon.InsertPoint: index out of range")
+		}
+		obj.points = append(obj.points, raster.Point{})
+		copy(obj.points[i+1:], obj.points[i:])
+		obj.points[i] = pixel2fixPoint(p)
+	})
+}
+
+// DeletePoint deletes the vertex with index i.
+// It panics if i is out of range.
+//
+func (obj *Polygon) DeletePoint(i int) {
+	obj.reshape(func() {
+		if i < 0 || i >= len(obj.points) {
+			panic("Polygon.DeletePoint: index out of range")
+		}
+		obj.points = append(obj.points[:i], obj.points[i+1:]...)
+	})
+}
+
+// reshape calls f to change the polygon's vertices,
+// then recalculates its outline and flushes the
+// old and new areas.
+func (obj *Polygon) reshape(f func()) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		f()
+		obj.makeOutline()
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+// Move moves the polygon by delta. As long as the polygon lies
+// wholly inside its canvas, its shape is not rasterized again,
+// making it cheap to drag even a polygon with many vertices.
+//
+func (obj *Polygon) Move(delta image.Point) {
+	obj.MoveFixed(pixel2fixPoint(delta))
+}
+
+// MoveFixed is like Move, but delta is in fixed point, with
+// 8 bits of fraction as for raster.Fix32, so that the polygon
+// can be moved by a fraction of a pixel. Its shape is rasterized
+// again unless delta is a whole number of pixels.
+//
+func (obj *Polygon) MoveFixed(delta raster.Point) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.moveFixed(delta, flush)
+	})
+}
+
+func (obj *Polygon) moveFixed(delta raster.Point, flush FlushFunc) {
+	r := obj.raster.Bbox()
+	for i, p := range obj.points {
+		obj.points[i] = raster.Point{p.X + delta.X, p.Y + delta.Y}
+	}
+	if !wholePixels(delta) || !obj.raster.translate(fix2pixelPoint(delta)) {
+		obj.makeOutline()
+	}
+	flush.Rects(nil, r, obj.raster.Bbox())
+}
+
+// Transform applies m to the vertices of the polygon.
+//
+func (obj *Polygon) Transform(m Affine) {
+	obj.reshape(func() {
+		for i, p := range obj.points {
+			obj.points[i] = m.fixed(p)
+		}
+	})
+}
+
+// SetFill changes the colour of the polygon.
+//
+func (obj *Polygon) SetFill(fill image.Image) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetFill(fill)
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+// SetFillRule sets the rule used to determine the
+// inside of the polygon when its edges intersect.
+//
+func (obj *Polygon) SetFillRule(rule FillRule) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetFillRule(rule)
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+// SetAntialias sets the anti-aliasing used to draw the polygon.
+// If a is AntialiasDefault, the setting of its canvas is used.
+//
+func (obj *Polygon) SetAntialias(a Antialias) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetAntialias(a)
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+// HitTest returns true if p lies inside the polygon.
+// Rather than relying on the rasterized outline, it tests
+// the centre of the pixel at p against the polygon's vertices
+// using the polygon's fill rule, so that points in the
+// concave parts of a shape do not count as hits.
+//
+func (obj *Polygon) HitTest(p image.Point) bool {
+	if !p.In(obj.raster.Bbox()) {
+		return false
+	}
+	return obj.raster.FillRule().inside(winding(obj.points, pixelCentre(p)))
+}
+
+// pixelCentre returns the fixed point coordinate of the
+// centre of the pixel at p.
+func pixelCentre(p image.Point) raster.Point {
+	q := pixel2fixPoint(p)
+	return raster.Point{q.X + fixScale/2, q.Y + fixScale/2}
+}
+
+// winding returns the winding number of the closed polygon
+// with vertices pts around the point p.
+func winding(pts []raster.Point, p raster.Point) int {
+	if len(pts) < 3 {
+		return 0
+	}
+	n := 0
+	p0 := pts[len(pts)-1]
+	for _, p1 := range pts {
+		if p0.Y <= p.Y {
+			if p1.Y > p.Y && side(p0, p1, p) > 0 {
+				n++
+			}
+		} else {
+			if p1.Y <= p.Y && side(p0, p1, p) < 0 {
+				n--
+			}
+		}
+		p0 = p1
+	}
+	return n
+}
+
+// side returns a positive number if p is to the left of the line
+// through p0 and p1, negative if it is to the right, and zero
+// if it lies on the line.
+func side(p0, p1, p raster.Point) int64 {
+	return int64(p1.X-p0.X)*int64(p.Y-p0.Y) - int64(p.X-p0.X)*int64(p1.Y-p0.Y)
+}
+
 // A line object represents a single straight line.
 type Line struct {
 	Item
@@ -146,6 +337,7 @@ type Line struct {
 	backing Backing
 	p0, p1  raster.Point
 	width   raster.Fix32
+	slop    float64 // hit tolerance in pixels.
 }
 
 // Line returns a new Line, coloured with col, from p0 to p1,
@@ -159,9 +351,14 @@ func NewLine(fill image.Image, p0, p1 image.Point, width float64) *Line {
 	obj.raster.SetFill(fill)
 	obj.Item = &obj.raster
 	obj.makeOutline()
+	obj.backing = NullBacking()
 	return obj
 }
 
+func (obj *Line) rasterItem() *RasterItem {
+	return &obj.raster
+}
+
 func (obj *Line) SetContainer(b Backing) {
 	obj.backing = b
 	obj.raster.SetContainer(b)
@@ -170,25 +367,35 @@ func (obj *Line) SetContainer(b Backing) {
 
 func (obj *Line) makeOutline() {
 	obj.raster.Clear()
+	pts := obj.outline()
+	obj.raster.Start(pts[0])
+	for _, p := range pts[1:] {
+		obj.raster.Add1(p)
+	}
+	obj.raster.Add1(pts[0])
+	obj.raster.CalcBbox()
+}
+
+// outline returns the corners of the quadrilateral
+// that is filled to draw the line.
+func (obj *Line) outline() [4]raster.Point {
 	sin, cos := isincos2(obj.p1.X-obj.p0.X, obj.p1.Y-obj.p0.Y)
 	dx := (cos * obj.width) / (2 * fixScale)
 	dy := (sin * obj.width) / (2 * fixScale)
-	q := raster.Point{
+	q0 := raster.Point{
 		obj.p0.X + fixScale/2 - sin/2,
 		obj.p0.Y + fixScale/2 - cos/2,
 	}
-	p0 := raster.Point{q.X - dx, q.Y + dy}
-	obj.raster.Start(p0)
-	obj.raster.Add1(raster.Point{q.X + dx, q.Y - dy})
-
-	q = raster.Point{
+	q1 := raster.Point{
 		obj.p1.X + fixScale/2 + sin/2,
 		obj.p1.Y + fixScale/2 + cos/2,
 	}
-	obj.raster.Add1(raster.Point{q.X + dx, q.Y - dy})
-	obj.raster.Add1(raster.Point{q.X - dx, q.Y + dy})
-	obj.raster.Add1(p0)
-	obj.raster.CalcBbox()
+	return [4]raster.Point{
+		{q0.X - dx, q0.Y + dy},
+		{q0.X + dx, q0.Y - dy},
+		{q1.X + dx, q1.Y - dy},
+		{q1.X - dx, q1.Y + dy},
+	}
 }
 
 // SetEndPoints changes the end coordinates of the Line.
@@ -199,11 +406,131 @@ func (obj *Line) SetEndPoints(p0, p1 image.Point) {
 		obj.p0 = pixel2fixPoint(p0)
 		obj.p1 = pixel2fixPoint(p1)
 		obj.makeOutline()
-		flush(r, nil)
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+// SetEndPointsFixed is like SetEndPoints, but the
+// coordinates are in fixed point, with 8 bits of
+// fraction as for raster.Fix32, so that the ends of the line can lie between
+// pixels and a slowly moving line moves smoothly.
+//
+func (obj *Line) SetEndPointsFixed(p0, p1 raster.Point) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		obj.p0 = p0
+		obj.p1 = p1
+		obj.makeOutline()
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+// Move moves the line by delta, without rasterizing
+// it again as long as it lies wholly inside its canvas.
+//
+func (obj *Line) Move(delta image.Point) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		d := pixel2fixPoint(delta)
+		obj.p0 = raster.Point{obj.p0.X + d.X, obj.p0.Y + d.Y}
+		obj.p1 = raster.Point{obj.p1.X + d.X, obj.p1.Y + d.Y}
+		if !obj.raster.translate(delta) {
+			obj.makeOutline()
+		}
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+// Transform applies m to the end points of the line;
+// its width stays the same.
+//
+func (obj *Line) Transform(m Affine) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		obj.p0 = m.fixed(obj.p0)
+		obj.p1 = m.fixed(obj.p1)
+		obj.makeOutline()
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+// setEndPoints is like SetEndPoints, but does not lock
+// or flush, for use by widgets from within Atomically.
+func (obj *Line) setEndPoints(p0, p1 image.Point) {
+	obj.p0 = pixel2fixPoint(p0)
+	obj.p1 = pixel2fixPoint(p1)
+	obj.makeOutline()
+}
+
+// SetWidth changes the width of the line.
+//
+func (obj *Line) SetWidth(width float64) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		obj.width = float2fix(width)
+		obj.makeOutline()
+		flush(r.Union(obj.raster.Bbox()), nil)
+	})
+}
+
+// SetAntialias sets the anti-aliasing used to draw the line.
+// If a is AntialiasDefault, the setting of its canvas is used.
+//
+func (obj *Line) SetAntialias(a Antialias) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetAntialias(a)
 		flush(obj.raster.Bbox(), nil)
 	})
 }
 
+// SetHitTolerance sets the distance, in pixels, from the
+// edge of the line within which a point will still be
+// considered to hit it. This allows a thin line to
+// be selected without pixel-perfect mouse positioning.
+//
+func (obj *Line) SetHitTolerance(d float64) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		if d < 0 {
+			d = 0
+		}
+		obj.slop = d
+	})
+}
+
+// HitTest returns true if the centre of the pixel at p
+// is within the line's hit tolerance of its stroke.
+//
+func (obj *Line) HitTest(p image.Point) bool {
+	slop := int(obj.slop + 0.5)
+	if !p.In(obj.raster.Bbox().Inset(-slop)) {
+		return false
+	}
+	q := pixelCentre(p)
+	p0 := raster.Point{obj.p0.X + fixScale/2, obj.p0.Y + fixScale/2}
+	p1 := raster.Point{obj.p1.X + fixScale/2, obj.p1.Y + fixScale/2}
+	return segmentDist(p0, p1, q) <= fixed2float(obj.width)/2+obj.slop
+}
+
+// segmentDist returns the distance in pixels between p
+// and the line segment from p0 to p1.
+func segmentDist(p0, p1, p raster.Point) float64 {
+	x0, y0 := fixed2float(p0.X), fixed2float(p0.Y)
+	dx, dy := fixed2float(p1.X)-x0, fixed2float(p1.Y)-y0
+	px, py := fixed2float(p.X)-x0, fixed2float(p.Y)-y0
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		t := (px*dx + py*dy) / l2
+		switch {
+		case t > 1:
+			t = 1
+		case t < 0:
+			t = 0
+		}
+		px -= t * dx
+		py -= t * dy
+	}
+	return math.Hypot(px, py)
+}
+
 // SetColor changes the colour of the line
 //
 func (obj *Line) SetFill(fill image.Image) {
@@ -225,30 +552,62 @@ type Slider struct {
 	backing Backing
 	value   values.Value
 	Item
-	c      *Canvas
-	val    float64
-	box    ImageItem
-	button ImageItem
-}
+	c        *Canvas
+	vertical bool
+	val      float64 // position of the button, in the range [0, 1].
+	cur      float64 // most recently received value.
+	min, max float64
+	step     float64
+	incr     float64
+	box      ImageItem
+	button   ImageItem
+	bsize    int // length of the button.
+	fg, bg   color.Color
+	theme    *Theme
+	pref     image.Point // the size the slider was created with.
+}
+
+// An Orientation gives the direction in which a widget is laid out.
+type Orientation int
+
+const (
+	Horizontal Orientation = iota
+	Vertical
+)
 
 // A Slider shows a mouse-adjustable slider bar.
 // NewSlider returns the Slider item.
 // The value is used to set and get the current slider value;
 // its Type() should be float64; the slider's value is in the
-// range [0, 1].
+// range [0, 1] unless changed with SetRange.
 //
 func NewSlider(r image.Rectangle, fg, bg color.Color, value values.Value) (obj *Slider) {
+	return NewOrientedSlider(r, Horizontal, fg, bg, value)
+}
+
+// NewVSlider is like NewSlider but returns a vertical slider.
+// The value is 0 when the button is at the bottom
+// and 1 when it is at the top.
+//
+func NewVSlider(r image.Rectangle, fg, bg color.Color, value values.Value) (obj *Slider) {
+	return NewOrientedSlider(r, Vertical, fg, bg, value)
+}
+
+// NewOrientedSlider returns a new Slider with the given orientation.
+//
+func NewOrientedSlider(r image.Rectangle, o Orientation, fg, bg color.Color, value values.Value) (obj *Slider) {
 	obj = new(Slider)
 	obj.value = value
+	obj.vertical = o == Vertical
+	obj.min, obj.max = 0, 1
+	obj.backing = NullBacking()
 	obj.c = NewCanvas(nil, r)
+	obj.fg, obj.bg = fg, bg
+	obj.pref = r.Size()
 	obj.box.R = r
-	obj.box.Image = Box(r.Dx(), r.Dy(), &image.Uniform{bg}, 1, image.Black)
 	obj.box.IsOpaque = opaqueColor(bg)
-
-	br := obj.buttonRect()
-	obj.button.R = br
-	obj.button.Image = Box(br.Dx(), br.Dy(), &image.Uniform{fg}, 1, image.Black)
 	obj.button.IsOpaque = opaqueColor(fg)
+	obj.restyle()
 	obj.c.AddItem(&obj.box)
 	obj.c.AddItem(&obj.button)
 
@@ -258,19 +617,205 @@ func NewSlider(r image.Rectangle, fg, bg color.Color, value values.Value) (obj *
 	return obj
 }
 
-const buttonWidth = 6
-
 func (obj *Slider) SetContainer(c Backing) {
 	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the slider,
+// overriding that of its canvas. If t is nil, the
+// canvas's theme is used.
+//
+func (obj *Slider) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.box.R, nil)
+	})
+	obj.backing.Flush()
+}
+
+var _ ResizableItem = (*Slider)(nil)
+var _ Sizer = (*Slider)(nil)
+
+// SetBounds moves and resizes the slider to occupy r.
+//
+func (obj *Slider) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.box.R
+		obj.box.R = r
+		obj.c.r = r
+		obj.restyle()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed for the button
+// to have room to move its own length.
+//
+func (obj *Slider) MinSize() image.Point {
+	t := themeFor(obj.theme, obj.backing)
+	n := t.ButtonSize + 2*t.BorderWidth
+	if obj.vertical {
+		return image.Pt(n, 2*n)
+	}
+	return image.Pt(2*n, n)
+}
+
+func (obj *Slider) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+// restyle redraws the box and button images
+// using the slider's current theme.
+func (obj *Slider) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	r := obj.box.R
+	obj.bsize = t.ButtonSize
+	obj.box.Image = Box(r.Dx(), r.Dy(), &image.Uniform{obj.bg}, t.BorderWidth, t.border())
+	br := obj.buttonRect()
+	obj.button.R = br
+	obj.button.Image = Box(br.Dx(), br.Dy(), &image.Uniform{obj.fg}, t.BorderWidth, t.border())
+}
+
+// SetRange sets the range of the slider's value to [min, max].
+// If step is greater than zero, the values produced by
+// dragging the slider are rounded to the nearest multiple
+// of step from min.
+// The default range is [0, 1] with no step.
+//
+func (obj *Slider) SetRange(min, max, step float64) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.min, obj.max, obj.step = min, max, step
+		obj.moveButton(flush)
+	})
+	obj.backing.Flush()
+}
+
+// SetIncrement sets the amount by which the value
+// changes when the slider is nudged with the arrow
+// keys or the scroll wheel. If incr is zero (the default),
+// the step is used, or a hundredth of the range if
+// there is no step.
+//
+func (obj *Slider) SetIncrement(incr float64) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		obj.incr = incr
+	})
+}
+
+// nudge changes the value by n increments,
+// keeping it within range.
+func (obj *Slider) nudge(n int) {
+	var v float64
+	obj.backing.Atomically(func(_ FlushFunc) {
+		incr := obj.incr
+		switch {
+		case incr != 0:
+		case obj.step > 0:
+			incr = obj.step
+		default:
+			incr = (obj.max - obj.min) / 100
+		}
+		if obj.max < obj.min {
+			incr = -incr
+		}
+		v = obj.cur + float64(n)*incr
+		v = obj.min + obj.val2frac(v)*(obj.max-obj.min)
+	})
+	obj.value.Set(v)
+}
+
+// HandleKey moves the slider with the arrow keys.
+// Home and End move it to the ends of its range.
+//
+func (obj *Slider) HandleKey(f Flusher, k ui.KeyEvent) bool {
+	switch k.Key {
+	case KeyRight, KeyUp:
+		obj.nudge(1)
+	case KeyLeft, KeyDown:
+		obj.nudge(-1)
+	case KeyHome:
+		obj.value.Set(obj.frac2val(0))
+	case KeyEnd:
+		obj.value.Set(obj.frac2val(1))
+	default:
+		return false
+	}
+	return true
+}
+
+// frac2val returns the value corresponding to
+// the position f along the slider, snapped
+// to the nearest step.
+func (obj *Slider) frac2val(f float64) (v float64) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		if f < 0 {
+			f = 0
+		}
+		if f > 1 {
+			f = 1
+		}
+		v = obj.min + f*(obj.max-obj.min)
+		if obj.step > 0 {
+			v = obj.min + math.Floor((v-obj.min)/obj.step+0.5)*obj.step
+			// don't let rounding take the value out of range.
+			switch {
+			case obj.max > obj.min && v > obj.max:
+				v -= obj.step
+			case obj.max < obj.min && v < obj.max:
+				v += obj.step
+			}
+		}
+	})
+	return
+}
+
+// val2frac returns the position along the slider
+// corresponding to v.
+func (obj *Slider) val2frac(v float64) float64 {
+	if obj.max == obj.min {
+		return 0
+	}
+	f := (v - obj.min) / (obj.max - obj.min)
+	if f > 1 {
+		f = 1
+	}
+	if f < 0 {
+		f = 0
+	}
+	return f
+}
+
+// axis returns the extent of the slider's box
+// along its direction of travel.
+func (obj *Slider) axis() (min, max int) {
+	if obj.vertical {
+		return obj.box.R.Min.Y, obj.box.R.Max.Y
+	}
+	return obj.box.R.Min.X, obj.box.R.Max.X
 }
 
 func (obj *Slider) buttonRect() (r image.Rectangle) {
-	r.Min.Y = obj.box.R.Min.Y
-	r.Max.Y = obj.box.R.Max.Y
+	min, max := obj.axis()
 	p := obj.val
-	centre := int(p*float64(obj.box.R.Max.X-obj.box.R.Min.X-buttonWidth)+0.5) + obj.box.R.Min.X + buttonWidth/2
-	r.Min.X = centre - buttonWidth/2
-	r.Max.X = centre + buttonWidth/2
+	if obj.vertical {
+		p = 1 - p
+	}
+	centre := int(p*float64(max-min-obj.bsize)+0.5) + min + obj.bsize/2
+	if obj.vertical {
+		r.Min.X = obj.box.R.Min.X
+		r.Max.X = obj.box.R.Max.X
+		r.Min.Y = centre - obj.bsize/2
+		r.Max.Y = centre + obj.bsize/2
+	} else {
+		r.Min.Y = obj.box.R.Min.Y
+		r.Max.Y = obj.box.R.Max.Y
+		r.Min.X = centre - obj.bsize/2
+		r.Max.X = centre + obj.bsize/2
+	}
 	return
 }
 
@@ -283,47 +828,61 @@ func (obj *Slider) listener() {
 		}
 		v := x.(float64)
 		obj.backing.Atomically(func(flush FlushFunc) {
-			if v > 1 {
-				v = 1
-			}
-			if v < 0 {
-				v = 0
-			}
-			obj.val = v
-			r := obj.button.R
-			obj.button.R = obj.buttonRect()
-			flush(r, nil)
-			flush(obj.button.R, nil)
+			obj.cur = v
+			obj.moveButton(flush)
 		})
 		obj.backing.Flush()
 	}
 }
 
-func (obj *Slider) x2val(x int) float64 {
-	return float64(x-(obj.box.R.Min.X+buttonWidth/2)) / float64(obj.box.R.Dx()-buttonWidth)
+// moveButton moves the button to reflect the current value.
+func (obj *Slider) moveButton(flush FlushFunc) {
+	obj.val = obj.val2frac(obj.cur)
+	r := obj.button.R
+	obj.button.R = obj.buttonRect()
+	flush.Rects(nil, r, obj.button.R)
+}
+
+// coord returns the component of p along the
+// slider's direction of travel.
+func (obj *Slider) coord(p image.Point) int {
+	if obj.vertical {
+		return p.Y
+	}
+	return p.X
+}
+
+func (obj *Slider) pos2frac(x int) float64 {
+	min, max := obj.axis()
+	v := float64(x-(min+obj.bsize/2)) / float64(max-min-obj.bsize)
+	if obj.vertical {
+		v = 1 - v
+	}
+	return v
 }
 
 func (obj *Slider) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
-	if m.Buttons&1 == 0 {
+	switch {
+	case m.Buttons&(WheelUp|WheelRight) != 0:
+		obj.nudge(1)
+		return true
+	case m.Buttons&(WheelDown|WheelLeft) != 0:
+		obj.nudge(-1)
+		return true
+	case m.Buttons&1 == 0:
 		return false
 	}
 	offset := 0
 	br := obj.buttonRect()
 	if !m.Loc.In(br) {
-		obj.value.Set(obj.x2val(m.Loc.X))
+		obj.value.Set(obj.frac2val(obj.pos2frac(obj.coord(m.Loc))))
 	} else {
-		offset = m.Loc.X - (br.Min.X+br.Max.X)/2
+		offset = obj.coord(m.Loc) - obj.coord(centre(br))
 	}
 
-	but := m.Buttons
-	for {
-		if m, ok := (<-ec).(*ui.MouseEvent); ok {
-			obj.value.Set(obj.x2val(m.Loc.X - offset))
-			if (m.Buttons & but) != but {
-				break
-			}
-		}
-	}
+	Drag(m, ec, func(m ui.MouseEvent) {
+		obj.value.Set(obj.frac2val(obj.pos2frac(obj.coord(m.Loc) - offset)))
+	})
 	return true
 }
 
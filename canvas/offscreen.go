@@ -0,0 +1,80 @@
+package canvas
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// An ImageBacking is a Backing that draws into an image in
+// memory rather than onto a window, for testing items without
+// a window system, or for drawing on a server with no display,
+// such as charts rendered by a web service. It is a Background,
+// so an item, usually a Canvas, is placed in it with SetItem.
+//
+type ImageBacking struct {
+	*Background
+	img *image.RGBA
+
+	mu    sync.Mutex
+	shown damage // areas shown since the last call to Shown.
+}
+
+// NewImageBacking returns a new ImageBacking drawing into a new
+// image with bounds r, with bg drawn behind its item. If bg
+// is nil, the background is transparent.
+//
+func NewImageBacking(r image.Rectangle, bg image.Image) *ImageBacking {
+	if bg == nil {
+		bg = image.Transparent
+	}
+	b := &ImageBacking{img: image.NewRGBA(r)}
+	b.Background = NewBackground(b.img, bg, func(r image.Rectangle) {
+		b.mu.Lock()
+		b.shown.add(r)
+		b.mu.Unlock()
+	})
+	return b
+}
+
+// NewImageCanvas returns a new canvas covering r inside a new
+// ImageBacking, as for NewImageBacking, and the backing itself.
+//
+func NewImageCanvas(r image.Rectangle, bg image.Image) (*Canvas, *ImageBacking) {
+	b := NewImageBacking(r, bg)
+	c := NewCanvas(nil, r)
+	b.SetItem(c)
+	return c, b
+}
+
+// Image returns the image that b draws into. It should only be
+// read from within Atomically, as its contents may be changing;
+// Snapshot returns a copy that may be read at any time.
+//
+func (b *ImageBacking) Image() *image.RGBA {
+	return b.img
+}
+
+// Snapshot flushes b and returns a copy
+// of the image that it draws into.
+//
+func (b *ImageBacking) Snapshot() *image.RGBA {
+	b.Flush()
+	img := image.NewRGBA(b.img.Bounds())
+	b.Atomically(func(_ FlushFunc) {
+		draw.Draw(img, img.Rect, b.img, img.Rect.Min, draw.Src)
+	})
+	return img
+}
+
+// Shown returns the areas of the image that have been
+// made visible by Flush since Shown was last called,
+// so that a test can check what a change redraws.
+//
+func (b *ImageBacking) Shown() []image.Rectangle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rs := []image.Rectangle(b.shown)
+	b.shown = nil
+	return rs
+}
@@ -0,0 +1,427 @@
+package canvas
+
+import (
+	"bufio"
+	"bytes"
+	"code.google.com/p/freetype-go/freetype/raster"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WritePDF writes c to w as a PDF document of one page, the size
+// of c, with one pixel to each point, for printing or reports.
+// The built-in items Rect, Polygon, Line, Ellipse and Path, and
+// nested canvases, are written as vector graphics, as long as
+// their fills are nil or uniform colours, so that they stay sharp
+// when printed; other items, such as text and images, and those
+// in a canvas showing a zoomed view, are drawn as images, using
+// the resolution of the canvas.
+//
+func (c *Canvas) WritePDF(w io.Writer) error {
+	var pw pdfWriter
+	top := outermost(c)
+	c.Atomically(func(_ FlushFunc) {
+		top.rendering = true
+		defer func() {
+			top.rendering = false
+		}()
+		pw.begin(c.r)
+		pw.canvas(c, c.r)
+	})
+	return pw.write(w, c.r)
+}
+
+// SavePDF writes c in PDF format to the
+// named file, creating it if necessary.
+//
+func (c *Canvas) SavePDF(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	err = c.WritePDF(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// A pdfWriter accumulates the content of a page
+// and the objects that it uses.
+type pdfWriter struct {
+	content bytes.Buffer
+	images  []*image.RGBA    // drawn as image XObjects /Im0, /Im1...
+	alphas  map[uint8]string // the graphics states setting each alpha.
+}
+
+// begin starts the page, flipping its coordinates so
+// that they are those of the image, with y downwards.
+func (pw *pdfWriter) begin(r image.Rectangle) {
+	pw.alphas = make(map[uint8]string)
+	pw.printf("1 0 0 -1 %d %d cm\n", -r.Min.X, r.Max.Y)
+}
+
+func (pw *pdfWriter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&pw.content, format, args...)
+}
+
+// canvas writes c and its items, clipped to clipr.
+func (pw *pdfWriter) canvas(c *Canvas, clipr image.Rectangle) {
+	clipr = clipr.Intersect(c.r)
+	if clipr.Empty() {
+		return
+	}
+	if !c.xform().identity() {
+		pw.raster(c, clipr)
+		return
+	}
+	pw.printf("q %d %d %d %d re W n\n", clipr.Min.X, clipr.Min.Y, clipr.Dx(), clipr.Dy())
+	if c.background != nil {
+		if col, ok := uniformColor(c.background); ok && c.bgmode != BackgroundCentred {
+			pw.fill(col, func() {
+				pw.rect(c.r)
+			}, "f")
+		} else {
+			img := newRGBA(clipr)
+			c.drawBackground(img, clipr)
+			pw.image(img)
+		}
+	}
+	if c.static != nil {
+		for _, it := range c.static.items {
+			pw.item(it, clipr)
+		}
+	}
+	for e := c.items.Front(); e != nil; e = e.Next() {
+		pw.item(e.Value.(Item), clipr)
+	}
+	pw.printf("Q\n")
+}
+
+// item writes it, clipped to clipr, as vector graphics
+// if it can, or as an image if not.
+func (pw *pdfWriter) item(it Item, clipr image.Rectangle) {
+	if !it.Bbox().Overlaps(clipr) {
+		return
+	}
+	ok := true
+	switch it := it.(type) {
+	case *Canvas:
+		pw.canvas(it, clipr)
+	case *Rect:
+		ok = pw.rectItem(it)
+	case *Polygon:
+		ok = pw.shape(it.raster.fill, it.raster.FillRule(), func() {
+			pw.polygon(it.points)
+		})
+	case *Line:
+		ok = pw.shape(it.raster.fill, NonZero, func() {
+			pts := it.outline()
+			pw.polygon(pts[:])
+		})
+	case *Ellipse:
+		ok = pw.shape(it.raster.fill, NonZero, func() {
+			pw.ellipse(fix2PointF(it.cr), fixed2float(it.ra), fixed2float(it.rb))
+		})
+	case *Path:
+		ok = pw.shape(it.raster.fill, it.raster.FillRule(), func() {
+			pw.path(it.segs)
+		})
+	default:
+		ok = false
+	}
+	if !ok {
+		pw.raster(it, clipr)
+	}
+}
+
+// raster draws it onto an image, which is written in its place.
+func (pw *pdfWriter) raster(it Item, clipr image.Rectangle) {
+	r := it.Bbox().Intersect(clipr)
+	if r.Empty() {
+		return
+	}
+	img := newRGBA(r)
+	it.Draw(img, r)
+	pw.image(img)
+}
+
+// shape fills the outline written by outline with fill, using
+// rule, reporting false if fill is not a uniform colour.
+func (pw *pdfWriter) shape(fill image.Image, rule FillRule, outline func()) bool {
+	if fill == nil {
+		return true
+	}
+	col, ok := uniformColor(fill)
+	if !ok {
+		return false
+	}
+	op := "f"
+	if rule != NonZero {
+		op = "f*"
+	}
+	pw.fill(col, outline, op)
+	return true
+}
+
+func (pw *pdfWriter) rectItem(obj *Rect) bool {
+	fill, ok := uniformColor(obj.fill)
+	if obj.fill != nil && !ok {
+		return false
+	}
+	border, ok := uniformColor(obj.borderFill)
+	if obj.border > 0 && obj.borderFill != nil && !ok {
+		return false
+	}
+	inner := obj.r.Inset(obj.border)
+	if obj.fill != nil {
+		pw.fill(fill, func() {
+			pw.rect(inner)
+		}, "f")
+	}
+	if obj.border > 0 && obj.borderFill != nil {
+		// The border is the area between the
+		// outer and inner rectangles.
+		pw.fill(border, func() {
+			pw.rect(obj.r)
+			pw.rect(inner)
+		}, "f*")
+	}
+	return true
+}
+
+// fill fills the path written by outline with col, using op.
+func (pw *pdfWriter) fill(col color.NRGBA, outline func(), op string) {
+	if col.A == 0 {
+		return
+	}
+	pw.printf("q %s %s %s rg\n", pdfColor(col.R), pdfColor(col.G), pdfColor(col.B))
+	if col.A != 0xff {
+		name, ok := pw.alphas[col.A]
+		if !ok {
+			name = "A" + strconv.Itoa(int(col.A))
+			pw.alphas[col.A] = name
+		}
+		pw.printf("/%s gs\n", name)
+	}
+	outline()
+	pw.printf("%s Q\n", op)
+}
+
+func (pw *pdfWriter) rect(r image.Rectangle) {
+	pw.printf("%d %d %d %d re\n", r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+}
+
+func (pw *pdfWriter) polygon(points []raster.Point) {
+	for i, p := range points {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		pw.printf("%s %s\n", pdfPoint(fix2PointF(p)), op)
+	}
+	if len(points) > 0 {
+		pw.printf("h\n")
+	}
+}
+
+// ellipse writes an ellipse centred on c with radii ra
+// and rb as four cubic curves, one for each quadrant.
+func (pw *pdfWriter) ellipse(c PointF, ra, rb float64) {
+	// The distance along the tangent of the control points
+	// of a cubic approximating a quarter circle.
+	const k = 0.5522847498
+	kx, ky := k*ra, k*rb
+	pw.printf("%s m\n", pdfPoint(PtF(c.X+ra, c.Y)))
+	pw.printf("%s %s %s c\n", pdfPoint(PtF(c.X+ra, c.Y+ky)), pdfPoint(PtF(c.X+kx, c.Y+rb)), pdfPoint(PtF(c.X, c.Y+rb)))
+	pw.printf("%s %s %s c\n", pdfPoint(PtF(c.X-kx, c.Y+rb)), pdfPoint(PtF(c.X-ra, c.Y+ky)), pdfPoint(PtF(c.X-ra, c.Y)))
+	pw.printf("%s %s %s c\n", pdfPoint(PtF(c.X-ra, c.Y-ky)), pdfPoint(PtF(c.X-kx, c.Y-rb)), pdfPoint(PtF(c.X, c.Y-rb)))
+	pw.printf("%s %s %s c\n", pdfPoint(PtF(c.X+kx, c.Y-rb)), pdfPoint(PtF(c.X+ra, c.Y-ky)), pdfPoint(PtF(c.X+ra, c.Y)))
+	pw.printf("h\n")
+}
+
+func (pw *pdfWriter) path(segs []pathSeg) {
+	var cur PointF
+	for _, s := range segs {
+		switch s.op {
+		case pathMove:
+			pw.printf("%s m\n", pdfPoint(s.p[0]))
+			cur = s.p[0]
+		case pathLine:
+			pw.printf("%s l\n", pdfPoint(s.p[0]))
+			cur = s.p[0]
+		case pathQuad:
+			// PDF has only cubic curves, which can
+			// represent any quadratic exactly.
+			c0 := PtF(cur.X+2.0/3*(s.p[0].X-cur.X), cur.Y+2.0/3*(s.p[0].Y-cur.Y))
+			c1 := PtF(s.p[1].X+2.0/3*(s.p[0].X-s.p[1].X), s.p[1].Y+2.0/3*(s.p[0].Y-s.p[1].Y))
+			pw.printf("%s %s %s c\n", pdfPoint(c0), pdfPoint(c1), pdfPoint(s.p[1]))
+			cur = s.p[1]
+		case pathCubic:
+			pw.printf("%s %s %s c\n", pdfPoint(s.p[0]), pdfPoint(s.p[1]), pdfPoint(s.p[2]))
+			cur = s.p[2]
+		}
+	}
+	pw.printf("h\n")
+}
+
+// image draws img at its own bounds.
+func (pw *pdfWriter) image(img *image.RGBA) {
+	r := img.Rect
+	pw.printf("q %d 0 0 %d %d %d cm /Im%d Do Q\n", r.Dx(), -r.Dy(), r.Min.X, r.Max.Y, len(pw.images))
+	pw.images = append(pw.images, img)
+}
+
+// write writes the document, with a page of the size of r.
+func (pw *pdfWriter) write(w io.Writer, r image.Rectangle) error {
+	out := &pdfOutput{w: w}
+	out.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	// Objects 1, 2 and 3 are the catalog, the page tree and
+	// the page; 4 is the content, followed by an image and
+	// its mask for each image.
+	out.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	out.object(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	var res bytes.Buffer
+	if len(pw.images) > 0 {
+		res.WriteString(" /XObject <<")
+		for i := range pw.images {
+			fmt.Fprintf(&res, " /Im%d %d 0 R", i, 5+2*i)
+		}
+		res.WriteString(" >>")
+	}
+	if len(pw.alphas) > 0 {
+		res.WriteString(" /ExtGState <<")
+		for a, name := range pw.alphas {
+			v := pdfColor(a)
+			fmt.Fprintf(&res, " /%s << /ca %s /CA %s >>", name, v, v)
+		}
+		res.WriteString(" >>")
+	}
+	out.object(3, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources <<%s >> /Contents 4 0 R >>", r.Dx(), r.Dy(), res.String()))
+	out.stream(4, "", pw.content.Bytes())
+	for i, img := range pw.images {
+		obj := 5 + 2*i
+		rgb, alpha := splitAlpha(img)
+		size := fmt.Sprintf("/Width %d /Height %d /BitsPerComponent 8", img.Rect.Dx(), img.Rect.Dy())
+		out.stream(obj, fmt.Sprintf("/Type /XObject /Subtype /Image %s /ColorSpace /DeviceRGB /SMask %d 0 R", size, obj+1), rgb)
+		out.stream(obj+1, fmt.Sprintf("/Type /XObject /Subtype /Image %s /ColorSpace /DeviceGray", size), alpha)
+	}
+	out.trailer()
+	return out.err
+}
+
+// splitAlpha returns the colours of the pixels of img, without the
+// alpha premultiplied, and their alpha, as PDF images hold them.
+func splitAlpha(img *image.RGBA) (rgb, alpha []byte) {
+	r := img.Rect
+	rgb = make([]byte, 0, 3*r.Dx()*r.Dy())
+	alpha = make([]byte, 0, r.Dx()*r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.RGBAAt(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+		}
+	}
+	return
+}
+
+// pdfOutput writes the objects of a PDF document,
+// remembering where each starts for the cross-reference
+// table, and the first error.
+type pdfOutput struct {
+	w       io.Writer
+	n       int64   // the number of bytes written.
+	offsets []int64 // of each object, from object 1.
+	err     error
+}
+
+func (out *pdfOutput) printf(format string, args ...interface{}) {
+	out.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+func (out *pdfOutput) write(b []byte) {
+	if out.err != nil {
+		return
+	}
+	n, err := out.w.Write(b)
+	out.n += int64(n)
+	out.err = err
+}
+
+func (out *pdfOutput) start(obj int) {
+	for len(out.offsets) < obj {
+		out.offsets = append(out.offsets, 0)
+	}
+	out.offsets[obj-1] = out.n
+	out.printf("%d 0 obj\n", obj)
+}
+
+func (out *pdfOutput) object(obj int, body string) {
+	out.start(obj)
+	out.printf("%s\nendobj\n", body)
+}
+
+// stream writes a stream object with the given dictionary
+// entries, compressing data.
+func (out *pdfOutput) stream(obj int, dict string, data []byte) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+	out.start(obj)
+	if dict != "" {
+		dict += " "
+	}
+	out.printf("<< %s/Filter /FlateDecode /Length %d >>\nstream\n", dict, z.Len())
+	out.write(z.Bytes())
+	out.printf("\nendstream\nendobj\n")
+}
+
+func (out *pdfOutput) trailer() {
+	xref := out.n
+	out.printf("xref\n0 %d\n0000000000 65535 f \n", len(out.offsets)+1)
+	for _, off := range out.offsets {
+		out.printf("%010d 00000 n \n", off)
+	}
+	out.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%EOF\n", len(out.offsets)+1, xref)
+}
+
+// uniformColor returns the colour of img, without the alpha
+// premultiplied, if it is a uniform colour.
+func uniformColor(img image.Image) (color.NRGBA, bool) {
+	u, ok := img.(*image.Uniform)
+	if !ok {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBAModel.Convert(u.C).(color.NRGBA), true
+}
+
+// pdfColor returns the colour component c as a number from 0 to 1.
+func pdfColor(c uint8) string {
+	return pdfNum(float64(c) / 255)
+}
+
+func pdfPoint(p PointF) string {
+	return pdfNum(p.X) + " " + pdfNum(p.Y)
+}
+
+// pdfNum formats f with no more precision than is needed.
+func pdfNum(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 4, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		s = "0"
+	}
+	return s
+}
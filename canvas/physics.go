@@ -0,0 +1,138 @@
+package canvas
+
+import (
+	"image"
+	"math"
+	"sync"
+	"time"
+)
+
+// A Body moves an item with simple physics: a velocity that is
+// changed by an acceleration, such as gravity, and slowed by drag,
+// with the item bouncing off the edges of a bounding rectangle.
+// It is run by an Animator (see Simulate), which moves the item
+// each frame, so the item must be movable (see MoveItem).
+// Velocities are in pixels a second, and accelerations in
+// pixels a second per second.
+//
+type Body struct {
+	it         Item
+	mu         sync.Mutex
+	vx, vy     float64
+	ax, ay     float64
+	drag       float64         // the fraction of the speed lost each second.
+	bounds     image.Rectangle // if non-empty, the item stays inside.
+	elasticity float64         // the fraction of the speed kept in a bounce.
+	fx, fy     float64         // movement not yet made, being less than a pixel.
+}
+
+// NewBody returns a new Body moving it, at rest.
+//
+func NewBody(it Item) *Body {
+	return &Body{it: it, elasticity: 1}
+}
+
+// SetVelocity sets the velocity of the body.
+//
+func (b *Body) SetVelocity(vx, vy float64) {
+	b.mu.Lock()
+	b.vx, b.vy = vx, vy
+	b.mu.Unlock()
+}
+
+// Velocity returns the velocity of the body.
+//
+func (b *Body) Velocity() (vx, vy float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.vx, b.vy
+}
+
+// SetAcceleration sets the constant acceleration of the body.
+//
+func (b *Body) SetAcceleration(ax, ay float64) {
+	b.mu.Lock()
+	b.ax, b.ay = ax, ay
+	b.mu.Unlock()
+}
+
+// SetDrag sets the fraction of its speed that the
+// body loses each second, from 0 for none to 1.
+//
+func (b *Body) SetDrag(drag float64) {
+	b.mu.Lock()
+	b.drag = clamp01(drag)
+	b.mu.Unlock()
+}
+
+// SetBounds keeps the bounding box of the item inside r,
+// bouncing it off the edges and keeping the fraction elasticity
+// of its speed each time. If r is empty, the item may go anywhere.
+//
+func (b *Body) SetBounds(r image.Rectangle, elasticity float64) {
+	b.mu.Lock()
+	b.bounds = r
+	b.elasticity = clamp01(elasticity)
+	b.mu.Unlock()
+}
+
+// Simulate starts moving the body, until the
+// animation returned is stopped.
+//
+func (a *Animator) Simulate(b *Body) *Animation {
+	return a.Start(b.tween(), nil)
+}
+
+// tween returns a tween that moves the body for ever.
+func (b *Body) tween() Tween {
+	d := time.Duration(math.MaxInt64)
+	var last float64
+	return Tween{d, Linear, func(v float64) {
+		now := v * float64(d) / float64(time.Second)
+		dt := now - last
+		last = now
+		if dt > 0 {
+			b.step(dt)
+		}
+	}}
+}
+
+// step moves the body on by dt seconds.
+func (b *Body) step(dt float64) {
+	b.mu.Lock()
+	b.vx += b.ax * dt
+	b.vy += b.ay * dt
+	if b.drag > 0 {
+		k := math.Pow(1-b.drag, dt)
+		b.vx *= k
+		b.vy *= k
+	}
+	b.fx += b.vx * dt
+	b.fy += b.vy * dt
+	delta := image.Pt(int(b.fx), int(b.fy))
+	b.fx -= float64(delta.X)
+	b.fy -= float64(delta.Y)
+	if bounds := b.bounds; !bounds.Empty() {
+		r := b.it.Bbox().Add(delta)
+		switch {
+		case r.Min.X < bounds.Min.X:
+			delta.X += bounds.Min.X - r.Min.X
+			b.vx, b.fx = math.Abs(b.vx)*b.elasticity, 0
+		case r.Max.X > bounds.Max.X:
+			delta.X -= r.Max.X - bounds.Max.X
+			b.vx, b.fx = -math.Abs(b.vx)*b.elasticity, 0
+		}
+		switch {
+		case r.Min.Y < bounds.Min.Y:
+			delta.Y += bounds.Min.Y - r.Min.Y
+			b.vy, b.fy = math.Abs(b.vy)*b.elasticity, 0
+		case r.Max.Y > bounds.Max.Y:
+			delta.Y -= r.Max.Y - bounds.Max.Y
+			b.vy, b.fy = -math.Abs(b.vy)*b.elasticity, 0
+		}
+	}
+	b.mu.Unlock()
+	if delta != image.ZP {
+		MoveItem(b.it, delta)
+	}
+}
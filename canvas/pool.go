@@ -0,0 +1,49 @@
+package canvas
+
+import (
+	"runtime"
+	"sync"
+)
+
+// rasterWorkers is the largest number of goroutines
+// used at once by prepareRasters.
+var rasterWorkers = runtime.NumCPU()
+
+// A rasterer is an item drawn by a RasterItem.
+type rasterer interface {
+	rasterItem() *RasterItem
+}
+
+// prepareRasters calculates the coverage masks of the given
+// raster items, sharing the work between up to rasterWorkers
+// goroutines. Each item's mask depends only on its own path,
+// so the items can be rasterized in any order, and are
+// then drawn from their masks in the usual way.
+func prepareRasters(items []*RasterItem) {
+	n := rasterWorkers
+	if n > len(items) {
+		n = len(items)
+	}
+	if n <= 1 {
+		for _, obj := range items {
+			obj.coverage()
+		}
+		return
+	}
+	work := make(chan *RasterItem)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for obj := range work {
+				obj.coverage()
+			}
+		}()
+	}
+	for _, obj := range items {
+		work <- obj
+	}
+	close(work)
+	wg.Wait()
+}
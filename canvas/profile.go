@@ -0,0 +1,118 @@
+package canvas
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A FrameProfile records where the time went
+// in drawing one frame.
+//
+type FrameProfile struct {
+	Rasterize time.Duration // calculating the coverage of shapes.
+	Composite time.Duration // drawing the changed areas, including Rasterize.
+	Flush     time.Duration // making the changed areas visible.
+
+	// Items holds the time spent drawing items, keyed by
+	// the name of their type. The time for a nested canvas
+	// includes that for the items inside it.
+	Items map[string]ItemProfile
+}
+
+// An ItemProfile records the drawing of items of one type.
+//
+type ItemProfile struct {
+	Draws int
+	Time  time.Duration
+}
+
+// profiling is non-zero when a profiler is set;
+// it is read without holding prof's lock.
+var profiling int32
+
+var prof struct {
+	sync.Mutex
+	f   func(p *FrameProfile)
+	cur FrameProfile
+}
+
+// SetProfiler sets a function to be called with the profile of
+// each frame shown by a Background, for finding out why drawing
+// is slow. The function is called with the canvas locked,
+// so it must not make changes to any canvas; it might,
+// for instance, log the profile or publish it with expvar.
+// If f is nil, profiling stops.
+//
+func SetProfiler(f func(p *FrameProfile)) {
+	prof.Lock()
+	defer prof.Unlock()
+	prof.f = f
+	prof.cur = FrameProfile{}
+	if f != nil {
+		atomic.StoreInt32(&profiling, 1)
+	} else {
+		atomic.StoreInt32(&profiling, 0)
+	}
+}
+
+// profileStart returns the time at which something
+// to be profiled starts, or the zero time if
+// there is no profiler.
+func profileStart() time.Time {
+	if atomic.LoadInt32(&profiling) == 0 {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// profileAdd adds the time since t0, if it is not zero,
+// to the duration chosen from the current profile by f.
+func profileAdd(t0 time.Time, f func(p *FrameProfile) *time.Duration) {
+	if t0.IsZero() {
+		return
+	}
+	d := time.Since(t0)
+	prof.Lock()
+	*f(&prof.cur) += d
+	prof.Unlock()
+}
+
+func profileRasterize(p *FrameProfile) *time.Duration { return &p.Rasterize }
+func profileComposite(p *FrameProfile) *time.Duration { return &p.Composite }
+func profileFlush(p *FrameProfile) *time.Duration     { return &p.Flush }
+
+// profileItem records the drawing of it,
+// started at t0, if that is not zero.
+func profileItem(it Item, t0 time.Time) {
+	if t0.IsZero() {
+		return
+	}
+	d := time.Since(t0)
+	name := fmt.Sprintf("%T", it)
+	prof.Lock()
+	if prof.cur.Items == nil {
+		prof.cur.Items = make(map[string]ItemProfile)
+	}
+	ip := prof.cur.Items[name]
+	ip.Draws++
+	ip.Time += d
+	prof.cur.Items[name] = ip
+	prof.Unlock()
+}
+
+// profileFrame passes the profile of the frame
+// just shown to the profiler, and starts another.
+func profileFrame() {
+	if atomic.LoadInt32(&profiling) == 0 {
+		return
+	}
+	prof.Lock()
+	p, f := prof.cur, prof.f
+	prof.cur = FrameProfile{}
+	prof.Unlock()
+	if f != nil {
+		f(&p)
+	}
+}
@@ -0,0 +1,243 @@
+package canvas
+
+import (
+	"code.google.com/p/rog-go/values"
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+)
+
+// stripeWidth is the width of each stripe shown by
+// an indeterminate ProgressBar.
+const stripeWidth = 8
+
+// stripeInterval is the time between steps of
+// the animation of an indeterminate ProgressBar.
+const stripeInterval = 50 * time.Millisecond
+
+// A ProgressBar shows the progress of some task as
+// a horizontal bar that fills from left to right.
+// If the amount of progress is unknown, it can instead
+// show moving diagonal stripes.
+//
+type ProgressBar struct {
+	backing Backing
+	value   values.Value
+	r       image.Rectangle
+	fg, bg  image.Image
+	frac    float64
+	stripes *stripeImage // non-nil when indeterminate.
+	stop    chan bool
+	border  int
+	line    image.Image
+	theme   *Theme
+	pref    image.Point // the size the bar was created with.
+}
+
+// NewProgressBar returns a new ProgressBar occupying r,
+// showing progress in fg on a background of bg.
+// The value, of type float64, holds the proportion
+// of the task completed, in the range [0, 1].
+// It may safely be set from any goroutine.
+//
+func NewProgressBar(r image.Rectangle, fg, bg color.Color, value values.Value) *ProgressBar {
+	obj := new(ProgressBar)
+	obj.backing = NullBacking()
+	obj.value = value
+	obj.r = r
+	obj.pref = r.Size()
+	obj.fg = &image.Uniform{fg}
+	obj.bg = &image.Uniform{bg}
+	obj.restyle()
+	go obj.listener()
+	return obj
+}
+
+func (obj *ProgressBar) listener() {
+	g := obj.value.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		v := clamp01(x.(float64))
+		obj.backing.Atomically(func(flush FlushFunc) {
+			if v == obj.frac {
+				return
+			}
+			old := obj.frac
+			obj.frac = v
+			if obj.stripes == nil {
+				// only the part of the bar between the old
+				// and new positions has changed.
+				x0, x1 := obj.barX(old), obj.barX(v)
+				if x0 > x1 {
+					x0, x1 = x1, x0
+				}
+				inner := obj.inner()
+				flush(image.Rect(x0, inner.Min.Y, x1, inner.Max.Y), nil)
+			}
+		})
+		obj.backing.Flush()
+	}
+}
+
+// inner returns the area inside the bar's border.
+func (obj *ProgressBar) inner() image.Rectangle {
+	return obj.r.Inset(obj.border)
+}
+
+// barX returns the x coordinate of the end of
+// the bar when the proportion f is complete.
+func (obj *ProgressBar) barX(f float64) int {
+	inner := obj.inner()
+	return inner.Min.X + int(f*float64(inner.Dx())+0.5)
+}
+
+// SetIndeterminate sets whether the progress bar shows
+// the amount of progress (false), or animated
+// stripes indicating that the task is ongoing (true).
+//
+func (obj *ProgressBar) SetIndeterminate(on bool) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		if on == (obj.stripes != nil) {
+			return
+		}
+		if on {
+			obj.stripes = &stripeImage{fg: obj.fg, bg: obj.bg}
+			obj.stop = make(chan bool)
+			go obj.animate(obj.stop)
+		} else {
+			obj.stripes = nil
+			close(obj.stop)
+			obj.stop = nil
+		}
+		flush(obj.inner(), nil)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *ProgressBar) animate(stop chan bool) {
+	t := time.NewTicker(stripeInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-stop:
+			return
+		}
+		obj.backing.Atomically(func(flush FlushFunc) {
+			if obj.stripes != nil {
+				obj.stripes.phase = (obj.stripes.phase + 1) % (2 * stripeWidth)
+				flush(obj.inner(), nil)
+			}
+		})
+		obj.backing.Flush()
+	}
+}
+
+var _ Item = (*ProgressBar)(nil)
+var _ ResizableItem = (*ProgressBar)(nil)
+var _ Sizer = (*ProgressBar)(nil)
+
+// SetBounds moves and resizes the progress bar to occupy r.
+//
+func (obj *ProgressBar) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed to show
+// two stripes inside the border.
+//
+func (obj *ProgressBar) MinSize() image.Point {
+	d := 2 * themeFor(obj.theme, obj.backing).BorderWidth
+	return image.Pt(2*stripeWidth+d, stripeWidth+d)
+}
+
+func (obj *ProgressBar) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+func (obj *ProgressBar) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the progress bar,
+// overriding that of its canvas. If t is nil, the
+// canvas's theme is used.
+//
+func (obj *ProgressBar) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *ProgressBar) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	obj.border = t.BorderWidth
+	obj.line = t.border()
+}
+
+func (obj *ProgressBar) Draw(dst draw.Image, clipr image.Rectangle) {
+	inner := obj.inner()
+	draw.Draw(dst, obj.r.Intersect(clipr), obj.line, image.ZP, draw.Over)
+	if obj.stripes != nil {
+		r := inner.Intersect(clipr)
+		draw.Draw(dst, r, obj.stripes, r.Min, draw.Over)
+		return
+	}
+	x := obj.barX(obj.frac)
+	bar := image.Rect(inner.Min.X, inner.Min.Y, x, inner.Max.Y).Intersect(clipr)
+	draw.Draw(dst, bar, obj.fg, image.ZP, draw.Over)
+	rest := image.Rect(x, inner.Min.Y, inner.Max.X, inner.Max.Y).Intersect(clipr)
+	draw.Draw(dst, rest, obj.bg, image.ZP, draw.Over)
+}
+
+func (obj *ProgressBar) Bbox() image.Rectangle {
+	return obj.r
+}
+
+func (obj *ProgressBar) HitTest(p image.Point) bool {
+	return p.In(obj.r)
+}
+
+func (obj *ProgressBar) Opaque() bool {
+	return opaqueImage(obj.fg) && opaqueImage(obj.bg) && opaqueImage(obj.line)
+}
+
+// stripeImage is an unbounded image of diagonal
+// stripes, alternately fg and bg.
+type stripeImage struct {
+	fg, bg image.Image
+	phase  int
+}
+
+func (s *stripeImage) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (s *stripeImage) Bounds() image.Rectangle {
+	return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+func (s *stripeImage) At(x, y int) color.Color {
+	d := (x + y - s.phase) % (2 * stripeWidth)
+	if d < 0 {
+		d += 2 * stripeWidth
+	}
+	if d < stripeWidth {
+		return s.fg.At(x, y)
+	}
+	return s.bg.At(x, y)
+}
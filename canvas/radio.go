@@ -0,0 +1,245 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+	"reflect"
+)
+
+// A RadioGroup shows a set of labelled options,
+// only one of which may be selected at a time.
+//
+type RadioGroup struct {
+	backing Backing
+	value   values.Value
+	Item
+	c        *Canvas
+	options  []string
+	rows     []image.Rectangle
+	dots     []*Marker
+	rings    []*Marker
+	faces    []*Marker // the background inside each ring.
+	labels   []*Text
+	dotCol   image.Image
+	selected int
+	byName   bool
+	font     *truetype.Font
+	theme    *Theme
+	r        image.Rectangle
+	pref     image.Point // the size the group was created with.
+}
+
+// NewRadioGroup returns a new RadioGroup occupying r, showing
+// the given options one above the other.
+// The value holds the currently selected option; if its type
+// is string, it holds the option's text, otherwise it should
+// be of type int, and holds the option's index.
+// If the value does not match any option, no option is selected.
+//
+func NewRadioGroup(r image.Rectangle, options []string, font *truetype.Font, fg, bg color.Color, value values.Value) *RadioGroup {
+	obj := new(RadioGroup)
+	obj.value = value
+	obj.backing = NullBacking()
+	obj.options = append([]string(nil), options...)
+	obj.byName = value.Type().Kind() == reflect.String
+	obj.selected = -1
+	obj.dotCol = &image.Uniform{fg}
+	obj.font = font
+	obj.r = r
+	obj.pref = r.Size()
+	obj.c = NewCanvas(nil, r)
+	obj.rows = make([]image.Rectangle, len(options))
+	for _, opt := range options {
+		dot := NewMarker(MarkerCircle, image.Transparent, 1, image.ZP)
+		obj.dots = append(obj.dots, dot)
+		ring := NewMarker(MarkerCircle, image.Black, 1, image.ZP)
+		obj.rings = append(obj.rings, ring)
+		face := NewMarker(MarkerCircle, &image.Uniform{bg}, 1, image.ZP)
+		obj.faces = append(obj.faces, face)
+		label := NewText(image.ZP, W, opt, font, 12, nil)
+		label.SetFill(&image.Uniform{fg})
+		obj.labels = append(obj.labels, label)
+		obj.c.AddItem(ring)
+		obj.c.AddItem(face)
+		obj.c.AddItem(dot)
+		obj.c.AddItem(label)
+	}
+	obj.layout()
+	obj.restyle()
+	obj.Item = obj.c
+	go obj.listener()
+	return obj
+}
+
+var _ ResizableItem = (*RadioGroup)(nil)
+var _ Sizer = (*RadioGroup)(nil)
+
+// layout divides the group into rows, one for each option,
+// and places the parts of each option within its row.
+// The background inside each ring is sized by restyle.
+func (obj *RadioGroup) layout() {
+	r := obj.r
+	n := len(obj.rows)
+	if n == 0 {
+		n = 1
+	}
+	h := r.Dy() / n
+	for i := range obj.rows {
+		row := image.Rect(r.Min.X, r.Min.Y+i*h, r.Max.X, r.Min.Y+(i+1)*h)
+		obj.rows[i] = row
+		size := h - 2
+		if size%2 == 0 {
+			size--
+		}
+		p := image.Pt(row.Min.X+h/2, centre(row).Y)
+		obj.rings[i].p = p
+		obj.rings[i].setSize(size)
+		obj.faces[i].p = p
+		obj.dots[i].p = p
+		obj.dots[i].setSize(size / 2)
+		obj.labels[i].setPoint(image.Pt(row.Min.X+h+h/2, p.Y))
+	}
+}
+
+// SetBounds moves and resizes the group to occupy r,
+// sharing its height equally between the options.
+//
+func (obj *RadioGroup) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		obj.c.r = r
+		obj.layout()
+		obj.restyle()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed for the smallest
+// rings with the labels beside them.
+//
+func (obj *RadioGroup) MinSize() image.Point {
+	var l image.Point
+	for _, label := range obj.labels {
+		l = maxPoint(l, label.Bbox().Size())
+	}
+	h := l.Y
+	if h < minCheckSize+2 {
+		h = minCheckSize + 2
+	}
+	return image.Pt(h+h/2+l.X, h*len(obj.rows))
+}
+
+func (obj *RadioGroup) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+func (obj *RadioGroup) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the options,
+// overriding that of the group's canvas. If t is nil,
+// the canvas's theme is used.
+//
+func (obj *RadioGroup) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *RadioGroup) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	for i, ring := range obj.rings {
+		ring.fill = t.border()
+		obj.faces[i].setSize(ring.size - 2*t.BorderWidth)
+		t.styleLabel(obj.labels[i], obj.font)
+	}
+}
+
+// index returns the index of the option held in x.
+func (obj *RadioGroup) index(x interface{}) int {
+	if obj.byName {
+		for i, opt := range obj.options {
+			if opt == x.(string) {
+				return i
+			}
+		}
+		return -1
+	}
+	i := int(reflect.ValueOf(x).Int())
+	if i < 0 || i >= len(obj.options) {
+		return -1
+	}
+	return i
+}
+
+func (obj *RadioGroup) listener() {
+	g := obj.value.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		sel := obj.index(x)
+		obj.backing.Atomically(func(flush FlushFunc) {
+			if sel == obj.selected {
+				return
+			}
+			// only the old and new selections need redrawing.
+			if old := obj.selected; old >= 0 {
+				obj.dots[old].fill = image.Transparent
+				flush(obj.dots[old].Bbox(), nil)
+			}
+			if sel >= 0 {
+				obj.dots[sel].fill = obj.dotCol
+				flush(obj.dots[sel].Bbox(), nil)
+			}
+			obj.selected = sel
+		})
+		obj.backing.Flush()
+	}
+}
+
+func (obj *RadioGroup) rowAt(p image.Point) (i int) {
+	i = -1
+	obj.backing.Atomically(func(_ FlushFunc) {
+		for j, r := range obj.rows {
+			if p.In(r) {
+				i = j
+			}
+		}
+	})
+	return
+}
+
+// HandleMouse selects the option under the pointer
+// when the first mouse button is released, as long
+// as it is the same option that the button was pressed over.
+//
+func (obj *RadioGroup) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if m.Buttons&1 == 0 {
+		return false
+	}
+	i := obj.rowAt(m.Loc)
+	if i < 0 {
+		return false
+	}
+	if m := Drag(m, ec, nil); obj.rowAt(m.Loc) == i {
+		if obj.byName {
+			obj.value.Set(obj.options[i])
+		} else {
+			obj.value.Set(reflect.ValueOf(i).Convert(obj.value.Type()).Interface())
+		}
+	}
+	return true
+}
@@ -2,16 +2,21 @@ package canvas
 
 import (
 	"code.google.com/p/freetype-go/freetype/raster"
-	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
+	"math"
 )
 
 // A RasterItem is a low level canvas object that
 // can be used to build higher level primitives.
 // It implements Item, and will calculate
-// (and remember) its bounding box on request.
+// (and remember) its bounding box when it is
+// first needed after the path has changed.
+// The coverage mask of its path is calculated when
+// it is first needed, and kept until the path or
+// the backing changes, so that drawing the item again
+// does not run the rasterizer.
 //
 // Otherwise it can be used as a raster.Rasterizer.
 //
@@ -19,71 +24,253 @@ type RasterItem struct {
 	rasterizer raster.Rasterizer
 	fill       image.Image
 	bbox       image.Rectangle
-	clipper    clippedPainter
+	bboxStale  bool         // the path has changed since bbox was calculated.
+	mask       *image.Alpha // coverage of the current path; nil if not yet calculated.
+	threshold  uint8
+	bounds     image.Rectangle // the rectangle of the backing.
+	antialias  Antialias       // the item's own setting.
+	inherited  Antialias       // the setting for items inside the backing.
 }
 
 // CalcBbox calculates the current bounding box of
-// all the pixels in the current path.
+// all the pixels in the current path, if the path has
+// changed since it was last calculated. There is no
+// need to call it, as Bbox calculates the bounding box
+// when needed, but it may be used to do the work
+// at a time of the caller's choosing.
 func (obj *RasterItem) CalcBbox() {
-	obj.bbox = rasterBbox(&obj.rasterizer)
+	if obj.bboxStale {
+		obj.bbox = rasterBbox(&obj.rasterizer)
+		obj.bboxStale = false
+	}
+}
+
+// box returns the bounding box of the path,
+// calculating it if necessary.
+func (obj *RasterItem) box() image.Rectangle {
+	obj.CalcBbox()
+	return obj.bbox
+}
+
+// AddBbox returns the union of r and the bounding box of
+// the path, so that an item made of several raster items
+// can accumulate the bounding box of all of them. An empty
+// rectangle adds nothing, whatever its position.
+//
+func (obj *RasterItem) AddBbox(r image.Rectangle) image.Rectangle {
+	b := obj.box()
+	switch {
+	case b.Empty():
+		return r
+	case r.Empty():
+		return b
+	}
+	return r.Union(b)
 }
 
 var yellow = image.Uniform{color.RGBA{0xff, 0xdd, 0xdd, 0xff}}
 
+// Draw draws the current path onto dst through
+// its coverage mask.
+//
 func (obj *RasterItem) Draw(dst draw.Image, clipr image.Rectangle) {
-	obj.clipper.Clipr = clipr
-	obj.clipper.Painter = NewPainter(dst, obj.fill, draw.Over)
 	//fmt.Printf("drawing, bbox %v, clipped to %v\n", obj.bbox, clipr)
 	//draw.Draw(dst, clipr, yellow, clipr.Min)
-	obj.rasterizer.Rasterize(&obj.clipper)
+	r := clipr.Intersect(obj.box())
+	if r.Empty() {
+		return
+	}
+	drawOver(dst, r, obj.fill, r.Min, obj.coverage(), r.Min)
 }
 
 func (obj *RasterItem) SetFill(fill image.Image) {
 	obj.fill = fill
 }
 
+// A FillRule determines which parts of a
+// self-intersecting path are considered to be inside it.
+type FillRule int
+
+const (
+	// EvenOdd fills areas that are enclosed an odd number of times.
+	EvenOdd FillRule = iota
+	// NonZero fills areas with a non-zero winding number.
+	NonZero
+)
+
+// SetFillRule sets the rule used to fill the path.
+// The default is EvenOdd.
+//
+func (obj *RasterItem) SetFillRule(rule FillRule) {
+	obj.rasterizer.UseNonZeroWinding = rule == NonZero
+	obj.changed()
+}
+
+// FillRule returns the rule used to fill the path.
+//
+func (obj *RasterItem) FillRule() FillRule {
+	if obj.rasterizer.UseNonZeroWinding {
+		return NonZero
+	}
+	return EvenOdd
+}
+
+// inside reports whether a point with the given
+// winding number is inside a shape filled with rule.
+func (rule FillRule) inside(n int) bool {
+	if rule == NonZero {
+		return n != 0
+	}
+	return n&1 != 0
+}
+
+// HitTest returns true if the coverage of the pixel at p
+// is greater than the item's hit threshold.
+// The coverage mask is calculated on the first call
+// after the path has changed, and retained thereafter.
+//
 func (obj *RasterItem) HitTest(p image.Point) bool {
-	var hit hitTestPainter
-	hit.P = p
-	obj.rasterizer.Rasterize(&hit)
-	return hit.Hit
+	if !p.In(obj.box()) {
+		return false
+	}
+	return obj.coverage().AlphaAt(p.X, p.Y).A > obj.threshold
+}
+
+// SetHitThreshold sets the alpha value that the coverage of a pixel
+// must exceed for HitTest to succeed. The default is zero,
+// so that any pixel touched by the path counts.
+// Like the other methods of RasterItem, it does no locking;
+// the items built on it, such as Ellipse, have SetHitThreshold
+// methods that do.
+//
+func (obj *RasterItem) SetHitThreshold(a uint8) {
+	obj.threshold = a
+}
+
+// coverage returns the coverage mask of the current path,
+// calculating it if necessary.
+func (obj *RasterItem) coverage() *image.Alpha {
+	if obj.stale() {
+		t0 := profileStart()
+		obj.release()
+		obj.mask = newAlpha(obj.box())
+		obj.rasterizer.Rasterize(alphaPainter{obj.mask})
+		obj.quality().apply(obj.mask)
+		profileAdd(t0, profileRasterize)
+	}
+	return obj.mask
+}
+
+// SetAntialias sets the anti-aliasing used to draw the
+// path. If a is AntialiasDefault, the setting of the
+// canvas holding the item is used.
+//
+func (obj *RasterItem) SetAntialias(a Antialias) {
+	obj.antialias = a
+	obj.release()
+}
+
+// quality returns the anti-aliasing used for the path.
+func (obj *RasterItem) quality() Antialias {
+	if obj.antialias != AntialiasDefault {
+		return obj.antialias
+	}
+	if obj.inherited != AntialiasDefault {
+		return obj.inherited
+	}
+	return AntialiasFast
+}
+
+// stale reports whether the coverage mask
+// needs to be calculated.
+func (obj *RasterItem) stale() bool {
+	return obj.mask == nil || !obj.mask.Rect.Eq(obj.box())
+}
+
+// release gives back the buffer of the coverage
+// mask, if there is one, for reuse.
+func (obj *RasterItem) release() {
+	freeAlpha(obj.mask)
+	obj.mask = nil
+}
+
+// changed records that the path has changed.
+func (obj *RasterItem) changed() {
+	obj.release()
+	obj.bboxStale = true
+}
+
+func (obj *RasterItem) rasterItem() *RasterItem {
+	return obj
 }
 
 func (obj *RasterItem) SetContainer(b Backing) {
 	r := b.Rect()
-	obj.rasterizer.Dx = r.Min.X
-	obj.rasterizer.Dy = r.Min.Y
+	obj.bounds = r
+	obj.inherited = AntialiasOf(b)
 	obj.rasterizer.SetBounds(r.Dx(), r.Dy())
 	obj.Clear()
 }
 
+// translate moves the rasterized path by d without
+// rasterizing it again, by offsetting the spans it
+// produces and the coverage mask, if that has been
+// calculated. The path is clipped to the backing rectangle
+// as it is rasterized, so the offset spans are the same
+// only if no part of the path lies outside it, before or
+// after the move; if some part might, translate does nothing
+// and returns false, and the path must be made again.
+func (obj *RasterItem) translate(d image.Point) bool {
+	inside := obj.bounds.Inset(1)
+	obj.CalcBbox()
+	r := obj.bbox.Add(d)
+	if obj.bbox.Empty() || !obj.bbox.In(inside) || !r.In(inside) {
+		return false
+	}
+	obj.rasterizer.Dx += d.X
+	obj.rasterizer.Dy += d.Y
+	obj.bbox = r
+	if obj.mask != nil {
+		obj.mask.Rect = obj.mask.Rect.Add(d)
+	}
+	return true
+}
+
 func (obj *RasterItem) pt(p raster.Point) raster.Point {
 	return raster.Point{p.X + raster.Fix32(obj.rasterizer.Dx)<<fixBits, p.Y + raster.Fix32(obj.rasterizer.Dy)<<fixBits}
 }
 
 func (obj *RasterItem) Add1(p raster.Point) {
+	obj.changed()
 	obj.rasterizer.Add1(obj.pt(p))
 }
 
 func (obj *RasterItem) Add2(p0, p1 raster.Point) {
+	obj.changed()
 	obj.rasterizer.Add2(obj.pt(p0), obj.pt(p1))
 }
 
 func (obj *RasterItem) Add3(p0, p1, p2 raster.Point) {
+	obj.changed()
 	obj.rasterizer.Add3(obj.pt(p0), obj.pt(p1), obj.pt(p2))
 }
 
 func (obj *RasterItem) Start(p raster.Point) {
+	obj.changed()
 	obj.rasterizer.Start(p)
 }
 
 func (obj *RasterItem) Clear() {
+	obj.release()
+	obj.rasterizer.Dx = obj.bounds.Min.X
+	obj.rasterizer.Dy = obj.bounds.Min.Y
 	obj.rasterizer.Clear()
+	obj.bbox = image.ZR
+	obj.bboxStale = false
 }
 
 func (obj *RasterItem) Bbox() image.Rectangle {
-	fmt.Printf("raster bbox %v\n", obj.bbox)
-	return obj.bbox
+	return obj.box()
 }
 
 func (obj *RasterItem) Opaque() bool {
@@ -131,6 +318,36 @@ func (p *clippedPainter) Paint(ss []raster.Span, last bool) {
 	}
 }
 
+// An alphaPainter is a raster.Painter that records the coverage
+// of each span that falls within the bounds of Image.
+type alphaPainter struct {
+	Image *image.Alpha
+}
+
+func (p alphaPainter) Paint(ss []raster.Span, _ bool) {
+	r := p.Image.Rect
+	for _, s := range ss {
+		if s.Y < r.Min.Y || s.Y >= r.Max.Y {
+			continue
+		}
+		if s.X0 < r.Min.X {
+			s.X0 = r.Min.X
+		}
+		if s.X1 > r.Max.X {
+			s.X1 = r.Max.X
+		}
+		if s.X0 >= s.X1 {
+			continue
+		}
+		a := uint8(s.A >> 8)
+		i := p.Image.PixOffset(s.X0, s.Y)
+		row := p.Image.Pix[i : i+s.X1-s.X0]
+		for j := range row {
+			row[j] = a
+		}
+	}
+}
+
 type hitTestPainter struct {
 	P   image.Point
 	Hit bool
@@ -277,7 +494,7 @@ func (g *genericImagePainter) Paint(ss []raster.Span, done bool) {
 		draw.DrawMask(g.image,
 			image.Rect(s.X0, s.Y, s.X1, s.Y+1),
 			g.src,
-			image.Point{s.X0, s.X1},
+			image.Point{s.X0, s.Y},
 			alphaColorImage(uint16(s.A)),
 			image.ZP,
 			g.op)
@@ -287,7 +504,6 @@ func (g *genericImagePainter) Paint(ss []raster.Span, done bool) {
 // NewPainter returns a Painter that will draw from src onto
 // dst using the Porter-Duff composition operator op.
 func NewPainter(dst draw.Image, src image.Image, op draw.Op) (p raster.Painter) {
-	defer func() { fmt.Printf("newpainter %T\n", p) }()
 	if src, ok := src.(*image.Uniform); ok {
 		switch dst := dst.(type) {
 		case *image.Alpha:
@@ -327,6 +543,12 @@ func float2fix(f float64) raster.Fix32 {
 	return raster.Fix32(f*fixScale + 0.5)
 }
 
+// roundFix is like float2fix, but rounds
+// negative numbers correctly too.
+func roundFix(f float64) raster.Fix32 {
+	return raster.Fix32(math.Floor(f*fixScale + 0.5))
+}
+
 func int2fix(i int) raster.Fix32 {
 	return raster.Fix32(i << fixBits)
 }
@@ -335,6 +557,11 @@ func fix2int(i raster.Fix32) int {
 	return int((i + fixScale/2) >> fixBits)
 }
 
+// wholePixels reports whether p lies on a pixel boundary.
+func wholePixels(p raster.Point) bool {
+	return (p.X|p.Y)&(fixScale-1) == 0
+}
+
 func pixel2fixPoint(p image.Point) raster.Point {
 	return raster.Point{raster.Fix32(p.X << fixBits), raster.Fix32(p.Y << fixBits)}
 }
@@ -0,0 +1,123 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"sync"
+	"time"
+)
+
+// Dispatch reads events from ec until it is closed,
+// passing mouse events to c.HandleMouse and key events to
+// c.HandleKey; other events are discarded. It is the usual
+// main loop of a program showing c in a window, and can
+// be given the events from Play to drive c without one.
+// If several movements of the pointer with no buttons
+// pressed are waiting in ec, only the latest is delivered.
+//
+func Dispatch(c *Canvas, ec <-chan interface{}) {
+	var pending interface{}
+	for {
+		x := pending
+		if x != nil {
+			pending = nil
+		} else {
+			var ok bool
+			if x, ok = <-ec; !ok {
+				return
+			}
+		}
+		switch e := x.(type) {
+		case ui.MouseEvent:
+			if e.Buttons == 0 {
+				e, pending = coalesce(e, ec)
+			}
+			c.HandleMouse(c, e, ec)
+		case ui.KeyEvent:
+			c.HandleKey(c, e)
+		}
+	}
+}
+
+// A RecordedEvent is an event captured by a Recorder,
+// with the time at which it arrived, measured from
+// the start of the recording.
+//
+type RecordedEvent struct {
+	T     time.Duration
+	Event interface{}
+}
+
+// A Recorder captures a stream of events, so that it can be
+// replayed later with Play, for instance to check the
+// behaviour of some widgets without a window.
+//
+type Recorder struct {
+	mu     sync.Mutex
+	start  time.Time
+	events []RecordedEvent
+}
+
+// NewRecorder returns a new Recorder with nothing recorded.
+//
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record returns a channel that delivers the events
+// from ec unchanged, recording each one as it passes.
+// The returned channel is closed when ec is closed.
+// The recording starts when Record is first called.
+//
+func (r *Recorder) Record(ec <-chan interface{}) <-chan interface{} {
+	r.mu.Lock()
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	r.mu.Unlock()
+	out := make(chan interface{})
+	go func() {
+		for e := range ec {
+			r.mu.Lock()
+			r.events = append(r.events, RecordedEvent{time.Since(r.start), e})
+			r.mu.Unlock()
+			out <- e
+		}
+		close(out)
+	}()
+	return out
+}
+
+// Events returns the events recorded so far.
+//
+func (r *Recorder) Events() []RecordedEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedEvent(nil), r.events...)
+}
+
+// Play returns a channel that delivers the given events in
+// order, and is then closed. If speed is greater than zero,
+// the events are delivered at their recorded times, with the
+// intervals between them divided by speed, so that 2 plays
+// them back twice as fast; otherwise they are delivered as
+// fast as they are read. The events are not changed, so
+// mouse events keep their original timestamps, and double
+// clicks are recognised whatever the speed.
+//
+func Play(events []RecordedEvent, speed float64) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		start := time.Now()
+		for _, e := range events {
+			if speed > 0 {
+				due := start.Add(time.Duration(float64(e.T) / speed))
+				if d := due.Sub(time.Now()); d > 0 {
+					time.Sleep(d)
+				}
+			}
+			out <- e.Event
+		}
+		close(out)
+	}()
+	return out
+}
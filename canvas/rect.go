@@ -0,0 +1,138 @@
+package canvas
+
+import (
+	"image"
+	"image/draw"
+)
+
+// A Rect represents a filled rectangle with an optional border.
+// Unlike an Image made with Box, it draws itself directly,
+// so it can be resized cheaply.
+//
+type Rect struct {
+	backing    Backing
+	r          image.Rectangle
+	fill       image.Image
+	border     int
+	borderFill image.Image
+}
+
+// NewRect returns a new Rect covering r, filled with fill
+// and with a border of the given width drawn with borderFill.
+// Either fill or borderFill may be nil, in which case
+// that part of the rectangle is not drawn.
+//
+func NewRect(r image.Rectangle, fill image.Image, border int, borderFill image.Image) *Rect {
+	obj := new(Rect)
+	obj.backing = NullBacking()
+	obj.r = r.Canon()
+	obj.fill = fill
+	obj.setBorder(border, borderFill)
+	return obj
+}
+
+func (obj *Rect) setBorder(border int, fill image.Image) {
+	if border < 0 {
+		border = 0
+	}
+	obj.border = border
+	obj.borderFill = fill
+}
+
+func (obj *Rect) SetContainer(b Backing) {
+	obj.backing = b
+}
+
+func (obj *Rect) Draw(dst draw.Image, clipr image.Rectangle) {
+	clipr = clipr.Intersect(obj.r)
+	inner := obj.r.Inset(obj.border)
+	if obj.fill != nil {
+		dr := inner.Intersect(clipr)
+		draw.DrawMask(dst, dr, obj.fill, dr.Min, nil, image.ZP, draw.Over)
+	}
+	if obj.border > 0 && obj.borderFill != nil {
+		r := obj.r
+		for _, side := range [...]image.Rectangle{
+			{r.Min, image.Pt(r.Max.X, inner.Min.Y)},                              // top
+			{image.Pt(r.Min.X, inner.Max.Y), r.Max},                              // bottom
+			{image.Pt(r.Min.X, inner.Min.Y), image.Pt(inner.Min.X, inner.Max.Y)}, // left
+			{image.Pt(inner.Max.X, inner.Min.Y), image.Pt(r.Max.X, inner.Max.Y)}, // right
+		} {
+			dr := side.Intersect(clipr)
+			draw.DrawMask(dst, dr, obj.borderFill, dr.Min, nil, image.ZP, draw.Over)
+		}
+	}
+}
+
+func (obj *Rect) Bbox() image.Rectangle {
+	return obj.r
+}
+
+func (obj *Rect) HitTest(p image.Point) bool {
+	return p.In(obj.r)
+}
+
+func (obj *Rect) Opaque() bool {
+	if obj.fill == nil || !opaqueImage(obj.fill) {
+		return false
+	}
+	return obj.border == 0 || obj.borderFill != nil && opaqueImage(obj.borderFill)
+}
+
+// SetBounds changes the rectangle covered by obj.
+//
+func (obj *Rect) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		flush.Rects(nil, old, r)
+	})
+}
+
+// Move moves obj by delta.
+//
+func (obj *Rect) Move(delta image.Point) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = old.Add(delta)
+		flush.Rects(nil, old, obj.r)
+	})
+}
+
+// SetCentre moves obj so that its centre is at p.
+//
+func (obj *Rect) SetCentre(p image.Point) {
+	r := obj.Bbox()
+	obj.SetBounds(r.Add(p.Sub(centre(r))))
+}
+
+// SetFill changes the image used to fill the inside of the rectangle.
+//
+func (obj *Rect) SetFill(fill image.Image) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.fill = fill
+		flush(obj.r, nil)
+	})
+}
+
+// SetBorder changes the width and fill of the rectangle's border.
+//
+func (obj *Rect) SetBorder(border int, fill image.Image) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.setBorder(border, fill)
+		flush(obj.r, nil)
+	})
+}
+
+// opaqueImage reports whether img is known to be
+// fully opaque.
+//
+func opaqueImage(img image.Image) bool {
+	if o, ok := img.(interface {
+		Opaque() bool
+	}); ok {
+		return o.Opaque()
+	}
+	return false
+}
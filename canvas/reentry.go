@@ -0,0 +1,67 @@
+package canvas
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const errReentrant = "canvas: Atomically called from within Atomically (see Background.SetReentrant)"
+
+// CheckLocks makes Atomically panic when called from within
+// Atomically on the same goroutine, rather than deadlocking,
+// so that such mistakes can be found, and makes Flush wait
+// until the outer call returns. Finding the calling goroutine
+// is slow, so it is off by default; a Background made reentrant
+// with SetReentrant always does it.
+//
+var CheckLocks = false
+
+// An ownedLock is a mutex that can know which goroutine
+// holds it, so that a goroutine that tries to take it
+// again can be told so rather than deadlocking.
+type ownedLock struct {
+	mu    sync.Mutex
+	owner int64 // the id of the goroutine holding mu, or 0 if unknown.
+}
+
+// Lock locks l without recording its owner.
+func (l *ownedLock) Lock() {
+	l.mu.Lock()
+}
+
+// lockAs locks l for the goroutine with the given id,
+// which is 0 if the owner is not to be recorded.
+func (l *ownedLock) lockAs(id int64) {
+	l.mu.Lock()
+	atomic.StoreInt64(&l.owner, id)
+}
+
+func (l *ownedLock) Unlock() {
+	atomic.StoreInt64(&l.owner, 0)
+	l.mu.Unlock()
+}
+
+// heldBy reports whether l is held by the goroutine
+// with the given id, which must not be 0.
+func (l *ownedLock) heldBy(id int64) bool {
+	return atomic.LoadInt64(&l.owner) == id
+}
+
+// goid returns the id of the calling goroutine,
+// as shown in the first line of its stack trace.
+func goid() int64 {
+	var buf [64]byte
+	s := buf[:runtime.Stack(buf[:], false)]
+	s = bytes.TrimPrefix(s, []byte("goroutine "))
+	if i := bytes.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	id, err := strconv.ParseInt(string(s), 10, 64)
+	if err != nil {
+		panic("canvas: cannot find goroutine id")
+	}
+	return id
+}
@@ -0,0 +1,29 @@
+package canvas
+
+import (
+	"image"
+	"testing"
+)
+
+func TestReentrantFlush(t *testing.T) {
+	shown := 0
+	b := NewBackground(image.NewRGBA(image.Rect(0, 0, 10, 10)), image.White, func(image.Rectangle) {
+		shown++
+	})
+	b.SetItem(NewRect(image.Rect(2, 2, 8, 8), image.Black, 0, nil))
+	b.SetReentrant(true)
+	b.Flush()
+	shown = 0
+	b.Atomically(func(flush FlushFunc) {
+		b.Atomically(func(flush FlushFunc) {
+			flush(image.Rect(0, 0, 5, 5), nil)
+			b.Flush()
+		})
+		if shown != 0 {
+			t.Errorf("Flush within Atomically showed changes before it returned")
+		}
+	})
+	if shown == 0 {
+		t.Errorf("Flush within Atomically showed nothing")
+	}
+}
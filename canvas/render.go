@@ -0,0 +1,60 @@
+package canvas
+
+import (
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// RenderTo draws the part of c within r, with the items
+// inside it, onto dst, which need not be the image that c
+// is shown on, and can be used for thumbnails or tests
+// when there is no window. Pixels of dst outside r, or
+// outside c, are not changed. c is drawn over the existing
+// contents of dst, so if c has no opaque background, dst
+// should usually be cleared first. dst may be of any pixel
+// format, though drawing onto an *image.RGBA is fastest.
+//
+func (c *Canvas) RenderTo(dst draw.Image, r image.Rectangle) {
+	r = r.Intersect(c.r).Intersect(dst.Bounds())
+	if r.Empty() {
+		return
+	}
+	top := outermost(c)
+	c.Atomically(func(_ FlushFunc) {
+		// stop the canvases remembering dst as
+		// the image that they are shown on.
+		top.rendering = true
+		defer func() {
+			top.rendering = false
+		}()
+		c.Draw(dst, r)
+	})
+}
+
+// Snapshot returns a new image holding the whole of c,
+// drawn as by RenderTo.
+//
+func (c *Canvas) Snapshot() *image.RGBA {
+	var r image.Rectangle
+	c.Atomically(func(_ FlushFunc) {
+		r = c.r
+	})
+	img := image.NewRGBA(r)
+	c.RenderTo(img, r)
+	return img
+}
+
+// WritePNG writes a snapshot of c to w in PNG format.
+//
+func (c *Canvas) WritePNG(w io.Writer) error {
+	return png.Encode(w, c.Snapshot())
+}
+
+// SaveTo writes a snapshot of c in PNG format
+// to the named file, creating it if necessary.
+//
+func (c *Canvas) SaveTo(file string) error {
+	return writePNGFile(file, c.Snapshot())
+}
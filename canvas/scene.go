@@ -0,0 +1,343 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/raster"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// A sceneItem is the form in which an item
+// is written by Save and read by Load.
+// Points and lengths are in pixels, with
+// fractions for those held in fixed point.
+type sceneItem struct {
+	Type       string       `json:"type"`
+	Rect       []int        `json:"rect,omitempty"` // x0, y0, x1, y1.
+	Points     [][2]float64 `json:"points,omitempty"`
+	Radii      []float64    `json:"radii,omitempty"`
+	Width      float64      `json:"width,omitempty"`
+	Fill       string       `json:"fill,omitempty"`
+	Border     int          `json:"border,omitempty"`
+	BorderFill string       `json:"borderFill,omitempty"`
+	Kind       int          `json:"kind,omitempty"`
+	Size       int          `json:"size,omitempty"`
+	Image      string       `json:"image,omitempty"`
+	Opaque     bool         `json:"opaque,omitempty"`
+	Static     bool         `json:"static,omitempty"`
+	Items      []sceneItem  `json:"items,omitempty"`
+}
+
+// Save writes the items of c, including those in the static
+// layer and in nested canvases, to w as JSON, bottom first,
+// so that they can be made again with Load. The built-in
+// items Rect, Image, Polygon, Line, Ellipse, Spline, Marker
+// and Canvas can be saved, as long as their fills are nil or
+// uniform colours; Save returns an error if it finds any other.
+// An image is saved by reference: ref is called with the image
+// of each Image item and returns the name by which it is saved,
+// such as the name of the file it was read from.
+//
+func (c *Canvas) Save(w io.Writer, ref func(img image.Image) (string, error)) error {
+	var items []sceneItem
+	var err error
+	c.Atomically(func(_ FlushFunc) {
+		items, err = c.saveItems(ref)
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(items, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Load reads items written by Save from r and adds them to c,
+// above any items already there. The image of each Image item
+// is obtained by calling open with the name returned by the
+// ref function given to Save.
+//
+func (c *Canvas) Load(r io.Reader, open func(ref string) (image.Image, error)) error {
+	var items []sceneItem
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return fmt.Errorf("canvas: cannot load scene: %v", err)
+	}
+	return loadItems(c, items, open)
+}
+
+// saveItems returns the saved form of the items
+// of c. The canvas must be locked.
+func (c *Canvas) saveItems(ref func(image.Image) (string, error)) ([]sceneItem, error) {
+	var items []sceneItem
+	add := func(it Item, static bool) error {
+		s, err := saveItem(it, ref)
+		if err != nil {
+			return err
+		}
+		s.Static = static
+		items = append(items, s)
+		return nil
+	}
+	if c.static != nil {
+		for _, it := range c.static.items {
+			if err := add(it, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for e := c.items.Front(); e != nil; e = e.Next() {
+		if err := add(e.Value.(Item), false); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func saveItem(it Item, ref func(image.Image) (string, error)) (s sceneItem, err error) {
+	fill := func(img image.Image) string {
+		f, e := saveFill(img)
+		if e != nil && err == nil {
+			err = fmt.Errorf("canvas: cannot save %T: %v", it, e)
+		}
+		return f
+	}
+	switch it := it.(type) {
+	case *Rect:
+		s = sceneItem{
+			Type:       "rect",
+			Rect:       saveRect(it.r),
+			Fill:       fill(it.fill),
+			Border:     it.border,
+			BorderFill: fill(it.borderFill),
+		}
+	case *Image:
+		s = sceneItem{
+			Type:   "image",
+			Rect:   saveRect(it.item.R),
+			Opaque: it.item.IsOpaque,
+		}
+		s.Image, err = ref(it.item.Image)
+	case *Polygon:
+		s = sceneItem{
+			Type:   "polygon",
+			Points: saveFixedPoints(it.points),
+			Fill:   fill(it.raster.fill),
+			Kind:   int(it.raster.FillRule()),
+		}
+	case *Line:
+		s = sceneItem{
+			Type:   "line",
+			Points: saveFixedPoints([]raster.Point{it.p0, it.p1}),
+			Width:  fixed2float(it.width),
+			Fill:   fill(it.raster.fill),
+		}
+	case *Ellipse:
+		s = sceneItem{
+			Type:   "ellipse",
+			Points: saveFixedPoints([]raster.Point{it.cr}),
+			Radii:  []float64{fixed2float(it.ra), fixed2float(it.rb)},
+			Width:  fixed2float(it.width),
+			Fill:   fill(it.raster.fill),
+		}
+	case *Spline:
+		s = sceneItem{
+			Type:   "spline",
+			Points: savePoints(it.points),
+			Width:  it.width,
+			Kind:   int(it.kind),
+			Fill:   fill(it.raster.fill),
+		}
+	case *Marker:
+		s = sceneItem{
+			Type:   "marker",
+			Points: savePoints([]image.Point{it.p}),
+			Radii:  []float64{float64(it.lo), float64(it.hi)},
+			Kind:   int(it.kind),
+			Size:   it.size,
+			Fill:   fill(it.fill),
+		}
+	case *Canvas:
+		s = sceneItem{
+			Type: "canvas",
+			Rect: saveRect(it.r),
+			Fill: fill(it.background),
+		}
+		if err == nil {
+			s.Items, err = it.saveItems(ref)
+		}
+	default:
+		err = fmt.Errorf("canvas: cannot save item of type %T", it)
+	}
+	return
+}
+
+func loadItems(c *Canvas, items []sceneItem, open func(string) (image.Image, error)) error {
+	for _, s := range items {
+		it, err := loadItem(s, open)
+		if err != nil {
+			return err
+		}
+		if s.Static {
+			c.AddStatic(it)
+		} else {
+			c.AddItem(it)
+		}
+	}
+	return nil
+}
+
+func loadItem(s sceneItem, open func(string) (image.Image, error)) (it Item, err error) {
+	fill := func(f string) image.Image {
+		img, e := loadFill(f)
+		if e != nil && err == nil {
+			err = e
+		}
+		return img
+	}
+	bad := func(what string) error {
+		return fmt.Errorf("canvas: bad %s in saved %s", what, s.Type)
+	}
+	switch s.Type {
+	case "rect":
+		if len(s.Rect) != 4 {
+			return nil, bad("rectangle")
+		}
+		it = NewRect(loadRect(s.Rect), fill(s.Fill), s.Border, fill(s.BorderFill))
+	case "image":
+		if len(s.Rect) != 4 {
+			return nil, bad("rectangle")
+		}
+		img, e := open(s.Image)
+		if e != nil {
+			return nil, e
+		}
+		it = NewImage(img, s.Opaque, loadRect(s.Rect).Min)
+	case "polygon":
+		obj := NewPolygon(fill(s.Fill), nil)
+		obj.points = loadFixedPoints(s.Points)
+		obj.raster.SetFillRule(FillRule(s.Kind))
+		it = obj
+	case "line":
+		if len(s.Points) != 2 {
+			return nil, bad("end points")
+		}
+		obj := NewLine(fill(s.Fill), image.ZP, image.ZP, s.Width)
+		p := loadFixedPoints(s.Points)
+		obj.p0, obj.p1 = p[0], p[1]
+		obj.makeOutline()
+		it = obj
+	case "ellipse":
+		if len(s.Points) != 1 || len(s.Radii) != 2 {
+			return nil, bad("geometry")
+		}
+		cr := loadFixedPoints(s.Points)[0]
+		ra, rb := roundFix(s.Radii[0]), roundFix(s.Radii[1])
+		obj := NewEllipse(fill(s.Fill), fix2pixelPoint(cr), fix2int(ra), fix2int(rb), s.Width)
+		if cr != obj.cr || ra != obj.ra || rb != obj.rb {
+			// the ellipse lies between pixels.
+			obj.cr, obj.ra, obj.rb = cr, ra, rb
+			obj.makeOutline()
+		}
+		it = obj
+	case "spline":
+		it = NewSpline(fill(s.Fill), SplineKind(s.Kind), loadPoints(s.Points), s.Width)
+	case "marker":
+		if len(s.Points) != 1 || len(s.Radii) != 2 {
+			return nil, bad("geometry")
+		}
+		obj := NewMarker(MarkerKind(s.Kind), fill(s.Fill), s.Size, loadPoints(s.Points)[0])
+		obj.lo, obj.hi = int(s.Radii[0]), int(s.Radii[1])
+		obj.makeMask()
+		it = obj
+	case "canvas":
+		if len(s.Rect) != 4 {
+			return nil, bad("rectangle")
+		}
+		var bg color.Color
+		if u, ok := fill(s.Fill).(*image.Uniform); ok {
+			bg = u.C
+		}
+		c := NewCanvas(bg, loadRect(s.Rect))
+		if e := loadItems(c, s.Items, open); e != nil {
+			return nil, e
+		}
+		it = c
+	default:
+		return nil, fmt.Errorf("canvas: unknown saved item type %q", s.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// saveFill returns the saved form of a fill, which must
+// be nil or uniform: the colour as #rrggbbaa,
+// without the alpha premultiplied.
+func saveFill(img image.Image) (string, error) {
+	if img == nil {
+		return "", nil
+	}
+	u, ok := img.(*image.Uniform)
+	if !ok {
+		return "", fmt.Errorf("fill of type %T is not a uniform colour", img)
+	}
+	c := color.NRGBAModel.Convert(u.C).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A), nil
+}
+
+func loadFill(s string) (image.Image, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var c color.NRGBA
+	if n, err := fmt.Sscanf(s, "#%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A); n != 4 || err != nil {
+		return nil, fmt.Errorf("canvas: bad colour %q", s)
+	}
+	return &image.Uniform{c}, nil
+}
+
+func saveRect(r image.Rectangle) []int {
+	return []int{r.Min.X, r.Min.Y, r.Max.X, r.Max.Y}
+}
+
+func loadRect(r []int) image.Rectangle {
+	return image.Rect(r[0], r[1], r[2], r[3])
+}
+
+func savePoints(points []image.Point) [][2]float64 {
+	s := make([][2]float64, len(points))
+	for i, p := range points {
+		s[i] = [2]float64{float64(p.X), float64(p.Y)}
+	}
+	return s
+}
+
+func loadPoints(s [][2]float64) []image.Point {
+	points := make([]image.Point, len(s))
+	for i, p := range s {
+		points[i] = image.Pt(int(p[0]), int(p[1]))
+	}
+	return points
+}
+
+func saveFixedPoints(points []raster.Point) [][2]float64 {
+	s := make([][2]float64, len(points))
+	for i, p := range points {
+		s[i] = [2]float64{fixed2float(p.X), fixed2float(p.Y)}
+	}
+	return s
+}
+
+func loadFixedPoints(s [][2]float64) []raster.Point {
+	points := make([]raster.Point, len(s))
+	for i, p := range s {
+		points[i] = raster.Point{roundFix(p[0]), roundFix(p[1])}
+	}
+	return points
+}
@@ -0,0 +1,335 @@
+package canvas
+
+import (
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+)
+
+// minThumb is the smallest length of a scrollbar's thumb.
+const minThumb = 8
+
+// A Scrollbar shows a bar with a thumb whose size is
+// proportional to the visible fraction of some larger
+// area, with arrow buttons at either end.
+//
+type Scrollbar struct {
+	backing Backing
+	value   values.Value
+	Item
+	c        *Canvas
+	vertical bool
+	r        image.Rectangle
+	pos      float64 // position of the thumb, in the range [0, 1].
+	visible  float64 // visible fraction, in the range (0, 1].
+	trough   Rect
+	thumb    Rect
+	arrows   [2]Rect
+	heads    [2]*Polygon // the triangles drawn on the arrows.
+	theme    *Theme
+	pref     image.Point // the size the scrollbar was created with.
+}
+
+// NewScrollbar returns a new Scrollbar occupying r with the
+// given orientation. The thumb is drawn with fg, the trough
+// with bg. The value, of type float64, holds the scroll
+// position in the range [0, 1]; 0 is at the top or left.
+// Initially the visible fraction is 0.1.
+//
+func NewScrollbar(r image.Rectangle, o Orientation, fg, bg color.Color, value values.Value) *Scrollbar {
+	obj := new(Scrollbar)
+	obj.backing = NullBacking()
+	obj.value = value
+	obj.vertical = o == Vertical
+	obj.r = r
+	obj.pref = r.Size()
+	obj.visible = 0.1
+	obj.c = NewCanvas(nil, r)
+	fill := &image.Uniform{fg}
+	obj.trough = *NewRect(r, &image.Uniform{bg}, 0, nil)
+	obj.c.AddItem(&obj.trough)
+	for i := range obj.arrows {
+		ar := obj.arrowRect(i)
+		obj.arrows[i] = *NewRect(ar, fill, 0, nil)
+		obj.heads[i] = NewPolygon(image.Black, obj.arrowPoints(i, ar))
+		obj.c.AddItem(&obj.arrows[i])
+		obj.c.AddItem(obj.heads[i])
+	}
+	obj.thumb = *NewRect(obj.thumbRect(), fill, 0, nil)
+	obj.c.AddItem(&obj.thumb)
+	obj.restyle()
+	obj.Item = obj.c
+	go obj.listener()
+	return obj
+}
+
+var _ HandlerItem = (*Scrollbar)(nil)
+var _ ResizableItem = (*Scrollbar)(nil)
+var _ Sizer = (*Scrollbar)(nil)
+
+func (obj *Scrollbar) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the scrollbar,
+// overriding that of its canvas. If t is nil, the
+// canvas's theme is used.
+//
+func (obj *Scrollbar) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Scrollbar) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	border := t.border()
+	obj.trough.setBorder(t.BorderWidth, border)
+	obj.thumb.setBorder(t.BorderWidth, border)
+	for i := range obj.arrows {
+		obj.arrows[i].setBorder(t.BorderWidth, border)
+		obj.heads[i].raster.SetFill(border)
+	}
+}
+
+// SetBounds moves and resizes the scrollbar to occupy r.
+// The arrow buttons remain square.
+//
+func (obj *Scrollbar) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		obj.c.r = r
+		obj.trough.r = r
+		for i := range obj.arrows {
+			ar := obj.arrowRect(i)
+			obj.arrows[i].r = ar
+			obj.heads[i].points = pixel2fixPoints(obj.arrowPoints(i, ar))
+			obj.heads[i].makeOutline()
+		}
+		obj.thumb.r = obj.thumbRect()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed for the two arrow
+// buttons with the smallest thumb between them.
+//
+func (obj *Scrollbar) MinSize() image.Point {
+	if obj.vertical {
+		return image.Pt(minThumb, 3*minThumb)
+	}
+	return image.Pt(3*minThumb, minThumb)
+}
+
+func (obj *Scrollbar) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+// thickness returns the size of the scrollbar across
+// its direction of travel.
+func (obj *Scrollbar) thickness() int {
+	if obj.vertical {
+		return obj.r.Dx()
+	}
+	return obj.r.Dy()
+}
+
+// along returns the extent of the scrollbar
+// along its direction of travel.
+func (obj *Scrollbar) along() (min, max int) {
+	if obj.vertical {
+		return obj.r.Min.Y, obj.r.Max.Y
+	}
+	return obj.r.Min.X, obj.r.Max.X
+}
+
+// span returns the rectangle that covers [min, max)
+// along the scrollbar's direction of travel.
+func (obj *Scrollbar) span(min, max int) image.Rectangle {
+	if obj.vertical {
+		return image.Rect(obj.r.Min.X, min, obj.r.Max.X, max)
+	}
+	return image.Rect(min, obj.r.Min.Y, max, obj.r.Max.Y)
+}
+
+// coord returns the component of p along the
+// scrollbar's direction of travel.
+func (obj *Scrollbar) coord(p image.Point) int {
+	if obj.vertical {
+		return p.Y
+	}
+	return p.X
+}
+
+// arrowRect returns the rectangle of the arrow button
+// at the start (i == 0) or end (i == 1) of the scrollbar.
+func (obj *Scrollbar) arrowRect(i int) image.Rectangle {
+	min, max := obj.along()
+	t := obj.thickness()
+	if i == 0 {
+		return obj.span(min, min+t)
+	}
+	return obj.span(max-t, max)
+}
+
+// arrowPoints returns the vertices of the triangle
+// drawn inside the arrow button with rectangle r.
+func (obj *Scrollbar) arrowPoints(i int, r image.Rectangle) []image.Point {
+	r = r.Inset(r.Dx() / 4)
+	c := centre(r)
+	switch {
+	case obj.vertical && i == 0:
+		return []image.Point{{c.X, r.Min.Y}, r.Max, {r.Min.X, r.Max.Y}}
+	case obj.vertical:
+		return []image.Point{r.Min, {r.Max.X, r.Min.Y}, {c.X, r.Max.Y}}
+	case i == 0:
+		return []image.Point{{r.Min.X, c.Y}, {r.Max.X, r.Min.Y}, r.Max}
+	}
+	return []image.Point{r.Min, {r.Max.X, c.Y}, {r.Min.X, r.Max.Y}}
+}
+
+// troughExtent returns the extent of the part of the
+// scrollbar that the thumb moves within, and the
+// length of the thumb.
+func (obj *Scrollbar) troughExtent() (min, max, thumb int) {
+	min, max = obj.along()
+	t := obj.thickness()
+	min += t
+	max -= t
+	thumb = int(obj.visible*float64(max-min) + 0.5)
+	if thumb < minThumb {
+		thumb = minThumb
+	}
+	if thumb > max-min {
+		thumb = max - min
+	}
+	return
+}
+
+func (obj *Scrollbar) thumbRect() image.Rectangle {
+	min, max, thumb := obj.troughExtent()
+	p := min + int(obj.pos*float64(max-min-thumb)+0.5)
+	return obj.span(p, p+thumb)
+}
+
+// SetVisible sets the fraction of the scrolled area
+// that is visible, which determines the size of the thumb.
+//
+func (obj *Scrollbar) SetVisible(f float64) {
+	if f <= 0 || f > 1 {
+		f = 1
+	}
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.visible = f
+		obj.moveThumb(flush)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Scrollbar) moveThumb(flush FlushFunc) {
+	r := obj.thumb.r
+	obj.thumb.r = obj.thumbRect()
+	flush.Rects(nil, r, obj.thumb.r)
+}
+
+func (obj *Scrollbar) listener() {
+	g := obj.value.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		v := clamp01(x.(float64))
+		obj.backing.Atomically(func(flush FlushFunc) {
+			obj.pos = v
+			obj.moveThumb(flush)
+		})
+		obj.backing.Flush()
+	}
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// scroll moves the scroll position by d, in units
+// of the visible fraction.
+func (obj *Scrollbar) scroll(d float64) {
+	var v float64
+	obj.backing.Atomically(func(_ FlushFunc) {
+		if obj.visible >= 1 {
+			v = obj.pos
+			return
+		}
+		// the scroll position covers the part of the
+		// area that is not visible.
+		v = clamp01(obj.pos + d*obj.visible/(1-obj.visible))
+	})
+	obj.value.Set(v)
+}
+
+// HandleMouse scrolls by a tenth of the visible fraction
+// when an arrow is clicked or the wheel is turned,
+// and by the whole visible fraction when the trough
+// is clicked. The thumb may be dragged.
+//
+func (obj *Scrollbar) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	switch {
+	case m.Buttons&(WheelUp|WheelLeft) != 0:
+		obj.scroll(-0.1)
+		return true
+	case m.Buttons&(WheelDown|WheelRight) != 0:
+		obj.scroll(0.1)
+		return true
+	case m.Buttons&1 == 0:
+		return false
+	}
+	var tr image.Rectangle
+	var pos float64
+	var tmin, tmax, thumb int
+	obj.backing.Atomically(func(_ FlushFunc) {
+		tr = obj.thumb.r
+		pos = obj.pos
+		tmin, tmax, thumb = obj.troughExtent()
+	})
+	switch {
+	case m.Loc.In(obj.arrowRect(0)):
+		obj.scroll(-0.1)
+	case m.Loc.In(obj.arrowRect(1)):
+		obj.scroll(0.1)
+	case m.Loc.In(tr):
+		p0 := obj.coord(m.Loc)
+		Drag(m, ec, func(m ui.MouseEvent) {
+			if n := tmax - tmin - thumb; n > 0 {
+				obj.value.Set(clamp01(pos + float64(obj.coord(m.Loc)-p0)/float64(n)))
+			}
+		})
+		return true
+	case obj.coord(m.Loc) < obj.coord(tr.Min):
+		obj.scroll(-1)
+	default:
+		obj.scroll(1)
+	}
+	waitRelease(m.Buttons, ec)
+	return true
+}
+
+// waitRelease reads mouse events from ec until
+// any of the buttons in but are released.
+func waitRelease(but int, ec <-chan interface{}) {
+	Drag(ui.MouseEvent{Buttons: but}, ec, nil)
+}
@@ -0,0 +1,267 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/raster"
+	"image"
+	"math"
+)
+
+// A SplineKind determines how a Spline
+// interpolates its control points.
+type SplineKind int
+
+const (
+	// CatmullRom splines pass through all their control points.
+	CatmullRom SplineKind = iota
+	// BSpline splines are smoother, but pass only
+	// through their first and last control points.
+	BSpline
+)
+
+// A Spline represents a smooth curve drawn through
+// or near a sequence of control points.
+//
+type Spline struct {
+	Item
+	raster  RasterItem
+	backing Backing
+	kind    SplineKind
+	points  []image.Point
+	width   float64
+}
+
+// NewSpline returns a new Spline of the given kind and width,
+// coloured with fill and using points as its control points.
+//
+func NewSpline(fill image.Image, kind SplineKind, points []image.Point, width float64) *Spline {
+	obj := new(Spline)
+	obj.kind = kind
+	obj.points = append([]image.Point(nil), points...)
+	obj.width = width
+	obj.raster.SetFill(fill)
+	obj.raster.SetFillRule(NonZero)
+	obj.Item = &obj.raster
+	obj.backing = NullBacking()
+	return obj
+}
+
+func (obj *Spline) rasterItem() *RasterItem {
+	return &obj.raster
+}
+
+func (obj *Spline) SetContainer(b Backing) {
+	obj.backing = b
+	obj.raster.SetContainer(b)
+	obj.makeOutline()
+}
+
+// SetPoints changes the control points of the spline.
+//
+func (obj *Spline) SetPoints(points []image.Point) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		obj.points = append(obj.points[:0], points...)
+		obj.makeOutline()
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+// Move moves the spline by delta.
+//
+func (obj *Spline) Move(delta image.Point) {
+	obj.Transform(Translation(float64(delta.X), float64(delta.Y)))
+}
+
+// Transform applies m to the control points of the
+// spline, rounding them to whole pixels; its
+// width stays the same.
+//
+func (obj *Spline) Transform(m Affine) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		for i, p := range obj.points {
+			obj.points[i] = m.pixel(p)
+		}
+		obj.makeOutline()
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+// SetAntialias sets the anti-aliasing used to draw the spline.
+// If a is AntialiasDefault, the setting of its canvas is used.
+//
+func (obj *Spline) SetAntialias(a Antialias) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetAntialias(a)
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+// SetHitThreshold sets the coverage that a pixel of the spline
+// must exceed for HitTest to succeed, as for RasterItem.
+//
+func (obj *Spline) SetHitThreshold(a uint8) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		obj.raster.SetHitThreshold(a)
+	})
+}
+
+// SetFill changes the colour of the spline.
+//
+func (obj *Spline) SetFill(fill image.Image) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetFill(fill)
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+func (obj *Spline) makeOutline() {
+	obj.raster.Clear()
+	strokePolyline(&obj.raster, obj.curve(), obj.width)
+	obj.raster.CalcBbox()
+}
+
+// curve returns the curve approximated as a set of points.
+// The points are offset to the centres of their pixels.
+func (obj *Spline) curve() (pts []fpoint) {
+	n := len(obj.points)
+	if n == 0 {
+		return nil
+	}
+	cp := make([]fpoint, 0, n+4)
+	if obj.kind == BSpline {
+		// Repeat the end points so that the curve is clamped to them.
+		cp = append(cp, pixelCentref(obj.points[0]))
+	}
+	cp = append(cp, pixelCentref(obj.points[0]))
+	for _, p := range obj.points {
+		cp = append(cp, pixelCentref(p))
+	}
+	cp = append(cp, pixelCentref(obj.points[n-1]))
+	if obj.kind == BSpline {
+		cp = append(cp, pixelCentref(obj.points[n-1]))
+	}
+	eval := catmullRom
+	if obj.kind == BSpline {
+		eval = bspline
+	}
+	pts = append(pts, eval(cp[0:4], 0))
+	for i := 0; i+3 < len(cp); i++ {
+		seg := cp[i : i+4]
+		steps := int(seg[1].dist(seg[2])/4) + 4
+		for j := 1; j <= steps; j++ {
+			pts = append(pts, eval(seg, float64(j)/float64(steps)))
+		}
+	}
+	return pts
+}
+
+func catmullRom(p []fpoint, t float64) fpoint {
+	t2 := t * t
+	t3 := t2 * t
+	f := func(p0, p1, p2, p3 float64) float64 {
+		return 0.5 * (2*p1 + (p2-p0)*t + (2*p0-5*p1+4*p2-p3)*t2 + (3*p1-p0-3*p2+p3)*t3)
+	}
+	return fpoint{f(p[0].x, p[1].x, p[2].x, p[3].x), f(p[0].y, p[1].y, p[2].y, p[3].y)}
+}
+
+func bspline(p []fpoint, t float64) fpoint {
+	t2 := t * t
+	t3 := t2 * t
+	b0 := (1 - 3*t + 3*t2 - t3) / 6
+	b1 := (4 - 6*t2 + 3*t3) / 6
+	b2 := (1 + 3*t + 3*t2 - 3*t3) / 6
+	b3 := t3 / 6
+	return fpoint{
+		b0*p[0].x + b1*p[1].x + b2*p[2].x + b3*p[3].x,
+		b0*p[0].y + b1*p[1].y + b2*p[2].y + b3*p[3].y,
+	}
+}
+
+// strokePolyline adds to r the outline of a line of the
+// given width following pts. The outline may
+// intersect itself, so r should use the NonZero fill rule.
+func strokePolyline(r *RasterItem, pts []fpoint, width float64) {
+	// remove duplicate points, which have no direction.
+	var q []fpoint
+	for _, p := range pts {
+		if len(q) == 0 || p.dist(q[len(q)-1]) > 1e-6 {
+			q = append(q, p)
+		}
+	}
+	pts = q
+	if len(pts) < 2 {
+		return
+	}
+	hw := width / 2
+	left := make([]fpoint, len(pts))
+	right := make([]fpoint, len(pts))
+	for i, p := range pts {
+		var n fpoint
+		switch i {
+		case 0:
+			n = pts[1].sub(p).normal()
+		case len(pts) - 1:
+			n = p.sub(pts[i-1]).normal()
+		default:
+			n0 := p.sub(pts[i-1]).normal()
+			n1 := pts[i+1].sub(p).normal()
+			n = n0.add(n1)
+			// scale to keep the stroke width constant at the join,
+			// limiting the scale at very sharp corners.
+			if d := n.x*n0.x + n.y*n0.y; d > 0.25 {
+				n = n.mul(1 / d)
+			} else {
+				n = n.mul(4)
+			}
+		}
+		left[i] = p.add(n.mul(hw))
+		right[i] = p.sub(n.mul(hw))
+	}
+	r.Start(left[0].fix())
+	for _, p := range left[1:] {
+		r.Add1(p.fix())
+	}
+	for i := len(right) - 1; i >= 0; i-- {
+		r.Add1(right[i].fix())
+	}
+	r.Add1(left[0].fix())
+}
+
+// An fpoint holds a point in floating point pixel coordinates.
+type fpoint struct {
+	x, y float64
+}
+
+func pixelCentref(p image.Point) fpoint {
+	return fpoint{float64(p.X) + 0.5, float64(p.Y) + 0.5}
+}
+
+func (p fpoint) add(q fpoint) fpoint {
+	return fpoint{p.x + q.x, p.y + q.y}
+}
+
+func (p fpoint) sub(q fpoint) fpoint {
+	return fpoint{p.x - q.x, p.y - q.y}
+}
+
+func (p fpoint) mul(k float64) fpoint {
+	return fpoint{p.x * k, p.y * k}
+}
+
+func (p fpoint) dist(q fpoint) float64 {
+	return math.Hypot(p.x-q.x, p.y-q.y)
+}
+
+// normal returns the unit vector perpendicular to p.
+func (p fpoint) normal() fpoint {
+	l := math.Hypot(p.x, p.y)
+	if l == 0 {
+		return fpoint{}
+	}
+	return fpoint{-p.y / l, p.x / l}
+}
+
+func (p fpoint) fix() raster.Point {
+	return raster.Point{float2fix(p.x), float2fix(p.y)}
+}
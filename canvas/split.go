@@ -0,0 +1,299 @@
+package canvas
+
+import (
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+)
+
+// dividerWidth is the width of the divider in a Split.
+const dividerWidth = 6
+
+// A Split divides its area into two panes separated by a
+// divider that can be dragged with the mouse to change their
+// relative sizes. Each pane shows one item, clipped to the pane.
+//
+type Split struct {
+	backing Backing
+	Item
+	c        *Canvas
+	r        image.Rectangle
+	vertical bool
+	frac     float64 // position of the divider, in the range [0, 1].
+	panes    [2]*Viewport
+	items    [2]Item
+	divider  Rect
+	theme    *Theme
+	pref     image.Point // the size the split was created with.
+}
+
+var _ HandlerItem = (*Split)(nil)
+var _ Backing = (*Split)(nil)
+var _ ResizableItem = (*Split)(nil)
+var _ Sizer = (*Split)(nil)
+
+// NewHSplit returns a new Split occupying r, with a on the
+// left and b on the right. The divider is placed the
+// proportion frac of the way across.
+//
+func NewHSplit(r image.Rectangle, a, b Item, frac float64) *Split {
+	return NewSplit(r, Horizontal, a, b, frac)
+}
+
+// NewVSplit returns a new Split occupying r, with a on the
+// top and b underneath. The divider is placed the
+// proportion frac of the way down.
+//
+func NewVSplit(r image.Rectangle, a, b Item, frac float64) *Split {
+	return NewSplit(r, Vertical, a, b, frac)
+}
+
+// NewSplit returns a new Split occupying r, with the panes laid out
+// in the given orientation.
+// Whenever the divider moves, each item that is a
+// ResizableItem is told the new bounds of its pane.
+//
+func NewSplit(r image.Rectangle, o Orientation, a, b Item, frac float64) *Split {
+	obj := new(Split)
+	obj.backing = NullBacking()
+	obj.r = r
+	obj.pref = r.Size()
+	obj.vertical = o == Vertical
+	obj.frac = clamp01(frac)
+	obj.items = [2]Item{a, b}
+	obj.c = NewCanvas(nil, r)
+	obj.c.SetContainer(obj)
+	pr := obj.paneRects()
+	for i, it := range obj.items {
+		obj.panes[i] = NewViewport(pr[i], it)
+		obj.panes[i].offset = pr[i].Min
+		obj.c.AddItem(obj.panes[i])
+	}
+	obj.divider = *NewRect(obj.dividerRect(), image.Black, 0, nil)
+	obj.c.AddItem(&obj.divider)
+	obj.restyle()
+	obj.Item = obj.c
+	obj.relayout(pr)
+	return obj
+}
+
+func (obj *Split) SetContainer(b Backing) {
+	obj.backing = b
+	obj.restyle()
+	// let the items in the panes find their theme again.
+	obj.c.SetContainer(obj)
+}
+
+// SetTheme sets the theme used to draw the divider,
+// overriding that of the split's canvas. If t is nil,
+// the canvas's theme is used.
+//
+func (obj *Split) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.divider.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Split) restyle() {
+	obj.divider.fill = themeFor(obj.theme, obj.backing).border()
+}
+
+func (obj *Split) Atomically(f func(FlushFunc)) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		f(func(r image.Rectangle, it Drawer) {
+			// anything outside the split is clipped.
+			if r = r.Intersect(obj.r); r.Empty() {
+				return
+			}
+			if it != nil {
+				it = obj
+			}
+			flush(r, it)
+		})
+	})
+}
+
+func (obj *Split) Rect() image.Rectangle {
+	return obj.r
+}
+
+func (obj *Split) Flush() {
+	obj.backing.Flush()
+}
+
+func (obj *Split) outer() Backing {
+	return obj.backing
+}
+
+func (obj *Split) HandleEnter(m ui.MouseEvent) {
+}
+
+func (obj *Split) HandleLeave(m ui.MouseEvent) {
+	obj.c.HandleLeave(m)
+}
+
+func (obj *Split) HandleKey(f Flusher, k ui.KeyEvent) bool {
+	return obj.c.HandleKey(f, k)
+}
+
+func (obj *Split) enterFocus(backward bool) bool {
+	return obj.c.enterFocus(backward)
+}
+
+func (obj *Split) leaveFocus() {
+	obj.c.leaveFocus()
+}
+
+// span returns the rectangle that covers [min, max)
+// across the split.
+func (obj *Split) span(min, max int) image.Rectangle {
+	if obj.vertical {
+		return image.Rect(obj.r.Min.X, min, obj.r.Max.X, max)
+	}
+	return image.Rect(min, obj.r.Min.Y, max, obj.r.Max.Y)
+}
+
+// dividerPos returns the coordinate of the start of the divider.
+func (obj *Split) dividerPos() int {
+	min, max := obj.r.Min.X, obj.r.Max.X
+	if obj.vertical {
+		min, max = obj.r.Min.Y, obj.r.Max.Y
+	}
+	return min + int(obj.frac*float64(max-min-dividerWidth)+0.5)
+}
+
+func (obj *Split) dividerRect() image.Rectangle {
+	p := obj.dividerPos()
+	return obj.span(p, p+dividerWidth)
+}
+
+func (obj *Split) paneRects() (r [2]image.Rectangle) {
+	p := obj.dividerPos()
+	if obj.vertical {
+		r[0] = obj.span(obj.r.Min.Y, p)
+		r[1] = obj.span(p+dividerWidth, obj.r.Max.Y)
+	} else {
+		r[0] = obj.span(obj.r.Min.X, p)
+		r[1] = obj.span(p+dividerWidth, obj.r.Max.X)
+	}
+	return
+}
+
+// SetFraction moves the divider the proportion
+// f of the way across (or down) the split.
+//
+func (obj *Split) SetFraction(f float64) {
+	var pr [2]image.Rectangle
+	changed := false
+	obj.backing.Atomically(func(flush FlushFunc) {
+		f = clamp01(f)
+		if f == obj.frac {
+			return
+		}
+		obj.frac = f
+		pr = obj.placePanes()
+		flush(obj.r, nil)
+		changed = true
+	})
+	if changed {
+		obj.relayout(pr)
+	}
+	obj.backing.Flush()
+}
+
+// placePanes moves the panes and the divider to
+// their current positions, and returns the
+// rectangles of the panes.
+func (obj *Split) placePanes() [2]image.Rectangle {
+	pr := obj.paneRects()
+	for i, pane := range obj.panes {
+		pane.r = pr[i]
+		pane.offset = pr[i].Min
+	}
+	obj.divider.r = obj.dividerRect()
+	return pr
+}
+
+// SetBounds moves and resizes the split to occupy r,
+// keeping the divider at the same proportion of
+// the way across, and lays out its items again.
+//
+func (obj *Split) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	var pr [2]image.Rectangle
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		obj.c.r = r
+		pr = obj.placePanes()
+		flush.Rects(nil, old, r)
+	})
+	obj.relayout(pr)
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed for both items
+// at their minimum sizes, with the divider between them.
+//
+func (obj *Split) MinSize() image.Point {
+	a, b := minSize(obj.items[0]), minSize(obj.items[1])
+	if obj.vertical {
+		return image.Pt(maxPoint(a, b).X, a.Y+b.Y+dividerWidth)
+	}
+	return image.Pt(a.X+b.X+dividerWidth, maxPoint(a, b).Y)
+}
+
+func (obj *Split) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+// relayout tells the items the new bounds of their panes.
+// It must not be called from within Atomically, as the items
+// will make their own changes.
+func (obj *Split) relayout(pr [2]image.Rectangle) {
+	for i, it := range obj.items {
+		if it, ok := it.(ResizableItem); ok {
+			it.SetBounds(pr[i])
+		}
+	}
+}
+
+// Fraction returns the current position of the divider.
+//
+func (obj *Split) Fraction() (f float64) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		f = obj.frac
+	})
+	return
+}
+
+// HandleMouse drags the divider when the first mouse button is
+// pressed over it; other events are passed to the panes.
+//
+func (obj *Split) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	var div image.Rectangle
+	obj.backing.Atomically(func(_ FlushFunc) {
+		div = obj.divider.r
+	})
+	if m.Buttons&1 == 0 || !m.Loc.In(div) {
+		return obj.c.HandleMouse(f, m, ec)
+	}
+	min, max := obj.r.Min.X, obj.r.Max.X
+	grab := m.Loc.X - div.Min.X
+	if obj.vertical {
+		min, max = obj.r.Min.Y, obj.r.Max.Y
+		grab = m.Loc.Y - div.Min.Y
+	}
+	Drag(m, ec, func(m ui.MouseEvent) {
+		p := m.Loc.X
+		if obj.vertical {
+			p = m.Loc.Y
+		}
+		if n := max - min - dividerWidth; n > 0 {
+			obj.SetFraction(float64(p-grab-min) / float64(n))
+		}
+	})
+	return true
+}
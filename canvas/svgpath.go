@@ -0,0 +1,568 @@
+package canvas
+
+import (
+	"errors"
+	"image"
+	"math"
+	"strconv"
+)
+
+// A Path is a filled shape whose outline is made of straight
+// lines and Bézier curves, such as one described by the path data
+// of SVG, so that icons and shapes drawn in vector tools can
+// be placed on a canvas. Each subpath is closed when it is filled.
+// As in SVG, the non-zero winding rule is used by default.
+//
+type Path struct {
+	Item
+	raster  RasterItem
+	backing Backing
+	segs    []pathSeg
+}
+
+type pathOp int
+
+const (
+	pathMove pathOp = iota
+	pathLine
+	pathQuad
+	pathCubic
+)
+
+// A pathSeg is one segment of a path, ending at the last
+// point used by its op, after any control points.
+type pathSeg struct {
+	op pathOp
+	p  [3]PointF
+}
+
+// NewPath returns a new Path coloured with fill, with the
+// outline described by the SVG path data d, such as
+// "M 10 10 h 20 v 20 z". The coordinates are in pixels.
+//
+func NewPath(fill image.Image, d string) (*Path, error) {
+	segs, err := parseSVGPath(d)
+	if err != nil {
+		return nil, err
+	}
+	obj := new(Path)
+	obj.segs = segs
+	obj.raster.SetFill(fill)
+	obj.raster.SetFillRule(NonZero)
+	obj.Item = &obj.raster
+	obj.backing = NullBacking()
+	return obj, nil
+}
+
+func (obj *Path) rasterItem() *RasterItem {
+	return &obj.raster
+}
+
+func (obj *Path) SetContainer(b Backing) {
+	obj.backing = b
+	obj.raster.SetContainer(b)
+	obj.makeOutline()
+}
+
+// SetData replaces the outline of the path with that
+// described by the SVG path data d. If d cannot be
+// parsed, the path is left unchanged.
+//
+func (obj *Path) SetData(d string) error {
+	segs, err := parseSVGPath(d)
+	if err != nil {
+		return err
+	}
+	obj.reshape(func() {
+		obj.segs = segs
+	})
+	return nil
+}
+
+// Move moves the path by delta.
+//
+func (obj *Path) Move(delta image.Point) {
+	obj.Transform(Translation(float64(delta.X), float64(delta.Y)))
+}
+
+// MoveF is like Move, but delta may be a fraction of a pixel.
+//
+func (obj *Path) MoveF(delta PointF) {
+	obj.Transform(Translation(delta.X, delta.Y))
+}
+
+// Transform applies m to the points of the path,
+// including the control points of its curves,
+// so that the curves are transformed exactly.
+//
+func (obj *Path) Transform(m Affine) {
+	obj.reshape(func() {
+		for i := range obj.segs {
+			for j, p := range obj.segs[i].p {
+				x, y := m.Transform(p.X, p.Y)
+				obj.segs[i].p[j] = PointF{x, y}
+			}
+		}
+	})
+}
+
+// SetFill changes the colour of the path.
+//
+func (obj *Path) SetFill(fill image.Image) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetFill(fill)
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+// SetFillRule sets the rule used to determine the inside of
+// the path, as for the fill-rule property of SVG.
+//
+func (obj *Path) SetFillRule(rule FillRule) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetFillRule(rule)
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+// SetAntialias sets the anti-aliasing used to draw the path.
+// If a is AntialiasDefault, the setting of its canvas is used.
+//
+func (obj *Path) SetAntialias(a Antialias) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.raster.SetAntialias(a)
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+// SetHitThreshold sets the coverage that a pixel of the path
+// must exceed for HitTest to succeed, as for RasterItem.
+//
+func (obj *Path) SetHitThreshold(a uint8) {
+	obj.backing.Atomically(func(_ FlushFunc) {
+		obj.raster.SetHitThreshold(a)
+	})
+}
+
+// reshape calls f to change the path's segments,
+// then recalculates its outline and flushes the
+// old and new areas.
+func (obj *Path) reshape(f func()) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		r := obj.raster.Bbox()
+		f()
+		obj.makeOutline()
+		flush.Rects(nil, r, obj.raster.Bbox())
+	})
+}
+
+func (obj *Path) makeOutline() {
+	obj.raster.Clear()
+	var start PointF
+	open := false
+	for _, s := range obj.segs {
+		switch s.op {
+		case pathMove:
+			if open {
+				obj.raster.Add1(start.fixed())
+			}
+			start = s.p[0]
+			obj.raster.Start(start.fixed())
+			open = true
+		case pathLine:
+			obj.raster.Add1(s.p[0].fixed())
+		case pathQuad:
+			obj.raster.Add2(s.p[0].fixed(), s.p[1].fixed())
+		case pathCubic:
+			obj.raster.Add3(s.p[0].fixed(), s.p[1].fixed(), s.p[2].fixed())
+		}
+	}
+	if open {
+		obj.raster.Add1(start.fixed())
+	}
+	obj.raster.CalcBbox()
+}
+
+// SVGPathPolygons returns the subpaths of the SVG path data d
+// as polygons, with each curve approximated by straight lines
+// to within tolerance pixels, for use with NewPolygonF.
+//
+func SVGPathPolygons(d string, tolerance float64) ([][]PointF, error) {
+	segs, err := parseSVGPath(d)
+	if err != nil {
+		return nil, err
+	}
+	if tolerance <= 0 {
+		tolerance = 0.25
+	}
+	var polys [][]PointF
+	var poly []PointF
+	for _, s := range segs {
+		switch s.op {
+		case pathMove:
+			if len(poly) > 1 {
+				polys = append(polys, poly)
+			}
+			poly = []PointF{s.p[0]}
+		case pathLine:
+			poly = append(poly, s.p[0])
+		case pathQuad, pathCubic:
+			poly = flattenCurve(poly, s, tolerance)
+		}
+	}
+	if len(poly) > 1 {
+		polys = append(polys, poly)
+	}
+	return polys, nil
+}
+
+// flattenCurve appends points along the curve s, which starts
+// at the last point of poly, to poly, no further apart than
+// is needed for lines between them to lie within tol.
+func flattenCurve(poly []PointF, s pathSeg, tol float64) []PointF {
+	p0 := poly[len(poly)-1]
+	ctrl := []PointF{p0, s.p[0], s.p[1]}
+	if s.op == pathCubic {
+		ctrl = append(ctrl, s.p[2])
+	}
+	// The distance of the control points from the chord
+	// bounds the distance of the curve from it.
+	end := ctrl[len(ctrl)-1]
+	dev := 0.0
+	for _, c := range ctrl[1 : len(ctrl)-1] {
+		dev = math.Max(dev, math.Hypot(c.X-(p0.X+end.X)/2, c.Y-(p0.Y+end.Y)/2))
+	}
+	n := int(math.Ceil(math.Sqrt(dev / tol)))
+	if n < 1 {
+		n = 1
+	}
+	for i := 1; i <= n; i++ {
+		poly = append(poly, bezier(ctrl, float64(i)/float64(n)))
+	}
+	return poly
+}
+
+// bezier returns the point at t along the Bézier
+// curve with control points ctrl.
+func bezier(ctrl []PointF, t float64) PointF {
+	p := append([]PointF(nil), ctrl...)
+	for n := len(p) - 1; n > 0; n-- {
+		for i := 0; i < n; i++ {
+			p[i] = PointF{p[i].X + (p[i+1].X-p[i].X)*t, p[i].Y + (p[i+1].Y-p[i].Y)*t}
+		}
+	}
+	return p[0]
+}
+
+// svgPathParser holds the state of parseSVGPath.
+type svgPathParser struct {
+	s    string
+	i    int
+	segs []pathSeg
+
+	cur, start PointF // the current point and that of the subpath.
+	ctrl       PointF // the last control point, for S and T.
+	lastOp     byte   // the last command, in upper case.
+}
+
+// parseSVGPath parses SVG path data, as in the d attribute of
+// a path element. Arcs are converted to cubic Bézier curves,
+// and horizontal and vertical lines to lines.
+func parseSVGPath(d string) ([]pathSeg, error) {
+	p := &svgPathParser{s: d}
+	cmd := byte(0)
+	for {
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			break
+		}
+		c := p.s[p.i]
+		if isPathCommand(c) {
+			cmd = c
+			p.i++
+		} else if cmd == 0 {
+			return nil, p.error("expected command")
+		}
+		if err := p.command(cmd); err != nil {
+			return nil, err
+		}
+		switch cmd {
+		case 'M':
+			// Further coordinates after a moveto are lines.
+			cmd = 'L'
+		case 'm':
+			cmd = 'l'
+		case 'Z', 'z':
+			cmd = 0
+		}
+	}
+	return p.segs, nil
+}
+
+// pathArgs holds the number of arguments of each command.
+var pathArgs = map[byte]int{'M': 2, 'L': 2, 'H': 1, 'V': 1, 'C': 6, 'S': 4, 'Q': 4, 'T': 2, 'A': 7, 'Z': 0}
+
+func isPathCommand(c byte) bool {
+	switch c {
+	case 'M', 'm', 'Z', 'z', 'L', 'l', 'H', 'h', 'V', 'v',
+		'C', 'c', 'S', 's', 'Q', 'q', 'T', 't', 'A', 'a':
+		return true
+	}
+	return false
+}
+
+func (p *svgPathParser) error(msg string) error {
+	return errors.New("svg path: " + msg + " at offset " + strconv.Itoa(p.i))
+}
+
+// command parses the arguments of one instance of cmd,
+// and adds the resulting segments.
+func (p *svgPathParser) command(cmd byte) error {
+	rel := cmd >= 'a'
+	upper := cmd &^ 0x20
+	var org PointF
+	if rel {
+		org = p.cur
+	}
+	var a [7]float64
+	for i := 0; i < pathArgs[upper]; i++ {
+		var err error
+		if upper == 'A' && (i == 3 || i == 4) {
+			a[i], err = p.flag()
+		} else {
+			a[i], err = p.number()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	pt := func(i int) PointF {
+		return PointF{org.X + a[i], org.Y + a[i+1]}
+	}
+	// For S and T, the first control point is the reflection
+	// of the last one, if the previous command was of the same kind.
+	reflect := func(kinds string) PointF {
+		for i := 0; i < len(kinds); i++ {
+			if p.lastOp == kinds[i] {
+				return PointF{2*p.cur.X - p.ctrl.X, 2*p.cur.Y - p.ctrl.Y}
+			}
+		}
+		return p.cur
+	}
+	switch upper {
+	case 'M':
+		p.cur = pt(0)
+		p.start = p.cur
+		p.add(pathMove, p.cur)
+	case 'Z':
+		if p.cur != p.start {
+			p.add(pathLine, p.start)
+		}
+		p.cur = p.start
+	case 'L':
+		p.line(pt(0))
+	case 'H':
+		x := a[0]
+		if rel {
+			x += p.cur.X
+		}
+		p.line(PointF{x, p.cur.Y})
+	case 'V':
+		y := a[0]
+		if rel {
+			y += p.cur.Y
+		}
+		p.line(PointF{p.cur.X, y})
+	case 'C':
+		p.cubic(pt(0), pt(2), pt(4))
+	case 'S':
+		p.cubic(reflect("CS"), pt(0), pt(2))
+	case 'Q':
+		p.quad(pt(0), pt(2))
+	case 'T':
+		p.quad(reflect("QT"), pt(0))
+	case 'A':
+		p.arc(a[0], a[1], a[2], a[3] != 0, a[4] != 0, pt(5))
+	}
+	p.lastOp = upper
+	return nil
+}
+
+// add adds a segment, first starting a subpath at the current
+// point if there is none, as after a closepath.
+func (p *svgPathParser) add(op pathOp, pts ...PointF) {
+	if op != pathMove && (len(p.segs) == 0 || p.lastOp == 'Z') {
+		p.segs = append(p.segs, pathSeg{op: pathMove, p: [3]PointF{p.cur}})
+	}
+	var s pathSeg
+	s.op = op
+	copy(s.p[:], pts)
+	p.segs = append(p.segs, s)
+}
+
+func (p *svgPathParser) line(to PointF) {
+	p.add(pathLine, to)
+	p.cur = to
+}
+
+func (p *svgPathParser) quad(c, to PointF) {
+	p.add(pathQuad, c, to)
+	p.ctrl = c
+	p.cur = to
+}
+
+func (p *svgPathParser) cubic(c0, c1, to PointF) {
+	p.add(pathCubic, c0, c1, to)
+	p.ctrl = c1
+	p.cur = to
+}
+
+// arc adds an elliptical arc from the current point to `to`,
+// following the SVG implementation notes on converting
+// from endpoint to centre parameterization, as cubic
+// curves each spanning no more than a quarter turn.
+func (p *svgPathParser) arc(rx, ry, rotation float64, large, sweep bool, to PointF) {
+	from := p.cur
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if from == to {
+		return
+	}
+	if rx == 0 || ry == 0 {
+		p.line(to)
+		return
+	}
+	phi := rotation * math.Pi / 180
+	sin, cos := math.Sin(phi), math.Cos(phi)
+	dx, dy := (from.X-to.X)/2, (from.Y-to.Y)/2
+	x1 := cos*dx + sin*dy
+	y1 := -sin*dx + cos*dy
+	// Enlarge the radii if they cannot span the endpoints.
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		l = math.Sqrt(l)
+		rx, ry = rx*l, ry*l
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	k := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		k = -k
+	}
+	cx1, cy1 := k*rx*y1/ry, -k*ry*x1/rx
+	cx := cos*cx1 - sin*cy1 + (from.X+to.X)/2
+	cy := sin*cx1 + cos*cy1 + (from.Y+to.Y)/2
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	// The control points of a cubic approximating an
+	// arc of the unit circle lie at this distance along
+	// the tangents at its ends.
+	t := 4.0 / 3 * math.Tan(step/4)
+	point := func(a float64) (PointF, PointF) {
+		ex, ey := rx*math.Cos(a), ry*math.Sin(a)
+		// The tangent at a.
+		tx, ty := -rx*math.Sin(a), ry*math.Cos(a)
+		pos := PointF{cx + cos*ex - sin*ey, cy + sin*ex + cos*ey}
+		tan := PointF{cos*tx - sin*ty, sin*tx + cos*ty}
+		return pos, tan
+	}
+	a0 := theta
+	p0, t0 := point(a0)
+	for i := 0; i < n; i++ {
+		a1 := a0 + step
+		p1, t1 := point(a1)
+		if i == n-1 {
+			p1 = to
+		}
+		p.cubic(PointF{p0.X + t*t0.X, p0.Y + t*t0.Y}, PointF{p1.X - t*t1.X, p1.Y - t*t1.Y}, p1)
+		a0, p0, t0 = a1, p1, t1
+	}
+}
+
+func (p *svgPathParser) skipSpace() {
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case ' ', '\t', '\n', '\r', '\f':
+			p.i++
+		default:
+			return
+		}
+	}
+}
+
+// skipSeparator skips white space and at most one comma.
+func (p *svgPathParser) skipSeparator() {
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == ',' {
+		p.i++
+		p.skipSpace()
+	}
+}
+
+// number parses a number, which may run straight into the
+// next, as in "0.5.5" or "1-2", as SVG allows.
+func (p *svgPathParser) number() (float64, error) {
+	p.skipSeparator()
+	start := p.i
+	if p.i < len(p.s) && (p.s[p.i] == '+' || p.s[p.i] == '-') {
+		p.i++
+	}
+	digits := p.digits()
+	if p.i < len(p.s) && p.s[p.i] == '.' {
+		p.i++
+		digits += p.digits()
+	}
+	if digits == 0 {
+		p.i = start
+		return 0, p.error("expected number")
+	}
+	if p.i < len(p.s) && (p.s[p.i] == 'e' || p.s[p.i] == 'E') {
+		j := p.i + 1
+		if j < len(p.s) && (p.s[j] == '+' || p.s[j] == '-') {
+			j++
+		}
+		if j < len(p.s) && p.s[j] >= '0' && p.s[j] <= '9' {
+			p.i = j
+			p.digits()
+		}
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.i], 64)
+	if err != nil {
+		return 0, p.error("bad number")
+	}
+	return f, nil
+}
+
+func (p *svgPathParser) digits() int {
+	n := 0
+	for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+		p.i++
+		n++
+	}
+	return n
+}
+
+// flag parses an arc flag, a single 0 or 1, which
+// need not be separated from what follows.
+func (p *svgPathParser) flag() (float64, error) {
+	p.skipSeparator()
+	if p.i < len(p.s) {
+		switch p.s[p.i] {
+		case '0':
+			p.i++
+			return 0, nil
+		case '1':
+			p.i++
+			return 1, nil
+		}
+	}
+	return 0, p.error("expected flag")
+}
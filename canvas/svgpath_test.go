@@ -0,0 +1,192 @@
+package canvas
+
+import (
+	"math"
+	"testing"
+)
+
+func seg(op pathOp, pts ...float64) pathSeg {
+	s := pathSeg{op: op}
+	for i := 0; i < len(pts); i += 2 {
+		s.p[i/2] = PointF{pts[i], pts[i+1]}
+	}
+	return s
+}
+
+var parseSVGPathTests = []struct {
+	d    string
+	want []pathSeg
+}{
+	// Implicit linetos after a moveto.
+	{"M 1 2 3 4 5 6", []pathSeg{
+		seg(pathMove, 1, 2),
+		seg(pathLine, 3, 4),
+		seg(pathLine, 5, 6),
+	}},
+	{"m1 2 3 4", []pathSeg{
+		seg(pathMove, 1, 2),
+		seg(pathLine, 4, 6),
+	}},
+	// Numbers that run together.
+	{"M0.5.5 1-2", []pathSeg{
+		seg(pathMove, 0.5, 0.5),
+		seg(pathLine, 1, -2),
+	}},
+	{"M1e2-3,-.5+6", []pathSeg{
+		seg(pathMove, 100, -3),
+		seg(pathLine, -0.5, 6),
+	}},
+	{"M0 0h10v10H0V5", []pathSeg{
+		seg(pathMove, 0, 0),
+		seg(pathLine, 10, 0),
+		seg(pathLine, 10, 10),
+		seg(pathLine, 0, 10),
+		seg(pathLine, 0, 5),
+	}},
+	// S and T reflect the previous control point only
+	// after a command of the same kind.
+	{"M0 0 C1 1 2 1 3 0 S5 -1 6 0", []pathSeg{
+		seg(pathMove, 0, 0),
+		seg(pathCubic, 1, 1, 2, 1, 3, 0),
+		seg(pathCubic, 4, -1, 5, -1, 6, 0),
+	}},
+	{"M0 0 S1 1 2 0", []pathSeg{
+		seg(pathMove, 0, 0),
+		seg(pathCubic, 0, 0, 1, 1, 2, 0),
+	}},
+	{"M0 0 Q1 1 2 0 T4 0 t2 0", []pathSeg{
+		seg(pathMove, 0, 0),
+		seg(pathQuad, 1, 1, 2, 0),
+		seg(pathQuad, 3, -1, 4, 0),
+		seg(pathQuad, 5, 1, 6, 0),
+	}},
+	{"M0 0 L1 1 T3 1", []pathSeg{
+		seg(pathMove, 0, 0),
+		seg(pathLine, 1, 1),
+		seg(pathQuad, 1, 1, 3, 1),
+	}},
+	// Subpaths after a closepath start at its start point.
+	{"M0 0 L10 0 L10 10 Z L0 10", []pathSeg{
+		seg(pathMove, 0, 0),
+		seg(pathLine, 10, 0),
+		seg(pathLine, 10, 10),
+		seg(pathLine, 0, 0),
+		seg(pathMove, 0, 0),
+		seg(pathLine, 0, 10),
+	}},
+	{"M1 1 h10 v10 z m5 5 h1 Z", []pathSeg{
+		seg(pathMove, 1, 1),
+		seg(pathLine, 11, 1),
+		seg(pathLine, 11, 11),
+		seg(pathLine, 1, 1),
+		seg(pathMove, 6, 6),
+		seg(pathLine, 7, 6),
+		seg(pathLine, 6, 6),
+	}},
+	{"M0 0 L1 0 L0 0 Z", []pathSeg{
+		seg(pathMove, 0, 0),
+		seg(pathLine, 1, 0),
+		seg(pathLine, 0, 0),
+	}},
+	// Degenerate arcs.
+	{"M0 0 A0 5 0 0 1 10 0 A5 5 0 0 1 10 0", []pathSeg{
+		seg(pathMove, 0, 0),
+		seg(pathLine, 10, 0),
+	}},
+}
+
+func TestParseSVGPath(t *testing.T) {
+	for _, test := range parseSVGPathTests {
+		got, err := parseSVGPath(test.d)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.d, err)
+			continue
+		}
+		if !equalSegs(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.d, got, test.want)
+		}
+	}
+}
+
+// Each of these pairs of paths should parse the same.
+var svgPathEquivTests = []struct {
+	d, like string
+}{
+	// Arc flags without separators.
+	{"M0 0 A5 5 0 1010 0", "M0 0 A 5 5 0 1 0 10 0"},
+	{"M0 0A5 5 0 109 1", "M0 0 A 5 5 0 1 0 9 1"},
+	{"M0 0a5,5,30,0,1,10,0", "M 0 0 a 5 5 30 0 1 10 0"},
+	{"M0 0 a5 5 0 0110 0 5 5 0 0110 0", "M0 0 A5 5 0 0 1 10 0 A5 5 0 0 1 20 0"},
+}
+
+func TestParseSVGPathEquiv(t *testing.T) {
+	for _, test := range svgPathEquivTests {
+		got, err := parseSVGPath(test.d)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.d, err)
+			continue
+		}
+		want, err := parseSVGPath(test.like)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", test.like, err)
+		}
+		if !equalSegs(got, want) {
+			t.Errorf("%q: got %v, want %v, as for %q", test.d, got, want, test.like)
+		}
+	}
+}
+
+func TestParseSVGPathArc(t *testing.T) {
+	segs, err := parseSVGPath("M0 0 A5 5 0 1 0 10 0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A half turn is made of two quarter turns.
+	if len(segs) != 3 || segs[1].op != pathCubic || segs[2].op != pathCubic {
+		t.Fatalf("got %v, want a move and two cubics", segs)
+	}
+	if p := segs[2].p[2]; !nearPoint(p, PointF{10, 0}) {
+		t.Errorf("arc ends at %v, want (10, 0)", p)
+	}
+	if p := segs[1].p[2]; !nearPoint(p, PointF{5, 5}) && !nearPoint(p, PointF{5, -5}) {
+		t.Errorf("arc passes through %v, want (5, ±5)", p)
+	}
+}
+
+var parseSVGPathErrorTests = []string{
+	"10 10",
+	"M 1",
+	"M0 0 L1",
+	"M0 0 A5 5 0 2 0 1 1",
+	"M0 0 Z 1 1",
+	"M0 0 X",
+}
+
+func TestParseSVGPathError(t *testing.T) {
+	for _, d := range parseSVGPathErrorTests {
+		if segs, err := parseSVGPath(d); err == nil {
+			t.Errorf("%q: got %v, want error", d, segs)
+		}
+	}
+}
+
+func equalSegs(a, b []pathSeg) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].op != b[i].op {
+			return false
+		}
+		for j := range a[i].p {
+			if !nearPoint(a[i].p[j], b[i].p[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func nearPoint(p, q PointF) bool {
+	return math.Abs(p.X-q.X) < 1e-9 && math.Abs(p.Y-q.Y) < 1e-9
+}
@@ -0,0 +1,433 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	tableRowHeight = listRowHeight
+	minColumnWidth = 8
+	resizeSlop     = 3 // how close to a column edge a press must be to resize it.
+)
+
+// A Table shows rows of text in columns with headers.
+// Only the visible rows are drawn, so a table may hold
+// many thousands of rows. The columns may be resized by
+// dragging the edges of their headers, and a row may be
+// selected by clicking on it.
+//
+type Table struct {
+	backing  Backing
+	value    values.Value
+	r        image.Rectangle
+	font     *truetype.Font
+	widths   []int
+	rows     [][]string
+	top      int // index of the first visible row.
+	selected int
+	header   []*Text
+	cells    [][]*Text // [visible row][column]
+	fg       image.Image
+	bg       image.Image
+	hilite   image.Image
+	headerBg image.Image
+	line     image.Image
+	bgCol    color.Color
+	pad      int // space to the left of each label.
+	theme    *Theme
+	pref     image.Point // the size the table was created with.
+}
+
+var _ HandlerItem = (*Table)(nil)
+var _ ResizableItem = (*Table)(nil)
+var _ Sizer = (*Table)(nil)
+
+// NewTable returns a new Table occupying r, with the given
+// column headers, initially of equal width, drawn in fg on bg.
+// The value, of type int, holds the index of the selected
+// row, or -1 if there is none.
+//
+func NewTable(r image.Rectangle, columns []string, font *truetype.Font, fg, bg color.Color, value values.Value) *Table {
+	obj := new(Table)
+	obj.backing = NullBacking()
+	obj.value = value
+	obj.r = r
+	obj.pref = r.Size()
+	obj.font = font
+	obj.selected = -1
+	obj.fg = &image.Uniform{fg}
+	obj.bg = &image.Uniform{bg}
+	obj.bgCol = bg
+	obj.widths = make([]int, len(columns))
+	for i, col := range columns {
+		obj.widths[i] = r.Dx() / len(columns)
+		obj.header = append(obj.header, obj.newLabel(col))
+	}
+	obj.cells = obj.makeCells(r)
+	obj.restyle()
+	go obj.listener()
+	return obj
+}
+
+func (obj *Table) newLabel(s string) *Text {
+	t := NewText(image.ZP, W, s, obj.font, 12, nil)
+	t.item.SetFill(obj.fg)
+	return t
+}
+
+// makeCells makes labels for as many rows
+// as fit below the header of a table of bounds r.
+// The labels are styled and placed by restyle.
+// It does not lock, so that the labels can be made
+// before the table's backing is locked.
+func (obj *Table) makeCells(r image.Rectangle) [][]*Text {
+	n := (r.Dy() - tableRowHeight) / tableRowHeight
+	if n < 0 {
+		n = 0
+	}
+	cells := make([][]*Text, n)
+	for i := range cells {
+		for _ = range obj.widths {
+			cells[i] = append(cells[i], obj.newLabel(""))
+		}
+	}
+	return cells
+}
+
+func (obj *Table) SetContainer(c Backing) {
+	obj.backing = c
+	obj.restyle()
+}
+
+// SetTheme sets the theme used to draw the table,
+// overriding that of its canvas. If t is nil, the
+// canvas's theme is used.
+//
+func (obj *Table) SetTheme(t *Theme) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.theme = t
+		obj.restyle()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Table) restyle() {
+	t := themeFor(obj.theme, obj.backing)
+	obj.hilite = &image.Uniform{shade(obj.bgCol, t.Pressed)}
+	// the header is shaded half as much as the selection.
+	obj.headerBg = &image.Uniform{shade(obj.bgCol, (1+t.Pressed)/2)}
+	obj.line = t.border()
+	obj.pad = t.Padding
+	for _, h := range obj.header {
+		t.styleLabel(h, obj.font)
+	}
+	for _, row := range obj.cells {
+		for _, label := range row {
+			t.styleLabel(label, obj.font)
+		}
+	}
+	obj.layout()
+}
+
+func (obj *Table) listener() {
+	g := obj.value.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		sel := x.(int)
+		obj.backing.Atomically(func(flush FlushFunc) {
+			if sel == obj.selected {
+				return
+			}
+			flush(obj.rowRect(obj.selected), nil)
+			obj.selected = sel
+			flush(obj.rowRect(sel), nil)
+		})
+		obj.backing.Flush()
+	}
+}
+
+// columnX returns the x coordinate of the left edge of column i.
+func (obj *Table) columnX(i int) int {
+	x := obj.r.Min.X
+	for _, w := range obj.widths[:i] {
+		x += w
+	}
+	return x
+}
+
+// rowRect returns the rectangle of the row with index i,
+// which is empty if the row is not visible.
+func (obj *Table) rowRect(i int) image.Rectangle {
+	n := i - obj.top
+	if i < 0 || n < 0 || n >= len(obj.cells) {
+		return image.ZR
+	}
+	y := obj.r.Min.Y + (n+1)*tableRowHeight
+	return image.Rect(obj.r.Min.X, y, obj.r.Max.X, y+tableRowHeight)
+}
+
+// layout sets the text and position of the header
+// and cell labels after a scroll, a column resize or
+// a change to the rows.
+func (obj *Table) layout() {
+	for j, h := range obj.header {
+		x := obj.columnX(j) + obj.pad
+		h.setPoint(image.Pt(x, obj.r.Min.Y+tableRowHeight/2))
+		for n, row := range obj.cells {
+			text := ""
+			if i := obj.top + n; i < len(obj.rows) && j < len(obj.rows[i]) {
+				text = obj.rows[i][j]
+			}
+			label := row[j]
+			if label.item.Text != text {
+				label.setText(text)
+			}
+			label.setPoint(image.Pt(x, obj.r.Min.Y+(n+1)*tableRowHeight+tableRowHeight/2))
+		}
+	}
+}
+
+// SetBounds moves and resizes the table to occupy r,
+// showing as many rows as fit. The columns are
+// scaled to keep their proportions.
+//
+func (obj *Table) SetBounds(r image.Rectangle) {
+	r = r.Canon()
+	cells := obj.makeCells(r)
+	obj.backing.Atomically(func(flush FlushFunc) {
+		old := obj.r
+		obj.r = r
+		if old.Dx() > 0 {
+			for i, w := range obj.widths {
+				w = w * r.Dx() / old.Dx()
+				if w < minColumnWidth {
+					w = minColumnWidth
+				}
+				obj.widths[i] = w
+			}
+		}
+		obj.cells = cells
+		if max := len(obj.rows) - len(obj.cells); obj.top > max {
+			obj.top = max
+		}
+		if obj.top < 0 {
+			obj.top = 0
+		}
+		obj.restyle()
+		flushBounds(flush, old, r)
+	})
+	obj.backing.Flush()
+}
+
+// MinSize returns the size needed to show the header
+// and one row with every column at its narrowest.
+//
+func (obj *Table) MinSize() image.Point {
+	return image.Pt(len(obj.widths)*minColumnWidth, 2*tableRowHeight)
+}
+
+func (obj *Table) PreferredSize() image.Point {
+	return maxPoint(obj.pref, obj.MinSize())
+}
+
+// SetRows replaces all the rows of the table.
+// Each row holds the text of its cells.
+// The selection is cleared and the table scrolled to the top.
+//
+func (obj *Table) SetRows(rows [][]string) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.rows = rows
+		obj.top = 0
+		obj.selected = -1
+		obj.layout()
+		flush(obj.r, nil)
+	})
+	obj.backing.Flush()
+	obj.value.Set(-1)
+}
+
+// SetRow changes the text of the row with index i.
+// Nothing is redrawn unless the row is visible.
+//
+func (obj *Table) SetRow(i int, row []string) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		if i < 0 || i >= len(obj.rows) {
+			return
+		}
+		obj.rows[i] = row
+		if r := obj.rowRect(i); !r.Empty() {
+			obj.layout()
+			flush(r, nil)
+		}
+	})
+	obj.backing.Flush()
+}
+
+// SetColumnWidth changes the width of column i.
+//
+func (obj *Table) SetColumnWidth(i, w int) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.setColumnWidth(i, w, flush)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Table) setColumnWidth(i, w int, flush FlushFunc) {
+	if w < minColumnWidth {
+		w = minColumnWidth
+	}
+	if i < 0 || i >= len(obj.widths) || w == obj.widths[i] {
+		return
+	}
+	obj.widths[i] = w
+	obj.layout()
+	// only the columns to the right of the
+	// left edge of the column can have changed.
+	r := obj.r
+	r.Min.X = obj.columnX(i)
+	flush(r, nil)
+}
+
+// ScrollTo scrolls the table so that the row with
+// index i is the first visible row, or as close
+// to it as possible.
+//
+func (obj *Table) ScrollTo(i int) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.scrollTo(i, flush)
+	})
+	obj.backing.Flush()
+}
+
+func (obj *Table) scrollTo(i int, flush FlushFunc) {
+	if max := len(obj.rows) - len(obj.cells); i > max {
+		i = max
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i == obj.top {
+		return
+	}
+	obj.top = i
+	obj.layout()
+	body := obj.r
+	body.Min.Y += tableRowHeight
+	flush(body, nil)
+}
+
+func (obj *Table) Draw(dst draw.Image, clipr image.Rectangle) {
+	clipr = clipr.Intersect(obj.r)
+	draw.Draw(dst, clipr, obj.bg, image.ZP, draw.Over)
+	hr := obj.r
+	hr.Max.Y = hr.Min.Y + tableRowHeight
+	draw.Draw(dst, hr.Intersect(clipr), obj.headerBg, image.ZP, draw.Over)
+	if r := obj.rowRect(obj.selected).Intersect(clipr); !r.Empty() {
+		draw.Draw(dst, r, obj.hilite, image.ZP, draw.Over)
+	}
+	x := obj.r.Min.X
+	for j, w := range obj.widths {
+		col := image.Rect(x, obj.r.Min.Y, x+w, obj.r.Max.Y).Intersect(clipr)
+		if !col.Empty() {
+			// each label is clipped to its own column.
+			obj.header[j].Draw(dst, col)
+			for n, row := range obj.cells {
+				if obj.top+n >= len(obj.rows) {
+					break
+				}
+				if cell := obj.rowRect(obj.top + n).Intersect(col); !cell.Empty() {
+					row[j].Draw(dst, cell)
+				}
+			}
+		}
+		x += w
+		draw.Draw(dst, image.Rect(x-1, obj.r.Min.Y, x, obj.r.Max.Y).Intersect(clipr), obj.line, image.ZP, draw.Over)
+	}
+	draw.Draw(dst, image.Rect(obj.r.Min.X, hr.Max.Y-1, obj.r.Max.X, hr.Max.Y).Intersect(clipr), obj.line, image.ZP, draw.Over)
+}
+
+func (obj *Table) Bbox() image.Rectangle {
+	return obj.r
+}
+
+func (obj *Table) HitTest(p image.Point) bool {
+	return p.In(obj.r)
+}
+
+func (obj *Table) Opaque() bool {
+	return opaqueImage(obj.bg)
+}
+
+// edgeAt returns the index of the column whose right
+// edge is at p in the header, or -1.
+func (obj *Table) edgeAt(p image.Point) int {
+	if p.Y >= obj.r.Min.Y+tableRowHeight {
+		return -1
+	}
+	x := obj.r.Min.X
+	for i, w := range obj.widths {
+		x += w
+		if p.X >= x-resizeSlop && p.X <= x+resizeSlop {
+			return i
+		}
+	}
+	return -1
+}
+
+// HandleMouse resizes a column when the edge of its
+// header is dragged with the first mouse button, and
+// selects the row under the pointer when the
+// button is pressed over it. The wheel scrolls
+// the table.
+//
+func (obj *Table) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	switch {
+	case m.Buttons&(WheelUp|WheelDown) != 0:
+		d := 3
+		if m.Buttons&WheelUp != 0 {
+			d = -3
+		}
+		obj.backing.Atomically(func(flush FlushFunc) {
+			obj.scrollTo(obj.top+d, flush)
+		})
+		obj.backing.Flush()
+		return true
+	case m.Buttons&1 == 0:
+		return false
+	}
+	var col, w0 int
+	sel := -1
+	obj.backing.Atomically(func(_ FlushFunc) {
+		col = obj.edgeAt(m.Loc)
+		if col >= 0 {
+			w0 = obj.widths[col]
+			return
+		}
+		for n := range obj.cells {
+			if i := obj.top + n; i < len(obj.rows) && m.Loc.In(obj.rowRect(i)) {
+				sel = i
+			}
+		}
+	})
+	if col < 0 {
+		if sel >= 0 {
+			obj.value.Set(sel)
+		}
+		waitRelease(m.Buttons, ec)
+		return true
+	}
+	x0 := m.Loc.X
+	Drag(m, ec, func(m ui.MouseEvent) {
+		obj.SetColumnWidth(col, w0+m.Loc.X-x0)
+	})
+	return true
+}
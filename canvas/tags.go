@@ -0,0 +1,147 @@
+package canvas
+
+import (
+	"image"
+)
+
+// AddTag attaches the string tag to it, which should be inside c,
+// so that it can be found and changed together with the other
+// items with the same tag. An item may have any number of tags.
+// Tags stay attached to an item while it is deleted, so that
+// it keeps them if it is added again.
+//
+func (c *Canvas) AddTag(it Item, tag string) {
+	c.Atomically(func(_ FlushFunc) {
+		if c.hasTag(it, tag) {
+			return
+		}
+		if c.tags == nil {
+			c.tags = make(map[Item][]string)
+		}
+		c.tags[it] = append(c.tags[it], tag)
+	})
+}
+
+// RemoveTag detaches tag from it.
+//
+func (c *Canvas) RemoveTag(it Item, tag string) {
+	c.Atomically(func(_ FlushFunc) {
+		tags := c.tags[it]
+		for i, t := range tags {
+			if t == tag {
+				tags = append(tags[:i], tags[i+1:]...)
+				break
+			}
+		}
+		if len(tags) == 0 {
+			delete(c.tags, it)
+		} else {
+			c.tags[it] = tags
+		}
+	})
+}
+
+// Tags returns the tags attached to it,
+// in the order they were added.
+//
+func (c *Canvas) Tags(it Item) (tags []string) {
+	c.Atomically(func(_ FlushFunc) {
+		tags = append(tags, c.tags[it]...)
+	})
+	return
+}
+
+// HasTag reports whether tag is attached to it.
+//
+func (c *Canvas) HasTag(it Item, tag string) (has bool) {
+	c.Atomically(func(_ FlushFunc) {
+		has = c.hasTag(it, tag)
+	})
+	return
+}
+
+func (c *Canvas) hasTag(it Item, tag string) bool {
+	for _, t := range c.tags[it] {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the items in c with the given tag, from
+// the bottom of the z-order to the top, starting with
+// any in the static layer.
+//
+func (c *Canvas) Find(tag string) (items []Item) {
+	c.Atomically(func(_ FlushFunc) {
+		items = c.find(tag)
+	})
+	return
+}
+
+func (c *Canvas) find(tag string) (items []Item) {
+	if len(c.tags) == 0 {
+		return nil
+	}
+	if c.static != nil {
+		for _, it := range c.static.items {
+			if c.hasTag(it, tag) {
+				items = append(items, it)
+			}
+		}
+	}
+	for e := c.items.Front(); e != nil; e = e.Next() {
+		if it := e.Value.(Item); c.hasTag(it, tag) {
+			items = append(items, it)
+		}
+	}
+	return
+}
+
+// MoveAll moves each item with the given tag by delta, if it
+// can be moved (see MoveItem), and returns the number moved.
+//
+func (c *Canvas) MoveAll(tag string, delta image.Point) int {
+	n := 0
+	for _, it := range c.Find(tag) {
+		if MoveItem(it, delta) {
+			n++
+		}
+	}
+	return n
+}
+
+// DeleteAll deletes each item with the given tag from c, along
+// with all its tags, and returns the number deleted.
+//
+func (c *Canvas) DeleteAll(tag string) (n int) {
+	c.Atomically(func(flush FlushFunc) {
+		for _, it := range c.find(tag) {
+			if c.deleteItem(it, flush) {
+				n++
+			}
+			delete(c.tags, it)
+		}
+	})
+	return
+}
+
+// RaiseAll moves the items with the given tag to the top of
+// the z-order, keeping them in the same order among themselves.
+//
+func (c *Canvas) RaiseAll(tag string) {
+	for _, it := range c.Find(tag) {
+		c.Raise(it)
+	}
+}
+
+// LowerAll moves the items with the given tag to the bottom of
+// the z-order, keeping them in the same order among themselves.
+//
+func (c *Canvas) LowerAll(tag string) {
+	items := c.Find(tag)
+	for i := len(items) - 1; i >= 0; i-- {
+		c.Lower(items[i])
+	}
+}
@@ -0,0 +1,172 @@
+package term
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// shrink returns img scaled down by scale, each pixel of the
+// result the average of a square of scale by scale pixels, or
+// fewer at the right and bottom edges. The result has its top
+// left at 0, 0.
+func shrink(img *image.RGBA, scale int) *image.RGBA {
+	r := img.Rect
+	if scale == 1 {
+		return &image.RGBA{Pix: img.Pix, Stride: img.Stride, Rect: r.Sub(r.Min)}
+	}
+	small := image.NewRGBA(image.Rect(0, 0, (r.Dx()+scale-1)/scale, (r.Dy()+scale-1)/scale))
+	for y := 0; y < small.Rect.Max.Y; y++ {
+		for x := 0; x < small.Rect.Max.X; x++ {
+			sr := image.Rect(x*scale, y*scale, x*scale+scale, y*scale+scale).Add(r.Min).Intersect(r)
+			var sum [4]int
+			for sy := sr.Min.Y; sy < sr.Max.Y; sy++ {
+				pix := img.Pix[img.PixOffset(sr.Min.X, sy):]
+				for i := 0; i < 4*sr.Dx(); i++ {
+					sum[i%4] += int(pix[i])
+				}
+			}
+			n := sr.Dx() * sr.Dy()
+			p := small.Pix[small.PixOffset(x, y):]
+			for i := range sum {
+				p[i] = uint8((sum[i] + n/2) / n)
+			}
+		}
+	}
+	return small
+}
+
+// writeHalfBlocks writes img as lines of half blocks, each
+// showing two pixels, one above the other, in 24-bit colour. If
+// move is true, each line starts by moving the cursor to the
+// character cell at, on the first line, for the top left of the
+// image, and the lines below it; otherwise the lines follow
+// on from the cursor, separated by newlines.
+func writeHalfBlocks(w *bufio.Writer, img *image.RGBA, at image.Point, move bool) {
+	r := img.Rect
+	for y := r.Min.Y; y < r.Max.Y; y += 2 {
+		if move {
+			// Cursor positions count from 1.
+			fmt.Fprintf(w, "\x1b[%d;%dH", at.Y+(y-r.Min.Y)/2+1, at.X+1)
+		}
+		// Only change the colours when they differ from
+		// those of the previous character.
+		fg, bg := color.RGBA{}, color.RGBA{}
+		first := true
+		for x := r.Min.X; x < r.Max.X; x++ {
+			top := img.RGBAAt(x, y)
+			bottom := top
+			if y+1 < r.Max.Y {
+				bottom = img.RGBAAt(x, y+1)
+			}
+			if first || top != fg {
+				fmt.Fprintf(w, "\x1b[38;2;%d;%d;%dm", top.R, top.G, top.B)
+				fg = top
+			}
+			if first || bottom != bg {
+				fmt.Fprintf(w, "\x1b[48;2;%d;%d;%dm", bottom.R, bottom.G, bottom.B)
+				bg = bottom
+			}
+			first = false
+			w.WriteString("▀")
+		}
+		w.WriteString("\x1b[0m")
+		if !move {
+			w.WriteString("\n")
+		}
+	}
+}
+
+// sixelLevels is the number of levels of each of red, green
+// and blue in the palette used for sixel graphics, which
+// must have no more than 256 colours.
+const sixelLevels = 6
+
+// writeSixel writes img as sixel graphics, with its colours
+// reduced to those of a palette of sixelLevels cubed colours.
+func writeSixel(w *bufio.Writer, img *image.RGBA) {
+	r := img.Rect
+	// Start the image, asking for square pixels, and
+	// giving its size, then define the palette, with its
+	// components given as percentages.
+	fmt.Fprintf(w, "\x1bP0;1q\"1;1;%d;%d", r.Dx(), r.Dy())
+	for i := 0; i < sixelLevels*sixelLevels*sixelLevels; i++ {
+		c := [3]int{i / (sixelLevels * sixelLevels), i / sixelLevels % sixelLevels, i % sixelLevels}
+		fmt.Fprintf(w, "#%d;2;%d;%d;%d", i, c[0]*100/(sixelLevels-1), c[1]*100/(sixelLevels-1), c[2]*100/(sixelLevels-1))
+	}
+	index := make([]int, r.Dx()*r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			index[(y-r.Min.Y)*r.Dx()+x-r.Min.X] = sixelColour(img.RGBAAt(x, y))
+		}
+	}
+	// Each band of six rows is written once for each colour
+	// in it, each character holding, in its lowest six bits,
+	// the pixels of one column in that colour, from the top.
+	bits := make([]byte, r.Dx())
+	used := make([]bool, sixelLevels*sixelLevels*sixelLevels)
+	for band := 0; band < r.Dy(); band += 6 {
+		for c := range used {
+			used[c] = false
+		}
+		for y := band; y < band+6 && y < r.Dy(); y++ {
+			for _, c := range index[y*r.Dx() : (y+1)*r.Dx()] {
+				used[c] = true
+			}
+		}
+		firstColour := true
+		for c, ok := range used {
+			if !ok {
+				continue
+			}
+			for x := range bits {
+				bits[x] = 0
+				for y := band; y < band+6 && y < r.Dy(); y++ {
+					if index[y*r.Dx()+x] == c {
+						bits[x] |= 1 << uint(y-band)
+					}
+				}
+			}
+			if !firstColour {
+				// Return to the start of the band.
+				w.WriteByte('$')
+			}
+			firstColour = false
+			fmt.Fprintf(w, "#%d", c)
+			writeSixelRun(w, bits)
+		}
+		w.WriteByte('-')
+	}
+	w.WriteString("\x1b\\")
+}
+
+// writeSixelRun writes the sixels bits, with any
+// character repeated more than three times written
+// as a count, followed by the character.
+func writeSixelRun(w *bufio.Writer, bits []byte) {
+	for i := 0; i < len(bits); {
+		n := 1
+		for i+n < len(bits) && bits[i+n] == bits[i] {
+			n++
+		}
+		ch := '?' + bits[i]
+		if n > 3 {
+			fmt.Fprintf(w, "!%d%c", n, ch)
+		} else {
+			for j := 0; j < n; j++ {
+				w.WriteByte(ch)
+			}
+		}
+		i += n
+	}
+}
+
+// sixelColour returns the index in the
+// sixel palette of the colour nearest to c.
+func sixelColour(c color.RGBA) int {
+	level := func(v uint8) int {
+		return (int(v)*(sixelLevels-1) + 127) / 255
+	}
+	return (level(c.R)*sixelLevels+level(c.G))*sixelLevels + level(c.B)
+}
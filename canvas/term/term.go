@@ -0,0 +1,282 @@
+// The term package provides a canvas backing that is shown in
+// a text terminal, so that canvas programs can be checked over
+// SSH, or anywhere else that there is no window system. It is
+// intended for debugging, not as a user interface: the image
+// is drawn with sixel graphics on terminals that support them,
+// and with Unicode half blocks, two pixels to each character,
+// on others, and no events are read from the terminal.
+//
+// For example:
+//
+//	t := term.NewTerminal(os.Stdout, image.Rect(0, 0, 640, 480), image.White, term.Auto, 4)
+//	defer t.Close()
+//	c := canvas.NewCanvas(nil, t.Rect())
+//	t.SetItem(c)
+//
+package term
+
+import (
+	"bufio"
+	"code.google.com/p/rog-go/canvas"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A Mode says how a Terminal draws its image.
+//
+type Mode int
+
+const (
+	// Auto uses Sixel if the terminal is
+	// thought to support it, or HalfBlocks if not.
+	Auto Mode = iota
+
+	// HalfBlocks draws each character as the Unicode
+	// upper half block, in the colour of the upper pixel,
+	// on a background in the colour of the lower pixel.
+	// It needs a terminal that supports 24-bit colour.
+	HalfBlocks
+
+	// Sixel draws the image as sixel graphics.
+	Sixel
+)
+
+// sixelTerms holds the prefixes of the values of $TERM
+// of terminals known to support sixel graphics.
+var sixelTerms = []string{
+	"contour",
+	"foot",
+	"mlterm",
+	"yaft",
+}
+
+// Detect returns the mode that Auto stands for, judging
+// by the environment, as the terminal cannot be asked
+// without reading from it.
+//
+func Detect() Mode {
+	t := os.Getenv("TERM")
+	if strings.Contains(t, "sixel") {
+		return Sixel
+	}
+	for _, s := range sixelTerms {
+		if strings.HasPrefix(t, s) {
+			return Sixel
+		}
+	}
+	if os.Getenv("TERM_PROGRAM") == "WezTerm" {
+		return Sixel
+	}
+	return HalfBlocks
+}
+
+// A Terminal is a canvas Backing whose image is drawn on a
+// terminal. It is a Background, so an item, usually a Canvas,
+// is placed in it with SetItem. The terminal is redrawn in its
+// own goroutine, so that a slow connection does not hold up the
+// program; changes made while it is being redrawn are drawn
+// together afterwards.
+//
+type Terminal struct {
+	*canvas.Background
+	img   *image.RGBA
+	w     *bufio.Writer
+	mode  Mode
+	scale int
+	ready chan bool // receives a value when damaged is non-empty.
+	quit  chan bool // closed by Close.
+	done  chan bool // closed when the drawing goroutine exits.
+
+	mu      sync.Mutex
+	damaged canvas.Damage
+	closed  bool
+	err     error // the first error writing to the terminal.
+}
+
+// NewTerminal returns a new Terminal with an image of bounds r,
+// with bg drawn behind its item, that is drawn by writing to w,
+// which is usually os.Stdout. The screen is cleared, and the image
+// drawn at its top left, each square of scale by scale pixels
+// shown as one pixel, their average, so that larger images fit
+// in the terminal; half blocks are one pixel wide, so most
+// terminals need a scale of 4 or more to show 640 pixels.
+//
+func NewTerminal(w io.Writer, r image.Rectangle, bg image.Image, mode Mode, scale int) *Terminal {
+	if mode == Auto {
+		mode = Detect()
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	t := &Terminal{
+		img:   image.NewRGBA(r),
+		w:     bufio.NewWriter(w),
+		mode:  mode,
+		scale: scale,
+		ready: make(chan bool, 1),
+		quit:  make(chan bool),
+		done:  make(chan bool),
+	}
+	t.Background = canvas.NewBackground(t.img, bg, t.damage)
+	// Clear the screen and hide the cursor.
+	t.w.WriteString("\x1b[2J\x1b[?25l")
+	t.damage(r)
+	go t.run()
+	return t
+}
+
+// Close stops drawing the terminal, once any changes already
+// flushed have been drawn, shows the cursor again below the image,
+// and returns the first error that there was writing to it.
+//
+func (t *Terminal) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return t.err
+	}
+	t.closed = true
+	close(t.quit)
+	t.mu.Unlock()
+	<-t.done
+
+	fmt.Fprintf(t.w, "\x1b[0m\x1b[%dH\x1b[?25h", t.rows()+1)
+	t.write(t.w.Flush())
+	return t.err
+}
+
+// damage records that r has changed, so that it is redrawn.
+func (t *Terminal) damage(r image.Rectangle) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.damaged.Add(r)
+	select {
+	case t.ready <- true:
+	default:
+	}
+}
+
+// run draws the areas of the image as they change,
+// until Close is called.
+func (t *Terminal) run() {
+	defer close(t.done)
+	for {
+		quit := false
+		select {
+		case <-t.ready:
+		case <-t.quit:
+			quit = true
+		}
+		t.mu.Lock()
+		damaged := t.damaged.Take()
+		t.mu.Unlock()
+		if len(damaged) > 0 {
+			t.draw(damaged)
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// draw redraws the areas rs of the image.
+func (t *Terminal) draw(rs []image.Rectangle) {
+	var r image.Rectangle
+	for _, dr := range rs {
+		r = r.Union(dr)
+	}
+	if t.mode == Sixel {
+		// A sixel image is drawn from the cursor, whose
+		// size in pixels is unknown, so it cannot be
+		// moved to part of the image: draw all of it.
+		r = t.img.Rect
+	}
+	// Round the area out to whole characters, so that
+	// every pixel averaged into them is copied.
+	cr := t.cells(r.Intersect(t.img.Rect))
+	if cr.Empty() {
+		return
+	}
+	r = image.Rect(cr.Min.X*t.scale, cr.Min.Y*t.scale*t.cellHeight(), cr.Max.X*t.scale, cr.Max.Y*t.scale*t.cellHeight())
+	r = r.Add(t.img.Rect.Min).Intersect(t.img.Rect)
+	// Copy the area, so that the image is only locked while
+	// copying, not while writing to the terminal.
+	img := image.NewRGBA(r)
+	t.Atomically(func(_ canvas.FlushFunc) {
+		draw.Draw(img, r, t.img, r.Min, draw.Src)
+	})
+	small := shrink(img, t.scale)
+	if t.mode == Sixel {
+		t.w.WriteString("\x1b[H")
+		writeSixel(t.w, small)
+	} else {
+		writeHalfBlocks(t.w, small, cr.Min, true)
+	}
+	t.write(t.w.Flush())
+}
+
+// cellHeight returns the number of pixels, after scaling,
+// drawn in each character cell of the terminal.
+func (t *Terminal) cellHeight() int {
+	if t.mode == Sixel {
+		return 1
+	}
+	return 2
+}
+
+// cells returns the character cells, with the top left
+// of the image at 0, 0, that cover the area r.
+func (t *Terminal) cells(r image.Rectangle) image.Rectangle {
+	r = r.Sub(t.img.Rect.Min)
+	w, h := t.scale, t.scale*t.cellHeight()
+	return image.Rect(r.Min.X/w, r.Min.Y/h, (r.Max.X+w-1)/w, (r.Max.Y+h-1)/h)
+}
+
+// rows returns the number of lines of the
+// terminal that the image is thought to cover.
+func (t *Terminal) rows() int {
+	if t.mode == Sixel {
+		// Assume that the characters are at least 6 pixels
+		// high, as they usually are; if they are taller, the
+		// cursor will be placed further below the image.
+		return (t.img.Rect.Dy()/t.scale + 5) / 6
+	}
+	return t.cells(t.img.Rect).Max.Y
+}
+
+func (t *Terminal) write(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+// WriteImage writes img to w once, at the cursor, scaled as
+// for NewTerminal, for a program that only needs to show
+// a single image, such as that returned by ImageBacking's
+// Snapshot method.
+//
+func WriteImage(w io.Writer, img image.Image, mode Mode, scale int) error {
+	if mode == Auto {
+		mode = Detect()
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Rect, img, rgba.Rect.Min, draw.Src)
+	bw := bufio.NewWriter(w)
+	small := shrink(rgba, scale)
+	if mode == Sixel {
+		writeSixel(bw, small)
+	} else {
+		writeHalfBlocks(bw, small, image.ZP, false)
+	}
+	return bw.Flush()
+}
@@ -142,8 +142,7 @@ func (t *Text) SetPoint(p0 image.Point) {
 		r := t.item.Bbox()
 		t.p = p0
 		t.recalc(false)
-		flush(r, nil)
-		flush(t.item.Bbox(), nil)
+		flush.Rects(nil, r, t.item.Bbox())
 	})
 }
 
@@ -175,18 +174,32 @@ func (t *Text) SetText(s string) {
 		r := t.item.Bbox()
 		t.item.Text = s
 		t.recalc(true)
-		flush(r, nil)
-		flush(t.item.Bbox(), nil)
+		flush.Rects(nil, r, t.item.Bbox())
 	})
 }
 
+// setText changes the text without locking or flushing.
+// It is for widgets that hold the text in an inner canvas,
+// and change it from within their own Atomically, where
+// calling SetText could try to take the same lock twice.
+func (t *Text) setText(s string) {
+	t.item.Text = s
+	t.recalc(true)
+}
+
+// setPoint is like SetPoint, but without locking
+// or flushing, as for setText.
+func (t *Text) setPoint(p0 image.Point) {
+	t.p = p0
+	t.recalc(false)
+}
+
 func (t *Text) SetFontSize(size float64) {
 	t.backing.Atomically(func(flush FlushFunc) {
 		r := t.item.Bbox()
 		t.item.SetFontSize(size)
 		t.recalc(true)
-		flush(r, nil)
-		flush(t.item.Bbox(), nil)
+		flush.Rects(nil, r, t.item.Bbox())
 	})
 }
 
@@ -0,0 +1,201 @@
+package canvas
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// An Affine represents an affine transformation.
+// A point (x, y) is transformed to
+//	(m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5]).
+//
+type Affine [6]float64
+
+// Identity is the identity transformation.
+var Identity = Affine{1, 0, 0, 0, 1, 0}
+
+// TriangleAffine returns the transformation that maps
+// the three points in from to the corresponding
+// points in to. It returns false if the points in from
+// are collinear.
+//
+func TriangleAffine(from, to [3]image.Point) (Affine, bool) {
+	// find the transformation from the unit triangle to each
+	// triangle, then combine the inverse of one with the other.
+	f, ok := unitTriangleAffine(from).Invert()
+	if !ok {
+		return Affine{}, false
+	}
+	return unitTriangleAffine(to).Mul(f), true
+}
+
+func unitTriangleAffine(p [3]image.Point) Affine {
+	x0, y0 := float64(p[0].X), float64(p[0].Y)
+	return Affine{
+		float64(p[1].X) - x0, float64(p[2].X) - x0, x0,
+		float64(p[1].Y) - y0, float64(p[2].Y) - y0, y0,
+	}
+}
+
+// Mul returns the transformation that applies n and then m.
+//
+func (m Affine) Mul(n Affine) Affine {
+	return Affine{
+		m[0]*n[0] + m[1]*n[3], m[0]*n[1] + m[1]*n[4], m[0]*n[2] + m[1]*n[5] + m[2],
+		m[3]*n[0] + m[4]*n[3], m[3]*n[1] + m[4]*n[4], m[3]*n[2] + m[4]*n[5] + m[5],
+	}
+}
+
+// Invert returns the inverse of m. It returns false
+// if m has no inverse.
+//
+func (m Affine) Invert() (Affine, bool) {
+	det := m[0]*m[4] - m[1]*m[3]
+	if det == 0 {
+		return Affine{}, false
+	}
+	return Affine{
+		m[4] / det, -m[1] / det, (m[1]*m[5] - m[4]*m[2]) / det,
+		-m[3] / det, m[0] / det, (m[3]*m[2] - m[0]*m[5]) / det,
+	}, true
+}
+
+// Transform returns the result of applying m to (x, y).
+//
+func (m Affine) Transform(x, y float64) (float64, float64) {
+	return m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5]
+}
+
+// Translation returns the transformation
+// that moves everything by (dx, dy).
+//
+func Translation(dx, dy float64) Affine {
+	return Affine{1, 0, dx, 0, 1, dy}
+}
+
+// Scaling returns the transformation that scales
+// everything by sx horizontally and sy vertically,
+// leaving the point about where it is.
+//
+func Scaling(sx, sy float64, about image.Point) Affine {
+	x, y := float64(about.X), float64(about.Y)
+	return Affine{sx, 0, x - sx*x, 0, sy, y - sy*y}
+}
+
+// Rotation returns the transformation that rotates
+// everything by angle radians around the point about.
+// As y increases downwards, a positive angle
+// rotates clockwise on the screen.
+//
+func Rotation(angle float64, about image.Point) Affine {
+	sin, cos := math.Sincos(angle)
+	x, y := float64(about.X), float64(about.Y)
+	return Affine{
+		cos, -sin, x - cos*x + sin*y,
+		sin, cos, y - sin*x - cos*y,
+	}
+}
+
+// A TexturedPolygon is a Polygon filled with an image
+// that has been mapped onto the canvas with an
+// affine transformation.
+//
+type TexturedPolygon struct {
+	*Polygon
+	tex affineImage
+}
+
+// NewTexturedPolygon returns a new polygon with the given vertices,
+// filled with tex transformed by m from its own coordinate
+// space to the canvas.
+//
+func NewTexturedPolygon(tex image.Image, m Affine, points []image.Point) *TexturedPolygon {
+	obj := new(TexturedPolygon)
+	obj.tex.src = tex
+	obj.tex.setTransform(m)
+	obj.Polygon = NewPolygon(&obj.tex, points)
+	return obj
+}
+
+// SetTransform changes the transformation used to
+// map the texture onto the canvas.
+//
+func (obj *TexturedPolygon) SetTransform(m Affine) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.tex.setTransform(m)
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+// SetTexture changes the image used to fill the polygon.
+//
+func (obj *TexturedPolygon) SetTexture(tex image.Image) {
+	obj.backing.Atomically(func(flush FlushFunc) {
+		obj.tex.src = tex
+		flush(obj.raster.Bbox(), nil)
+	})
+}
+
+// An affineImage is an infinite image that displays src
+// transformed by an affine transformation. Points
+// that map outside the bounds of src are transparent.
+// It uses bilinear interpolation.
+type affineImage struct {
+	src image.Image
+	inv Affine // transformation from destination to src.
+}
+
+func (img *affineImage) setTransform(m Affine) {
+	var ok bool
+	if img.inv, ok = m.Invert(); !ok {
+		// a degenerate transformation has nothing visible.
+		img.inv = Affine{0, 0, -1e9, 0, 0, -1e9}
+	}
+}
+
+func (img *affineImage) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+func (img *affineImage) Bounds() image.Rectangle {
+	return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+func (img *affineImage) At(x, y int) color.Color {
+	sx, sy := img.inv.Transform(float64(x)+0.5, float64(y)+0.5)
+	// sample positions are at pixel centres.
+	sx -= 0.5
+	sy -= 0.5
+	x0, y0 := floor(sx), floor(sy)
+	fx, fy := sx-float64(x0), sy-float64(y0)
+	var acc [4]float64
+	add := func(x, y int, w float64) {
+		if w == 0 || !image.Pt(x, y).In(img.src.Bounds()) {
+			return
+		}
+		r, g, b, a := img.src.At(x, y).RGBA()
+		acc[0] += float64(r) * w
+		acc[1] += float64(g) * w
+		acc[2] += float64(b) * w
+		acc[3] += float64(a) * w
+	}
+	add(x0, y0, (1-fx)*(1-fy))
+	add(x0+1, y0, fx*(1-fy))
+	add(x0, y0+1, (1-fx)*fy)
+	add(x0+1, y0+1, fx*fy)
+	return color.RGBA64{
+		uint16(acc[0] + 0.5),
+		uint16(acc[1] + 0.5),
+		uint16(acc[2] + 0.5),
+		uint16(acc[3] + 0.5),
+	}
+}
+
+func floor(x float64) int {
+	i := int(x)
+	if float64(i) > x {
+		i--
+	}
+	return i
+}
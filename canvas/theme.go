@@ -0,0 +1,152 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"image"
+	"image/color"
+)
+
+// A Theme holds the attributes, other than their
+// foreground and background colours, used to draw widgets.
+// A theme may be set on a canvas, in which case it is
+// used by all the widgets inside it (including those in
+// nested canvases) that do not have a theme of their own.
+// A widget looks for its theme when it is added to
+// a canvas, and when SetTheme is called on it.
+// Fields left as zero take their values from DefaultTheme,
+// so a theme need only set those that differ from it.
+//
+type Theme struct {
+	Border      color.Color // colour of widget borders.
+	BorderWidth int
+	Focus       color.Color    // colour of the ring around the item with the keyboard focus.
+	Font        *truetype.Font // used for widgets created with a nil font.
+	FontSize    float64
+	Padding     int     // space between the edge of a widget and its label.
+	ButtonSize  int     // length of a slider's button.
+	Hover       float64 // background brightness of a widget under the pointer.
+	Pressed     float64 // background brightness of a pressed or selected widget.
+}
+
+// DefaultTheme is the theme used by widgets when
+// no other theme has been set.
+//
+var DefaultTheme = &Theme{
+	Border:      color.Black,
+	BorderWidth: 1,
+	Focus:       color.RGBA{0x40, 0x80, 0xff, 0xff},
+	FontSize:    12,
+	Padding:     4,
+	ButtonSize:  6,
+	Hover:       1.1,
+	Pressed:     0.8,
+}
+
+// SetTheme sets the theme used by widgets inside c.
+// If t is nil, the theme of the canvas containing
+// c is used. Widgets already inside c are restyled.
+//
+func (c *Canvas) SetTheme(t *Theme) {
+	c.Atomically(func(flush FlushFunc) {
+		c.theme = t
+		// the widgets look for their theme in SetContainer.
+		for e := c.items.Front(); e != nil; e = e.Next() {
+			e.Value.(Item).SetContainer(c)
+		}
+		if c.static != nil {
+			c.static.setContainer()
+		}
+		flush(c.r, nil)
+	})
+	c.Flush()
+}
+
+// nestedBacking is implemented by items that act as
+// the backing for the items inside them, so that the
+// theme of the canvas outside can be found.
+type nestedBacking interface {
+	outer() Backing
+}
+
+// ThemeOf returns the theme to be used by a widget inside b.
+// It is the theme of the innermost canvas containing
+// b that has one, looking through containers such as
+// Split and BoxLayout, or DefaultTheme if there is none.
+//
+func ThemeOf(b Backing) *Theme {
+	for {
+		switch c := b.(type) {
+		case *Canvas:
+			if c.theme != nil {
+				return c.theme.complete()
+			}
+			b = c.backing
+		case nestedBacking:
+			b = c.outer()
+		default:
+			return DefaultTheme
+		}
+	}
+}
+
+// themeFor returns t if it is non-nil, otherwise
+// the theme for a widget inside b.
+func themeFor(t *Theme, b Backing) *Theme {
+	if t != nil {
+		return t.complete()
+	}
+	return ThemeOf(b)
+}
+
+// complete returns t, or if any of its fields are zero,
+// a copy of t with those of DefaultTheme in their place.
+func (t *Theme) complete() *Theme {
+	d := DefaultTheme
+	if t == d {
+		return t
+	}
+	c := *t
+	zero := false
+	fill := func(isZero bool, set func()) {
+		if isZero {
+			set()
+			zero = true
+		}
+	}
+	fill(c.Border == nil, func() { c.Border = d.Border })
+	fill(c.BorderWidth == 0, func() { c.BorderWidth = d.BorderWidth })
+	fill(c.Focus == nil, func() { c.Focus = d.Focus })
+	fill(c.Font == nil, func() { c.Font = d.Font })
+	fill(c.FontSize == 0, func() { c.FontSize = d.FontSize })
+	fill(c.Padding == 0, func() { c.Padding = d.Padding })
+	fill(c.ButtonSize == 0, func() { c.ButtonSize = d.ButtonSize })
+	fill(c.Hover == 0, func() { c.Hover = d.Hover })
+	fill(c.Pressed == 0, func() { c.Pressed = d.Pressed })
+	if !zero {
+		return t
+	}
+	return &c
+}
+
+// border returns the image used to draw borders.
+func (t *Theme) border() image.Image {
+	return &image.Uniform{t.Border}
+}
+
+// font returns f, or the theme's font if f is nil.
+func (t *Theme) font(f *truetype.Font) *truetype.Font {
+	if f == nil {
+		return t.Font
+	}
+	return f
+}
+
+// styleLabel sets the font of label to f, or the theme's
+// font if f is nil, and its size to the theme's font size.
+// The label is changed directly, as it is called
+// from within Atomically by the widget holding the label.
+func (t *Theme) styleLabel(label *Text, f *truetype.Font) {
+	label.item.SetFont(t.font(f))
+	label.item.SetFontSize(t.FontSize)
+	label.recalc(true)
+}
@@ -0,0 +1,135 @@
+package canvas
+
+import (
+	"math"
+	"time"
+)
+
+// A Timeline sequences tweens, each starting at its own offset
+// from the start of the timeline, so that they may follow
+// one another or overlap, to make a transition with several
+// steps, such as sliding an item in, pausing, and fading it out.
+// A Timeline is itself run as a tween (see Tween and Play).
+//
+type Timeline struct {
+	entries []timelineEntry
+	cursor  time.Duration // where Then starts the next tween.
+	repeat  int           // the number of extra times to run; negative for ever.
+}
+
+type timelineEntry struct {
+	at   time.Duration
+	t    Tween
+	done func()
+}
+
+// NewTimeline returns a new, empty Timeline.
+//
+func NewTimeline() *Timeline {
+	return &Timeline{}
+}
+
+// Add adds t to the timeline, starting at the offset at. If done
+// is not nil, it is called when t finishes, each time it does.
+//
+func (tl *Timeline) Add(at time.Duration, t Tween, done func()) {
+	if t.Ease == nil {
+		t.Ease = Linear
+	}
+	tl.entries = append(tl.entries, timelineEntry{at, t, done})
+	if end := at + t.Duration; end > tl.cursor {
+		tl.cursor = end
+	}
+}
+
+// Then adds t to start when all the tweens added so far,
+// and any pause, have finished.
+//
+func (tl *Timeline) Then(t Tween, done func()) {
+	tl.Add(tl.cursor, t, done)
+}
+
+// Pause delays the start of the next tween added with Then by d.
+//
+func (tl *Timeline) Pause(d time.Duration) {
+	tl.cursor += d
+}
+
+// SetRepeat sets the number of times the timeline is run again
+// after the first; if n is negative, it repeats until stopped.
+// Each tween starts again from the beginning of its change.
+//
+func (tl *Timeline) SetRepeat(n int) {
+	tl.repeat = n
+}
+
+// Duration returns the length of one run of the timeline.
+//
+func (tl *Timeline) Duration() time.Duration {
+	return tl.cursor
+}
+
+// Tween returns a tween that runs the timeline. The tweens
+// in the timeline must not be changed while it is running.
+//
+func (tl *Timeline) Tween() Tween {
+	entries := append([]timelineEntry(nil), tl.entries...)
+	total := tl.cursor
+	d := time.Duration(math.MaxInt64)
+	if tl.repeat >= 0 {
+		d = total * time.Duration(tl.repeat+1)
+	}
+	finished := make([]bool, len(entries))
+	cycle := 0
+	// finish makes the final step of every tween
+	// in the current cycle that has not already had it.
+	finish := func() {
+		for i, e := range entries {
+			if !finished[i] {
+				finished[i] = true
+				e.t.Step(1)
+				if e.done != nil {
+					e.done()
+				}
+			}
+		}
+	}
+	return Tween{d, Linear, func(v float64) {
+		if total <= 0 {
+			finish()
+			return
+		}
+		elapsed := time.Duration(v * float64(d))
+		if v >= 1 {
+			finish()
+			return
+		}
+		if k := int(elapsed / total); k != cycle {
+			finish()
+			cycle = k
+			for i := range finished {
+				finished[i] = false
+			}
+		}
+		local := elapsed % total
+		for i, e := range entries {
+			switch {
+			case finished[i] || local < e.at:
+			case local >= e.at+e.t.Duration:
+				finished[i] = true
+				e.t.Step(1)
+				if e.done != nil {
+					e.done()
+				}
+			default:
+				e.t.Step(e.t.Ease(float64(local-e.at) / float64(e.t.Duration)))
+			}
+		}
+	}}
+}
+
+// Play starts running tl, as for Start.
+//
+func (a *Animator) Play(tl *Timeline, done func()) *Animation {
+	return a.Start(tl.Tween(), done)
+}
@@ -0,0 +1,167 @@
+package canvas
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// A Timer is a callback scheduled with After or Every.
+//
+type Timer struct {
+	s      *scheduler
+	c      *Canvas
+	f      func(flush FlushFunc)
+	when   time.Time
+	period time.Duration // zero for a timer that runs once.
+	index  int           // index in the heap, or -1.
+}
+
+// After arranges for f to be called once, from within
+// c.Atomically, after at least d has elapsed. The canvas
+// is flushed after f returns.
+//
+// The callbacks for all the timers of a canvas are called
+// in turn from a single goroutine, started when needed,
+// so they should be quick; the changes they make are
+// synchronised with all other changes to the canvas,
+// making them suitable for animations and blinking cursors.
+//
+func (c *Canvas) After(d time.Duration, f func(flush FlushFunc)) *Timer {
+	return c.sched.add(&Timer{c: c, f: f, when: time.Now().Add(d)})
+}
+
+// Every arranges for f to be called, as for After, every d
+// until the timer is stopped. If the calls fall behind,
+// for instance because f is slow, intervening calls
+// are skipped rather than made late. It panics
+// if d is not positive.
+//
+func (c *Canvas) Every(d time.Duration, f func(flush FlushFunc)) *Timer {
+	if d <= 0 {
+		panic("non-positive interval for Every")
+	}
+	return c.sched.add(&Timer{c: c, f: f, when: time.Now().Add(d), period: d})
+}
+
+// Stop stops the timer, so that f will not be called
+// again. It reports whether a call was prevented.
+// If f is running when Stop is called, it is allowed
+// to finish.
+//
+func (t *Timer) Stop() bool {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	if t.index < 0 {
+		// Not waiting: either finished, stopped
+		// already, or running now.
+		stopped := t.period > 0
+		t.period = 0
+		return stopped
+	}
+	t.period = 0
+	heap.Remove(&t.s.timers, t.index)
+	return true
+}
+
+// scheduler runs the timers of a canvas.
+// Its zero value is ready to use.
+type scheduler struct {
+	mu      sync.Mutex
+	timers  timerHeap
+	running bool
+	wake    chan bool // signalled when the earliest timer changes.
+}
+
+func (s *scheduler) add(t *Timer) *Timer {
+	t.s = s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	heap.Push(&s.timers, t)
+	if s.wake == nil {
+		s.wake = make(chan bool, 1)
+	}
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+	select {
+	case s.wake <- true:
+	default:
+	}
+	return t
+}
+
+// run calls the timers as they fall due, and
+// returns when there are none left.
+func (s *scheduler) run() {
+	for {
+		s.mu.Lock()
+		if len(s.timers) == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		t := s.timers[0]
+		d := t.when.Sub(time.Now())
+		if d > 0 {
+			s.mu.Unlock()
+			wait := time.NewTimer(d)
+			select {
+			case <-wait.C:
+			case <-s.wake:
+			}
+			wait.Stop()
+			continue
+		}
+		heap.Pop(&s.timers)
+		s.mu.Unlock()
+
+		t.c.Atomically(t.f)
+		t.c.Flush()
+
+		s.mu.Lock()
+		// Stop clears the period of a running timer.
+		if t.period > 0 {
+			now := time.Now()
+			t.when = t.when.Add(t.period)
+			if t.when.Before(now) {
+				t.when = now.Add(t.period)
+			}
+			heap.Push(&s.timers, t)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// timerHeap implements heap.Interface, with
+// the earliest timer first.
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int {
+	return len(h)
+}
+
+func (h timerHeap) Less(i, j int) bool {
+	return h[i].when.Before(h[j].when)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*Timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
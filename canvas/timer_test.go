@@ -0,0 +1,77 @@
+package canvas
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+func newTimerCanvas() *Canvas {
+	c, _ := NewImageCanvas(image.Rect(0, 0, 10, 10), image.White)
+	return c
+}
+
+func TestTimerStopTwice(t *testing.T) {
+	c := newTimerCanvas()
+	for _, tm := range []*Timer{
+		c.After(time.Hour, func(FlushFunc) {}),
+		c.Every(time.Hour, func(FlushFunc) {}),
+	} {
+		if !tm.Stop() {
+			t.Errorf("first Stop of waiting timer returned false")
+		}
+		if tm.Stop() {
+			t.Errorf("second Stop returned true")
+		}
+	}
+}
+
+func TestTimerStopRunning(t *testing.T) {
+	c := newTimerCanvas()
+	running := make(chan bool)
+	release := make(chan bool)
+	calls := 0
+	tm := c.Every(time.Millisecond, func(FlushFunc) {
+		calls++
+		if calls == 1 {
+			running <- true
+			<-release
+		}
+	})
+	<-running
+	if !tm.Stop() {
+		t.Errorf("Stop of running periodic timer returned false")
+	}
+	if tm.Stop() {
+		t.Errorf("second Stop of running timer returned true")
+	}
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+	c.Atomically(func(FlushFunc) {
+		if calls != 1 {
+			t.Errorf("stopped timer called %d times; want 1", calls)
+		}
+	})
+}
+
+func TestTimerEvery(t *testing.T) {
+	c := newTimerCanvas()
+	ticks := make(chan bool, 10)
+	tm := c.Every(time.Millisecond, func(FlushFunc) {
+		select {
+		case ticks <- true:
+		default:
+		}
+	})
+	defer tm.Stop()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ticks:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timer called %d times; want 3", i)
+		}
+	}
+	if !tm.Stop() {
+		t.Errorf("Stop of rescheduled timer returned false")
+	}
+}
@@ -0,0 +1,138 @@
+package canvas
+
+import (
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/color"
+	"time"
+)
+
+// TooltipDelay is how long the pointer must rest over
+// an item before its tooltip is shown.
+//
+var TooltipDelay = 700 * time.Millisecond
+
+// tooltipColor is the background colour of tooltips
+// made by NewTooltip.
+var tooltipColor = color.RGBA{0xff, 0xff, 0xe0, 0xff}
+
+// tipState holds the state of the tooltip shown on a top canvas.
+type tipState struct {
+	timer *time.Timer // pending display of the tooltip.
+	shown Item        // the tooltip currently shown, if any.
+	gen   int         // incremented each time the pointer moves.
+}
+
+// A tooltip is shown on the top canvas; it is never
+// hit by the mouse, so it does not get in the way of
+// events destined for the items underneath.
+type tooltip struct {
+	MoveableItem
+}
+
+func (t *tooltip) HitTest(p image.Point) bool {
+	return false
+}
+
+// NewTooltip returns an item suitable for use as a
+// tooltip, showing s in a small box.
+//
+func NewTooltip(s string, font *truetype.Font) Item {
+	label := NewText(image.ZP, N|W, s, font, 10, nil)
+	r := label.Bbox().Inset(-3)
+	c := NewCanvas(nil, r)
+	c.AddItem(NewRect(r, &image.Uniform{tooltipColor}, 1, image.Black))
+	c.AddItem(label)
+	return c
+}
+
+// SetTooltip arranges for tip to be shown near the
+// pointer when it rests over it for TooltipDelay.
+// The tooltip is hidden when the pointer moves
+// or a button is pressed. If tip is nil, any tooltip
+// for it is removed.
+//
+// The pointer is tracked with the mouse events passed to
+// HandleMouse, so the tooltip will only appear if
+// events are delivered when no buttons are pressed.
+// While an item is handling mouse events itself,
+// the canvas does not see them, so a tooltip shown
+// over such an item stays until the item returns.
+//
+func (c *Canvas) SetTooltip(it, tip Item) {
+	c.Atomically(func(_ FlushFunc) {
+		if tip == nil {
+			delete(c.tips, it)
+			return
+		}
+		if c.tips == nil {
+			c.tips = make(map[Item]Item)
+		}
+		c.tips[it] = tip
+	})
+}
+
+// trackTip hides any tooltip that is shown and,
+// if m is a movement over an item with a tooltip,
+// starts the timer to show it.
+func (c *Canvas) trackTip(m ui.MouseEvent) {
+	// the state of the tooltip is kept in the top canvas,
+	// which sees all mouse events before any nested
+	// canvas, so that the innermost item's tooltip is
+	// the one shown, and the tooltip is hidden even
+	// when the pointer has moved outside c.
+	top := TopCanvas(c)
+	top.Atomically(func(flush FlushFunc) {
+		if len(c.tips) == 0 && top.tip.shown == nil && top.tip.timer == nil {
+			return
+		}
+		top.tip.gen++
+		if top.tip.timer != nil {
+			top.tip.timer.Stop()
+			top.tip.timer = nil
+		}
+		if top.tip.shown != nil {
+			top.deleteItem(top.tip.shown, flush)
+			top.tip.shown = nil
+		}
+		if m.Buttons != 0 {
+			return
+		}
+		tip := c.tips[c.hit(m.Loc)]
+		if tip == nil {
+			return
+		}
+		gen := top.tip.gen
+		top.tip.timer = time.AfterFunc(TooltipDelay, func() {
+			c.showTip(top, gen, tip, m.Loc)
+		})
+	})
+}
+
+// showTip shows tip near p, as long as the
+// pointer has not moved since the timer was started.
+func (c *Canvas) showTip(top *Canvas, gen int, tip Item, p image.Point) {
+	t := &tooltip{Moveable(tip)}
+	// place the tooltip below and to the right of the pointer,
+	// keeping it inside the top canvas.
+	r := tip.Bbox()
+	r = r.Add(p.Add(image.Pt(8, 16)).Sub(r.Min))
+	tr := top.Rect()
+	if d := r.Max.X - tr.Max.X; d > 0 {
+		r = r.Sub(image.Pt(d, 0))
+	}
+	if d := r.Max.Y - tr.Max.Y; d > 0 {
+		r = r.Sub(image.Pt(0, r.Dy()+24))
+	}
+	t.SetCentre(centre(r))
+	top.Atomically(func(flush FlushFunc) {
+		if top.tip.gen != gen {
+			return
+		}
+		top.tip.timer = nil
+		top.tip.shown = t
+		top.addItem(t, flush)
+	})
+	top.Flush()
+}
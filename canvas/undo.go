@@ -0,0 +1,276 @@
+package canvas
+
+import (
+	"image"
+	"sync"
+)
+
+// An UndoStack records changes made to the items of a canvas,
+// so that an editor can undo them and redo them again.
+// Each change is made by calling Do with a function that makes
+// it through the methods of the Edit it is given, each of which
+// remembers how to reverse what it did.
+//
+type UndoStack struct {
+	c      *Canvas
+	mu     sync.Mutex
+	done   []*Edit
+	undone []*Edit
+	limit  int // the most edits kept; zero for no limit.
+}
+
+// An Edit is a group of changes made together,
+// to be undone and redone as one.
+//
+type Edit struct {
+	name string
+	c    *Canvas
+	ops  []undoOp
+}
+
+// An undoOp is one change that has been made,
+// and the functions that reverse and repeat it.
+type undoOp struct {
+	undo, redo func()
+}
+
+// NewUndoStack returns a new UndoStack for changes
+// to the items in c, keeping at most limit edits,
+// or any number if limit is zero.
+//
+func NewUndoStack(c *Canvas, limit int) *UndoStack {
+	return &UndoStack{c: c, limit: limit}
+}
+
+// Do calls f to make a change, named by name, and records
+// it for undoing. Any edits undone and not yet redone are
+// discarded. The canvas is flushed when f returns.
+// Do must not be called from within Atomically, as each
+// change made by f locks the canvas itself.
+//
+func (u *UndoStack) Do(name string, f func(e *Edit)) {
+	e := &Edit{name: name, c: u.c}
+	f(e)
+	u.c.Flush()
+	if len(e.ops) == 0 {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.done = append(u.done, e)
+	if u.limit > 0 && len(u.done) > u.limit {
+		u.done = append(u.done[:0], u.done[len(u.done)-u.limit:]...)
+	}
+	u.undone = nil
+}
+
+// Undo reverses the most recent edit not already undone,
+// and reports whether there was one.
+//
+func (u *UndoStack) Undo() bool {
+	u.mu.Lock()
+	if len(u.done) == 0 {
+		u.mu.Unlock()
+		return false
+	}
+	e := u.done[len(u.done)-1]
+	u.done = u.done[:len(u.done)-1]
+	u.undone = append(u.undone, e)
+	u.mu.Unlock()
+	for i := len(e.ops) - 1; i >= 0; i-- {
+		e.ops[i].undo()
+	}
+	u.c.Flush()
+	return true
+}
+
+// Redo makes again the edit most recently undone,
+// and reports whether there was one.
+//
+func (u *UndoStack) Redo() bool {
+	u.mu.Lock()
+	if len(u.undone) == 0 {
+		u.mu.Unlock()
+		return false
+	}
+	e := u.undone[len(u.undone)-1]
+	u.undone = u.undone[:len(u.undone)-1]
+	u.done = append(u.done, e)
+	u.mu.Unlock()
+	for _, op := range e.ops {
+		op.redo()
+	}
+	u.c.Flush()
+	return true
+}
+
+// UndoName returns the name of the edit that Undo would
+// reverse, for showing in a menu, or false if there is none.
+//
+func (u *UndoStack) UndoName() (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.done) == 0 {
+		return "", false
+	}
+	return u.done[len(u.done)-1].name, true
+}
+
+// RedoName returns the name of the edit that Redo
+// would make again, or false if there is none.
+//
+func (u *UndoStack) RedoName() (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.undone) == 0 {
+		return "", false
+	}
+	return u.undone[len(u.undone)-1].name, true
+}
+
+// Clear discards all the edits recorded.
+//
+func (u *UndoStack) Clear() {
+	u.mu.Lock()
+	u.done, u.undone = nil, nil
+	u.mu.Unlock()
+}
+
+// Record makes a change that cannot be made with the other
+// methods of Edit, by calling redo, and records undo as the
+// way to reverse it.
+//
+func (e *Edit) Record(undo, redo func()) {
+	redo()
+	e.ops = append(e.ops, undoOp{undo, redo})
+}
+
+// Add adds it to the top of the canvas.
+//
+func (e *Edit) Add(it Item) {
+	c := e.c
+	e.Record(func() { c.Delete(it) }, func() { c.AddItem(it) })
+}
+
+// Delete deletes it from the canvas. If the deletion is
+// undone, it is put back where it was in the z-order,
+// as long as the item that was above it is still there.
+//
+func (e *Edit) Delete(it Item) {
+	c := e.c
+	var above Item
+	c.Atomically(func(_ FlushFunc) {
+		for el := c.items.Front(); el != nil; el = el.Next() {
+			if el.Value.(Item) == it {
+				if next := el.Next(); next != nil {
+					above = next.Value.(Item)
+				}
+				break
+			}
+		}
+	})
+	e.Record(func() {
+		c.AddItem(it)
+		if above != nil && c.contains(above) {
+			c.LowerBelow(it, above)
+		}
+	}, func() {
+		c.Delete(it)
+	})
+}
+
+// Move moves it by delta, if it can be moved (see MoveItem).
+//
+func (e *Edit) Move(it Item, delta image.Point) {
+	if _, ok := it.(Mover); !ok {
+		switch it.(type) {
+		case MoveableItem, Resizer:
+		default:
+			return
+		}
+	}
+	e.Record(func() {
+		MoveItem(it, image.ZP.Sub(delta))
+	}, func() {
+		MoveItem(it, delta)
+	})
+}
+
+// SetBounds gives it the bounding rectangle r.
+//
+func (e *Edit) SetBounds(it ResizableItem, r image.Rectangle) {
+	old := e.bbox(it)
+	e.Record(func() { it.SetBounds(old) }, func() { it.SetBounds(r) })
+}
+
+// Transform applies m to the geometry of it. The change
+// is undone with the inverse of m, so m must have one;
+// if it does not, Transform does nothing.
+//
+func (e *Edit) Transform(it Transformer, m Affine) {
+	inv, ok := m.Invert()
+	if !ok {
+		return
+	}
+	e.Record(func() { it.Transform(inv) }, func() { it.Transform(m) })
+}
+
+// SetFill changes the fill of it, which must be one of the
+// built-in items whose fill can be found, so that it can be
+// put back; if it is not, SetFill does nothing.
+//
+func (e *Edit) SetFill(it Colorer, fill image.Image) {
+	var old image.Image
+	ok := false
+	e.c.Atomically(func(_ FlushFunc) {
+		old, ok = fillOf(it)
+	})
+	if !ok {
+		return
+	}
+	e.Record(func() { it.SetFill(old) }, func() { it.SetFill(fill) })
+}
+
+// bbox returns the bounding box of it,
+// with the canvas locked.
+func (e *Edit) bbox(it Item) (r image.Rectangle) {
+	e.c.Atomically(func(_ FlushFunc) {
+		r = it.Bbox()
+	})
+	return
+}
+
+// fillOf returns the fill of one of the
+// built-in items with a SetFill method.
+func fillOf(it Colorer) (image.Image, bool) {
+	switch it := it.(type) {
+	case *Rect:
+		return it.fill, true
+	case *Marker:
+		return it.fill, true
+	case *Polygon:
+		return it.raster.fill, true
+	case *Line:
+		return it.raster.fill, true
+	case *Ellipse:
+		return it.raster.fill, true
+	case *Spline:
+		return it.raster.fill, true
+	case *RasterItem:
+		return it.fill, true
+	}
+	return nil, false
+}
+
+// contains reports whether it is one of the items of c.
+func (c *Canvas) contains(it Item) (found bool) {
+	c.Atomically(func(_ FlushFunc) {
+		for e := c.items.Front(); e != nil; e = e.Next() {
+			if e.Value.(Item) == it {
+				found = true
+				return
+			}
+		}
+	})
+	return
+}
@@ -0,0 +1,247 @@
+package canvas
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// An xform is the transformation between the coordinates
+// of the items inside a canvas, its world, and those of
+// the canvas in its container, where it is shown.
+type xform struct {
+	zoom float64     // zero if there is no transformation.
+	org  image.Point // the world point shown at min.
+	min  image.Point // the top left of the canvas.
+}
+
+// xform returns the current transformation of c,
+// including that for its device pixel ratio.
+func (c *Canvas) xform() xform {
+	if c.ratio == 0 {
+		return xform{c.zoom, c.org, c.r.Min}
+	}
+	z, org := c.zoom, c.org
+	if z == 0 {
+		z, org = 1, c.r.Min
+	}
+	return xform{z * c.ratio, org, c.r.Min}
+}
+
+// identity reports whether t leaves points unchanged.
+func (t xform) identity() bool {
+	return t.zoom == 0 || t.zoom == 1 && t.org == t.min
+}
+
+// world returns the world point shown at p.
+func (t xform) world(p image.Point) image.Point {
+	if t.identity() {
+		return p
+	}
+	return image.Pt(
+		t.org.X+int(math.Floor(float64(p.X-t.min.X)/t.zoom)),
+		t.org.Y+int(math.Floor(float64(p.Y-t.min.Y)/t.zoom)),
+	)
+}
+
+// worldRect returns the smallest world rectangle
+// holding everything shown in r.
+func (t xform) worldRect(r image.Rectangle) image.Rectangle {
+	if t.identity() {
+		return r
+	}
+	return image.Rect(
+		t.org.X+int(math.Floor(float64(r.Min.X-t.min.X)/t.zoom)),
+		t.org.Y+int(math.Floor(float64(r.Min.Y-t.min.Y)/t.zoom)),
+		t.org.X+int(math.Ceil(float64(r.Max.X-t.min.X)/t.zoom)),
+		t.org.Y+int(math.Ceil(float64(r.Max.Y-t.min.Y)/t.zoom)),
+	)
+}
+
+// screenRect returns the smallest rectangle holding
+// everything that shows the world rectangle r.
+func (t xform) screenRect(r image.Rectangle) image.Rectangle {
+	if t.identity() {
+		return r
+	}
+	return image.Rect(
+		t.min.X+int(math.Floor(float64(r.Min.X-t.org.X)*t.zoom)),
+		t.min.Y+int(math.Floor(float64(r.Min.Y-t.org.Y)*t.zoom)),
+		t.min.X+int(math.Ceil(float64(r.Max.X-t.org.X)*t.zoom)),
+		t.min.Y+int(math.Ceil(float64(r.Max.Y-t.org.Y)*t.zoom)),
+	)
+}
+
+// SetZoom sets the scale at which the items inside c are
+// shown: with a zoom of 2, each unit of the items' coordinate
+// space, their world, is shown as two pixels. The world point
+// shown at the top left of c stays where it is. The items
+// are drawn at their own size and then scaled, so a large
+// zoom shows their pixels; mouse events are passed to them
+// in world coordinates. SetZoom panics if z is not positive.
+//
+func (c *Canvas) SetZoom(z float64) {
+	if z <= 0 {
+		panic("non-positive zoom")
+	}
+	c.setView(func() {
+		if c.zoom == 0 {
+			c.org = c.r.Min
+		}
+		c.zoom = z
+	})
+}
+
+// SetPan scrolls c so that the world point p
+// is shown at the top left of c.
+//
+func (c *Canvas) SetPan(p image.Point) {
+	c.setView(func() {
+		if c.zoom == 0 {
+			c.zoom = 1
+		}
+		c.org = p
+	})
+}
+
+// setView calls f to change the view transformation
+// of c, and redraws the canvas.
+func (c *Canvas) setView(f func()) {
+	c.Atomically(func(flush FlushFunc) {
+		f()
+		// the whole canvas changes, wherever it is in the world.
+		flush(c.xform().worldRect(c.r), nil)
+	})
+	c.Flush()
+}
+
+// Zoom returns the scale at which the items in c are shown.
+//
+func (c *Canvas) Zoom() (z float64) {
+	c.Atomically(func(_ FlushFunc) {
+		z = c.zoom
+	})
+	if z == 0 {
+		z = 1
+	}
+	return
+}
+
+// Pan returns the world point shown at the top left of c.
+//
+func (c *Canvas) Pan() (p image.Point) {
+	c.Atomically(func(_ FlushFunc) {
+		p = c.xform().world(c.r.Min)
+	})
+	return
+}
+
+// ToWorld returns the world point shown at p,
+// which is in the coordinates of c's container.
+//
+func (c *Canvas) ToWorld(p image.Point) (q image.Point) {
+	c.Atomically(func(_ FlushFunc) {
+		q = c.xform().world(p)
+	})
+	return
+}
+
+// SetWorld sets the rectangle in which the items of c are
+// placed, returned by Rect, for canvases whose world is
+// larger than the area they show (see SetZoom and SetPan).
+// If r is empty, the world is the rectangle of the canvas.
+// Items already in c are told of the change.
+//
+func (c *Canvas) SetWorld(r image.Rectangle) {
+	c.Atomically(func(flush FlushFunc) {
+		c.world = r
+		c.setContainers()
+		flush(c.xform().worldRect(c.r), nil)
+	})
+	c.Flush()
+}
+
+// drawViewed draws the part clipr of c onto dst
+// when c has a view transformation, by drawing its
+// world at its own scale and then scaling that.
+func (c *Canvas) drawViewed(dst draw.Image, clipr image.Rectangle) {
+	t := c.xform()
+	wr := t.worldRect(clipr)
+	if wr.Empty() {
+		return
+	}
+	// world goes back to the pool afterwards, so the canvases
+	// drawn onto it must not keep it (see shownDirectly).
+	world := newRGBA(wr)
+	defer freeRGBA(world)
+	c.drawWorld(world, wr)
+	shown := newRGBA(clipr)
+	defer freeRGBA(shown)
+	for y := clipr.Min.Y; y < clipr.Max.Y; y++ {
+		// sample the world at the centre of each pixel.
+		wy := t.org.Y + int(math.Floor((float64(y-t.min.Y)+0.5)/t.zoom))
+		if wy < wr.Min.Y || wy >= wr.Max.Y {
+			continue
+		}
+		i := shown.PixOffset(clipr.Min.X, y)
+		for x := clipr.Min.X; x < clipr.Max.X; x, i = x+1, i+4 {
+			wx := t.org.X + int(math.Floor((float64(x-t.min.X)+0.5)/t.zoom))
+			if wx < wr.Min.X || wx >= wr.Max.X {
+				continue
+			}
+			j := world.PixOffset(wx, wy)
+			copy(shown.Pix[i:i+4], world.Pix[j:j+4])
+		}
+	}
+	drawOver(dst, clipr, shown, clipr.Min, nil, image.ZP)
+}
+
+// SetPixelRatio sets the number of device pixels in each
+// direction for every logical pixel of c, so that an application
+// written for an ordinary display is not tiny on a high
+// resolution one. The items inside c are placed in logical
+// coordinates, from Rect, which is that many times smaller
+// than c itself, and their drawing is scaled up as it is
+// shown, so that lines become thicker and text larger;
+// mouse events are passed to them in logical coordinates.
+// A ratio of 2, the usual one for such displays, shows each
+// logical pixel as a square of four device pixels. The ratio
+// applies together with any zoom (see SetZoom).
+// SetPixelRatio panics if r is not positive.
+//
+func (c *Canvas) SetPixelRatio(r float64) {
+	if r <= 0 {
+		panic("non-positive pixel ratio")
+	}
+	c.Atomically(func(flush FlushFunc) {
+		if r == 1 {
+			r = 0
+		}
+		c.ratio = r
+		c.setContainers()
+		flush(c.xform().worldRect(c.r), nil)
+	})
+	c.Flush()
+}
+
+// PixelRatio returns the device pixel ratio of c.
+//
+func (c *Canvas) PixelRatio() (r float64) {
+	c.Atomically(func(_ FlushFunc) {
+		r = c.ratio
+	})
+	if r == 0 {
+		r = 1
+	}
+	return
+}
+
+// logical returns the rectangle of logical pixels
+// covered by c when it has a device pixel ratio.
+func (c *Canvas) logical() image.Rectangle {
+	size := image.Pt(
+		int(math.Ceil(float64(c.r.Dx())/c.ratio)),
+		int(math.Ceil(float64(c.r.Dy())/c.ratio)),
+	)
+	return image.Rectangle{c.r.Min, c.r.Min.Add(size)}
+}
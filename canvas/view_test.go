@@ -0,0 +1,28 @@
+package canvas
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// TestViewedNestedCanvas checks that a canvas inside a zoomed
+// canvas does not keep the temporary image it was drawn onto,
+// which goes back to the buffer pool afterwards.
+func TestViewedNestedCanvas(t *testing.T) {
+	c, b := NewImageCanvas(image.Rect(0, 0, 100, 100), image.White)
+	c.SetZoom(2)
+	inner := NewCanvas(color.White, image.Rect(10, 10, 40, 40))
+	c.AddItem(inner)
+	c.Flush()
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	inner.AddItem(NewRect(image.Rect(20, 20, 30, 30), &image.Uniform{red}, 0, nil))
+	inner.Flush()
+	img := b.Snapshot()
+	if got := img.RGBAAt(50, 50); got != red {
+		t.Errorf("zoomed item: got %v at (50, 50), want %v", got, red)
+	}
+	if got := img.RGBAAt(30, 30); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("got %v at (30, 30), want white", got)
+	}
+}
@@ -0,0 +1,337 @@
+package canvas
+
+import (
+	"code.google.com/p/rog-go/values"
+	"code.google.com/p/x-go-binding/ui"
+	"image"
+	"image/draw"
+)
+
+// A Viewport shows a rectangular window onto another
+// item, usually a Canvas larger than the viewport itself.
+// The item is clipped to the viewport's rectangle and
+// offset by the current scroll position.
+//
+type Viewport struct {
+	backing Backing
+	r       image.Rectangle // rectangle of the viewport in its container.
+	item    Item
+	offset  image.Point // point in item coordinates shown at r.Min.
+	xv, yv  values.Value
+}
+
+var _ Backing = (*Viewport)(nil)
+var _ HandlerItem = (*Viewport)(nil)
+
+// NewViewport returns a new Viewport occupying r,
+// showing item. Initially the top left of item's
+// bounding box is shown at the top left of r.
+//
+func NewViewport(r image.Rectangle, item Item) *Viewport {
+	v := &Viewport{
+		backing: NullBacking(),
+		r:       r,
+		item:    item,
+		offset:  item.Bbox().Min,
+	}
+	item.SetContainer(v)
+	return v
+}
+
+// delta returns the vector from container to item coordinates.
+func (v *Viewport) delta() image.Point {
+	return v.offset.Sub(v.r.Min)
+}
+
+// SetOffset scrolls the viewport so that the point p
+// in the item's coordinate space is shown at the top
+// left of the viewport.
+//
+func (v *Viewport) SetOffset(p image.Point) {
+	v.backing.Atomically(func(flush FlushFunc) {
+		v.setOffset(p, flush)
+	})
+	v.backing.Flush()
+}
+
+func (v *Viewport) setOffset(p image.Point, flush FlushFunc) {
+	if p.Eq(v.offset) {
+		return
+	}
+	v.offset = p
+	flush(v.r, nil)
+}
+
+// Offset returns the current scroll offset.
+//
+func (v *Viewport) Offset() (p image.Point) {
+	v.backing.Atomically(func(_ FlushFunc) {
+		p = v.offset
+	})
+	return
+}
+
+// Bind binds the horizontal and vertical scroll positions
+// to x and y, either of which may be nil. Each holds a float64
+// in the range [0, 1], as used by Scrollbar; 0 shows the
+// top or left of the item, and 1 the bottom or right.
+//
+func (v *Viewport) Bind(x, y values.Value) {
+	v.backing.Atomically(func(_ FlushFunc) {
+		v.xv, v.yv = x, y
+	})
+	if x != nil {
+		go v.listener(x, false)
+	}
+	if y != nil {
+		go v.listener(y, true)
+	}
+}
+
+// scrollRange returns the range over which the
+// offset may move.
+func (v *Viewport) scrollRange() (min, max image.Point) {
+	b := v.item.Bbox()
+	max = b.Max.Sub(v.r.Size())
+	if max.X < b.Min.X {
+		max.X = b.Min.X
+	}
+	if max.Y < b.Min.Y {
+		max.Y = b.Min.Y
+	}
+	return b.Min, max
+}
+
+func (v *Viewport) listener(val values.Value, vertical bool) {
+	g := val.Getter()
+	for {
+		x, ok := g.Get()
+		if !ok {
+			break
+		}
+		f := clamp01(x.(float64))
+		v.backing.Atomically(func(flush FlushFunc) {
+			min, max := v.scrollRange()
+			p := v.offset
+			if vertical {
+				p.Y = min.Y + int(f*float64(max.Y-min.Y)+0.5)
+			} else {
+				p.X = min.X + int(f*float64(max.X-min.X)+0.5)
+			}
+			v.setOffset(p, flush)
+		})
+		v.backing.Flush()
+	}
+}
+
+// VisibleFraction returns the proportion of the item
+// that is visible horizontally and vertically, suitable
+// for passing to Scrollbar.SetVisible.
+//
+func (v *Viewport) VisibleFraction() (fx, fy float64) {
+	v.backing.Atomically(func(_ FlushFunc) {
+		b := v.item.Bbox()
+		fx, fy = 1, 1
+		if b.Dx() > v.r.Dx() {
+			fx = float64(v.r.Dx()) / float64(b.Dx())
+		}
+		if b.Dy() > v.r.Dy() {
+			fy = float64(v.r.Dy()) / float64(b.Dy())
+		}
+	})
+	return
+}
+
+func (v *Viewport) SetContainer(b Backing) {
+	v.backing = b
+	v.item.SetContainer(v)
+}
+
+func (v *Viewport) Atomically(f func(FlushFunc)) {
+	v.backing.Atomically(func(flush FlushFunc) {
+		f(func(r image.Rectangle, it Drawer) {
+			// anything outside the viewport is clipped.
+			r = r.Sub(v.delta()).Intersect(v.r)
+			if r.Empty() {
+				return
+			}
+			if it != nil {
+				it = v
+			}
+			flush(r, it)
+		})
+	})
+}
+
+// Rect returns the part of the item's coordinate
+// space that is currently visible.
+//
+func (v *Viewport) Rect() image.Rectangle {
+	return v.r.Add(v.delta())
+}
+
+func (v *Viewport) Flush() {
+	v.backing.Flush()
+}
+
+func (v *Viewport) outer() Backing {
+	return v.backing
+}
+
+func (v *Viewport) Draw(dst draw.Image, clipr image.Rectangle) {
+	clipr = clipr.Intersect(v.r)
+	if clipr.Empty() {
+		return
+	}
+	delta := v.delta()
+	clipr = clipr.Add(delta)
+	v.item.Draw(SliceImage(clipr.Max.X, clipr.Max.Y, clipr, dst, delta), clipr)
+}
+
+func (v *Viewport) Bbox() image.Rectangle {
+	return v.r
+}
+
+func (v *Viewport) HitTest(p image.Point) bool {
+	return p.In(v.r) && v.item.HitTest(p.Add(v.delta()))
+}
+
+func (v *Viewport) Opaque() bool {
+	return v.item.Opaque() && v.item.Bbox().Sub(v.delta()).Intersect(v.r).Eq(v.r)
+}
+
+// HandleMouse passes mouse events to the viewed item, if it
+// implements HandleMouser, translating them to the
+// item's coordinate space. If the item does not absorb
+// the event, the scroll wheel scrolls the viewport.
+//
+// After the item has finished handling the events,
+// one event more than it consumed may have been read
+// from ec and discarded.
+//
+func (v *Viewport) HandleMouse(f Flusher, m ui.MouseEvent, ec <-chan interface{}) bool {
+	if !m.Loc.In(v.r) {
+		return false
+	}
+	var delta image.Point
+	v.backing.Atomically(func(_ FlushFunc) {
+		delta = v.delta()
+	})
+	if h, ok := v.item.(HandleMouser); ok {
+		tc, done := translateMouse(ec, delta)
+		m1 := m
+		m1.Loc = m.Loc.Add(delta)
+		absorbed := h.HandleMouse(v, m1, tc)
+		done()
+		if absorbed {
+			return true
+		}
+	}
+	var d image.Point
+	switch {
+	case m.Buttons&WheelUp != 0:
+		d.Y = -1
+	case m.Buttons&WheelDown != 0:
+		d.Y = 1
+	case m.Buttons&WheelLeft != 0:
+		d.X = -1
+	case m.Buttons&WheelRight != 0:
+		d.X = 1
+	default:
+		return false
+	}
+	v.backing.Atomically(func(flush FlushFunc) {
+		min, max := v.scrollRange()
+		p := v.offset
+		p.X += d.X * (v.r.Dx()/10 + 1)
+		p.Y += d.Y * (v.r.Dy()/10 + 1)
+		if p.X < min.X {
+			p.X = min.X
+		}
+		if p.X > max.X {
+			p.X = max.X
+		}
+		if p.Y < min.Y {
+			p.Y = min.Y
+		}
+		if p.Y > max.Y {
+			p.Y = max.Y
+		}
+		v.setOffset(p, flush)
+		if v.xv != nil && d.X != 0 && max.X > min.X {
+			v.xv.Set(float64(p.X-min.X) / float64(max.X-min.X))
+		}
+		if v.yv != nil && d.Y != 0 && max.Y > min.Y {
+			v.yv.Set(float64(p.Y-min.Y) / float64(max.Y-min.Y))
+		}
+	})
+	v.backing.Flush()
+	return true
+}
+
+// HandleEnter and HandleLeave pass the events on to
+// the viewed item, if it implements HoverHandler.
+//
+func (v *Viewport) HandleEnter(m ui.MouseEvent) {
+	if h, ok := v.item.(HoverHandler); ok {
+		h.HandleEnter(v.translate(m))
+	}
+}
+
+func (v *Viewport) HandleLeave(m ui.MouseEvent) {
+	if h, ok := v.item.(HoverHandler); ok {
+		h.HandleLeave(v.translate(m))
+	}
+}
+
+// translate returns m translated to the item's coordinate space.
+func (v *Viewport) translate(m ui.MouseEvent) ui.MouseEvent {
+	v.backing.Atomically(func(_ FlushFunc) {
+		m.Loc = m.Loc.Add(v.delta())
+	})
+	return m
+}
+
+// translateMouse returns a channel that delivers the events from ec,
+// with the locations of mouse events offset by delta.
+// The done function must be called when no more events
+// are needed; it stops the translation.
+func translateMouse(ec <-chan interface{}, delta image.Point) (<-chan interface{}, func()) {
+	return mapMouse(ec, func(p image.Point) image.Point {
+		return p.Add(delta)
+	})
+}
+
+// mapMouse is like translateMouse, but the location of
+// each mouse event is changed to f of the location.
+func mapMouse(ec <-chan interface{}, f func(image.Point) image.Point) (<-chan interface{}, func()) {
+	tc := make(chan interface{})
+	stop := make(chan bool)
+	go func() {
+		defer close(tc)
+		for {
+			var e interface{}
+			var ok bool
+			select {
+			case e, ok = <-ec:
+				if !ok {
+					return
+				}
+			case <-stop:
+				return
+			}
+			if m, isMouse := e.(ui.MouseEvent); isMouse {
+				m.Loc = f(m.Loc)
+				e = m
+			}
+			select {
+			case tc <- e:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return tc, func() {
+		close(stop)
+	}
+}
@@ -0,0 +1,119 @@
+package vnc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+)
+
+// A pixelFormat describes how pixels are sent to a client.
+type pixelFormat struct {
+	bpp, depth                      uint8
+	bigEndian, trueColour           bool
+	redMax, greenMax, blueMax       uint16
+	redShift, greenShift, blueShift uint8
+}
+
+// serverFormat is the format in which pixels are sent
+// until the client asks for another: 32 bits for each,
+// holding 8 bits of red, green and blue.
+var serverFormat = pixelFormat{
+	bpp:        32,
+	depth:      24,
+	trueColour: true,
+	redMax:     255,
+	greenMax:   255,
+	blueMax:    255,
+	redShift:   16,
+	greenShift: 8,
+	blueShift:  0,
+}
+
+// marshal stores f in b[0:16], as sent in the protocol.
+func (f pixelFormat) marshal(b []byte) {
+	b[0] = f.bpp
+	b[1] = f.depth
+	b[2] = flag(f.bigEndian)
+	b[3] = flag(f.trueColour)
+	binary.BigEndian.PutUint16(b[4:6], f.redMax)
+	binary.BigEndian.PutUint16(b[6:8], f.greenMax)
+	binary.BigEndian.PutUint16(b[8:10], f.blueMax)
+	b[10] = f.redShift
+	b[11] = f.greenShift
+	b[12] = f.blueShift
+	b[13], b[14], b[15] = 0, 0, 0
+}
+
+func unmarshalFormat(b []byte) pixelFormat {
+	return pixelFormat{
+		bpp:        b[0],
+		depth:      b[1],
+		bigEndian:  b[2] != 0,
+		trueColour: b[3] != 0,
+		redMax:     binary.BigEndian.Uint16(b[4:6]),
+		greenMax:   binary.BigEndian.Uint16(b[6:8]),
+		blueMax:    binary.BigEndian.Uint16(b[8:10]),
+		redShift:   b[10],
+		greenShift: b[11],
+		blueShift:  b[12],
+	}
+}
+
+func flag(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// valid reports whether pixels can be sent in f. Colour
+// maps are not supported, only true colour.
+func (f pixelFormat) valid() bool {
+	if !f.trueColour {
+		return false
+	}
+	switch f.bpp {
+	case 8, 16, 32:
+	default:
+		return false
+	}
+	for _, s := range []uint8{f.redShift, f.greenShift, f.blueShift} {
+		if s >= f.bpp {
+			return false
+		}
+	}
+	return true
+}
+
+// writePixels writes the pixels of img to w in f.
+func (f pixelFormat) writePixels(w *bufio.Writer, img *image.RGBA) error {
+	n := int(f.bpp / 8)
+	r := img.Bounds()
+	row := make([]byte, n*r.Dx())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		pix := img.Pix[img.PixOffset(r.Min.X, y):]
+		for x := 0; x < r.Dx(); x++ {
+			p := pix[4*x:]
+			v := scale(p[0], f.redMax)<<f.redShift |
+				scale(p[1], f.greenMax)<<f.greenShift |
+				scale(p[2], f.blueMax)<<f.blueShift
+			out := row[n*x : n*x+n]
+			for i := range out {
+				if f.bigEndian {
+					out[n-1-i] = byte(v >> (8 * uint(i)))
+				} else {
+					out[i] = byte(v >> (8 * uint(i)))
+				}
+			}
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scale scales the 8-bit colour component c to the range [0, max].
+func scale(c uint8, max uint16) uint32 {
+	return (uint32(c)*uint32(max) + 127) / 255
+}
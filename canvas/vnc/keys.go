@@ -0,0 +1,55 @@
+package vnc
+
+// Key events are sent by VNC clients as X keysyms,
+// which the canvas package uses for special keys.
+const (
+	keyTab    = 0xff09
+	keyShiftL = 0xffe1
+	keyShiftR = 0xffe2
+)
+
+// keypadKeysyms maps the keysyms of keypad keys to the
+// characters or keysyms of the ordinary keys that they
+// stand for, so that, for instance, the Enter key on the
+// keypad is the same as Return.
+var keypadKeysyms = map[int]int{
+	0xff80: ' ',    // KP_Space.
+	0xff89: 0xff09, // KP_Tab: Tab.
+	0xff8d: 0xff0d, // KP_Enter: Return.
+	0xff95: 0xff50, // KP_Home: Home.
+	0xff96: 0xff51, // KP_Left: Left.
+	0xff97: 0xff52, // KP_Up: Up.
+	0xff98: 0xff53, // KP_Right: Right.
+	0xff99: 0xff54, // KP_Down: Down.
+	0xff9a: 0xff55, // KP_Prior: Prior (Page Up).
+	0xff9b: 0xff56, // KP_Next: Next (Page Down).
+	0xff9c: 0xff57, // KP_End: End.
+	0xff9e: 0xff63, // KP_Insert: Insert.
+	0xff9f: 0xffff, // KP_Delete: Delete.
+	0xffaa: '*',
+	0xffab: '+',
+	0xffac: ',',
+	0xffad: '-',
+	0xffae: '.',
+	0xffaf: '/',
+	0xffbd: '=',
+}
+
+// keyValue returns the value to be sent in a ui.KeyEvent for the
+// keysym k. Keysyms for characters, which are Latin-1 below 0x100
+// and Unicode offset by 0x1000000, send the character; others,
+// such as those of the arrow and function keys, are sent as
+// they are. Keys on the keypad send the same as the ordinary
+// keys that they stand for.
+func keyValue(k int) int {
+	if v, ok := keypadKeysyms[k]; ok {
+		return v
+	}
+	switch {
+	case k >= 0xffb0 && k <= 0xffb9: // KP_0 to KP_9.
+		return '0' + k - 0xffb0
+	case k&^0xffffff == 0x1000000:
+		return k & 0xffffff
+	}
+	return k
+}
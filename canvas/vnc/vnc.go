@@ -0,0 +1,568 @@
+// The vnc package provides a canvas backing that acts as
+// a minimal VNC server, speaking version 3.8 of the RFB protocol
+// (and the older 3.3 and 3.7), so that a canvas application can
+// be viewed and used from any VNC client. Only the raw
+// encoding is sent, and there is no authentication,
+// so it is best used on a trusted network.
+//
+// The RFB protocol is specified in RFC 6143.
+//
+package vnc
+
+import (
+	"bufio"
+	"code.google.com/p/rog-go/canvas"
+	"code.google.com/p/x-go-binding/ui"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+)
+
+// A Server is a canvas Backing whose image is served to VNC
+// clients. It is a Background, so an item, usually a Canvas,
+// is placed in it with SetItem. The events sent by the clients
+// are delivered on the channel returned by EventChan, as
+// ui.MouseEvent and ui.KeyEvent values, in the same way as
+// those from a window. Text cut in a client is placed on the
+// clipboard of the Background, and text copied to that
+// clipboard is sent to all the clients.
+//
+type Server struct {
+	*canvas.Background
+	img     *image.RGBA
+	name    string
+	events  chan interface{}
+	quit    chan bool      // closed by Close.
+	readers sync.WaitGroup // the goroutines delivering events.
+
+	mu        sync.Mutex
+	clients   map[*client]bool
+	listeners map[net.Listener]bool
+	closed    bool
+}
+
+// A client is a connection from a VNC client.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	wmu sync.Mutex // guards w.
+	w   *bufio.Writer
+
+	ready chan bool // receives a value when an update may be due.
+	shift bool      // a shift key is held down; used only by the reader.
+
+	// The following are guarded by Server.mu.
+	format  pixelFormat
+	damaged canvas.Damage   // areas changed since they were last sent.
+	wanted  image.Rectangle // the area of the update requested.
+	pending bool            // an update has been requested.
+}
+
+// NewServer returns a new Server with an image of bounds r,
+// with bg drawn behind its item. The clients show the
+// server's name as the title of the desktop.
+//
+func NewServer(r image.Rectangle, bg image.Image, name string) *Server {
+	s := &Server{
+		img:       image.NewRGBA(r),
+		name:      name,
+		events:    make(chan interface{}),
+		quit:      make(chan bool),
+		clients:   make(map[*client]bool),
+		listeners: make(map[net.Listener]bool),
+	}
+	s.Background = canvas.NewBackground(s.img, bg, s.damage)
+	s.SetClipboard(&cutBuffer{s: s, data: make(map[string][]byte)})
+	return s
+}
+
+// EventChan returns the channel on which the
+// events sent by the clients are delivered.
+//
+func (s *Server) EventChan() <-chan interface{} {
+	return s.events
+}
+
+// ListenAndServe listens on the TCP network address
+// addr, such as ":5900", and serves clients that connect.
+//
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve serves each client that connects to l, until l fails
+// or s is closed, in which case l is closed too.
+//
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return errClosed
+	}
+	s.listeners[l] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+var errClosed = errors.New("vnc: server closed")
+
+// Close disconnects all the clients, stops the listeners
+// being served, and refuses any more. The event channel is
+// closed once the events already sent by the clients have
+// been delivered or dropped.
+//
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.quit)
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	go func() {
+		s.readers.Wait()
+		close(s.events)
+	}()
+	return nil
+}
+
+// damage records that r has changed, so that
+// it is sent to all the clients.
+func (s *Server) damage(r image.Rectangle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.damaged.Add(r)
+		c.signal()
+	}
+}
+
+// signal tells c's sender that an update may be due.
+func (c *client) signal() {
+	select {
+	case c.ready <- true:
+	default:
+	}
+}
+
+// ServeConn serves the single client connected through conn,
+// returning when it disconnects. It closes conn.
+//
+func (s *Server) ServeConn(conn net.Conn) error {
+	defer conn.Close()
+	c := &client{
+		conn:   conn,
+		r:      bufio.NewReader(conn),
+		w:      bufio.NewWriter(conn),
+		format: serverFormat,
+		ready:  make(chan bool, 1),
+	}
+	if err := s.handshake(c); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errClosed
+	}
+	s.clients[c] = true
+	s.readers.Add(1)
+	s.mu.Unlock()
+
+	done := make(chan bool)
+	go s.sender(c, done)
+	err := s.readMessages(c)
+	s.readers.Done()
+	close(done)
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+// handshake agrees on the protocol version and security
+// type with c, and tells it the size and format of the image.
+func (s *Server) handshake(c *client) error {
+	if err := c.write([]byte("RFB 003.008\n")); err != nil {
+		return err
+	}
+	var version [12]byte
+	if _, err := io.ReadFull(c.r, version[:]); err != nil {
+		return err
+	}
+	// Only 3.3, 3.7 and 3.8 are defined; others,
+	// such as 3.5 and 3.889, are treated as 3.3.
+	minor := string(version[8:11])
+	switch minor {
+	case "007", "008":
+		// One security type is offered: 1, no authentication.
+		if err := c.write([]byte{1, 1}); err != nil {
+			return err
+		}
+		choice, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if choice != 1 {
+			return errors.New("vnc: client chose unsupported security type")
+		}
+		if minor == "008" {
+			// The security result: OK.
+			if err := c.write([]byte{0, 0, 0, 0}); err != nil {
+				return err
+			}
+		}
+	default:
+		// The server decides the security type.
+		if err := c.write([]byte{0, 0, 0, 1}); err != nil {
+			return err
+		}
+	}
+	// ClientInit holds the shared flag, which we ignore:
+	// all clients share the image.
+	if _, err := c.r.ReadByte(); err != nil {
+		return err
+	}
+	r := s.img.Bounds()
+	b := make([]byte, 24+len(s.name))
+	binary.BigEndian.PutUint16(b[0:2], uint16(r.Dx()))
+	binary.BigEndian.PutUint16(b[2:4], uint16(r.Dy()))
+	serverFormat.marshal(b[4:20])
+	binary.BigEndian.PutUint32(b[20:24], uint32(len(s.name)))
+	copy(b[24:], s.name)
+	return c.write(b)
+}
+
+// write writes b to c and flushes it.
+func (c *client) write(b []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.w.Write(b); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// Client to server message types.
+const (
+	msgSetPixelFormat    = 0
+	msgSetEncodings      = 2
+	msgUpdateRequest     = 3
+	msgKeyEvent          = 4
+	msgPointerEvent      = 5
+	msgClientCutText     = 6
+	msgFramebufferUpdate = 0 // Server to client.
+	msgServerCutText     = 3 // Server to client.
+)
+
+// readMessages reads the messages sent by c,
+// until it disconnects or sends one that is invalid.
+func (s *Server) readMessages(c *client) error {
+	min := s.img.Rect.Min
+	var b [20]byte
+	for {
+		t, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		var ev interface{}
+		switch t {
+		case msgSetPixelFormat:
+			if _, err := io.ReadFull(c.r, b[0:19]); err != nil {
+				return err
+			}
+			f := unmarshalFormat(b[3:19])
+			if !f.valid() {
+				return errors.New("vnc: unsupported pixel format")
+			}
+			s.mu.Lock()
+			c.format = f
+			s.mu.Unlock()
+		case msgSetEncodings:
+			// Only the raw encoding is sent,
+			// which all clients accept.
+			if _, err := io.ReadFull(c.r, b[0:3]); err != nil {
+				return err
+			}
+			n := int(binary.BigEndian.Uint16(b[1:3]))
+			if _, err := io.CopyN(ioutil.Discard, c.r, int64(4*n)); err != nil {
+				return err
+			}
+		case msgUpdateRequest:
+			if _, err := io.ReadFull(c.r, b[0:9]); err != nil {
+				return err
+			}
+			x, y := int(binary.BigEndian.Uint16(b[1:3])), int(binary.BigEndian.Uint16(b[3:5]))
+			w, h := int(binary.BigEndian.Uint16(b[5:7])), int(binary.BigEndian.Uint16(b[7:9]))
+			r := image.Rect(x, y, x+w, y+h).Add(min)
+			s.mu.Lock()
+			if b[0] == 0 {
+				// Not incremental: the whole area must be sent.
+				c.damaged.Add(r.Intersect(s.img.Rect))
+			}
+			c.wanted = r
+			c.pending = true
+			c.signal()
+			s.mu.Unlock()
+		case msgKeyEvent:
+			if _, err := io.ReadFull(c.r, b[0:7]); err != nil {
+				return err
+			}
+			down := b[0] != 0
+			k := int(binary.BigEndian.Uint32(b[3:7]))
+			if k == keyShiftL || k == keyShiftR {
+				c.shift = down
+			}
+			if k == keyTab && c.shift {
+				k = canvas.KeyBackTab
+			}
+			k = keyValue(k)
+			if !down {
+				k = -k
+			}
+			ev = ui.KeyEvent{k}
+		case msgPointerEvent:
+			if _, err := io.ReadFull(c.r, b[0:5]); err != nil {
+				return err
+			}
+			// The buttons are numbered as in X.
+			x, y := int(binary.BigEndian.Uint16(b[1:3])), int(binary.BigEndian.Uint16(b[3:5]))
+			ev = ui.MouseEvent{
+				Buttons: int(b[0]),
+				Loc:     image.Pt(x, y).Add(min),
+				Time:    time.Now(),
+			}
+		case msgClientCutText:
+			if _, err := io.ReadFull(c.r, b[0:7]); err != nil {
+				return err
+			}
+			n := binary.BigEndian.Uint32(b[3:7])
+			if n > maxCutText {
+				return errors.New("vnc: cut text too long")
+			}
+			text := make([]byte, n)
+			if _, err := io.ReadFull(c.r, text); err != nil {
+				return err
+			}
+			s.cutFromClient(latin1ToUTF8(text))
+		default:
+			return errors.New("vnc: unknown message type")
+		}
+		if ev == nil {
+			continue
+		}
+		select {
+		case s.events <- ev:
+		case <-s.quit:
+			return errClosed
+		}
+	}
+}
+
+// maxCutText is the longest text accepted from a client.
+const maxCutText = 1 << 20
+
+// sender sends c each update that it has requested, once
+// some of the requested area has changed, until done is closed.
+func (s *Server) sender(c *client, done chan bool) {
+	for {
+		select {
+		case <-c.ready:
+		case <-done:
+			return
+		}
+		s.mu.Lock()
+		if !c.pending || c.damaged.Empty() {
+			s.mu.Unlock()
+			continue
+		}
+		// Send the changes inside the area requested, and
+		// keep the rest until they are requested too.
+		var rs []image.Rectangle
+		for _, r := range c.damaged.Take() {
+			for _, rest := range subtract(r, c.wanted) {
+				c.damaged.Add(rest)
+			}
+			if r = r.Intersect(c.wanted); !r.Empty() {
+				rs = append(rs, r)
+			}
+		}
+		if len(rs) == 0 {
+			s.mu.Unlock()
+			continue
+		}
+		c.pending = false
+		f := c.format
+		s.mu.Unlock()
+		if s.sendUpdate(c, f, rs) != nil {
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+// subtract returns the parts of r outside s,
+// as at most four rectangles.
+func subtract(r, s image.Rectangle) []image.Rectangle {
+	s = s.Intersect(r)
+	if s.Empty() {
+		return []image.Rectangle{r}
+	}
+	var rs []image.Rectangle
+	add := func(q image.Rectangle) {
+		if !q.Empty() {
+			rs = append(rs, q)
+		}
+	}
+	add(image.Rect(r.Min.X, r.Min.Y, r.Max.X, s.Min.Y))
+	add(image.Rect(r.Min.X, s.Max.Y, r.Max.X, r.Max.Y))
+	add(image.Rect(r.Min.X, s.Min.Y, s.Min.X, s.Max.Y))
+	add(image.Rect(s.Max.X, s.Min.Y, r.Max.X, s.Max.Y))
+	return rs
+}
+
+// sendUpdate sends c the areas rs of the image, in format f.
+func (s *Server) sendUpdate(c *client, f pixelFormat, rs []image.Rectangle) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	var b [12]byte
+	b[0] = msgFramebufferUpdate
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(rs)))
+	if _, err := c.w.Write(b[0:4]); err != nil {
+		return err
+	}
+	min := s.img.Rect.Min
+	for _, r := range rs {
+		// Copy the area, so that the image is only locked
+		// while copying, not while sending.
+		img := image.NewRGBA(r)
+		s.Atomically(func(_ canvas.FlushFunc) {
+			draw.Draw(img, r, s.img, r.Min, draw.Src)
+		})
+		p := r.Min.Sub(min)
+		binary.BigEndian.PutUint16(b[0:2], uint16(p.X))
+		binary.BigEndian.PutUint16(b[2:4], uint16(p.Y))
+		binary.BigEndian.PutUint16(b[4:6], uint16(r.Dx()))
+		binary.BigEndian.PutUint16(b[6:8], uint16(r.Dy()))
+		binary.BigEndian.PutUint32(b[8:12], 0) // The raw encoding.
+		if _, err := c.w.Write(b[0:12]); err != nil {
+			return err
+		}
+		if err := f.writePixels(c.w, img); err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+// cutBuffer is the clipboard of a Server,
+// whose text is shared with the clients.
+type cutBuffer struct {
+	s    *Server
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (cb *cutBuffer) Get(format string) ([]byte, bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	d, ok := cb.data[format]
+	return d, ok
+}
+
+// Set replaces the data held, and sends any
+// text held to all the clients.
+func (cb *cutBuffer) Set(data map[string][]byte) {
+	cb.mu.Lock()
+	cb.data = make(map[string][]byte)
+	for f, d := range data {
+		cb.data[f] = append([]byte(nil), d...)
+	}
+	text, ok := cb.data[canvas.TextFormat]
+	cb.mu.Unlock()
+	if !ok {
+		return
+	}
+	latin1 := utf8ToLatin1(text)
+	msg := make([]byte, 8+len(latin1))
+	msg[0] = msgServerCutText
+	binary.BigEndian.PutUint32(msg[4:8], uint32(len(latin1)))
+	copy(msg[8:], latin1)
+	s := cb.s
+	s.mu.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+	for _, c := range clients {
+		c.write(msg)
+	}
+}
+
+// cutFromClient places text, cut in a client, on the clipboard.
+// If the clipboard is the server's own, the text is not sent
+// back to the clients, which have it already.
+func (s *Server) cutFromClient(text string) {
+	switch cb := s.Clipboard().(type) {
+	case *cutBuffer:
+		cb.mu.Lock()
+		cb.data = map[string][]byte{canvas.TextFormat: []byte(text)}
+		cb.mu.Unlock()
+	case nil:
+	default:
+		cb.Set(map[string][]byte{canvas.TextFormat: []byte(text)})
+	}
+}
+
+// Cut text is sent as Latin-1.
+func latin1ToUTF8(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+func utf8ToLatin1(b []byte) []byte {
+	latin1 := make([]byte, 0, len(b))
+	for _, r := range string(b) {
+		if r >= 0x100 {
+			r = '?'
+		}
+		latin1 = append(latin1, byte(r))
+	}
+	return latin1
+}
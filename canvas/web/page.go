@@ -0,0 +1,139 @@
+package web
+
+import (
+	"html/template"
+)
+
+// page is the page served to browsers. It draws each tile as it
+// arrives, in order, and sends mouse events, with the buttons
+// numbered as in X, and key events, with special keys sent as
+// the X keysyms used by the canvas package (see canvas.KeyTab).
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>canvas</title>
+<style>
+body { margin: 0; }
+canvas { display: block; outline: none; }
+</style>
+</head>
+<body>
+<canvas id="c" width="{{.Width}}" height="{{.Height}}" tabindex="0"></canvas>
+<script>
+(function() {
+	var cvs = document.getElementById("c");
+	var ctx = cvs.getContext("2d");
+	var loc = window.location;
+	var path = loc.pathname.replace(/\/?$/, "/ws");
+	var ws = new WebSocket((loc.protocol == "https:" ? "wss://" : "ws://") + loc.host + path);
+
+	// Tiles are drawn in the order they were sent,
+	// so that a later one is never covered by an earlier.
+	var tiles = [], drawing = false;
+	function drawNext() {
+		if (tiles.length == 0) {
+			drawing = false;
+			return;
+		}
+		drawing = true;
+		var t = tiles.shift();
+		var img = new Image();
+		img.onload = function() {
+			ctx.drawImage(img, t.X, t.Y);
+			drawNext();
+		};
+		img.src = "data:image/png;base64," + t.PNG;
+	}
+	ws.onmessage = function(m) {
+		tiles.push(JSON.parse(m.data));
+		if (!drawing) {
+			drawNext();
+		}
+	};
+	function send(e) {
+		if (ws.readyState == WebSocket.OPEN) {
+			ws.send(JSON.stringify(e));
+		}
+	}
+
+	// The browser numbers the middle and right buttons
+	// the other way round from X.
+	var buttons = 0;
+	function xButtons(b) {
+		return (b & 1) | (b & 2) << 1 | (b & 4) >> 1;
+	}
+	function mouse(e) {
+		var r = cvs.getBoundingClientRect();
+		send({Type: "mouse", X: Math.floor(e.clientX - r.left), Y: Math.floor(e.clientY - r.top), Buttons: buttons});
+	}
+	cvs.onmousedown = function(e) {
+		cvs.focus();
+		buttons = xButtons(e.buttons);
+		mouse(e);
+		e.preventDefault();
+	};
+	cvs.onmouseup = cvs.onmousemove = function(e) {
+		buttons = xButtons(e.buttons);
+		mouse(e);
+	};
+	cvs.oncontextmenu = function(e) {
+		e.preventDefault();
+	};
+	// The wheel is buttons 4 and 5, pressed and released at once.
+	cvs.onwheel = function(e) {
+		var b = e.deltaY < 0 ? 8 : 16;
+		buttons |= b;
+		mouse(e);
+		buttons &= ~b;
+		mouse(e);
+		e.preventDefault();
+	};
+
+	var keysyms = {
+		Backspace: 0xff08, Tab: 0xff09, Enter: 0xff0d, Escape: 0xff1b,
+		Home: 0xff50, ArrowLeft: 0xff51, ArrowUp: 0xff52, ArrowRight: 0xff53,
+		ArrowDown: 0xff54, PageUp: 0xff55, PageDown: 0xff56, End: 0xff57,
+		Insert: 0xff63, Delete: 0xffff, CapsLock: 0xffe5, Meta: 0xffeb
+	};
+	function keysym(e) {
+		var k = e.key;
+		if (k == "Tab" && e.shiftKey) {
+			return 0xfe20;
+		}
+		if (k == "Shift" || k == "Control" || k == "Alt") {
+			var base = {Shift: 0xffe1, Control: 0xffe3, Alt: 0xffe9}[k];
+			return e.location == 2 ? base + 1 : base;
+		}
+		if (keysyms[k]) {
+			return keysyms[k];
+		}
+		var f = /^F([0-9]+)$/.exec(k);
+		if (f) {
+			return 0xffbd + parseInt(f[1], 10);
+		}
+		// Other keys that produce a single character send it.
+		if (Array.from(k).length == 1) {
+			return k.codePointAt(0);
+		}
+		return 0;
+	}
+	function key(e, sign) {
+		var k = keysym(e);
+		if (k != 0) {
+			send({Type: "key", Key: sign * k});
+			e.preventDefault();
+		}
+	}
+	cvs.onkeydown = function(e) {
+		key(e, 1);
+	};
+	cvs.onkeyup = function(e) {
+		key(e, -1);
+	};
+	cvs.focus();
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,299 @@
+// The web package provides a canvas backing that is shown
+// in a web browser. The page that it serves draws the image
+// of the backing onto an HTML5 canvas, and is sent the areas
+// that change, as PNG tiles, over a WebSocket, through which
+// the browser sends back mouse and keyboard events. Any number
+// of browsers may view the same backing at once.
+//
+// There is no authentication: anyone who can reach the
+// server can view and use the canvas, so it is best bound to
+// localhost, or used on a trusted network. So that other web
+// pages shown in the user's browser cannot connect to it, a
+// WebSocket is refused unless it is opened by a page served
+// from the same host (see Server.CheckOrigin).
+//
+// For example:
+//
+//	s := web.NewServer(image.Rect(0, 0, 640, 480), image.White)
+//	c := canvas.NewCanvas(nil, s.Rect())
+//	s.SetItem(c)
+//	http.Handle("/", s)
+//	go http.ListenAndServe("localhost:8080", nil)
+//	for e := range s.EventChan() {
+//		if m, ok := e.(ui.MouseEvent); ok && m.Buttons != 0 {
+//			c.HandleMouse(c, m, s.EventChan())
+//		}
+//	}
+//
+package web
+
+import (
+	"bytes"
+	"code.google.com/p/go.net/websocket"
+	"code.google.com/p/rog-go/canvas"
+	"code.google.com/p/x-go-binding/ui"
+	"encoding/base64"
+	"image"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TileSize is the size of the largest tile sent to the
+// browser; larger areas are sent as several tiles, so that
+// the browser can start drawing before all have arrived.
+//
+const TileSize = 128
+
+// A Server is a canvas Backing whose image is served to web
+// browsers. It is a Background, so an item, usually a Canvas,
+// is placed in it with SetItem. The events sent by the browsers
+// are delivered on the channel returned by EventChan, as
+// ui.MouseEvent and ui.KeyEvent values, in the same way
+// as those from a window.
+//
+type Server struct {
+	*canvas.Background
+
+	// CheckOrigin, if non-nil, is called with each request
+	// to open a WebSocket, and reports whether it should be
+	// allowed. If it is nil, the Origin header of the request,
+	// if there is one, must name the host that it was sent to.
+	CheckOrigin func(req *http.Request) bool
+
+	img     *image.RGBA
+	events  chan interface{}
+	quit    chan bool      // closed by Close.
+	readers sync.WaitGroup // the goroutines delivering events.
+
+	mu      sync.Mutex
+	clients map[*client]bool
+	closed  bool
+}
+
+// A client is a browser viewing the image.
+type client struct {
+	ws      *websocket.Conn
+	damaged canvas.Damage // areas not yet sent; guarded by Server.mu.
+	ready   chan bool     // receives a value when damaged is non-empty.
+}
+
+// tile is the message sent to the browser for each tile:
+// the PNG image of the area at X, Y, in base 64.
+type tile struct {
+	X, Y int
+	PNG  string
+}
+
+// event is the message sent by the browser for each event.
+// Type is "mouse" or "key". For a mouse event, X and Y
+// are relative to the top left of the image.
+type event struct {
+	Type    string
+	X, Y    int
+	Buttons int
+	Key     int
+}
+
+// NewServer returns a new Server with an image of bounds r,
+// with bg drawn behind its item. Use it as an http.Handler to
+// serve its page; the page connects back to the same path,
+// with "/ws" appended, for its WebSocket.
+//
+func NewServer(r image.Rectangle, bg image.Image) *Server {
+	s := &Server{
+		img:     image.NewRGBA(r),
+		events:  make(chan interface{}),
+		quit:    make(chan bool),
+		clients: make(map[*client]bool),
+	}
+	s.Background = canvas.NewBackground(s.img, bg, s.damage)
+	return s
+}
+
+// EventChan returns the channel on which the
+// events sent by the browsers are delivered.
+//
+func (s *Server) EventChan() <-chan interface{} {
+	return s.events
+}
+
+// Close disconnects all the browsers and refuses any more.
+// The event channel is closed once the events already
+// sent by the browsers have been delivered or dropped.
+//
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.quit)
+	for c := range s.clients {
+		c.ws.Close()
+	}
+	go func() {
+		s.readers.Wait()
+		close(s.events)
+	}()
+	return nil
+}
+
+// damage records that r has changed, so that
+// it is sent to all the browsers.
+func (s *Server) damage(r image.Rectangle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.add(r)
+	}
+}
+
+// add adds r to the areas that c has not been sent.
+func (c *client) add(r image.Rectangle) {
+	c.damaged.Add(r)
+	select {
+	case c.ready <- true:
+	default:
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if strings.HasSuffix(req.URL.Path, "/ws") {
+		check := s.CheckOrigin
+		if check == nil {
+			check = sameOrigin
+		}
+		if !check(req) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		websocket.Handler(s.serveWS).ServeHTTP(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	r := s.img.Bounds()
+	page.Execute(w, map[string]interface{}{
+		"Width":  r.Dx(),
+		"Height": r.Dy(),
+	})
+}
+
+// sameOrigin reports whether req was sent by a page from
+// the host that it was sent to, or by a program other than
+// a browser, which sends no Origin header.
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, req.Host)
+}
+
+// serveWS serves a browser connected through ws, sending
+// it the whole image and then each area as it changes,
+// while reading the events that it sends.
+func (s *Server) serveWS(ws *websocket.Conn) {
+	c := &client{ws: ws, ready: make(chan bool, 1)}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ws.Close()
+		return
+	}
+	s.clients[c] = true
+	c.add(s.img.Bounds())
+	s.readers.Add(1)
+	s.mu.Unlock()
+
+	done := make(chan bool)
+	go func() {
+		defer s.readers.Done()
+		s.readEvents(ws)
+		close(done)
+	}()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+	for {
+		select {
+		case <-c.ready:
+		case <-done:
+			return
+		}
+		s.mu.Lock()
+		damaged := c.damaged.Take()
+		s.mu.Unlock()
+		for _, r := range damaged {
+			if err := s.send(ws, r); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// send sends the area r of the image to ws as tiles.
+func (s *Server) send(ws *websocket.Conn, r image.Rectangle) error {
+	r = r.Intersect(s.img.Bounds())
+	var buf bytes.Buffer
+	for y := r.Min.Y; y < r.Max.Y; y += TileSize {
+		for x := r.Min.X; x < r.Max.X; x += TileSize {
+			tr := image.Rect(x, y, x+TileSize, y+TileSize).Intersect(r)
+			// Copy the tile, so that the image is only locked while
+			// copying, not while encoding and sending.
+			img := image.NewRGBA(tr)
+			s.Atomically(func(_ canvas.FlushFunc) {
+				draw.Draw(img, tr, s.img, tr.Min, draw.Src)
+			})
+			buf.Reset()
+			if err := png.Encode(&buf, img); err != nil {
+				return err
+			}
+			min := tr.Min.Sub(s.img.Rect.Min)
+			err := websocket.JSON.Send(ws, tile{min.X, min.Y, base64.StdEncoding.EncodeToString(buf.Bytes())})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readEvents reads the events sent by the browser
+// connected through ws, and delivers them.
+func (s *Server) readEvents(ws *websocket.Conn) {
+	min := s.img.Rect.Min
+	for {
+		var e event
+		if err := websocket.JSON.Receive(ws, &e); err != nil {
+			return
+		}
+		var ev interface{}
+		switch e.Type {
+		case "mouse":
+			ev = ui.MouseEvent{
+				Buttons: e.Buttons,
+				Loc:     image.Pt(e.X, e.Y).Add(min),
+				Time:    time.Now(),
+			}
+		case "key":
+			ev = ui.KeyEvent{e.Key}
+		default:
+			continue
+		}
+		select {
+		case s.events <- ev:
+		case <-s.quit:
+			return
+		}
+	}
+}
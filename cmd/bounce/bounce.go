@@ -397,7 +397,7 @@ func makeBall(b ball) Ball {
 	p := b.p.point().Sub(image.Pt(ballSize/2, ballSize/2))
 	item := canvas.NewImage(img, true, p)
 	window.AddItem(item)
-	window.Raise(item, nil, false)
+	window.Lower(item)
 	return Ball{item}
 }
 
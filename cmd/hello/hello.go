@@ -23,6 +23,8 @@ func main() {
 	screen := win.Screen()
 
 	bg := canvas.NewBackground(screen.(*image.RGBA), image.White, flushFunc(win))
+	bg.SetClipboard(win.Display().Clipboard())
+	bg.SetSelection(win.Display().Primary())
 	cvs = canvas.NewCanvas(nil, bg.Rect())
 	bg.SetItem(cvs)
 
@@ -55,6 +57,9 @@ func main() {
 		case nil:
 			log.Fatal("quitting")
 			return
+		case draw.ConfigEvent:
+			// the window has been resized.
+			bg.SetImage(win.Screen())
 		case draw.MouseEvent:
 			if e.Buttons == 0 {
 				break
@@ -0,0 +1,14 @@
+/*
+ * The Objective-C side of the cocoa package. Windows are
+ * known to C by the integer id that Go gives them; all
+ * calls into AppKit are made on the main thread.
+ */
+
+void runApp(void);
+void stopApp(void);
+int newWindow(int id, int width, int height);
+void closeWindow(int id);
+void setTitle(int id, char *title);
+void setSize(int id, int width, int height);
+void invalidate(int id, int x, int y, int width, int height);
+void drawImage(void *ctx, void *pix, int width, int height);
@@ -0,0 +1,247 @@
+#import <Cocoa/Cocoa.h>
+#include "cocoa.h"
+#include "_cgo_export.h"
+
+/*
+ * A GoView is the content view of a window. It draws the
+ * image held by Go, and passes its events on to Go.
+ */
+@interface GoView : NSView {
+@public
+	int goID;
+	NSUInteger flags;	/* modifier flags as of the last flagsChanged: */
+	NSPoint loc;
+}
+@end
+
+@interface GoWindowDelegate : NSObject <NSWindowDelegate> {
+@public
+	int goID;
+}
+@end
+
+static NSMutableDictionary *windows;	/* NSWindow, keyed by Go id. */
+
+static NSWindow *
+windowFor(int id)
+{
+	return [windows objectForKey:[NSNumber numberWithInt:id]];
+}
+
+@implementation GoView
+
+- (BOOL)isFlipped {
+	/* Put the origin at the top left, as in Go images. */
+	return YES;
+}
+
+- (BOOL)isOpaque {
+	return YES;
+}
+
+- (BOOL)acceptsFirstResponder {
+	return YES;
+}
+
+- (void)drawRect:(NSRect)r {
+	goPaint(goID, [[NSGraphicsContext currentContext] CGContext]);
+}
+
+- (void)setFrameSize:(NSSize)size {
+	[super setFrameSize:size];
+	goResize(goID, (int)size.width, (int)size.height);
+}
+
+- (void)mouse:(NSEvent *)e {
+	loc = [self convertPoint:[e locationInWindow] fromView:nil];
+	goMouse(goID, (int)[NSEvent pressedMouseButtons], (int)loc.x, (int)loc.y);
+}
+
+- (void)mouseDown:(NSEvent *)e { [self mouse:e]; }
+- (void)mouseUp:(NSEvent *)e { [self mouse:e]; }
+- (void)mouseDragged:(NSEvent *)e { [self mouse:e]; }
+- (void)mouseMoved:(NSEvent *)e { [self mouse:e]; }
+- (void)rightMouseDown:(NSEvent *)e { [self mouse:e]; }
+- (void)rightMouseUp:(NSEvent *)e { [self mouse:e]; }
+- (void)rightMouseDragged:(NSEvent *)e { [self mouse:e]; }
+- (void)otherMouseDown:(NSEvent *)e { [self mouse:e]; }
+- (void)otherMouseUp:(NSEvent *)e { [self mouse:e]; }
+- (void)otherMouseDragged:(NSEvent *)e { [self mouse:e]; }
+
+- (void)scrollWheel:(NSEvent *)e {
+	goScroll(goID, [e scrollingDeltaY], [e hasPreciseScrollingDeltas]);
+}
+
+- (void)key:(NSEvent *)e down:(int)down {
+	NSString *s;
+	unichar c;
+
+	/*
+	 * With Control held, send the key's own character,
+	 * as X does, rather than a control code.
+	 */
+	if([e modifierFlags] & NSEventModifierFlagControl)
+		s = [e charactersIgnoringModifiers];
+	else
+		s = [e characters];
+	if([s length] == 0)
+		return;
+	c = [s characterAtIndex:0];
+	if(CFStringIsSurrogateHighCharacter(c) && [s length] > 1)
+		goKey(goID, CFStringGetLongCharacterForSurrogatePair(c, [s characterAtIndex:1]), down);
+	else
+		goKey(goID, c, down);
+}
+
+- (void)keyDown:(NSEvent *)e { [self key:e down:1]; }
+- (void)keyUp:(NSEvent *)e { [self key:e down:0]; }
+
+- (void)flagsChanged:(NSEvent *)e {
+	NSUInteger f;
+
+	f = [e modifierFlags];
+	goModifiers(goID, (unsigned int)flags, (unsigned int)f);
+	flags = f;
+}
+
+@end
+
+@implementation GoWindowDelegate
+
+- (void)windowWillClose:(NSNotification *)n {
+	[windows removeObjectForKey:[NSNumber numberWithInt:goID]];
+	goClosed(goID);
+}
+
+@end
+
+void
+runApp(void)
+{
+	[NSAutoreleasePool new];
+	[NSApplication sharedApplication];
+	[NSApp setActivationPolicy:NSApplicationActivationPolicyRegular];
+	[NSApp activateIgnoringOtherApps:YES];
+	[NSApp run];
+}
+
+void
+stopApp(void)
+{
+	dispatch_async(dispatch_get_main_queue(), ^{
+		NSEvent *e;
+
+		[NSApp stop:nil];
+		/* stop: only takes effect after an event has been handled. */
+		e = [NSEvent otherEventWithType:NSEventTypeApplicationDefined location:NSZeroPoint
+			modifierFlags:0 timestamp:0 windowNumber:0 context:nil subtype:0 data1:0 data2:0];
+		[NSApp postEvent:e atStart:YES];
+	});
+}
+
+int
+newWindow(int id, int width, int height)
+{
+	__block int ok;
+
+	ok = 0;
+	dispatch_sync(dispatch_get_main_queue(), ^{
+		NSWindow *w;
+		GoView *v;
+		GoWindowDelegate *d;
+		NSUInteger style;
+
+		style = NSWindowStyleMaskTitled | NSWindowStyleMaskClosable |
+			NSWindowStyleMaskMiniaturizable | NSWindowStyleMaskResizable;
+		w = [[NSWindow alloc] initWithContentRect:NSMakeRect(0, 0, width, height)
+			styleMask:style backing:NSBackingStoreBuffered defer:NO];
+		if(w == nil)
+			return;
+		[w setReleasedWhenClosed:NO];
+		v = [[GoView alloc] initWithFrame:NSMakeRect(0, 0, width, height)];
+		v->goID = id;
+		d = [GoWindowDelegate new];
+		d->goID = id;
+		[w setContentView:v];
+		[v release];
+		[w setDelegate:d];
+		[w setAcceptsMouseMovedEvents:YES];
+		[w makeFirstResponder:v];
+		if(windows == nil)
+			windows = [NSMutableDictionary new];
+		[windows setObject:w forKey:[NSNumber numberWithInt:id]];
+		[w cascadeTopLeftFromPoint:NSMakePoint(20, 20)];
+		[w makeKeyAndOrderFront:nil];
+		ok = 1;
+	});
+	return ok;
+}
+
+void
+closeWindow(int id)
+{
+	dispatch_async(dispatch_get_main_queue(), ^{
+		[windowFor(id) close];
+	});
+}
+
+void
+setTitle(int id, char *title)
+{
+	NSString *s;
+
+	s = [[NSString alloc] initWithUTF8String:title];
+	free(title);
+	dispatch_async(dispatch_get_main_queue(), ^{
+		[windowFor(id) setTitle:s];
+		[s release];
+	});
+}
+
+void
+setSize(int id, int width, int height)
+{
+	dispatch_async(dispatch_get_main_queue(), ^{
+		[windowFor(id) setContentSize:NSMakeSize(width, height)];
+	});
+}
+
+void
+invalidate(int id, int x, int y, int width, int height)
+{
+	dispatch_async(dispatch_get_main_queue(), ^{
+		[[windowFor(id) contentView] setNeedsDisplayInRect:NSMakeRect(x, y, width, height)];
+	});
+}
+
+/*
+ * drawImage draws the width×height RGBA pixels, with alpha
+ * premultiplied, as Go's image.RGBA holds them, into ctx.
+ * It is called by Go with the pixels locked.
+ */
+void
+drawImage(void *ctx, void *pix, int width, int height)
+{
+	CGColorSpaceRef cs;
+	CGContextRef bm;
+	CGImageRef img;
+	CGContextRef c;
+
+	c = ctx;
+	cs = CGColorSpaceCreateDeviceRGB();
+	bm = CGBitmapContextCreate(pix, width, height, 8, 4*width, cs,
+		kCGImageAlphaPremultipliedLast | kCGBitmapByteOrder32Big);
+	img = CGBitmapContextCreateImage(bm);
+	/*
+	 * The view is flipped, but images are drawn with their
+	 * first row at the bottom, so flip it back.
+	 */
+	CGContextSaveGState(c);
+	CGContextTranslateCTM(c, 0, height);
+	CGContextScaleCTM(c, 1, -1);
+	CGContextDrawImage(c, CGRectMake(0, 0, width, height), img);
+	CGContextRestoreGState(c);
+	CGImageRelease(img);
+	CGContextRelease(bm);
+	CGColorSpaceRelease(cs);
+}
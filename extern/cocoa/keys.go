@@ -0,0 +1,103 @@
+// +build darwin
+
+package cocoa
+
+import "C"
+
+import (
+	"exp/draw"
+)
+
+// specialKeysyms maps the characters that Cocoa sends for keys
+// that do not send printable characters to the X keysyms that
+// the x11 package sends for them, so that programs see the same
+// values on both. Cocoa sends the function keys, including
+// the arrows, as characters in Unicode's private use area.
+var specialKeysyms = map[int]int{
+	0x03:   0xff0d, // Enter, on the keypad: Return.
+	0x08:   0xff08, // BackSpace.
+	0x09:   0xff09, // Tab.
+	0x0d:   0xff0d, // Return.
+	0x19:   0xfe20, // Shift-Tab: ISO_Left_Tab.
+	0x1b:   0xff1b, // Escape.
+	0x7f:   0xff08, // Delete, above Return: BackSpace.
+	0xf700: 0xff52, // Up.
+	0xf701: 0xff54, // Down.
+	0xf702: 0xff51, // Left.
+	0xf703: 0xff53, // Right.
+	0xf727: 0xff63, // Insert.
+	0xf728: 0xffff, // Delete.
+	0xf729: 0xff50, // Home.
+	0xf72b: 0xff57, // End.
+	0xf72c: 0xff55, // Prior (Page Up).
+	0xf72d: 0xff56, // Next (Page Down).
+}
+
+// Unicode values of the function keys F1 to F35.
+const (
+	keyF1  = 0xf704
+	keyF35 = 0xf726
+)
+
+// modifierKeysyms maps the bits of Cocoa's modifier
+// flags to the keysyms of the keys that set them.
+var modifierKeysyms = []struct {
+	flag   uint
+	keysym int
+}{
+	{1 << 16, 0xffe5}, // Caps Lock: Caps_Lock.
+	{1 << 17, 0xffe1}, // Shift: Shift_L.
+	{1 << 18, 0xffe3}, // Control: Control_L.
+	{1 << 19, 0xffe9}, // Option: Alt_L.
+	{1 << 20, 0xffeb}, // Command: Super_L.
+}
+
+// keyValue returns the value to be sent in a draw.KeyEvent for
+// the character c that Cocoa sends for a key.
+func keyValue(c int) int {
+	if k, ok := specialKeysyms[c]; ok {
+		return k
+	}
+	if c >= keyF1 && c <= keyF35 {
+		return 0xffbe + c - keyF1
+	}
+	return c
+}
+
+//export goKey
+func goKey(id C.int, c C.int, down C.int) {
+	w := lookup(id)
+	if w == nil {
+		return
+	}
+	v := keyValue(int(c))
+	if v >= 0xf700 && v <= 0xf8ff {
+		// Other function keys, which X has no keysyms for.
+		return
+	}
+	if down == 0 {
+		v = -v
+	}
+	w.event <- draw.KeyEvent{v}
+}
+
+// goModifiers is called when the modifier keys held down
+// change from old to flags, sending a key press or
+// release for each modifier key that has changed.
+//
+//export goModifiers
+func goModifiers(id C.int, old, flags C.uint) {
+	w := lookup(id)
+	if w == nil {
+		return
+	}
+	for _, m := range modifierKeysyms {
+		was, is := uint(old)&m.flag != 0, uint(flags)&m.flag != 0
+		switch {
+		case is && !was:
+			w.event <- draw.KeyEvent{m.keysym}
+		case was && !is:
+			w.event <- draw.KeyEvent{-m.keysym}
+		}
+	}
+}
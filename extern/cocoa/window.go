@@ -0,0 +1,363 @@
+// +build darwin
+
+// The cocoa package implements the exp/draw Window interface with
+// a Cocoa window, so that programs written for the x11 package, such
+// as those using the canvas package, also run on Mac OS X. Each
+// window's content view draws the window's image, and its mouse and
+// keyboard events are sent as draw events, with the keys that do
+// not send characters given as the X keysyms that the x11 package
+// sends for them.
+//
+// Cocoa must be run on the program's main thread, so a program
+// using the package must call Main from its main function, doing
+// its own work in the function that it passes to Main:
+//
+//	func main() {
+//		cocoa.Main(func() {
+//			win, err := cocoa.NewWindow()
+//			...
+//		})
+//	}
+package cocoa
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework Cocoa
+#include <stdlib.h>
+#include "cocoa.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"exp/draw"
+	"image"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+func init() {
+	// Keep the main goroutine on the main
+	// thread, for Main to run Cocoa on.
+	runtime.LockOSThread()
+}
+
+// Main runs f in a new goroutine, and runs Cocoa's event loop on
+// the main thread until f returns. It must be called from the
+// program's main goroutine, before any windows are created.
+func Main(f func()) {
+	go func() {
+		defer C.stopApp()
+		f()
+	}()
+	C.runApp()
+}
+
+// The default size of a window's content, as for
+// the x11 package.
+const (
+	windowHeight = 600
+	windowWidth  = 800
+)
+
+// A Window is a draw.Window that also lets the
+// application set its title and size.
+type Window interface {
+	draw.Window
+
+	// SetTitle sets the title shown in the window's title bar.
+	SetTitle(title string) error
+
+	// SetSize asks for the window's content to be resized
+	// to the given size. As for a resize made by the user,
+	// a draw.ConfigEvent is sent when it has been.
+	SetSize(size image.Point) error
+}
+
+// A window is a Window. Its methods hold
+// mu while they use the fields after it.
+type window struct {
+	id    int
+	event chan interface{}
+	mouse chan draw.MouseEvent
+
+	mu     sync.Mutex
+	img    *image.RGBA
+	pix    unsafe.Pointer // the pixels of the window, as of the last flush, in C memory.
+	closed bool
+
+	// Used only by the main thread.
+	mouseState draw.MouseEvent
+	wheel      float64 // wheel movement not yet sent, in notches.
+}
+
+var (
+	windowsMu sync.Mutex
+	windows   = make(map[int]*window) // keyed by id.
+	nextID    = 1
+)
+
+func lookup(id C.int) *window {
+	windowsMu.Lock()
+	defer windowsMu.Unlock()
+	return windows[int(id)]
+}
+
+// NewWindow returns a new Window, backed by a newly
+// created and shown Cocoa window. It must not be called
+// by the main thread; see Main.
+func NewWindow() (Window, error) {
+	w := &window{
+		img:   image.NewRGBA(image.Rect(0, 0, windowWidth, windowHeight)),
+		pix:   C.calloc(4*windowWidth*windowHeight, 1),
+		event: make(chan interface{}),
+		mouse: make(chan draw.MouseEvent),
+	}
+	windowsMu.Lock()
+	w.id = nextID
+	nextID++
+	windows[w.id] = w
+	windowsMu.Unlock()
+	go bufferMouse(w.mouse, w.event)
+	if C.newWindow(C.int(w.id), windowWidth, windowHeight) == 0 {
+		windowsMu.Lock()
+		delete(windows, w.id)
+		windowsMu.Unlock()
+		close(w.mouse)
+		C.free(w.pix)
+		return nil, errors.New("cannot create window")
+	}
+	return w, nil
+}
+
+//export goPaint
+func goPaint(id C.int, ctx unsafe.Pointer) {
+	w := lookup(id)
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	r := w.img.Bounds()
+	C.drawImage(ctx, w.pix, C.int(r.Dx()), C.int(r.Dy()))
+}
+
+//export goResize
+func goResize(id C.int, width, height C.int) {
+	w := lookup(id)
+	if w == nil {
+		return
+	}
+	if w.resize(int(width), int(height)) {
+		w.event <- draw.ConfigEvent{image.Config{image.RGBAColorModel, int(width), int(height)}}
+	}
+}
+
+//export goMouse
+func goMouse(id C.int, buttons, x, y C.int) {
+	w := lookup(id)
+	if w == nil {
+		return
+	}
+	// Cocoa numbers the buttons left, right, middle;
+	// X, and so draw, numbers them left, middle, right.
+	b := 0
+	if buttons&1 != 0 {
+		b |= 1
+	}
+	if buttons&4 != 0 {
+		b |= 2
+	}
+	if buttons&2 != 0 {
+		b |= 4
+	}
+	// Keep the wheel buttons, which are
+	// pressed and released by goScroll.
+	w.mouseState.Buttons = w.mouseState.Buttons&^7 | b
+	w.mouseState.Loc = image.Pt(int(x), int(y))
+	w.sendMouse()
+}
+
+//export goScroll
+func goScroll(id C.int, dy C.double, precise C.int) {
+	w := lookup(id)
+	if w == nil {
+		return
+	}
+	// Each notch of the wheel sends a press and release of button
+	// 4 (up) or 5 (down), as in X. Trackpads send movements in
+	// pixels, which are added up until they make a notch.
+	notches := float64(dy)
+	if precise != 0 {
+		notches /= pixelsPerNotch
+	}
+	w.wheel += notches
+	for w.wheel >= 1 || w.wheel <= -1 {
+		mask := 1 << 3
+		if w.wheel < 0 {
+			mask = 1 << 4
+			w.wheel++
+		} else {
+			w.wheel--
+		}
+		w.mouseState.Buttons |= mask
+		w.sendMouse()
+		w.mouseState.Buttons &^= mask
+		w.sendMouse()
+	}
+}
+
+// pixelsPerNotch is the distance scrolled on a trackpad
+// that is taken to be one notch of a mouse wheel.
+const pixelsPerNotch = 10
+
+//export goClosed
+func goClosed(id C.int) {
+	w := lookup(id)
+	if w == nil {
+		return
+	}
+	windowsMu.Lock()
+	delete(windows, w.id)
+	windowsMu.Unlock()
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	close(w.mouse)
+}
+
+func (w *window) sendMouse() {
+	w.mouseState.Nsec = time.Now().UnixNano()
+	w.mouse <- w.mouseState
+}
+
+// resize replaces the image of the window with a new one of size
+// width×height, keeping what it holds where they overlap, and
+// reports whether the size has changed. The window is not drawn
+// again until the client flushes the new image.
+func (w *window) resize(width, height int) bool {
+	r := image.Rect(0, 0, width, height)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if r.Eq(w.img.Bounds()) || r.Empty() {
+		return false
+	}
+	img := image.NewRGBA(r)
+	draw.DrawMask(img, r, w.img, image.ZP, nil, image.ZP, draw.Src)
+	w.img = img
+	C.free(w.pix)
+	w.pix = C.calloc(C.size_t(4*width*height), 1)
+	w.copyPix(r)
+	return true
+}
+
+// copyPix copies the area r of w.img into w.pix.
+// It is called with w.mu held.
+func (w *window) copyPix(r image.Rectangle) {
+	stride := w.img.Stride
+	n := stride * w.img.Rect.Dy()
+	// View the C memory as a slice, to copy into it.
+	pix := (*[1 << 30]byte)(w.pix)[:n:n]
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := w.img.PixOffset(r.Min.X, y)
+		copy(pix[i:i+4*r.Dx()], w.img.Pix[i:])
+	}
+}
+
+// Screen returns the image of the window. After a draw.ConfigEvent
+// has been received, the image returned will be a new one, of
+// the new size of the window.
+func (w *window) Screen() draw.Image {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.img
+}
+
+func (w *window) FlushImageRect(r image.Rectangle) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	r = r.Intersect(w.img.Bounds())
+	w.copyPix(r)
+	w.mu.Unlock()
+	if !r.Empty() {
+		C.invalidate(C.int(w.id), C.int(r.Min.X), C.int(r.Min.Y), C.int(r.Dx()), C.int(r.Dy()))
+	}
+}
+
+func (w *window) FlushImage() {
+	w.FlushImageRect(w.Screen().Bounds())
+}
+
+func (w *window) EventChan() <-chan interface{} {
+	return w.event
+}
+
+// Close closes the window. The event
+// channel is closed once it has been.
+func (w *window) Close() error {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if !closed {
+		C.closeWindow(C.int(w.id))
+	}
+	return nil
+}
+
+func (w *window) SetTitle(title string) error {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return errClosed
+	}
+	// setTitle frees the string.
+	C.setTitle(C.int(w.id), C.CString(title))
+	return nil
+}
+
+func (w *window) SetSize(size image.Point) error {
+	if size.X <= 0 || size.Y <= 0 {
+		return errors.New("invalid window size")
+	}
+	C.setSize(C.int(w.id), C.int(size.X), C.int(size.Y))
+	return nil
+}
+
+var errClosed = errors.New("window closed")
+
+// bufferMouse sends the mouse events received on mc to out,
+// queueing them, so that the main thread is not held up while
+// the client is busy; events in which only the position of
+// the mouse has changed replace the last one queued. When mc is
+// closed, out is closed once the queue has been sent.
+func bufferMouse(mc <-chan draw.MouseEvent, out chan<- interface{}) {
+	var q []draw.MouseEvent
+	for mc != nil || len(q) > 0 {
+		var send chan<- interface{}
+		var next draw.MouseEvent
+		if len(q) > 0 {
+			send, next = out, q[0]
+		}
+		select {
+		case m, ok := <-mc:
+			if !ok {
+				mc = nil
+				break
+			}
+			if n := len(q); n > 0 && q[n-1].Buttons == m.Buttons {
+				q[n-1] = m
+			} else {
+				q = append(q, m)
+			}
+		case send <- next:
+			q = q[1:]
+		}
+	}
+	close(out)
+}
@@ -0,0 +1,126 @@
+package draw
+
+// Adapters between this package's geometry, operators and images
+// and those of the standard library's image and image/draw packages,
+// so that images made by code written for the standard library can
+// be drawn with DrawMask, and images of this package passed to it.
+
+import (
+	"image"
+	"image/color"
+	stddraw "image/draw"
+)
+
+// Std returns p as an image.Point.
+func (p Point) Std() image.Point { return image.Point{p.X, p.Y} }
+
+// StdPoint returns the image.Point p as a Point.
+func StdPoint(p image.Point) Point { return Point{p.X, p.Y} }
+
+// Std returns r as an image.Rectangle.
+func (r Rectangle) Std() image.Rectangle { return image.Rectangle{r.Min.Std(), r.Max.Std()} }
+
+// StdRect returns the image.Rectangle r as a Rectangle.
+func StdRect(r image.Rectangle) Rectangle { return Rectangle{StdPoint(r.Min), StdPoint(r.Max)} }
+
+// Std returns op as the equivalent image/draw operator.
+func (op Op) Std() stddraw.Op {
+	if op == Src {
+		return stddraw.Src
+	}
+	return stddraw.Over
+}
+
+// StdOp returns the image/draw operator op as an Op.
+func StdOp(op stddraw.Op) Op {
+	if op == stddraw.Src {
+		return Src
+	}
+	return Over
+}
+
+// A StdImage is an image as the standard library's image
+// package defines them, with bounds that need not start at
+// the origin, rather than a width and height.
+type StdImage interface {
+	ColorModel() color.Model
+	Bounds() image.Rectangle
+	At(x, y int) color.Color
+}
+
+// A source is an image as this package uses them,
+// starting at the origin, with a width and height.
+type source interface {
+	ColorModel() color.Model
+	Width() int
+	Height() int
+	At(x, y int) color.Color
+}
+
+// FromStd returns an Image that shows img, with the top left
+// of img's bounds at the origin, for drawing with DrawMask.
+// If img is an image/draw Image, setting a pixel of the result
+// sets the corresponding pixel of img; otherwise it does nothing.
+func FromStd(img StdImage) Image {
+	return fromStd{img, img.Bounds()}
+}
+
+type fromStd struct {
+	img StdImage
+	r   image.Rectangle
+}
+
+func (m fromStd) ColorModel() color.Model { return m.img.ColorModel() }
+
+func (m fromStd) Width() int { return m.r.Dx() }
+
+func (m fromStd) Height() int { return m.r.Dy() }
+
+func (m fromStd) At(x, y int) color.Color { return m.img.At(x+m.r.Min.X, y+m.r.Min.Y) }
+
+func (m fromStd) Set(x, y int, c color.Color) {
+	if dst, ok := m.img.(stddraw.Image); ok {
+		dst.Set(x+m.r.Min.X, y+m.r.Min.Y, c)
+	}
+}
+
+// ToStd returns an image/draw Image that shows img, with
+// bounds from the origin to img's width and height, for
+// code written for the standard library. If img is not an
+// Image, setting a pixel of the result does nothing.
+func ToStd(img source) stddraw.Image {
+	if m, ok := img.(fromStd); ok && m.r.Min == image.ZP {
+		if dst, ok := m.img.(stddraw.Image); ok {
+			return dst
+		}
+	}
+	return toStd{img}
+}
+
+type toStd struct {
+	img source
+}
+
+func (m toStd) ColorModel() color.Model { return m.img.ColorModel() }
+
+func (m toStd) Bounds() image.Rectangle { return image.Rect(0, 0, m.img.Width(), m.img.Height()) }
+
+func (m toStd) At(x, y int) color.Color { return m.img.At(x, y) }
+
+func (m toStd) Set(x, y int, c color.Color) {
+	if dst, ok := m.img.(Image); ok {
+		dst.Set(x, y, c)
+	}
+}
+
+// StdDrawMask is like DrawMask, but takes the rectangle and
+// points as image types, and the source and mask as standard
+// library images, each aligned as by FromStd. A nil mask is
+// treated as opaque.
+func StdDrawMask(dst Image, r image.Rectangle, src StdImage, sp image.Point, mask StdImage, mp image.Point, op Op) {
+	var m image.Image
+	if mask != nil {
+		m = FromStd(mask)
+	}
+	DrawMask(dst, StdRect(r), FromStd(src), StdPoint(sp), m, StdPoint(mp), op)
+}
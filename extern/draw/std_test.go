@@ -0,0 +1,72 @@
+package draw
+
+import (
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	"testing"
+)
+
+func TestStdGeometry(t *testing.T) {
+	r := Rect(-1, 2, 30, 40)
+	if got := r.Std(); got != image.Rect(-1, 2, 30, 40) {
+		t.Errorf("Rectangle.Std: got %v", got)
+	}
+	if got := StdRect(r.Std()); !got.Eq(r) {
+		t.Errorf("StdRect: got %v, want %v", got, r)
+	}
+	if got := StdPoint(image.Pt(3, 4)); !got.Eq(Pt(3, 4)) {
+		t.Errorf("StdPoint: got %v", got)
+	}
+	for _, op := range []Op{Over, Src} {
+		if got := StdOp(op.Std()); got != op {
+			t.Errorf("StdOp(%d.Std()): got %d", op, got)
+		}
+	}
+}
+
+// stdImage is a standard library image, of
+// bounds r, that records the pixels set in it.
+type stdImage struct {
+	r   image.Rectangle
+	set map[image.Point]color.Color
+}
+
+func (m *stdImage) ColorModel() color.Model { return color.RGBAModel }
+
+func (m *stdImage) Bounds() image.Rectangle { return m.r }
+
+func (m *stdImage) At(x, y int) color.Color {
+	if c, ok := m.set[image.Pt(x, y)]; ok {
+		return c
+	}
+	return color.RGBA{uint8(x), uint8(y), 0, 0xff}
+}
+
+func (m *stdImage) Set(x, y int, c color.Color) {
+	m.set[image.Pt(x, y)] = c
+}
+
+func TestFromStd(t *testing.T) {
+	std := &stdImage{image.Rect(10, 20, 15, 30), make(map[image.Point]color.Color)}
+	img := FromStd(std)
+	if img.Width() != 5 || img.Height() != 10 {
+		t.Fatalf("FromStd: got size %dx%d, want 5x10", img.Width(), img.Height())
+	}
+	if want := (color.RGBA{11, 22, 0, 0xff}); !eq(img.At(1, 2), want) {
+		t.Errorf("FromStd: at (1, 2) got %v, want %v", img.At(1, 2), want)
+	}
+	img.Set(0, 0, color.RGBA{1, 2, 3, 4})
+	if c, ok := std.set[image.Pt(10, 20)]; !ok || !eq(c, color.RGBA{1, 2, 3, 4}) {
+		t.Errorf("FromStd: Set set %v", std.set)
+	}
+
+	back := ToStd(img)
+	if back.Bounds() != image.Rect(0, 0, 5, 10) {
+		t.Errorf("ToStd: got bounds %v", back.Bounds())
+	}
+	if !eq(back.At(1, 2), img.At(1, 2)) {
+		t.Errorf("ToStd: at (1, 2) got %v, want %v", back.At(1, 2), img.At(1, 2))
+	}
+	var _ stddraw.Image = back
+}
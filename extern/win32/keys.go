@@ -0,0 +1,115 @@
+// +build windows
+
+package win32
+
+import (
+	"exp/draw"
+	"unicode"
+	"unicode/utf16"
+)
+
+// vkKeysyms maps the virtual key codes of the keys that do not
+// send characters, or whose characters are control codes, to the
+// X keysyms that the x11 package sends for them, so that programs
+// see the same values on both.
+var vkKeysyms = map[int]int{
+	0x08: 0xff08, // VK_BACK: BackSpace.
+	0x09: 0xff09, // VK_TAB: Tab.
+	0x0d: 0xff0d, // VK_RETURN: Return.
+	0x10: 0xffe1, // VK_SHIFT: Shift_L.
+	0x11: 0xffe3, // VK_CONTROL: Control_L.
+	0x12: 0xffe9, // VK_MENU: Alt_L.
+	0x13: 0xff13, // VK_PAUSE: Pause.
+	0x14: 0xffe5, // VK_CAPITAL: Caps_Lock.
+	0x1b: 0xff1b, // VK_ESCAPE: Escape.
+	0x21: 0xff55, // VK_PRIOR: Prior (Page Up).
+	0x22: 0xff56, // VK_NEXT: Next (Page Down).
+	0x23: 0xff57, // VK_END: End.
+	0x24: 0xff50, // VK_HOME: Home.
+	0x25: 0xff51, // VK_LEFT: Left.
+	0x26: 0xff52, // VK_UP: Up.
+	0x27: 0xff53, // VK_RIGHT: Right.
+	0x28: 0xff54, // VK_DOWN: Down.
+	0x2d: 0xff63, // VK_INSERT: Insert.
+	0x2e: 0xffff, // VK_DELETE: Delete.
+	0x5b: 0xffeb, // VK_LWIN: Super_L.
+	0x5c: 0xffec, // VK_RWIN: Super_R.
+	0x90: 0xff7f, // VK_NUMLOCK: Num_Lock.
+}
+
+// Virtual key codes used by keysym.
+const (
+	vkControl = 0x11
+	vkMenu    = 0x12
+	vkF1      = 0x70
+	vkF24     = 0x87
+)
+
+// keysym returns the keysym sent for the key with virtual key code
+// vk, or 0 if the key sends characters, which come in wmChar
+// messages, translated by the keyboard layout.
+func keysym(vk int) int {
+	if k, ok := vkKeysyms[vk]; ok {
+		return k
+	}
+	if vk >= vkF1 && vk <= vkF24 {
+		return 0xffbe + vk - vkF1 // F1 to F24.
+	}
+	if keyDown(vkControl) && !keyDown(vkMenu) {
+		// With Control held, letters and digits send control codes,
+		// or nothing, rather than themselves, so send the key's own
+		// character, as X does, leaving Control to the modifiers.
+		switch {
+		case vk >= 'A' && vk <= 'Z':
+			return int(unicode.ToLower(rune(vk)))
+		case vk >= '0' && vk <= '9':
+			return vk
+		}
+	}
+	return 0
+}
+
+// handleKey handles a key press or release of the key
+// with virtual key code vk. It is called by the thread
+// that owns the window.
+func (w *window) handleKey(vk int, press bool) {
+	if vk < 0 || vk >= len(w.chars) {
+		return
+	}
+	if press {
+		if k := keysym(vk); k != 0 {
+			w.chars[vk] = k
+			w.event <- draw.KeyEvent{k}
+		}
+		// Otherwise the key's character is sent
+		// by the wmChar message that follows.
+		w.lastVK = vk
+		return
+	}
+	// Release the character that the press sent.
+	if k := w.chars[vk]; k != 0 {
+		w.chars[vk] = 0
+		w.event <- draw.KeyEvent{-k}
+	}
+}
+
+// handleChar handles the UTF-16 code unit c, sent in a wmChar
+// message for the key press handled most recently.
+func (w *window) handleChar(c uint16) {
+	r := rune(c)
+	switch {
+	case utf16.IsSurrogate(r) && w.surrogate == 0:
+		// The first half of a pair; wait for the second.
+		w.surrogate = r
+		return
+	case utf16.IsSurrogate(r):
+		r = utf16.DecodeRune(w.surrogate, r)
+		w.surrogate = 0
+	}
+	if r < 0x20 || r == 0x7f {
+		// Control codes are sent as keysyms by handleKey.
+		return
+	}
+	w.chars[w.lastVK] = int(r)
+	w.event <- draw.KeyEvent{int(r)}
+}
@@ -0,0 +1,220 @@
+// +build windows
+
+package win32
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	gdi32    = syscall.NewLazyDLL("gdi32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procAdjustWindowRect  = user32.NewProc("AdjustWindowRect")
+	procBeginPaint        = user32.NewProc("BeginPaint")
+	procCreateWindowExW   = user32.NewProc("CreateWindowExW")
+	procDefWindowProcW    = user32.NewProc("DefWindowProcW")
+	procDestroyWindow     = user32.NewProc("DestroyWindow")
+	procDispatchMessageW  = user32.NewProc("DispatchMessageW")
+	procEndPaint          = user32.NewProc("EndPaint")
+	procGetKeyState       = user32.NewProc("GetKeyState")
+	procGetMessageW       = user32.NewProc("GetMessageW")
+	procInvalidateRect    = user32.NewProc("InvalidateRect")
+	procLoadCursorW       = user32.NewProc("LoadCursorW")
+	procPostMessageW      = user32.NewProc("PostMessageW")
+	procPostQuitMessage   = user32.NewProc("PostQuitMessage")
+	procRegisterClassExW  = user32.NewProc("RegisterClassExW")
+	procReleaseCapture    = user32.NewProc("ReleaseCapture")
+	procSetCapture        = user32.NewProc("SetCapture")
+	procSetWindowPos      = user32.NewProc("SetWindowPos")
+	procSetWindowTextW    = user32.NewProc("SetWindowTextW")
+	procShowWindow        = user32.NewProc("ShowWindow")
+	procTranslateMessage  = user32.NewProc("TranslateMessage")
+	procSetDIBitsToDevice = gdi32.NewProc("SetDIBitsToDevice")
+	procGetModuleHandleW  = kernel32.NewProc("GetModuleHandleW")
+)
+
+// Window messages.
+const (
+	wmDestroy     = 0x0002
+	wmSize        = 0x0005
+	wmPaint       = 0x000f
+	wmClose       = 0x0010
+	wmEraseBkgnd  = 0x0014
+	wmKeyDown     = 0x0100
+	wmKeyUp       = 0x0101
+	wmChar        = 0x0102
+	wmSysKeyDown  = 0x0104
+	wmSysKeyUp    = 0x0105
+	wmMouseMove   = 0x0200
+	wmLButtonDown = 0x0201
+	wmLButtonUp   = 0x0202
+	wmRButtonDown = 0x0204
+	wmRButtonUp   = 0x0205
+	wmMButtonDown = 0x0207
+	wmMButtonUp   = 0x0208
+	wmMouseWheel  = 0x020a
+	wmApp         = 0x8000
+
+	// wmSetTitle is sent to a window by SetTitle, so that
+	// its title is set by the thread that owns it.
+	wmSetTitle = wmApp + 1
+)
+
+const (
+	wsOverlappedWindow = 0x00cf0000
+	cwUseDefault       = 0x80000000
+	swShow             = 5
+	idcArrow           = 32512
+	csHRedraw          = 0x0002
+	csVRedraw          = 0x0001
+	swpNoMove          = 0x0002
+	swpNoZOrder        = 0x0004
+	swpAsyncWindowPos  = 0x4000
+	dibRGBColors       = 0
+	biRGB              = 0
+	wheelDelta         = 120
+)
+
+type point struct {
+	x, y int32
+}
+
+type rect struct {
+	left, top, right, bottom int32
+}
+
+type msg struct {
+	hwnd    syscall.Handle
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      point
+}
+
+type wndClassEx struct {
+	size       uint32
+	style      uint32
+	wndProc    uintptr
+	clsExtra   int32
+	wndExtra   int32
+	instance   syscall.Handle
+	icon       syscall.Handle
+	cursor     syscall.Handle
+	background syscall.Handle
+	menuName   *uint16
+	className  *uint16
+	iconSm     syscall.Handle
+}
+
+type paintStruct struct {
+	hdc        syscall.Handle
+	erase      int32
+	paint      rect
+	restore    int32
+	incUpdate  int32
+	rgbReserve [32]byte
+}
+
+type bitmapInfoHeader struct {
+	size          uint32
+	width         int32
+	height        int32
+	planes        uint16
+	bitCount      uint16
+	compression   uint32
+	sizeImage     uint32
+	xPelsPerMeter int32
+	yPelsPerMeter int32
+	clrUsed       uint32
+	clrImportant  uint32
+}
+
+// bitmapInfo is a BITMAPINFO with no colour table,
+// as is used for 32-bit images.
+type bitmapInfo struct {
+	header bitmapInfoHeader
+	colors [1]uint32
+}
+
+func boolArg(b bool) uintptr {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func getModuleHandle() syscall.Handle {
+	h, _, _ := procGetModuleHandleW.Call(0)
+	return syscall.Handle(h)
+}
+
+func registerClass(wc *wndClassEx) error {
+	wc.size = uint32(unsafe.Sizeof(*wc))
+	r, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(wc)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func createWindow(class, title *uint16, style uint32, w, h int32, instance syscall.Handle) (syscall.Handle, error) {
+	r, _, err := procCreateWindowExW.Call(
+		0,
+		uintptr(unsafe.Pointer(class)),
+		uintptr(unsafe.Pointer(title)),
+		uintptr(style),
+		cwUseDefault, cwUseDefault,
+		uintptr(w), uintptr(h),
+		0, 0,
+		uintptr(instance),
+		0)
+	if r == 0 {
+		return 0, err
+	}
+	return syscall.Handle(r), nil
+}
+
+// adjustWindowRect returns the size of a window with the given
+// style whose client area, inside its frame, has size w×h.
+func adjustWindowRect(w, h int32, style uint32) (int32, int32) {
+	r := rect{0, 0, w, h}
+	procAdjustWindowRect.Call(uintptr(unsafe.Pointer(&r)), uintptr(style), 0)
+	return r.right - r.left, r.bottom - r.top
+}
+
+func defWindowProc(hwnd syscall.Handle, m uint32, wParam, lParam uintptr) uintptr {
+	r, _, _ := procDefWindowProcW.Call(uintptr(hwnd), uintptr(m), wParam, lParam)
+	return r
+}
+
+func getMessage(m *msg) bool {
+	r, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(m)), 0, 0, 0)
+	// GetMessage returns -1 on error, and 0 for WM_QUIT.
+	return int32(r) > 0
+}
+
+func translateMessage(m *msg) {
+	procTranslateMessage.Call(uintptr(unsafe.Pointer(m)))
+}
+
+func dispatchMessage(m *msg) {
+	procDispatchMessageW.Call(uintptr(unsafe.Pointer(m)))
+}
+
+func postMessage(hwnd syscall.Handle, m uint32, wParam, lParam uintptr) {
+	procPostMessageW.Call(uintptr(hwnd), uintptr(m), wParam, lParam)
+}
+
+func invalidateRect(hwnd syscall.Handle, r *rect) {
+	procInvalidateRect.Call(uintptr(hwnd), uintptr(unsafe.Pointer(r)), 0)
+}
+
+// keyDown reports whether the key with virtual key code vk is held down.
+func keyDown(vk int) bool {
+	r, _, _ := procGetKeyState.Call(uintptr(vk))
+	return r&0x8000 != 0
+}
@@ -0,0 +1,445 @@
+// +build windows
+
+// The win32 package implements the exp/draw Window interface
+// with a native Windows window, so that programs written for the
+// x11 package, such as those using the canvas package, also run on
+// Windows. The image of the window is drawn into a bitmap in the
+// window's own pixel order and painted with SetDIBitsToDevice;
+// mouse and keyboard messages are sent as draw events, with the
+// keys that do not send characters given as the X keysyms that
+// the x11 package sends for them.
+package win32
+
+import (
+	"errors"
+	"exp/draw"
+	"image"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The default size of a window's client area, as for
+// the x11 package.
+const (
+	windowHeight = 600
+	windowWidth  = 800
+)
+
+// A Window is a draw.Window that also lets the
+// application set its title and size.
+type Window interface {
+	draw.Window
+
+	// SetTitle sets the title shown in the window's title bar.
+	SetTitle(title string) error
+
+	// SetSize asks for the window's client area to be resized
+	// to the given size. As for a resize made by the user,
+	// a draw.ConfigEvent is sent when it has been.
+	SetSize(size image.Point) error
+}
+
+// A window is a Window, owned by the thread, locked
+// to its goroutine, that runs its message loop.
+type window struct {
+	hwnd  syscall.Handle
+	event chan interface{}
+	mouse chan draw.MouseEvent
+
+	mu     sync.Mutex
+	img    *image.RGBA
+	bits   []byte // the pixels of the window, as of the last flush, in BGRA order.
+	closed bool
+	title  string // to be set by the owning thread; see SetTitle.
+
+	// Used only by the owning thread.
+	shown      bool // NewWindow has returned, so events may be sent.
+	mouseState draw.MouseEvent
+	wheel      int      // wheel movement not yet sent, in units of 1/wheelDelta notches.
+	chars      [256]int // the character or keysym sent by each key held down.
+	lastVK     int      // the key whose press was handled most recently.
+	surrogate  rune     // the first half of a character sent in two wmChar messages.
+}
+
+const className = "rog-go-win32"
+
+var (
+	registerOnce sync.Once
+	registerErr  error
+
+	// windows maps the handle of each
+	// window to the window itself.
+	windowsMu sync.Mutex
+	windows   = make(map[syscall.Handle]*window)
+)
+
+// NewWindow returns a new Window, backed by a newly
+// created and shown window on the desktop.
+func NewWindow() (Window, error) {
+	w := &window{
+		img:   image.NewRGBA(image.Rect(0, 0, windowWidth, windowHeight)),
+		bits:  make([]byte, 4*windowWidth*windowHeight),
+		event: make(chan interface{}),
+		mouse: make(chan draw.MouseEvent),
+	}
+	errc := make(chan error)
+	go w.run(errc)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	go bufferMouse(w.mouse, w.event)
+	return w, nil
+}
+
+// run creates the window and runs its message loop until
+// it is destroyed, sending any error creating it on errc.
+// Windows belong to the thread that creates them, and their
+// messages are only delivered to it, so run keeps to one thread.
+func (w *window) run(errc chan<- error) {
+	runtime.LockOSThread()
+	instance := getModuleHandle()
+	registerOnce.Do(func() {
+		cursor, _, _ := procLoadCursorW.Call(0, idcArrow)
+		registerErr = registerClass(&wndClassEx{
+			style:     csHRedraw | csVRedraw,
+			wndProc:   syscall.NewCallback(wndProc),
+			instance:  instance,
+			cursor:    syscall.Handle(cursor),
+			className: syscall.StringToUTF16Ptr(className),
+		})
+	})
+	if registerErr != nil {
+		errc <- registerErr
+		return
+	}
+	// Register the window while it is being created, so
+	// that wndProc can find it for the messages sent by
+	// CreateWindowEx itself.
+	windowsMu.Lock()
+	creating = w
+	windowsMu.Unlock()
+	ww, wh := adjustWindowRect(windowWidth, windowHeight, wsOverlappedWindow)
+	hwnd, err := createWindow(syscall.StringToUTF16Ptr(className), syscall.StringToUTF16Ptr(""), wsOverlappedWindow, ww, wh, instance)
+	windowsMu.Lock()
+	creating = nil
+	if err == nil {
+		w.hwnd = hwnd
+		windows[hwnd] = w
+	}
+	windowsMu.Unlock()
+	if err != nil {
+		errc <- err
+		return
+	}
+	errc <- nil
+	w.shown = true
+	procShowWindow.Call(uintptr(hwnd), swShow)
+
+	var m msg
+	for getMessage(&m) {
+		translateMessage(&m)
+		dispatchMessage(&m)
+	}
+	close(w.mouse)
+}
+
+// creating holds the window being created by CreateWindowEx,
+// before its handle is known; guarded by windowsMu.
+var creating *window
+
+func lookup(hwnd syscall.Handle) *window {
+	windowsMu.Lock()
+	defer windowsMu.Unlock()
+	if w := windows[hwnd]; w != nil {
+		return w
+	}
+	if creating != nil {
+		creating.hwnd = hwnd
+		windows[hwnd] = creating
+		return creating
+	}
+	return nil
+}
+
+// wndProc is the window procedure of all windows, called
+// by the thread that owns the window hwnd for its messages.
+func wndProc(hwnd syscall.Handle, m uint32, wParam, lParam uintptr) uintptr {
+	w := lookup(hwnd)
+	if w == nil {
+		return defWindowProc(hwnd, m, wParam, lParam)
+	}
+	switch m {
+	case wmPaint:
+		w.paint()
+		return 0
+	case wmEraseBkgnd:
+		// Every pixel is painted, so there is
+		// no need to erase them first.
+		return 1
+	case wmSize:
+		width, height := int(lParam&0xffff), int(lParam>>16&0xffff)
+		if w.resize(width, height) && w.shown {
+			w.event <- draw.ConfigEvent{image.Config{image.RGBAColorModel, width, height}}
+		}
+		return 0
+	case wmMouseMove:
+		w.mouseState.Loc = image.Pt(int(int16(lParam)), int(int16(lParam>>16)))
+		w.sendMouse()
+		return 0
+	case wmLButtonDown, wmLButtonUp, wmMButtonDown, wmMButtonUp, wmRButtonDown, wmRButtonUp:
+		w.mouseState.Loc = image.Pt(int(int16(lParam)), int(int16(lParam>>16)))
+		w.button(m)
+		return 0
+	case wmMouseWheel:
+		// Each notch of the wheel sends a press and release of
+		// button 4 (up) or 5 (down), as in X, at the last position
+		// of the mouse in the window, as the message's own is
+		// relative to the screen. Smaller movements, as sent by
+		// some touch pads, are added up until they make a notch.
+		w.wheel += int(int16(wParam >> 16))
+		for w.wheel >= wheelDelta || w.wheel <= -wheelDelta {
+			mask := 1 << 3
+			if w.wheel < 0 {
+				mask = 1 << 4
+				w.wheel += wheelDelta
+			} else {
+				w.wheel -= wheelDelta
+			}
+			w.mouseState.Buttons |= mask
+			w.sendMouse()
+			w.mouseState.Buttons &^= mask
+			w.sendMouse()
+		}
+		return 0
+	case wmKeyDown, wmSysKeyDown:
+		w.handleKey(int(wParam), true)
+	case wmKeyUp, wmSysKeyUp:
+		w.handleKey(int(wParam), false)
+	case wmChar:
+		w.handleChar(uint16(wParam))
+		return 0
+	case wmSetTitle:
+		w.mu.Lock()
+		title := w.title
+		w.mu.Unlock()
+		procSetWindowTextW.Call(uintptr(hwnd), uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(title))))
+		return 0
+	case wmClose:
+		procDestroyWindow.Call(uintptr(hwnd))
+		return 0
+	case wmDestroy:
+		windowsMu.Lock()
+		delete(windows, hwnd)
+		windowsMu.Unlock()
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		procPostQuitMessage.Call(0)
+		return 0
+	}
+	return defWindowProc(hwnd, m, wParam, lParam)
+}
+
+// button handles the press or release, m, of a mouse button.
+// While any button is held, the mouse is captured, so that a
+// drag that leaves the window is still followed.
+func (w *window) button(m uint32) {
+	var mask int
+	switch m {
+	case wmLButtonDown, wmLButtonUp:
+		mask = 1
+	case wmMButtonDown, wmMButtonUp:
+		mask = 2
+	case wmRButtonDown, wmRButtonUp:
+		mask = 4
+	}
+	switch m {
+	case wmLButtonDown, wmMButtonDown, wmRButtonDown:
+		if w.mouseState.Buttons == 0 {
+			procSetCapture.Call(uintptr(w.hwnd))
+		}
+		w.mouseState.Buttons |= mask
+	default:
+		w.mouseState.Buttons &^= mask
+		if w.mouseState.Buttons == 0 {
+			procReleaseCapture.Call()
+		}
+	}
+	w.sendMouse()
+}
+
+func (w *window) sendMouse() {
+	w.mouseState.Nsec = time.Now().UnixNano()
+	w.mouse <- w.mouseState
+}
+
+// paint paints the area of the window that needs it from w.bits.
+func (w *window) paint() {
+	var ps paintStruct
+	hdc, _, _ := procBeginPaint.Call(uintptr(w.hwnd), uintptr(unsafe.Pointer(&ps)))
+	w.mu.Lock()
+	r := w.img.Bounds()
+	bi := bitmapInfo{header: bitmapInfoHeader{
+		width: int32(r.Dx()),
+		// A negative height puts the first row at the top.
+		height:      -int32(r.Dy()),
+		planes:      1,
+		bitCount:    32,
+		compression: biRGB,
+	}}
+	bi.header.size = uint32(unsafe.Sizeof(bi.header))
+	if len(w.bits) > 0 {
+		procSetDIBitsToDevice.Call(
+			hdc,
+			0, 0, uintptr(r.Dx()), uintptr(r.Dy()),
+			0, 0, 0, uintptr(r.Dy()),
+			uintptr(unsafe.Pointer(&w.bits[0])),
+			uintptr(unsafe.Pointer(&bi)),
+			dibRGBColors)
+	}
+	w.mu.Unlock()
+	procEndPaint.Call(uintptr(w.hwnd), uintptr(unsafe.Pointer(&ps)))
+}
+
+// resize replaces the image of the window with a new one of size
+// w×h, keeping what it holds where they overlap, and reports
+// whether the size has changed. The window is not painted again
+// until the client flushes the new image.
+func (w *window) resize(width, height int) bool {
+	r := image.Rect(0, 0, width, height)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if r.Eq(w.img.Bounds()) || r.Empty() {
+		return false
+	}
+	img := image.NewRGBA(r)
+	draw.DrawMask(img, r, w.img, image.ZP, nil, image.ZP, draw.Src)
+	w.img = img
+	w.bits = make([]byte, 4*width*height)
+	w.copyBits(r)
+	return true
+}
+
+// copyBits copies the area r of w.img into w.bits,
+// swapping red and blue. It is called with w.mu held.
+func (w *window) copyBits(r image.Rectangle) {
+	stride := 4 * w.img.Rect.Dx()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		src := w.img.Pix[w.img.PixOffset(r.Min.X, y):]
+		dst := w.bits[y*stride+4*r.Min.X:]
+		for i := 0; i < 4*r.Dx(); i += 4 {
+			dst[i+0] = src[i+2]
+			dst[i+1] = src[i+1]
+			dst[i+2] = src[i+0]
+			dst[i+3] = src[i+3]
+		}
+	}
+}
+
+// Screen returns the image of the window. After a draw.ConfigEvent
+// has been received, the image returned will be a new one, of
+// the new size of the window.
+func (w *window) Screen() draw.Image {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.img
+}
+
+func (w *window) FlushImageRect(r image.Rectangle) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	r = r.Intersect(w.img.Bounds())
+	w.copyBits(r)
+	w.mu.Unlock()
+	// InvalidateRect may be called by any thread; the
+	// window's own is sent wmPaint to paint the area.
+	invalidateRect(w.hwnd, &rect{int32(r.Min.X), int32(r.Min.Y), int32(r.Max.X), int32(r.Max.Y)})
+}
+
+func (w *window) FlushImage() {
+	w.FlushImageRect(w.Screen().Bounds())
+}
+
+func (w *window) EventChan() <-chan interface{} {
+	return w.event
+}
+
+// Close destroys the window. The event
+// channel is closed once it has been.
+func (w *window) Close() error {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return nil
+	}
+	// A window can only be destroyed by its own thread,
+	// so ask it to close, as the user would.
+	postMessage(w.hwnd, wmClose, 0, 0)
+	return nil
+}
+
+func (w *window) SetTitle(title string) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return errClosed
+	}
+	w.title = title
+	w.mu.Unlock()
+	postMessage(w.hwnd, wmSetTitle, 0, 0)
+	return nil
+}
+
+func (w *window) SetSize(size image.Point) error {
+	if size.X <= 0 || size.Y <= 0 {
+		return errors.New("invalid window size")
+	}
+	ww, wh := adjustWindowRect(int32(size.X), int32(size.Y), wsOverlappedWindow)
+	// SWP_ASYNCWINDOWPOS leaves the window's own thread
+	// to resize it, rather than waiting for it to.
+	r, _, err := procSetWindowPos.Call(uintptr(w.hwnd), 0, 0, 0, uintptr(ww), uintptr(wh), swpNoMove|swpNoZOrder|swpAsyncWindowPos)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+var errClosed = errors.New("window closed")
+
+// bufferMouse sends the mouse events received on mc to out,
+// queueing them, so that the window's thread is not held up
+// while the client is busy; events in which only the position of
+// the mouse has changed replace the last one queued. When mc is
+// closed, out is closed once the queue has been sent.
+func bufferMouse(mc <-chan draw.MouseEvent, out chan<- interface{}) {
+	var q []draw.MouseEvent
+	for mc != nil || len(q) > 0 {
+		var send chan<- interface{}
+		var next draw.MouseEvent
+		if len(q) > 0 {
+			send, next = out, q[0]
+		}
+		select {
+		case m, ok := <-mc:
+			if !ok {
+				mc = nil
+				break
+			}
+			if n := len(q); n > 0 && q[n-1].Buttons == m.Buttons {
+				q[n-1] = m
+			} else {
+				q = append(q, m)
+			}
+		case send <- next:
+			q = q[1:]
+		}
+	}
+	close(out)
+}
@@ -26,30 +26,35 @@ import (
 
 type resID uint32 // X resource IDs.
 
-// TODO(nigeltao): Handle window resizes.
+// The initial size of the window; it changes
+// when the window is resized (see resize).
 const (
 	windowHeight = 600
 	windowWidth  = 800
 )
 
+// A conn is a single window on a Display.
 type conn struct {
-	// TODO(nigeltao): Figure out which goroutine should be responsible for closing c,
-	// or if there is a race condition if one goroutine calls c.Close whilst another one
-	// is reading from r, or writing to w.
-	c io.Closer
-	r *bufio.Reader
-	w *bufio.Writer
+	d *Display
 
-	gc, window, root, visual resID
+	gc, window resID
 
 	img        *image.RGBA
 	bufimg     *image.RGBA     // coherent image, as of last FlushImage.
 	dirty      image.Rectangle // of bufimg that needs to be flushed to server.
 	flushLock  sync.Mutex
+	closed     bool // set by Close; guarded by flushLock.
 	event      chan interface{}
-	mouseState draw.MouseEvent
+	mouse      chan draw.MouseEvent
+	mouseState draw.MouseEvent // accessed only by the display's pumper.
 
-	buf [256]byte // General purpose scratch buffer.
+	keyLock  sync.Mutex
+	mods     Modifiers // as of the latest key or mouse event.
+	down     [32]byte  // a bit for each keycode that is held down.
+	noRepeat bool      // drop the presses sent when a key repeats.
+
+	shm   *shmImage // if non-nil, pixels are sent through shared memory (see shmFor).
+	noShm bool      // shared memory cannot be used. Both are used only by the flusher.
 
 	flush     chan bool
 	flushBuf0 [24]byte
@@ -57,21 +62,44 @@ type conn struct {
 }
 
 // flusher runs in its own goroutine, serving both FlushImage calls directly from the exp/draw client
-// and indirectly from X expose events. It paints c.img to the X server via PutImage requests.
+// and indirectly from X expose events. It paints c.img to the X server via PutImage requests,
+// or through shared memory when the server is on the same machine.
 func (c *conn) flusher() {
-	imgr := c.img.Bounds()
 	for {
 		_ = <-c.flush
 		if closed(c.flush) {
+			if c.shm != nil {
+				c.shmFree()
+			}
 			return
 		}
+		c.flushLock.Lock()
+		imgr := c.bufimg.Bounds()
+		c.flushLock.Unlock()
+		shm := c.shmFor(imgr)
+
 		c.flushLock.Lock()
 		dirty := c.dirty
 		c.dirty = image.ZR
 
-		// sanity check against dubious flush rectangles
-		if dirty = dirty.Intersect(imgr); dirty.Empty() {
+		// sanity check against dubious flush rectangles,
+		// and those left over from before a resize.
+		if dirty = dirty.Intersect(c.bufimg.Bounds()); dirty.Empty() {
+			c.flushLock.Unlock()
+			continue
+		}
+
+		if shm != nil && shm.r.Eq(c.bufimg.Bounds()) {
+			shm.copy(c.bufimg, dirty)
+			// Don't hold the lock while waiting for the server,
+			// as the pumper needs it to handle expose events.
 			c.flushLock.Unlock()
+			if shm.put(c, dirty) != nil {
+				// Fall back to sending the pixels over the socket.
+				c.shmFree()
+				c.noShm = true
+				c.FlushImageRect(dirty)
+			}
 			continue
 		}
 
@@ -97,12 +125,17 @@ func (c *conn) flusher() {
 		setU32LE(c.flushBuf0[12:16], 1<<16|uint32(w))
 		c.flushBuf0[21] = 0x18 // depth = 24 bits.
 
+		// The connection is shared with the other windows on the display.
+		c.d.writeLock.Lock()
+
 		// Pix holds the image's pixels. The pixel at (x, y) is Pix[y*Stride+x].
 		stride := c.bufimg.Stride
 		for y := dirty.Min.Y; y < dirty.Max.Y; y++ {
 			setU32LE(c.flushBuf0[16:20], uint32(y<<16|dirty.Min.X))
-			_, err := c.w.Write(c.flushBuf0[0:24])
+			c.d.seq++
+			_, err := c.d.w.Write(c.flushBuf0[0:24])
 			if err != nil {
+				c.d.writeLock.Unlock()
 				c.flushLock.Unlock()
 				return
 			}
@@ -118,14 +151,17 @@ func (c *conn) flusher() {
 					c.flushBuf1[4*i+2] = rgba.R
 				}
 				x += nx
-				_, err := c.w.Write(c.flushBuf1[0 : 4*nx])
+				_, err := c.d.w.Write(c.flushBuf1[0 : 4*nx])
 				if err != nil {
+					c.d.writeLock.Unlock()
 					c.flushLock.Unlock()
 					return
 				}
 			}
 		}
-		if c.w.Flush() != nil {
+		err := c.d.w.Flush()
+		c.d.writeLock.Unlock()
+		if err != nil {
 			c.flushLock.Unlock()
 			return
 		}
@@ -133,15 +169,45 @@ func (c *conn) flusher() {
 	}
 }
 
+// Close destroys the window. No more events are sent
+// for it, but its event channel is not closed.
 func (c *conn) Close() error {
-	// TODO
-	return nil
+	d := c.d
+	d.mu.Lock()
+	delete(d.windows, c.window)
+	d.mu.Unlock()
+	c.flushLock.Lock()
+	if c.closed {
+		c.flushLock.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.flush)
+	c.flushLock.Unlock()
+	var buf [16]byte
+	setU32LE(buf[0:4], 0x00020004) // 0x04 is the DestroyWindow opcode, and the message is 2 x 4 bytes long.
+	setU32LE(buf[4:8], uint32(c.window))
+	setU32LE(buf[8:12], 0x0002003c) // 0x3c is the FreeGC opcode, and the message is 2 x 4 bytes long.
+	setU32LE(buf[12:16], uint32(c.gc))
+	return d.write(buf[:])
 }
 
-func (c *conn) Screen() draw.Image { return c.img }
+// Screen returns the image of the window. After a draw.ConfigEvent
+// has been received, the image returned will be a new one, of
+// the new size of the window.
+func (c *conn) Screen() draw.Image {
+	c.flushLock.Lock()
+	defer c.flushLock.Unlock()
+	return c.img
+}
 
 func (c *conn) FlushImageRect(r image.Rectangle) {
 	c.flushLock.Lock()
+	if c.closed {
+		c.flushLock.Unlock()
+		return
+	}
+	r = r.Intersect(c.img.Bounds())
 	draw.DrawMask(c.bufimg, r, c.img, r.Min, nil, image.ZP, draw.Src)
 	c.dirty = c.dirty.Union(r)
 	// We do the send (the <- operator) in an expression context, rather than in
@@ -164,70 +230,79 @@ func (c *conn) EventChan() <-chan interface{} {
 	return c.event
 }
 
-// pumper runs in its own goroutine, reading X events and demuxing them over the kbd / mouse / resize / quit chans.
-func (c *conn) pumper(mouse chan<- draw.MouseEvent) {
-	var timestamp timeTranslate
-	for {
-		// X events are always 32 bytes long.
-		_, err := io.ReadFull(c.r, c.buf[0:32])
-		if err != nil {
-			// TODO(nigeltao): should draw.Window expose err?
-			// TODO(nigeltao): should we do c.quit<-true? Should c.quit be a buffered channel?
-			// Or is c.quit only for non-exceptional closing (e.g. when the window manager destroys
-			// our window), and not for e.g. an I/O error?
-			break
+// handle demuxes the X event in buf, which is for c, over the kbd / mouse / resize chans.
+// It is called by the display's pumper.
+func (c *conn) handle(buf []byte, timestamp *timeTranslate) {
+	switch buf[0] {
+	case 0x02, 0x03: // Key press, key release.
+		c.handleKey(buf)
+	case 0x04, 0x05: // Button press, button release.
+		c.setMouseModifiers(buf)
+		c.mouseState.Nsec = timestamp.Nanoseconds(getU32LE(buf[4:8]))
+		mask := 1 << (buf[1] - 1)
+		if buf[0] == 0x04 {
+			c.mouseState.Buttons |= mask
+		} else {
+			c.mouseState.Buttons &^= mask
 		}
-		switch c.buf[0] {
-		case 0x02, 0x03: // Key press, key release.
-			// BUG(nigeltao): Keycode to keysym mapping is not implemented.
-
-			// The keycode is in c.buf[1], but as keymaps aren't implemented yet, we'll use the
-			// space character as a placeholder.
-			keysym := int(' ')
-			// TODO(nigeltao): Should we send KeyboardChan ints for Shift/Ctrl/Alt? Should Shift-A send
-			// the same int down the channel as the sent on just the A key?
-			// TODO(nigeltao): How should IME events (e.g. key presses that should generate CJK text) work? Or
-			// is that outside the scope of the draw.Window interface?
-			if c.buf[0] == 0x03 {
-				keysym = -keysym
-			}
-			c.event <- draw.KeyEvent{keysym}
-		case 0x04, 0x05: // Button press, button release.
-			c.mouseState.Nsec = timestamp.Nanoseconds(getU32LE(c.buf[4:8]))
-			mask := 1 << (c.buf[1] - 1)
-			if c.buf[0] == 0x04 {
-				c.mouseState.Buttons |= mask
-			} else {
-				c.mouseState.Buttons &^= mask
-			}
-			mouse <- c.mouseState
-		case 0x06: // Motion notify.
-			c.mouseState.Nsec = timestamp.Nanoseconds(getU32LE(c.buf[4:8]))
-			c.mouseState.Loc.X = int(int16(c.buf[25])<<8 | int16(c.buf[24]))
-			c.mouseState.Loc.Y = int(int16(c.buf[27])<<8 | int16(c.buf[26]))
-			// TODO(nigeltao): update mouseState's timestamp.
-			mouse <- c.mouseState
-		case 0x0c: // Expose.
-			// TODO(nigeltao): Should we ignore the very first expose event? A freshly mapped window
-			// will trigger expose, but until the first c.FlushImage call, there's probably nothing to
-			// paint but black. For an 800x600 window, at 4 bytes per pixel, each repaint writes about
-			// 2MB over the socket.
-			x := int(c.buf[9])<<8 | int(c.buf[8])
-			y := int(c.buf[11])<<8 | int(c.buf[10])
-			w := int(c.buf[13])<<8 | int(c.buf[12])
-			h := int(c.buf[15])<<8 | int(c.buf[14])
-			c.flushLock.Lock()
-			c.dirty = c.dirty.Union(image.Rect(x, y, x+w, x+h))
-			select {
-			case c.flush <- false:
-			default:
-			}
+		c.mouse <- c.mouseState
+	case 0x06: // Motion notify.
+		c.setMouseModifiers(buf)
+		c.mouseState.Nsec = timestamp.Nanoseconds(getU32LE(buf[4:8]))
+		c.mouseState.Loc.X = int(int16(buf[25])<<8 | int16(buf[24]))
+		c.mouseState.Loc.Y = int(int16(buf[27])<<8 | int16(buf[26]))
+		// TODO(nigeltao): update mouseState's timestamp.
+		c.mouse <- c.mouseState
+	case 0x0c: // Expose.
+		// TODO(nigeltao): Should we ignore the very first expose event? A freshly mapped window
+		// will trigger expose, but until the first c.FlushImage call, there's probably nothing to
+		// paint but black. For an 800x600 window, at 4 bytes per pixel, each repaint writes about
+		// 2MB over the socket.
+		x := int(buf[9])<<8 | int(buf[8])
+		y := int(buf[11])<<8 | int(buf[10])
+		w := int(buf[13])<<8 | int(buf[12])
+		h := int(buf[15])<<8 | int(buf[14])
+		c.flushLock.Lock()
+		if c.closed {
 			c.flushLock.Unlock()
-			// TODO(nigeltao): Should we listen to DestroyNotify (0x11) and ResizeRequest (0x19) events?
-			// What about EnterNotify (0x07) and LeaveNotify (0x08)?
+			break
+		}
+		c.dirty = c.dirty.Union(image.Rect(x, y, x+w, x+h))
+		select {
+		case c.flush <- false:
+		default:
+		}
+		c.flushLock.Unlock()
+		// TODO(nigeltao): Should we listen to DestroyNotify (0x11) events?
+		// What about EnterNotify (0x07) and LeaveNotify (0x08)?
+	case 0x16: // Configure notify.
+		// Bytes 20:24 hold the width and height of the window.
+		w := int(buf[21])<<8 | int(buf[20])
+		h := int(buf[23])<<8 | int(buf[22])
+		if c.resize(w, h) {
+			c.event <- draw.ConfigEvent{image.Config{image.RGBAColorModel, w, h}}
 		}
 	}
-	close(c.event)
+}
+
+// resize replaces the images of the window with new ones of
+// size w×h, keeping what they hold where they overlap, and
+// reports whether the size has changed. The window's contents
+// are not drawn again until the client flushes the new image.
+func (c *conn) resize(w, h int) bool {
+	r := image.Rect(0, 0, w, h)
+	c.flushLock.Lock()
+	defer c.flushLock.Unlock()
+	if r.Eq(c.img.Bounds()) || r.Empty() {
+		return false
+	}
+	img := image.NewRGBA(r)
+	draw.DrawMask(img, r, c.img, image.ZP, nil, image.ZP, draw.Src)
+	bufimg := image.NewRGBA(r)
+	draw.DrawMask(bufimg, r, c.bufimg, image.ZP, nil, image.ZP, draw.Src)
+	c.img, c.bufimg = img, bufimg
+	c.dirty = c.dirty.Intersect(r)
+	return true
 }
 
 // connect connects to the X server given by the full X11 display name (e.g.
@@ -350,11 +425,22 @@ func readU32LE(r io.Reader, b []byte) (uint32, error) {
 	return getU32LE(b), nil
 }
 
+// Gets the little-endian representation of u from b[0:2]
+func getU16LE(b []byte) (u uint16) {
+	return uint16(b[0]) | uint16(b[1])<<8
+}
+
 // Gets the little-endian representation of u from b[0:4]
 func getU32LE(b []byte) (u uint32) {
 	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
 }
 
+// setU16LE sets b[0:2] to be the little-endian representation of u.
+func setU16LE(b []byte, u uint16) {
+	b[0] = byte(u & 0xff)
+	b[1] = byte(u >> 8)
+}
+
 // setU32LE sets b[0:4] to be the little-endian representation of u.
 func setU32LE(b []byte, u uint32) {
 	b[0] = byte((u >> 0) & 0xff)
@@ -452,27 +538,27 @@ func checkScreens(r io.Reader, b []byte, n int) (root, visual uint32, err error)
 
 // handshake performs the protocol handshake with the X server, and ensures
 // that the server provides a compatible Screen, Depth, etc.
-func (c *conn) handshake() error {
-	_, err := io.ReadFull(c.r, c.buf[0:8])
+func (d *Display) handshake() error {
+	_, err := io.ReadFull(d.r, d.buf[0:8])
 	if err != nil {
 		return err
 	}
 	// Byte 0:1 should be 1 (success), bytes 2:6 should be 0xb0000000 (major/minor version 11.0).
-	if c.buf[0] != 1 || c.buf[2] != 11 || c.buf[3] != 0 || c.buf[4] != 0 || c.buf[5] != 0 {
+	if d.buf[0] != 1 || d.buf[2] != 11 || d.buf[3] != 0 || d.buf[4] != 0 || d.buf[5] != 0 {
 		return errors.New("unsupported X version")
 	}
 	// Ignore the release number.
-	_, err = io.ReadFull(c.r, c.buf[0:4])
+	_, err = io.ReadFull(d.r, d.buf[0:4])
 	if err != nil {
 		return err
 	}
 	// Read the resource ID base.
-	resourceIdBase, err := readU32LE(c.r, c.buf[0:4])
+	resourceIdBase, err := readU32LE(d.r, d.buf[0:4])
 	if err != nil {
 		return err
 	}
 	// Read the resource ID mask.
-	resourceIdMask, err := readU32LE(c.r, c.buf[0:4])
+	resourceIdMask, err := readU32LE(d.r, d.buf[0:4])
 	if err != nil {
 		return err
 	}
@@ -480,12 +566,12 @@ func (c *conn) handshake() error {
 		return errors.New("X resource ID mask is too small")
 	}
 	// Ignore the motion buffer size.
-	_, err = io.ReadFull(c.r, c.buf[0:4])
+	_, err = io.ReadFull(d.r, d.buf[0:4])
 	if err != nil {
 		return err
 	}
 	// Read the vendor length.
-	vendorLen, err := readU16LE(c.r, c.buf[0:2])
+	vendorLen, err := readU16LE(d.r, d.buf[0:2])
 	if err != nil {
 		return err
 	}
@@ -495,7 +581,7 @@ func (c *conn) handshake() error {
 		return errors.New("unsupported X vendor")
 	}
 	// Read the maximum request length.
-	maxReqLen, err := readU16LE(c.r, c.buf[0:2])
+	maxReqLen, err := readU16LE(d.r, d.buf[0:2])
 	if err != nil {
 		return err
 	}
@@ -503,24 +589,26 @@ func (c *conn) handshake() error {
 		return errors.New("unsupported X maximum request length")
 	}
 	// Read the roots length.
-	rootsLen, err := readU8(c.r, c.buf[0:1])
+	rootsLen, err := readU8(d.r, d.buf[0:1])
 	if err != nil {
 		return err
 	}
 	// Read the pixmap formats length.
-	pixmapFormatsLen, err := readU8(c.r, c.buf[0:1])
+	pixmapFormatsLen, err := readU8(d.r, d.buf[0:1])
 	if err != nil {
 		return err
 	}
 	// Ignore some things that we don't care about (totalling 30 bytes):
 	// imageByteOrder(1), bitmapFormatBitOrder(1), bitmapFormatScanlineUnit(1) bitmapFormatScanlinePad(1),
 	// minKeycode(1), maxKeycode(1), padding(4), vendor(20, hard-coded above).
-	_, err = io.ReadFull(c.r, c.buf[0:30])
+	// The keycodes are kept, for reading the keyboard mapping.
+	_, err = io.ReadFull(d.r, d.buf[0:30])
 	if err != nil {
 		return err
 	}
+	d.minKeycode, d.maxKeycode = d.buf[4], d.buf[5]
 	// Check that we have an agreeable pixmap format.
-	agree, err := checkPixmapFormats(c.r, c.buf[0:8], int(pixmapFormatsLen))
+	agree, err := checkPixmapFormats(d.r, d.buf[0:8], int(pixmapFormatsLen))
 	if err != nil {
 		return err
 	}
@@ -528,22 +616,22 @@ func (c *conn) handshake() error {
 		return errors.New("unsupported X pixmap formats")
 	}
 	// Check that we have an agreeable screen.
-	root, visual, err := checkScreens(c.r, c.buf[0:24], int(rootsLen))
+	root, visual, err := checkScreens(d.r, d.buf[0:24], int(rootsLen))
 	if err != nil {
 		return err
 	}
 	if root == 0 || visual == 0 {
 		return errors.New("unsupported X screen")
 	}
-	c.gc = resID(resourceIdBase)
-	c.window = resID(resourceIdBase + 1)
-	c.root = resID(root)
-	c.visual = resID(visual)
+	d.idBase = resourceIdBase
+	d.idMask = resourceIdMask
+	d.root = resID(root)
+	d.visual = resID(visual)
 	return nil
 }
 
 // NewWindow calls NewWindowDisplay with $DISPLAY.
-func NewWindow() (draw.Window, error) {
+func NewWindow() (Window, error) {
 	display := os.Getenv("DISPLAY")
 	if len(display) == 0 {
 		return nil, errors.New("$DISPLAY not set")
@@ -551,69 +639,16 @@ func NewWindow() (draw.Window, error) {
 	return NewWindowDisplay(display)
 }
 
-// NewWindowDisplay returns a new draw.Context, backed by a newly created and
+// NewWindowDisplay returns a new Window, backed by a newly created and
 // mapped X11 window. The X server to connect to is specified by the display
-// string, such as ":1".
-func NewWindowDisplay(display string) (draw.Window, error) {
-	socket, displayStr, err := connect(display)
+// string, such as ":1". To open more than one window on the same
+// connection, use OpenDisplay and Display.NewWindow instead.
+func NewWindowDisplay(display string) (Window, error) {
+	d, err := OpenDisplay(display)
 	if err != nil {
 		return nil, err
 	}
-	c := new(conn)
-	c.c = socket
-	c.r = bufio.NewReader(socket)
-	c.w = bufio.NewWriter(socket)
-	err = authenticate(c.w, displayStr)
-	if err != nil {
-		return nil, err
-	}
-	err = c.handshake()
-	if err != nil {
-		return nil, err
-	}
-
-	// Now that we're connected, show a window, via three X protocol messages.
-	// First, create a graphics context (GC).
-	setU32LE(c.buf[0:4], 0x00060037) // 0x37 is the CreateGC opcode, and the message is 6 x 4 bytes long.
-	setU32LE(c.buf[4:8], uint32(c.gc))
-	setU32LE(c.buf[8:12], uint32(c.root))
-	setU32LE(c.buf[12:16], 0x00010004) // Bit 2 is XCB_GC_FOREGROUND, bit 16 is XCB_GC_GRAPHICS_EXPOSURES.
-	setU32LE(c.buf[16:20], 0x00000000) // The Foreground is black.
-	setU32LE(c.buf[20:24], 0x00000000) // GraphicsExposures' value is unused.
-	// Second, create the window.
-	setU32LE(c.buf[24:28], 0x000a0001) // 0x01 is the CreateWindow opcode, and the message is 10 x 4 bytes long.
-	setU32LE(c.buf[28:32], uint32(c.window))
-	setU32LE(c.buf[32:36], uint32(c.root))
-	setU32LE(c.buf[36:40], 0x00000000) // Initial (x, y) is (0, 0).
-	setU32LE(c.buf[40:44], windowHeight<<16|windowWidth)
-	setU32LE(c.buf[44:48], 0x00010000) // Border width is 0, XCB_WINDOW_CLASS_INPUT_OUTPUT is 1.
-	setU32LE(c.buf[48:52], uint32(c.visual))
-	setU32LE(c.buf[52:56], 0x00000802) // Bit 1 is XCB_CW_BACK_PIXEL, bit 11 is XCB_CW_EVENT_MASK.
-	setU32LE(c.buf[56:60], 0x00000000) // The Back-Pixel is black.
-	setU32LE(c.buf[60:64], 0x0000804f) // Key/button press and release, pointer motion, and expose event masks.
-	// Third, map the window.
-	setU32LE(c.buf[64:68], 0x00020008) // 0x08 is the MapWindow opcode, and the message is 2 x 4 bytes long.
-	setU32LE(c.buf[68:72], uint32(c.window))
-	// Write the bytes.
-	_, err = c.w.Write(c.buf[0:72])
-	if err != nil {
-		return nil, err
-	}
-	err = c.w.Flush()
-	if err != nil {
-		return nil, err
-	}
-
-	c.img = image.NewRGBA(image.Rect(0, 0, windowWidth, windowHeight))
-	c.bufimg = image.NewRGBA(image.Rect(0, 0, windowWidth, windowHeight))
-	// TODO(nigeltao): Should these channels be buffered?
-	c.event = make(chan interface{})
-	mouse := make(chan draw.MouseEvent)
-	go bufferMouse(mouse, c.event)
-	c.flush = make(chan bool, 1)
-	go c.flusher()
-	go c.pumper(mouse)
-	return c, nil
+	return d.NewWindow()
 }
 
 // timeTranslate translates from milliseconds to nanoseconds
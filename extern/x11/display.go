@@ -0,0 +1,382 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11
+
+import (
+	"bufio"
+	"errors"
+	"exp/draw"
+	"image"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// A Display is a connection to an X server, on which
+// any number of windows may be opened, each with its own
+// image, event channel and flusher.
+type Display struct {
+	// TODO(nigeltao): Figure out which goroutine should be responsible for closing c,
+	// or if there is a race condition if one goroutine calls c.Close whilst another one
+	// is reading from r, or writing to w.
+	c io.Closer
+	r *bufio.Reader
+	w *bufio.Writer
+
+	writeLock sync.Mutex // guards w, which is shared by all the windows, and seq.
+	seq       uint16     // the sequence number of the last request sent.
+
+	root, visual   resID
+	idBase, idMask uint32
+	shmOpcode      uint8  // the major opcode of MIT-SHM, or 0 if it is not available.
+	nextID         uint32 // guarded by mu.
+
+	minKeycode, maxKeycode byte
+	keyRepeat              bool // the server sends no release before a repeated key press.
+
+	mu      sync.Mutex
+	windows map[resID]*conn
+	replies map[uint16]chan []byte // requests waiting for replies, by sequence number.
+	atoms   map[string]resID
+	keysyms [][]int // the keysyms of each keycode from minKeycode.
+
+	selLock     sync.Mutex
+	selections  map[string]*Selection
+	selWindow   resID      // the hidden window that owns our selections.
+	selNotify   chan resID // receives the property of the next selection notify event.
+	convertLock sync.Mutex // held while waiting for a selection's data.
+
+	buf [256]byte // General purpose scratch buffer, used by handshake and pumper.
+}
+
+// OpenDisplay connects to the X server specified by the display
+// string, such as ":1", without opening any windows.
+func OpenDisplay(display string) (*Display, error) {
+	socket, displayStr, err := connect(display)
+	if err != nil {
+		return nil, err
+	}
+	d := new(Display)
+	d.c = socket
+	d.r = bufio.NewReader(socket)
+	d.w = bufio.NewWriter(socket)
+	err = authenticate(d.w, displayStr)
+	if err != nil {
+		return nil, err
+	}
+	err = d.handshake()
+	if err != nil {
+		return nil, err
+	}
+	d.windows = make(map[resID]*conn)
+	d.replies = make(map[uint16]chan []byte)
+	d.atoms = make(map[string]resID)
+	go d.pumper()
+	d.shmOpcode, _ = d.queryExtension("MIT-SHM")
+	if err := d.loadKeymap(); err != nil {
+		return nil, err
+	}
+	d.keyRepeat = d.setDetectableRepeat()
+	return d, nil
+}
+
+// newID allocates a new X resource ID.
+func (d *Display) newID() (resID, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.nextID > d.idMask {
+		return 0, errors.New("out of X resource IDs")
+	}
+	id := d.idBase | d.nextID
+	d.nextID++
+	return resID(id), nil
+}
+
+// write writes the X requests in b, none of which may
+// have a reply, and flushes them to the server.
+func (d *Display) write(b []byte) error {
+	d.writeLock.Lock()
+	defer d.writeLock.Unlock()
+	// Count the requests, so that we know the sequence
+	// numbers of any sent later. Bytes 2:4 of each
+	// request hold its length in 4-byte units.
+	for i := 0; i+4 <= len(b); {
+		d.seq++
+		i += 4 * (int(b[i+3])<<8 | int(b[i+2]))
+	}
+	_, err := d.w.Write(b)
+	if err != nil {
+		return err
+	}
+	return d.w.Flush()
+}
+
+// request sends the single X request in b and waits for its reply,
+// which is returned whole, including the first 32 bytes.
+func (d *Display) request(b []byte) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	d.writeLock.Lock()
+	d.seq++
+	d.mu.Lock()
+	d.replies[d.seq] = ch
+	d.mu.Unlock()
+	_, err := d.w.Write(b)
+	if err == nil {
+		err = d.w.Flush()
+	}
+	d.writeLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	reply, ok := <-ch
+	if !ok {
+		return nil, errors.New("X connection closed")
+	}
+	if reply[0] == 0 {
+		return nil, xError(reply)
+	}
+	return reply, nil
+}
+
+// check sends the single X request in b, which has no reply,
+// and waits until the server has acted on it, returning any
+// error that it caused.
+func (d *Display) check(b []byte) error {
+	ch := make(chan []byte, 1)
+	d.writeLock.Lock()
+	d.seq++
+	seq := d.seq
+	d.mu.Lock()
+	d.replies[seq] = ch
+	d.mu.Unlock()
+	_, err := d.w.Write(b)
+	d.writeLock.Unlock()
+	if err == nil {
+		// Any error caused by b arrives before the reply
+		// to a later request. GetInputFocus has a reply,
+		// and no effect.
+		_, err = d.request([]byte{0x2b, 0, 1, 0})
+	}
+	d.mu.Lock()
+	delete(d.replies, seq)
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case msg := <-ch:
+		if msg != nil {
+			return xError(msg)
+		}
+	default:
+	}
+	return nil
+}
+
+// xError returns the X error in msg as an error.
+func xError(msg []byte) error {
+	return errors.New("X error " + strconv.Itoa(int(msg[1])))
+}
+
+// queryExtension returns the major opcode of the named
+// extension, or 0 if the server does not have it.
+func (d *Display) queryExtension(name string) (uint8, error) {
+	n := len(name)
+	b := make([]byte, 8+pad4(n))
+	b[0] = 0x62 // QueryExtension opcode.
+	setU16LE(b[2:4], uint16(len(b)/4))
+	setU16LE(b[4:6], uint16(n))
+	copy(b[8:], name)
+	reply, err := d.request(b)
+	if err != nil {
+		return 0, err
+	}
+	// Byte 8 is whether the extension is present, byte 9 its major opcode.
+	if reply[8] == 0 {
+		return 0, nil
+	}
+	return reply[9], nil
+}
+
+// atom returns the X atom with the given name, creating it if necessary.
+func (d *Display) atom(name string) (resID, error) {
+	d.mu.Lock()
+	a, ok := d.atoms[name]
+	d.mu.Unlock()
+	if ok {
+		return a, nil
+	}
+	n := len(name)
+	b := make([]byte, 8+pad4(n))
+	b[0] = 0x10 // InternAtom opcode; only-if-exists is false.
+	setU16LE(b[2:4], uint16(len(b)/4))
+	setU16LE(b[4:6], uint16(n))
+	copy(b[8:], name)
+	reply, err := d.request(b)
+	if err != nil {
+		return 0, err
+	}
+	a = resID(getU32LE(reply[8:12]))
+	d.mu.Lock()
+	d.atoms[name] = a
+	d.mu.Unlock()
+	return a, nil
+}
+
+// pad4 returns n rounded up to a multiple of 4.
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// NewWindow returns a new Window, backed by a newly
+// created and mapped X11 window on d.
+func (d *Display) NewWindow() (Window, error) {
+	c := &conn{d: d}
+	var err error
+	if c.gc, err = d.newID(); err != nil {
+		return nil, err
+	}
+	if c.window, err = d.newID(); err != nil {
+		return nil, err
+	}
+	c.img = image.NewRGBA(image.Rect(0, 0, windowWidth, windowHeight))
+	c.bufimg = image.NewRGBA(image.Rect(0, 0, windowWidth, windowHeight))
+	// TODO(nigeltao): Should these channels be buffered?
+	c.event = make(chan interface{})
+	c.mouse = make(chan draw.MouseEvent)
+	c.flush = make(chan bool, 1)
+
+	// Register the window before it is mapped, so that
+	// none of its events are missed.
+	d.mu.Lock()
+	d.windows[c.window] = c
+	d.mu.Unlock()
+
+	// Show the window, via three X protocol messages.
+	// First, create a graphics context (GC).
+	var buf [72]byte
+	setU32LE(buf[0:4], 0x00060037) // 0x37 is the CreateGC opcode, and the message is 6 x 4 bytes long.
+	setU32LE(buf[4:8], uint32(c.gc))
+	setU32LE(buf[8:12], uint32(d.root))
+	setU32LE(buf[12:16], 0x00010004) // Bit 2 is XCB_GC_FOREGROUND, bit 16 is XCB_GC_GRAPHICS_EXPOSURES.
+	setU32LE(buf[16:20], 0x00000000) // The Foreground is black.
+	setU32LE(buf[20:24], 0x00000000) // GraphicsExposures' value is unused.
+	// Second, create the window.
+	setU32LE(buf[24:28], 0x000a0001) // 0x01 is the CreateWindow opcode, and the message is 10 x 4 bytes long.
+	setU32LE(buf[28:32], uint32(c.window))
+	setU32LE(buf[32:36], uint32(d.root))
+	setU32LE(buf[36:40], 0x00000000) // Initial (x, y) is (0, 0).
+	setU32LE(buf[40:44], windowHeight<<16|windowWidth)
+	setU32LE(buf[44:48], 0x00010000) // Border width is 0, XCB_WINDOW_CLASS_INPUT_OUTPUT is 1.
+	setU32LE(buf[48:52], uint32(d.visual))
+	setU32LE(buf[52:56], 0x00000802) // Bit 1 is XCB_CW_BACK_PIXEL, bit 11 is XCB_CW_EVENT_MASK.
+	setU32LE(buf[56:60], 0x00000000) // The Back-Pixel is black.
+	setU32LE(buf[60:64], 0x0002804f) // Key/button press and release, pointer motion, expose and structure notify event masks.
+	// Third, map the window.
+	setU32LE(buf[64:68], 0x00020008) // 0x08 is the MapWindow opcode, and the message is 2 x 4 bytes long.
+	setU32LE(buf[68:72], uint32(c.window))
+	if err := d.write(buf[:]); err != nil {
+		d.mu.Lock()
+		delete(d.windows, c.window)
+		d.mu.Unlock()
+		return nil, err
+	}
+
+	go bufferMouse(c.mouse, c.event)
+	go c.flusher()
+	return c, nil
+}
+
+// pumper runs in its own goroutine, reading X events and
+// passing each to the window that it is for.
+func (d *Display) pumper() {
+	var timestamp timeTranslate
+	for {
+		// X events and errors are always 32 bytes long, as is the start of a reply.
+		_, err := io.ReadFull(d.r, d.buf[0:32])
+		if err != nil {
+			// TODO(nigeltao): should draw.Window expose err?
+			// TODO(nigeltao): should we do c.quit<-true? Should c.quit be a buffered channel?
+			// Or is c.quit only for non-exceptional closing (e.g. when the window manager destroys
+			// our window), and not for e.g. an I/O error?
+			break
+		}
+		if d.buf[0] <= 1 {
+			// An error or a reply.
+			if err := d.reply(); err != nil {
+				break
+			}
+			continue
+		}
+		if code := d.buf[0] & 0x7f; code >= 0x1d && code <= 0x1f {
+			d.handleSelection(d.buf[0:32])
+			continue
+		}
+		switch d.buf[0] {
+		case 0x22: // Mapping notify.
+			// Byte 4 says which mapping has changed; 2 is that of the pointer.
+			if d.buf[4] != 2 {
+				// Loading it waits for a reply, which we must read.
+				go d.loadKeymap()
+			}
+			continue
+		case 0x03: // Key release.
+			if !d.keyRepeat && d.isRepeatRelease(d.buf[0:32]) {
+				// The key is still held down; drop the release,
+				// so that the press that follows is seen as a repeat.
+				continue
+			}
+		}
+		d.mu.Lock()
+		c := d.windows[eventWindow(d.buf[0:32])]
+		d.mu.Unlock()
+		if c != nil {
+			c.handle(d.buf[0:32], &timestamp)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, c := range d.windows {
+		close(c.event)
+	}
+	for _, ch := range d.replies {
+		close(ch)
+	}
+}
+
+// reply reads the rest of the reply or error whose first 32 bytes
+// are in d.buf, and passes it to the request waiting for it, if any.
+func (d *Display) reply() error {
+	msg := append([]byte(nil), d.buf[0:32]...)
+	if msg[0] == 1 {
+		// Bytes 4:8 of a reply hold the length of the rest in 4-byte units.
+		n := int(getU32LE(msg[4:8]))
+		msg = append(msg, make([]byte, 4*n)...)
+		if _, err := io.ReadFull(d.r, msg[32:]); err != nil {
+			return err
+		}
+	}
+	seq := uint16(msg[3])<<8 | uint16(msg[2])
+	d.mu.Lock()
+	ch := d.replies[seq]
+	delete(d.replies, seq)
+	d.mu.Unlock()
+	if ch != nil {
+		ch <- msg
+	}
+	return nil
+}
+
+// eventWindow returns the window that the event in b is for,
+// or 0 if it is not one of the events that we handle.
+func eventWindow(b []byte) resID {
+	switch b[0] {
+	case 0x02, 0x03, 0x04, 0x05, 0x06: // Key, button and motion events.
+		return resID(getU32LE(b[12:16]))
+	case 0x0c, 0x16: // Expose, configure notify.
+		return resID(getU32LE(b[4:8]))
+	}
+	return 0
+}
@@ -0,0 +1,310 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11
+
+import (
+	"exp/draw"
+	"unicode"
+)
+
+// Modifiers holds the state of the modifier keys, as a set of
+// bits. The bits are those of the X core protocol; Alt, Num Lock
+// and Super are the modifiers that X.Org conventionally maps
+// those keys to.
+type Modifiers uint16
+
+const (
+	ModShift   Modifiers = 0x01
+	ModLock    Modifiers = 0x02 // Caps Lock.
+	ModCtrl    Modifiers = 0x04
+	ModAlt     Modifiers = 0x08 // Mod1.
+	ModNumLock Modifiers = 0x10 // Mod2.
+	ModSuper   Modifiers = 0x40 // Mod4, the "Windows" key.
+)
+
+// Keysyms of the modifier keys, with the bits that they set.
+var modifierKeysyms = map[int]Modifiers{
+	0xffe1: ModShift, // Shift_L.
+	0xffe2: ModShift, // Shift_R.
+	0xffe3: ModCtrl,  // Control_L.
+	0xffe4: ModCtrl,  // Control_R.
+	0xffe7: ModAlt,   // Meta_L.
+	0xffe8: ModAlt,   // Meta_R.
+	0xffe9: ModAlt,   // Alt_L.
+	0xffea: ModAlt,   // Alt_R.
+	0xffeb: ModSuper, // Super_L.
+	0xffec: ModSuper, // Super_R.
+}
+
+// keypadKeysyms maps the keysyms of keypad keys to the
+// characters or keysyms of the ordinary keys that they
+// stand for, so that, for instance, the Enter key on the
+// keypad is the same as Return.
+var keypadKeysyms = map[int]int{
+	0xff80: ' ',    // KP_Space.
+	0xff89: 0xff09, // KP_Tab: Tab.
+	0xff8d: 0xff0d, // KP_Enter: Return.
+	0xff95: 0xff50, // KP_Home: Home.
+	0xff96: 0xff51, // KP_Left: Left.
+	0xff97: 0xff52, // KP_Up: Up.
+	0xff98: 0xff53, // KP_Right: Right.
+	0xff99: 0xff54, // KP_Down: Down.
+	0xff9a: 0xff55, // KP_Prior: Prior (Page Up).
+	0xff9b: 0xff56, // KP_Next: Next (Page Down).
+	0xff9c: 0xff57, // KP_End: End.
+	0xff9d: 0xff58, // KP_Begin: Begin.
+	0xff9e: 0xff63, // KP_Insert: Insert.
+	0xff9f: 0xffff, // KP_Delete: Delete.
+	0xffaa: '*',
+	0xffab: '+',
+	0xffac: ',',
+	0xffad: '-',
+	0xffae: '.',
+	0xffaf: '/',
+	0xffbd: '=',
+}
+
+// loadKeymap reads the keyboard mapping of the server, which maps
+// each keycode to the keysyms that it produces. It waits for a
+// reply, so must not be called by the pumper.
+func (d *Display) loadKeymap() error {
+	n := int(d.maxKeycode) - int(d.minKeycode) + 1
+	var b [8]byte
+	b[0] = 0x65 // GetKeyboardMapping opcode.
+	setU16LE(b[2:4], 2)
+	b[4] = d.minKeycode
+	b[5] = byte(n)
+	reply, err := d.request(b[:])
+	if err != nil {
+		return err
+	}
+	// Byte 1 is the number of keysyms for each keycode,
+	// which follow the first 32 bytes.
+	per := int(reply[1])
+	keysyms := make([][]int, n)
+	for i := range keysyms {
+		for j := 0; j < per; j++ {
+			off := 32 + 4*(i*per+j)
+			if off+4 > len(reply) {
+				break
+			}
+			keysyms[i] = append(keysyms[i], int(getU32LE(reply[off:off+4])))
+		}
+	}
+	d.mu.Lock()
+	d.keysyms = keysyms
+	d.mu.Unlock()
+	return nil
+}
+
+// setDetectableRepeat asks the server, through the XKEYBOARD
+// extension, not to send a key release before each repeated
+// key press while a key is held down, so that repeats can be
+// told from separate presses. It reports whether it could.
+func (d *Display) setDetectableRepeat() bool {
+	xkb, err := d.queryExtension("XKEYBOARD")
+	if err != nil || xkb == 0 {
+		return false
+	}
+	// The extension must be enabled before it is used.
+	var use [8]byte
+	use[0] = xkb
+	use[1] = 0 // XkbUseExtension.
+	setU16LE(use[2:4], 2)
+	setU16LE(use[4:6], 1) // Version 1.0.
+	setU16LE(use[6:8], 0)
+	reply, err := d.request(use[:])
+	if err != nil || reply[1] == 0 {
+		return false
+	}
+	var b [28]byte
+	b[0] = xkb
+	b[1] = 21 // XkbPerClientFlags.
+	setU16LE(b[2:4], 7)
+	setU16LE(b[4:6], 0x100) // The core keyboard.
+	setU32LE(b[8:12], 1)    // Change XkbPCF_DetectableAutoRepeat,
+	setU32LE(b[12:16], 1)   // setting it.
+	reply, err = d.request(b[:])
+	if err != nil {
+		return false
+	}
+	// Bytes 8:12 hold the flags that are supported.
+	return getU32LE(reply[8:12])&1 != 0
+}
+
+// keysym returns the keysym for the key with the given keycode,
+// when the modifiers in state are held, following the rules of
+// the X protocol for choosing between the unshifted and
+// shifted keysyms of the first group.
+func (d *Display) keysym(keycode byte, state Modifiers) int {
+	d.mu.Lock()
+	var syms []int
+	if i := int(keycode) - int(d.minKeycode); i >= 0 && i < len(d.keysyms) {
+		syms = d.keysyms[i]
+	}
+	d.mu.Unlock()
+	var k0, k1 int
+	if len(syms) > 0 {
+		k0 = syms[0]
+	}
+	if len(syms) > 1 {
+		k1 = syms[1]
+	}
+	if k1 == 0 {
+		// A key with a single keysym that is a letter
+		// is its lower case, and shifted, its upper case.
+		k0, k1 = lowerKeysym(k0), upperKeysym(k0)
+	}
+	shift := state&ModShift != 0
+	switch {
+	case state&ModNumLock != 0 && isKeypad(k1):
+		// Num Lock swaps the digits and the
+		// cursor keys of the keypad.
+		if shift {
+			return k0
+		}
+		return k1
+	case shift:
+		if state&ModLock != 0 {
+			return upperKeysym(k1)
+		}
+		return k1
+	case state&ModLock != 0:
+		return upperKeysym(k0)
+	}
+	return k0
+}
+
+// isKeypad reports whether k is the keysym of a key on the keypad.
+func isKeypad(k int) bool {
+	return k >= 0xff80 && k <= 0xffbd
+}
+
+// Keysyms below 0x100 are Latin-1 characters, and those
+// in the range 0x1000000 to 0x110ffff are Unicode characters,
+// offset by 0x1000000.
+const unicodeKeysym = 0x1000000
+
+func lowerKeysym(k int) int {
+	if k < 0x100 {
+		return int(unicode.ToLower(rune(k)))
+	}
+	if k&^0xffffff == unicodeKeysym {
+		return unicodeKeysym | int(unicode.ToLower(rune(k&0xffffff)))
+	}
+	return k
+}
+
+func upperKeysym(k int) int {
+	if k < 0x100 {
+		if k == 0xdf || k == 0xff {
+			// ß and ÿ have no upper case in Latin-1.
+			return k
+		}
+		return int(unicode.ToUpper(rune(k)))
+	}
+	if k&^0xffffff == unicodeKeysym {
+		return unicodeKeysym | int(unicode.ToUpper(rune(k&0xffffff)))
+	}
+	return k
+}
+
+// keyValue returns the value to be sent in a draw.KeyEvent for
+// the keysym k. Keys that produce characters send the character;
+// others, such as the arrow, function and modifier keys, send their
+// keysyms, all of which are at least 0xfe00. Keys on the keypad send
+// the same as the ordinary keys that they stand for.
+func keyValue(k int) int {
+	if v, ok := keypadKeysyms[k]; ok {
+		return v
+	}
+	switch {
+	case k >= 0xffb0 && k <= 0xffb9: // KP_0 to KP_9.
+		return '0' + k - 0xffb0
+	case k&^0xffffff == unicodeKeysym:
+		return k & 0xffffff
+	}
+	// Keysyms of other character sets, between 0x100 and 0xfe00,
+	// are sent as they are.
+	return k
+}
+
+// handleKey handles the key press or release event in buf.
+// It is called by the display's pumper.
+func (c *conn) handleKey(buf []byte) {
+	press := buf[0] == 0x02
+	keycode := buf[1]
+	// Bytes 28:30 hold the modifiers held before the event.
+	state := Modifiers(getU16LE(buf[28:30]))
+	k := c.d.keysym(keycode, state)
+
+	c.keyLock.Lock()
+	if mod, ok := modifierKeysyms[k]; ok {
+		if press {
+			state |= mod
+		} else {
+			state &^= mod
+		}
+	}
+	c.mods = state
+	bit := byte(1) << (keycode & 7)
+	repeat := press && c.down[keycode>>3]&bit != 0
+	if press {
+		c.down[keycode>>3] |= bit
+	} else {
+		c.down[keycode>>3] &^= bit
+	}
+	noRepeat := c.noRepeat
+	c.keyLock.Unlock()
+
+	if k == 0 || repeat && noRepeat {
+		return
+	}
+	// TODO(nigeltao): How should IME events (e.g. key presses that should generate CJK text) work? Or
+	// is that outside the scope of the draw.Window interface?
+	v := keyValue(k)
+	if !press {
+		v = -v
+	}
+	c.event <- draw.KeyEvent{v}
+}
+
+// setMouseModifiers records the modifiers held during the
+// button or motion event in buf.
+func (c *conn) setMouseModifiers(buf []byte) {
+	c.keyLock.Lock()
+	c.mods = Modifiers(getU16LE(buf[28:30]))
+	c.keyLock.Unlock()
+}
+
+func (c *conn) Modifiers() Modifiers {
+	c.keyLock.Lock()
+	defer c.keyLock.Unlock()
+	return c.mods
+}
+
+func (c *conn) SetKeyRepeat(on bool) {
+	c.keyLock.Lock()
+	c.noRepeat = !on
+	c.keyLock.Unlock()
+}
+
+// isRepeatRelease reports whether the key release event in buf is
+// followed, in what has already been read from the server, by a
+// press of the same key at the same time, as the server sends when
+// a key repeats and detectable repeat could not be set.
+func (d *Display) isRepeatRelease(buf []byte) bool {
+	if d.r.Buffered() < 32 {
+		return false
+	}
+	next, err := d.r.Peek(32)
+	if err != nil {
+		return false
+	}
+	return next[0]&0x7f == 0x02 &&
+		next[1] == buf[1] &&
+		getU32LE(next[4:8]) == getU32LE(buf[4:8]) &&
+		getU32LE(next[12:16]) == getU32LE(buf[12:16])
+}
@@ -0,0 +1,389 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11
+
+import (
+	"sync"
+	"time"
+)
+
+// TextFormat is the format of plain text in a selection,
+// the same as that used by the clipboard of the canvas package.
+const TextFormat = "text/plain;charset=utf-8"
+
+// A Selection is an X selection, such as the clipboard, through
+// which data is passed from one client to another. The data may
+// be offered in several formats at once, each named by a MIME type
+// such as TextFormat. A Selection satisfies the Clipboard interface
+// of the canvas package, so it can be given to a canvas Background
+// with SetClipboard.
+type Selection struct {
+	d    *Display
+	atom resID
+
+	mu      sync.Mutex
+	owned   bool             // whether this client holds the selection.
+	targets map[resID][]byte // the data we hold, by target atom.
+	formats []resID          // the targets we offer, in order.
+}
+
+// Predefined X atoms used by selections.
+const (
+	atomPRIMARY = 1
+	atomATOM    = 4
+)
+
+// selectionTimeout is how long Get waits for the
+// owner of a selection to send its data.
+const selectionTimeout = 2 * time.Second
+
+// Clipboard returns the CLIPBOARD selection of d, used
+// for explicit cut, copy and paste.
+func (d *Display) Clipboard() *Selection {
+	return d.selection("CLIPBOARD")
+}
+
+// Primary returns the PRIMARY selection of d, which by
+// convention holds the text that was last selected,
+// and is pasted with the middle mouse button.
+func (d *Display) Primary() *Selection {
+	return d.selection("PRIMARY")
+}
+
+func (d *Display) selection(name string) *Selection {
+	d.selLock.Lock()
+	s := d.selections[name]
+	d.selLock.Unlock()
+	if s != nil {
+		return s
+	}
+	// Don't hold selLock while waiting for a reply,
+	// as the pumper needs it to handle selection events.
+	s = &Selection{d: d}
+	if name == "PRIMARY" {
+		s.atom = atomPRIMARY
+	} else {
+		s.atom, _ = d.atom(name)
+	}
+	d.selLock.Lock()
+	defer d.selLock.Unlock()
+	if s1 := d.selections[name]; s1 != nil {
+		return s1
+	}
+	if d.selections == nil {
+		d.selections = make(map[string]*Selection)
+	}
+	d.selections[name] = s
+	return s
+}
+
+// selectionWindow returns the hidden window that owns d's
+// selections and receives the data of others, creating it
+// if necessary.
+func (d *Display) selectionWindow() (resID, error) {
+	d.selLock.Lock()
+	defer d.selLock.Unlock()
+	if d.selWindow != 0 {
+		return d.selWindow, nil
+	}
+	w, err := d.newID()
+	if err != nil {
+		return 0, err
+	}
+	var b [32]byte
+	setU32LE(b[0:4], 0x00080001) // 0x01 is the CreateWindow opcode, and the message is 8 x 4 bytes long; depth is 0.
+	setU32LE(b[4:8], uint32(w))
+	setU32LE(b[8:12], uint32(d.root))
+	setU32LE(b[12:16], 0x00000000) // (x, y) is (0, 0).
+	setU32LE(b[16:20], 0x00010001) // 1x1 pixels.
+	setU32LE(b[20:24], 0x00020000) // Border width is 0, XCB_WINDOW_CLASS_INPUT_ONLY is 2.
+	setU32LE(b[24:28], 0x00000000) // Visual is CopyFromParent.
+	setU32LE(b[28:32], 0x00000000) // No attributes.
+	if err := d.write(b[:]); err != nil {
+		return 0, err
+	}
+	d.selWindow = w
+	return w, nil
+}
+
+// target returns the target atom for a MIME format.
+func (d *Display) target(format string) (resID, error) {
+	if format == TextFormat {
+		return d.atom("UTF8_STRING")
+	}
+	return d.atom(format)
+}
+
+// Set takes ownership of the selection, offering data, which
+// maps each format to the data in that format.
+func (s *Selection) Set(data map[string][]byte) {
+	d := s.d
+	w, err := d.selectionWindow()
+	if err != nil || s.atom == 0 {
+		return
+	}
+	// The pumper answers requests for TARGETS, but
+	// cannot intern the atom itself.
+	if _, err := d.atom("TARGETS"); err != nil {
+		return
+	}
+	targets := make(map[resID][]byte)
+	var formats []resID
+	for f, b := range data {
+		t, err := d.target(f)
+		if err != nil {
+			return
+		}
+		targets[t] = append([]byte(nil), b...)
+		formats = append(formats, t)
+	}
+	if text, ok := data[TextFormat]; ok {
+		// Older clients ask for STRING (Latin-1) or TEXT.
+		var latin1 []byte
+		for _, r := range string(text) {
+			if r >= 0x100 {
+				r = '?'
+			}
+			latin1 = append(latin1, byte(r))
+		}
+		targets[atomSTRING] = latin1
+		formats = append(formats, atomSTRING)
+		if t, err := d.atom("TEXT"); err == nil {
+			targets[t] = latin1
+			formats = append(formats, t)
+		}
+	}
+	s.mu.Lock()
+	s.owned = true
+	s.targets = targets
+	s.formats = formats
+	s.mu.Unlock()
+
+	var b [16]byte
+	b[0] = 0x16 // SetSelectionOwner opcode.
+	setU16LE(b[2:4], 4)
+	setU32LE(b[4:8], uint32(w))
+	setU32LE(b[8:12], uint32(s.atom))
+	setU32LE(b[12:16], 0) // CurrentTime.
+	d.write(b[:])
+}
+
+// Get returns the data held in the selection in the given
+// format, or false if there is none, asking the client that
+// holds the selection for it if that is not this one.
+func (s *Selection) Get(format string) ([]byte, bool) {
+	d := s.d
+	t, err := d.target(format)
+	if err != nil || s.atom == 0 {
+		return nil, false
+	}
+	s.mu.Lock()
+	if s.owned {
+		b, ok := s.targets[t]
+		s.mu.Unlock()
+		return append([]byte(nil), b...), ok
+	}
+	s.mu.Unlock()
+
+	b, ok := s.convert(t)
+	if !ok && format == TextFormat {
+		// The owner may only have Latin-1.
+		if b, ok = s.convert(atomSTRING); ok {
+			b = []byte(latin1ToString(b))
+		}
+	}
+	return b, ok
+}
+
+// convert asks the owner of the selection for its data as the
+// target t, and waits for it to be stored in a property of our
+// selection window, from which it is read.
+func (s *Selection) convert(t resID) ([]byte, bool) {
+	d := s.d
+	w, err := d.selectionWindow()
+	if err != nil {
+		return nil, false
+	}
+	prop, err := d.atom("GO_SELECTION")
+	if err != nil {
+		return nil, false
+	}
+	// Only one conversion at a time, so that we
+	// know which request a notification is for.
+	d.convertLock.Lock()
+	defer d.convertLock.Unlock()
+	notify := make(chan resID, 1)
+	d.selLock.Lock()
+	d.selNotify = notify
+	d.selLock.Unlock()
+	defer func() {
+		d.selLock.Lock()
+		d.selNotify = nil
+		d.selLock.Unlock()
+	}()
+
+	var b [24]byte
+	b[0] = 0x18 // ConvertSelection opcode.
+	setU16LE(b[2:4], 6)
+	setU32LE(b[4:8], uint32(w))
+	setU32LE(b[8:12], uint32(s.atom))
+	setU32LE(b[12:16], uint32(t))
+	setU32LE(b[16:20], uint32(prop))
+	setU32LE(b[20:24], 0) // CurrentTime.
+	if d.write(b[:]) != nil {
+		return nil, false
+	}
+	select {
+	case p := <-notify:
+		if p == 0 {
+			// The owner refused, or there is no owner.
+			return nil, false
+		}
+	case <-time.After(selectionTimeout):
+		return nil, false
+	}
+
+	var g [24]byte
+	g[0] = 0x14 // GetProperty opcode.
+	g[1] = 1    // Delete the property once read.
+	setU16LE(g[2:4], 6)
+	setU32LE(g[4:8], uint32(w))
+	setU32LE(g[8:12], uint32(prop))
+	setU32LE(g[12:16], 0)          // Any type.
+	setU32LE(g[16:20], 0)          // Offset.
+	setU32LE(g[20:24], 0x00ffffff) // Length, in 4-byte units.
+	reply, err := d.request(g[:])
+	if err != nil {
+		return nil, false
+	}
+	// Byte 1 is the format, bytes 16:20 the length of the value in
+	// units of that format. Large values sent incrementally, using
+	// the INCR protocol, are not supported.
+	format := int(reply[1])
+	if format == 0 {
+		return nil, false
+	}
+	n := int(getU32LE(reply[16:20])) * format / 8
+	if 32+n > len(reply) {
+		return nil, false
+	}
+	return append([]byte(nil), reply[32:32+n]...), true
+}
+
+// handleSelection handles the selection event in buf.
+// It is called by the pumper, so must not wait for replies.
+func (d *Display) handleSelection(buf []byte) {
+	switch buf[0] & 0x7f {
+	case 0x1d: // Selection clear: another client has taken the selection.
+		if s := d.selectionFor(resID(getU32LE(buf[12:16]))); s != nil {
+			s.mu.Lock()
+			s.owned = false
+			s.targets = nil
+			s.formats = nil
+			s.mu.Unlock()
+		}
+	case 0x1e: // Selection request: another client wants our data.
+		requestor := resID(getU32LE(buf[12:16]))
+		sel := resID(getU32LE(buf[16:20]))
+		target := resID(getU32LE(buf[20:24]))
+		prop := resID(getU32LE(buf[24:28]))
+		if prop == 0 {
+			// Obsolete clients leave the property to us.
+			prop = target
+		}
+		if !d.sendSelection(requestor, sel, target, prop) {
+			prop = 0
+		}
+		// Tell the requestor that the data is there, or that it is not.
+		var b [44]byte
+		b[0] = 0x19 // SendEvent opcode; propagate is false.
+		setU16LE(b[2:4], 11)
+		setU32LE(b[4:8], uint32(requestor))
+		setU32LE(b[8:12], 0) // No event mask: send to the requestor's client.
+		ev := b[12:]
+		ev[0] = 0x1f // SelectionNotify event.
+		copy(ev[4:8], buf[4:8])
+		setU32LE(ev[8:12], uint32(requestor))
+		setU32LE(ev[12:16], uint32(sel))
+		setU32LE(ev[16:20], uint32(target))
+		setU32LE(ev[20:24], uint32(prop))
+		d.write(b[:])
+	case 0x1f: // Selection notify: the data we asked for is ready.
+		d.selLock.Lock()
+		if d.selNotify != nil {
+			d.selNotify <- resID(getU32LE(buf[20:24]))
+			d.selNotify = nil
+		}
+		d.selLock.Unlock()
+	}
+}
+
+// sendSelection stores our data for the selection sel as target
+// in the property prop of the requestor's window, and reports
+// whether there was any such data.
+func (d *Display) sendSelection(requestor, sel, target, prop resID) bool {
+	s := d.selectionFor(sel)
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.owned {
+		return false
+	}
+	typ, format := target, 8
+	var data []byte
+	if d.isAtom(target, "TARGETS") {
+		targets, _ := d.lookupAtom("TARGETS")
+		typ, format = atomATOM, 32
+		data = make([]byte, 4*(len(s.formats)+1))
+		setU32LE(data[0:4], uint32(targets))
+		for i, t := range s.formats {
+			setU32LE(data[4+4*i:], uint32(t))
+		}
+	} else {
+		b, ok := s.targets[target]
+		if !ok {
+			return false
+		}
+		data = b
+	}
+	return d.changeProperty(requestor, prop, typ, format, data) == nil
+}
+
+// selectionFor returns our Selection with the given atom, if any.
+func (d *Display) selectionFor(atom resID) *Selection {
+	d.selLock.Lock()
+	defer d.selLock.Unlock()
+	for _, s := range d.selections {
+		if s.atom == atom {
+			return s
+		}
+	}
+	return nil
+}
+
+// lookupAtom returns the atom with the given name if it has
+// already been interned, without asking the server.
+func (d *Display) lookupAtom(name string) (resID, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	a, ok := d.atoms[name]
+	return a, ok
+}
+
+// isAtom reports whether a is the already interned atom name.
+func (d *Display) isAtom(a resID, name string) bool {
+	b, ok := d.lookupAtom(name)
+	return ok && a == b
+}
+
+func latin1ToString(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
@@ -0,0 +1,125 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11
+
+import (
+	"image"
+)
+
+// The MIT-SHM extension lets a local client share memory with the
+// X server, so that an image can be painted by telling the server
+// where it is rather than by sending all its pixels over the socket.
+// The extension's requests are numbered by their minor opcodes.
+const (
+	shmAttach   = 1
+	shmDetach   = 2
+	shmPutImage = 3
+)
+
+// An shmImage is a segment of shared memory attached to the
+// X server, holding the pixels of a window in the server's
+// format: 4 bytes of blue, green, red and padding for each.
+type shmImage struct {
+	seg resID // the segment, as known to the server.
+	mem []byte
+	r   image.Rectangle
+}
+
+// shmFor returns a shared memory image for c of the size of r,
+// attaching a new one if necessary, or nil if shared memory
+// cannot be used, in which case c never tries it again.
+// It is called by the flusher without c.flushLock held,
+// as it waits for replies from the server.
+func (c *conn) shmFor(r image.Rectangle) *shmImage {
+	d := c.d
+	if c.noShm || d.shmOpcode == 0 || r.Empty() {
+		return nil
+	}
+	if c.shm != nil {
+		if c.shm.r.Eq(r) {
+			return c.shm
+		}
+		c.shmFree()
+	}
+	id, mem, err := shmAlloc(4 * r.Dx() * r.Dy())
+	if err != nil {
+		c.noShm = true
+		return nil
+	}
+	seg, err := d.newID()
+	if err == nil {
+		var b [16]byte
+		b[0] = d.shmOpcode
+		b[1] = shmAttach
+		setU16LE(b[2:4], 4)
+		setU32LE(b[4:8], uint32(seg))
+		setU32LE(b[8:12], uint32(id))
+		b[12] = 1 // Read only.
+		// The attach fails if the server is not on this machine.
+		err = d.check(b[:])
+	}
+	// Once attached, the segment can be marked for removal;
+	// it goes away when both we and the server have detached it,
+	// even if we exit without doing so.
+	shmRemove(id)
+	if err != nil {
+		shmDetachMem(mem)
+		c.noShm = true
+		return nil
+	}
+	c.shm = &shmImage{seg, mem, r}
+	return c.shm
+}
+
+// shmFree detaches c's shared memory image.
+func (c *conn) shmFree() {
+	var b [8]byte
+	b[0] = c.d.shmOpcode
+	b[1] = shmDetach
+	setU16LE(b[2:4], 2)
+	setU32LE(b[4:8], uint32(c.shm.seg))
+	c.d.write(b[:])
+	shmDetachMem(c.shm.mem)
+	c.shm = nil
+}
+
+// copy copies the area r of img into s, converting the pixels.
+func (s *shmImage) copy(img *image.RGBA, r image.Rectangle) {
+	w := s.r.Dx()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Pix[y*img.Stride:]
+		out := s.mem[4*(y*w+r.Min.X):]
+		for i, rgba := range row[r.Min.X:r.Max.X] {
+			out[4*i+0] = rgba.B
+			out[4*i+1] = rgba.G
+			out[4*i+2] = rgba.R
+		}
+	}
+}
+
+// put paints the area r of s onto the window of c, and waits until
+// the server has done so, so that s may be changed again.
+func (s *shmImage) put(c *conn, r image.Rectangle) error {
+	var b [40]byte
+	b[0] = c.d.shmOpcode
+	b[1] = shmPutImage
+	setU16LE(b[2:4], 10)
+	setU32LE(b[4:8], uint32(c.window))
+	setU32LE(b[8:12], uint32(c.gc))
+	setU16LE(b[12:14], uint16(s.r.Dx())) // The total width and height.
+	setU16LE(b[14:16], uint16(s.r.Dy()))
+	setU16LE(b[16:18], uint16(r.Min.X)) // The source rectangle.
+	setU16LE(b[18:20], uint16(r.Min.Y))
+	setU16LE(b[20:22], uint16(r.Dx()))
+	setU16LE(b[22:24], uint16(r.Dy()))
+	setU16LE(b[24:26], uint16(r.Min.X)) // The destination.
+	setU16LE(b[26:28], uint16(r.Min.Y))
+	b[28] = 0x18 // depth = 24 bits.
+	b[29] = 0x02 // XCB_IMAGE_FORMAT_Z_PIXMAP.
+	b[30] = 0    // Send no completion event.
+	setU32LE(b[32:36], uint32(s.seg))
+	setU32LE(b[36:40], 0) // Offset.
+	return c.d.check(b[:])
+}
@@ -0,0 +1,22 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux linux,!amd64,!arm64
+
+package x11
+
+import (
+	"errors"
+)
+
+// Without System V shared memory, windows
+// are always painted through the socket.
+
+func shmAlloc(n int) (id int, mem []byte, err error) {
+	return 0, nil, errors.New("no shared memory")
+}
+
+func shmRemove(id int) {}
+
+func shmDetachMem(mem []byte) {}
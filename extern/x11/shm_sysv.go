@@ -0,0 +1,46 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux,amd64 linux,arm64
+
+package x11
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ipcPrivate = 0
+	ipcCreat   = 01000
+	ipcRmid    = 0
+)
+
+// shmAlloc creates a System V shared memory segment
+// of n bytes, and attaches it to our address space.
+func shmAlloc(n int) (id int, mem []byte, err error) {
+	r, _, e := syscall.Syscall(syscall.SYS_SHMGET, ipcPrivate, uintptr(n), ipcCreat|0600)
+	if e != 0 {
+		return 0, nil, e
+	}
+	id = int(r)
+	addr, _, e := syscall.Syscall(syscall.SYS_SHMAT, uintptr(id), 0, 0)
+	if e != 0 {
+		shmRemove(id)
+		return 0, nil, e
+	}
+	mem = (*[1 << 30]byte)(unsafe.Pointer(addr))[:n]
+	return id, mem, nil
+}
+
+// shmRemove marks the segment id to be removed
+// when it is no longer attached anywhere.
+func shmRemove(id int) {
+	syscall.Syscall(syscall.SYS_SHMCTL, uintptr(id), ipcRmid, 0)
+}
+
+// shmDetachMem detaches mem, returned by shmAlloc.
+func shmDetachMem(mem []byte) {
+	syscall.Syscall(syscall.SYS_SHMDT, uintptr(unsafe.Pointer(&mem[0])), 0, 0)
+}
@@ -0,0 +1,222 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x11
+
+import (
+	"errors"
+	"exp/draw"
+	"image"
+)
+
+// A Window is a draw.Window that also lets the application
+// describe itself to the window manager and control the
+// size and position of the window.
+type Window interface {
+	draw.Window
+
+	// SetTitle sets the title shown by the window manager,
+	// for the window and for its icon.
+	SetTitle(title string) error
+
+	// SetIcon sets the icon shown for the window by the window
+	// manager, such as in a task bar. Small images, such as
+	// 32x32 or 48x48 pixels, are usual.
+	SetIcon(icon image.Image) error
+
+	// SetSize asks for the window to be resized to the
+	// given size. As for a resize made by the user,
+	// a draw.ConfigEvent is sent when it has been.
+	SetSize(size image.Point) error
+
+	// SetPosition asks for the top left corner of the
+	// window to be moved to p, on the screen.
+	SetPosition(p image.Point) error
+
+	// SetMinSize asks the window manager not to let
+	// the user make the window smaller than size.
+	SetMinSize(size image.Point) error
+
+	// SetFullscreen asks the window manager to show the window
+	// covering the whole screen, or, if on is false, to show
+	// it as it was before. A draw.ConfigEvent is sent when
+	// the size of the window has changed.
+	SetFullscreen(on bool) error
+
+	// Display returns the display that the window is on,
+	// which holds the selections, such as the clipboard.
+	Display() *Display
+
+	// Modifiers returns the modifier keys that were held
+	// down as of the latest key or mouse event.
+	Modifiers() Modifiers
+
+	// SetKeyRepeat sets whether a key that is held down
+	// sends further key presses as it repeats. Repeats
+	// are sent by default.
+	SetKeyRepeat(on bool)
+}
+
+// Predefined X atoms.
+const (
+	atomCARDINAL     = 6
+	atomSTRING       = 31
+	atomWM_ICON_NAME = 37
+	atomWM_NAME      = 39
+	// WM_NORMAL_HINTS and its type WM_SIZE_HINTS.
+	atomWM_NORMAL_HINTS = 40
+	atomWM_SIZE_HINTS   = 41
+)
+
+func (c *conn) Display() *Display {
+	return c.d
+}
+
+func (c *conn) SetTitle(title string) error {
+	// WM_NAME holds Latin-1; modern window managers
+	// prefer _NET_WM_NAME, which holds UTF-8.
+	latin1 := make([]byte, 0, len(title))
+	for _, r := range title {
+		if r >= 0x100 {
+			r = '?'
+		}
+		latin1 = append(latin1, byte(r))
+	}
+	if err := c.changeProperty(atomWM_NAME, atomSTRING, 8, latin1); err != nil {
+		return err
+	}
+	if err := c.changeProperty(atomWM_ICON_NAME, atomSTRING, 8, latin1); err != nil {
+		return err
+	}
+	utf8, err := c.d.atom("UTF8_STRING")
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"_NET_WM_NAME", "_NET_WM_ICON_NAME"} {
+		prop, err := c.d.atom(name)
+		if err != nil {
+			return err
+		}
+		if err := c.changeProperty(prop, utf8, 8, []byte(title)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) SetIcon(icon image.Image) error {
+	r := icon.Bounds()
+	// _NET_WM_ICON holds the width and height, then
+	// the pixels as non-premultiplied ARGB, 32 bits each.
+	data := make([]byte, 4*(2+r.Dx()*r.Dy()))
+	setU32LE(data[0:4], uint32(r.Dx()))
+	setU32LE(data[4:8], uint32(r.Dy()))
+	i := 8
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := image.NRGBAColorModel.Convert(icon.At(x, y)).(image.NRGBAColor)
+			setU32LE(data[i:i+4], uint32(p.A)<<24|uint32(p.R)<<16|uint32(p.G)<<8|uint32(p.B))
+			i += 4
+		}
+	}
+	prop, err := c.d.atom("_NET_WM_ICON")
+	if err != nil {
+		return err
+	}
+	return c.changeProperty(prop, atomCARDINAL, 32, data)
+}
+
+func (c *conn) SetSize(size image.Point) error {
+	if size.X <= 0 || size.Y <= 0 || size.X > 0xffff || size.Y > 0xffff {
+		return errors.New("bad window size")
+	}
+	return c.configure(0x000c, uint32(size.X), uint32(size.Y)) // Bits 2 and 3 are the width and height.
+}
+
+func (c *conn) SetPosition(p image.Point) error {
+	return c.configure(0x0003, uint32(int32(p.X)), uint32(int32(p.Y))) // Bits 0 and 1 are x and y.
+}
+
+// configure sends a ConfigureWindow request for the window,
+// setting the attributes given by mask to values, in order.
+func (c *conn) configure(mask uint16, values ...uint32) error {
+	b := make([]byte, 12+4*len(values))
+	b[0] = 0x0c // ConfigureWindow opcode.
+	setU16LE(b[2:4], uint16(len(b)/4))
+	setU32LE(b[4:8], uint32(c.window))
+	setU16LE(b[8:10], mask)
+	for i, v := range values {
+		setU32LE(b[12+4*i:], v)
+	}
+	return c.d.write(b)
+}
+
+func (c *conn) SetMinSize(size image.Point) error {
+	// WM_SIZE_HINTS is 18 32-bit values: flags, then the
+	// obsolete x, y, width and height, then the minimum
+	// width and height, and some others that we leave zero.
+	data := make([]byte, 18*4)
+	setU32LE(data[0:4], 1<<4) // PMinSize: the minimum size is set.
+	setU32LE(data[20:24], uint32(size.X))
+	setU32LE(data[24:28], uint32(size.Y))
+	return c.changeProperty(atomWM_NORMAL_HINTS, atomWM_SIZE_HINTS, 32, data)
+}
+
+func (c *conn) SetFullscreen(on bool) error {
+	state, err := c.d.atom("_NET_WM_STATE")
+	if err != nil {
+		return err
+	}
+	fullscreen, err := c.d.atom("_NET_WM_STATE_FULLSCREEN")
+	if err != nil {
+		return err
+	}
+	// A mapped window's state is changed by sending a ClientMessage
+	// event to the root window, for the window manager to act on.
+	b := make([]byte, 44)
+	b[0] = 0x19 // SendEvent opcode; propagate is false.
+	setU16LE(b[2:4], uint16(len(b)/4))
+	setU32LE(b[4:8], uint32(c.d.root))
+	setU32LE(b[8:12], 0x00180000) // SubstructureNotify and SubstructureRedirect event masks.
+	ev := b[12:]
+	ev[0] = 0x21 // ClientMessage event.
+	ev[1] = 32   // Format.
+	setU32LE(ev[4:8], uint32(c.window))
+	setU32LE(ev[8:12], uint32(state))
+	action := uint32(0) // _NET_WM_STATE_REMOVE.
+	if on {
+		action = 1 // _NET_WM_STATE_ADD.
+	}
+	setU32LE(ev[12:16], action)
+	setU32LE(ev[16:20], uint32(fullscreen))
+	setU32LE(ev[24:28], 1) // The request comes from an application.
+	return c.d.write(b)
+}
+
+// changeProperty replaces the value of the property prop
+// of the window with data, of the given type and format
+// (the number of bits in each unit of data).
+func (c *conn) changeProperty(prop, typ resID, format int, data []byte) error {
+	return c.d.changeProperty(c.window, prop, typ, format, data)
+}
+
+// changeProperty is like conn.changeProperty,
+// for the window w on d.
+func (d *Display) changeProperty(w, prop, typ resID, format int, data []byte) error {
+	n := 24 + pad4(len(data))
+	if n/4 > 0xffff {
+		return errors.New("X property too large")
+	}
+	b := make([]byte, n)
+	b[0] = 0x12 // ChangeProperty opcode.
+	b[1] = 0x00 // Replace the old value.
+	setU16LE(b[2:4], uint16(n/4))
+	setU32LE(b[4:8], uint32(w))
+	setU32LE(b[8:12], uint32(prop))
+	setU32LE(b[12:16], uint32(typ))
+	b[16] = uint8(format)
+	setU32LE(b[20:24], uint32(len(data)/(format/8)))
+	copy(b[24:], data)
+	return d.write(b)
+}